@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+const (
+	flagZipAName                  = "zip-a"
+	flagZipADescription           = "Path to first Twitter data zip"
+	flagZipBName                  = "zip-b"
+	flagZipBDescription           = "Path to second Twitter data zip"
+	flagFormatName                = "format"
+	flagFormatDescription         = "Output format: text or json"
+	flagResolveHandlesName        = "resolve-handles"
+	flagResolveHandlesDescription = "Resolve missing handles, and handles absent from both archives, over the network"
+	formatText                    = "text"
+	formatJSON                    = "json"
+	usageMessage                  = "usage: account --zip-a A.zip --zip-b B.zip [--format text|json] <id-or-handle>..."
+	unknownFormatErrorFormat      = "unknown format %q"
+	loadErrorFormat               = "read %s: %v"
+	lookupErrorFormat             = "lookup %s: %v"
+	encodeErrorFormat             = "encode: %v"
+	handlesResolverErrorFormat    = "handles resolver: %v"
+	accountHeaderFormat           = "%s [id %s]\n"
+	labelsLineFormat              = "  labels: %s\n"
+	labelsErrorLineFormat         = "  labels: %s (%s)\n"
+	relationshipLineFormat        = "  %s %s: follows=%s followed-by=%s muted=%s blocked=%s buckets=%s\n"
+	memberOfListsLineFormat       = "    member of lists: %s\n"
+	listSeparator                 = " "
+	accountLabelFormat            = "%s (%s%s)"
+	accountHandlePrefix           = "@"
+	bucketSeparator               = ","
+	noBucketsText                 = "none"
+	yesText                       = "yes"
+	noText                        = "no"
+)
+
+func main() {
+	var zipPathA string
+	var zipPathB string
+	var format string
+	var resolveHandles bool
+
+	flag.StringVar(&zipPathA, flagZipAName, "", flagZipADescription)
+	flag.StringVar(&zipPathB, flagZipBName, "", flagZipBDescription)
+	flag.StringVar(&format, flagFormatName, formatText, flagFormatDescription)
+	flag.BoolVar(&resolveHandles, flagResolveHandlesName, false, flagResolveHandlesDescription)
+	flag.Parse()
+
+	references := flag.Args()
+	if zipPathA == "" || zipPathB == "" || len(references) == 0 {
+		fmt.Fprintln(os.Stderr, usageMessage)
+		os.Exit(2)
+	}
+	if format != formatText && format != formatJSON {
+		dief(unknownFormatErrorFormat, format)
+	}
+
+	accountSetsA, ownerA, err := matrix.ReadTwitterZip(zipPathA)
+	if err != nil {
+		dief(loadErrorFormat, zipPathA, err)
+	}
+	accountSetsB, ownerB, err := matrix.ReadTwitterZip(zipPathB)
+	if err != nil {
+		dief(loadErrorFormat, zipPathB, err)
+	}
+	matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+
+	var resolutionErrors map[string]error
+	var idResolver matrix.AccountIDResolver
+	if resolveHandles {
+		resolver, err := handles.NewResolver(handles.Config{})
+		if err != nil {
+			dief(handlesResolverErrorFormat, err)
+		}
+		resolutionErrors = matrix.MaybeResolveHandles(context.Background(), resolver, true, &accountSetsA, &accountSetsB)
+		idResolver = resolver
+	}
+
+	comparison := matrix.BuildComparison(accountSetsA, accountSetsB, ownerA, ownerB)
+	details := make([]matrix.AccountDetail, 0, len(references))
+	for _, reference := range references {
+		detail, err := comparison.DescribeResolvedAccount(context.Background(), reference, resolutionErrors, idResolver)
+		if err != nil {
+			dief(lookupErrorFormat, reference, err)
+		}
+		details = append(details, detail)
+	}
+
+	if format == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(details); err != nil {
+			dief(encodeErrorFormat, err)
+		}
+		return
+	}
+	for _, detail := range details {
+		printDetail(detail)
+	}
+}
+
+func printDetail(detail matrix.AccountDetail) {
+	fmt.Printf(accountHeaderFormat, accountLabel(detail.Record), detail.Record.AccountID)
+	if detail.ResolverError != "" {
+		fmt.Printf(labelsErrorLineFormat, detail.ResolverStatus, detail.ResolverError)
+	} else {
+		fmt.Printf(labelsLineFormat, detail.ResolverStatus)
+	}
+	for _, relationship := range detail.Relationships {
+		buckets := make([]string, 0, len(relationship.Buckets))
+		for _, bucket := range relationship.Buckets {
+			buckets = append(buckets, string(bucket))
+		}
+		bucketText := strings.Join(buckets, bucketSeparator)
+		if bucketText == "" {
+			bucketText = noBucketsText
+		}
+		fmt.Printf(relationshipLineFormat,
+			relationship.Slot,
+			relationship.Owner,
+			yesNo(relationship.Follows),
+			yesNo(relationship.FollowedBy),
+			yesNo(relationship.Muted),
+			yesNo(relationship.Blocked),
+			bucketText,
+		)
+		if len(relationship.MemberOfLists) > 0 {
+			fmt.Printf(memberOfListsLineFormat, strings.Join(relationship.MemberOfLists, listSeparator))
+		}
+	}
+}
+
+func accountLabel(record matrix.AccountRecord) string {
+	display := strings.TrimSpace(record.DisplayName)
+	handle := strings.TrimSpace(record.UserName)
+	switch {
+	case display != "" && handle != "":
+		return fmt.Sprintf(accountLabelFormat, display, accountHandlePrefix, handle)
+	case handle != "":
+		return accountHandlePrefix + handle
+	case display != "":
+		return display
+	default:
+		return record.AccountID
+	}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return yesText
+	}
+	return noText
+}
+
+func dief(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -1,23 +1,25 @@
 // main.go
 //
 // Clone @accountB's "following" onto @accountA using X/Twitter API v2.
-// - Reads following.js (X export) and/or a plain file of numeric IDs.
+// - Reads following.js (X export) and/or a plain file of numeric IDs and @handles.
 // - OAuth 2.0 PKCE with localhost callback; ONE hardcoded token file: ./token.json
 // - Derives authenticated user automatically (no source username/id flags).
-// - Robust 429/5xx backoff using Retry-After / x-rate-limit-reset.
+// - Robust 429/5xx backoff using Retry-After / x-rate-limit-reset via internal/ratelimit.
 // - Idempotent follow loop with clear logs.
 // - NEW: Fail-fast on plan gating (client-not-enrolled) and optional HTML fallback.
 //
 // Flags (no --scopes, no source-id/username):
 //
 //	--following-js-path   file|dir|glob of following*.js
-//	--ids-path            file of numeric IDs (one per line)
+//	--ids-path            file of numeric IDs or @handles (one per line); handles are resolved to IDs
 //	--client-id           OAuth2 client id
 //	--redirect-uri        http://localhost:8080 or https://localhost:8080 (must match app)
 //	--auth-base-url       default https://twitter.com
 //	--api-base-url        default https://api.twitter.com
 //	--max                 max follows to attempt (default 350)
-//	--sleep-ms            sleep between follow requests (default 900ms)
+//	--sleep-ms            minimum spacing between API requests (default 900ms); slows down after 429s
+//	--max-retries         retries of a request after 429/5xx/network errors (default 5)
+//	--max-rate-limit-wait longest rate limit pause that is waited out (default 15m)
 //	--emit-intent-html    path to write a manual-click follow page if API is gated
 //	--debug               verbose logging
 package main
@@ -25,6 +27,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	crand "crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -40,9 +43,7 @@ import (
 	"html"
 	"io"
 	"io/fs"
-	"math"
 	"math/big"
-	mrand "math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -51,9 +52,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+	"github.com/f-sync/fsync/internal/ratelimit"
 )
 
 /* ============================ Constants & required scopes ============================ */
@@ -69,10 +73,8 @@ var requiredScopes = []string{
 	"offline.access", // for refresh tokens
 }
 
-func init() {
-	// Seed jitter RNG once.
-	mrand.Seed(time.Now().UnixNano())
-}
+// apiLimiter paces every API request and retries 429/5xx responses; main replaces it with one built from flags.
+var apiLimiter = ratelimit.New(ratelimit.Config{})
 
 /* ============================ Models ============================ */
 
@@ -130,17 +132,21 @@ type TokenStore struct {
 
 func main() {
 	followingJSPathFlag := flag.String("following-js-path", "", "Path to following.js (file), a directory with following*.js parts, or a glob pattern")
-	idsPathFlag := flag.String("ids-path", "", "Path to a file with one numeric X user ID per line")
+	idsPathFlag := flag.String("ids-path", "", "Path to a file with one numeric X user ID or @handle per line")
 	clientIDFlag := flag.String("client-id", "", "OAuth 2.0 Client ID for PKCE")
 	redirectURIFlag := flag.String("redirect-uri", "https://localhost:8080", "Redirect URI registered in the X app settings")
 	authBaseURLFlag := flag.String("auth-base-url", "https://twitter.com", "Base URL for browser authorize flow (twitter.com or x.com)")
 	apiBaseURLFlag := flag.String("api-base-url", "https://api.twitter.com", "Base URL for API and token requests")
 	maxFollowsPerRunFlag := flag.Int("max", 350, "Maximum follows to attempt in this run")
-	sleepBetweenRequestsMillisFlag := flag.Int("sleep-ms", 900, "Milliseconds to sleep between follow requests")
+	sleepBetweenRequestsMillisFlag := flag.Int("sleep-ms", 900, "Minimum milliseconds between API requests")
 	emitIntentHTMLFlag := flag.String("emit-intent-html", "", "If set, write an HTML file with follow-intent links when API is gated")
+	maxRetriesFlag := flag.Int("max-retries", 5, "Retries of a request after 429, 5xx, or network errors")
+	maxRateLimitWaitFlag := flag.Duration("max-rate-limit-wait", 15*time.Minute, "Longest rate limit pause that is waited out")
 	debugFlag := flag.Bool("debug", false, "Print path resolution and HTTP diagnostics")
 	flag.Parse()
 
+	apiLimiter = ratelimit.New(newAPILimiterConfig(*sleepBetweenRequestsMillisFlag, *maxRetriesFlag, *maxRateLimitWaitFlag, *debugFlag))
+
 	if *followingJSPathFlag == "" && *idsPathFlag == "" {
 		exitWithError("either --following-js-path or --ids-path must be provided")
 	}
@@ -159,25 +165,29 @@ func main() {
 		fmt.Printf("[debug] token scopes: %q\n", tokenStore.Scope)
 	}
 
-	// Load target IDs (set)
-	allTargetIDs := make(map[string]struct{})
+	// Load target references, then resolve them into a set of IDs
+	var targetReferences []string
 	if *followingJSPathFlag != "" {
 		jsIDs, err := loadIDsFromFollowingJS(*followingJSPathFlag, *debugFlag)
 		if err != nil {
 			exitWithError(fmt.Sprintf("failed to load IDs from following.js path: %v", err))
 		}
-		for _, id := range jsIDs {
-			allTargetIDs[id] = struct{}{}
-		}
+		targetReferences = append(targetReferences, jsIDs...)
 	}
 	if *idsPathFlag != "" {
-		fileIDs, err := loadIDsFromPlainFile(*idsPathFlag)
+		fileReferences, err := loadReferencesFromPlainFile(*idsPathFlag)
 		if err != nil {
 			exitWithError(fmt.Sprintf("failed to load IDs from ids file: %v", err))
 		}
-		for _, id := range fileIDs {
-			allTargetIDs[id] = struct{}{}
-		}
+		targetReferences = append(targetReferences, fileReferences...)
+	}
+	targetIDs, err := resolveTargetReferences(accessTokenValue, *apiBaseURLFlag, apiLimiter, targetReferences, *debugFlag)
+	if err != nil {
+		exitWithError(fmt.Sprintf("failed to resolve target handles: %v", err))
+	}
+	allTargetIDs := make(map[string]struct{}, len(targetIDs))
+	for _, id := range targetIDs {
+		allTargetIDs[id] = struct{}{}
 	}
 	if len(allTargetIDs) == 0 {
 		exitWithError("no target user IDs found after loading inputs")
@@ -245,8 +255,6 @@ func main() {
 			skipped++
 			fmt.Printf("%s: skipped\n", targetID)
 		}
-
-		time.Sleep(time.Duration(*sleepBetweenRequestsMillisFlag) * time.Millisecond)
 	}
 
 	fmt.Printf("\nDone. attempted=%d, followed_or_requested=%d, skipped=%d, errors=%d\n", attempt, success, skipped, failed)
@@ -527,7 +535,8 @@ func followTargetUser(httpClient *http.Client, bearerToken string, sourceUserID
 
 /* ============================ Input Loading ============================ */
 
-func loadIDsFromPlainFile(path string) ([]string, error) {
+// loadReferencesFromPlainFile reads numeric IDs and handles, with or without the leading @, skipping other lines.
+func loadReferencesFromPlainFile(path string) ([]string, error) {
 	fileHandle, openErr := os.Open(path)
 	if openErr != nil {
 		return nil, fmt.Errorf("open ids file: %w", openErr)
@@ -543,6 +552,10 @@ func loadIDsFromPlainFile(path string) ([]string, error) {
 		}
 		if isAllDigits(line) {
 			collected = append(collected, line)
+			continue
+		}
+		if _, valid := handles.NormalizeHandle(line); valid {
+			collected = append(collected, line)
 		}
 	}
 	if scanErr := lineScanner.Err(); scanErr != nil {
@@ -551,6 +564,34 @@ func loadIDsFromPlainFile(path string) ([]string, error) {
 	return collected, nil
 }
 
+// resolveTargetReferences turns handles into numeric IDs through the users lookup, paced by limiter together with every
+// other API request. Handles that cannot be resolved are reported and skipped.
+func resolveTargetReferences(bearerToken string, apiBaseURL string, limiter *ratelimit.Limiter, references []string, debug bool) ([]string, error) {
+	resolver, resolverErr := handles.NewResolver(handles.Config{
+		Backends:       []handles.Backend{handles.BackendAPI},
+		APIBaseURL:     apiBaseURL,
+		APIBearerToken: bearerToken,
+		APILimiter:     limiter,
+		Cache:          handles.NewMemoryCache(),
+	})
+	if resolverErr != nil {
+		return nil, resolverErr
+	}
+	accountIDs, resolveErrs := matrix.ResolveAccountReferences(context.Background(), resolver, references)
+	unresolved := make([]string, 0, len(resolveErrs))
+	for reference := range resolveErrs {
+		unresolved = append(unresolved, reference)
+	}
+	sort.Strings(unresolved)
+	for _, reference := range unresolved {
+		fmt.Printf("skipping %s: %v\n", reference, resolveErrs[reference])
+	}
+	if debug {
+		fmt.Printf("[debug] resolved %d of %d target entries\n", len(accountIDs), len(references))
+	}
+	return accountIDs, nil
+}
+
 func loadIDsFromFollowingJS(pathOrGlob string, debug bool) ([]string, error) {
 	if debug {
 		if workingDir, _ := os.Getwd(); workingDir != "" {
@@ -798,98 +839,39 @@ func buildAuthorizeURL(clientID string, redirectURI string, scopes string, state
 /* ============================ HTTP backoff (429/5xx) ============================ */
 
 func doWithRateLimitRetry(client *http.Client, req *http.Request, debug bool) (*http.Response, []byte, error) {
-	const maxAttempts = 6
-	var lastErr error
-
-	for attemptIndex := 1; attemptIndex <= maxAttempts; attemptIndex++ {
-		response, err := client.Do(req)
-		if err != nil {
-			lastErr = err
-			if debug {
-				fmt.Printf("[debug] http error: %v (attempt %d/%d)\n", err, attemptIndex, maxAttempts)
-			}
-			sleepExponential(attemptIndex, debug, "net-error")
-			continue
-		}
-
-		body, _ := io.ReadAll(response.Body)
-		_ = response.Body.Close()
-
-		// Happy path
-		if response.StatusCode/100 == 2 {
-			return response, body, nil
-		}
-
-		// 429 handling with headers
-		if response.StatusCode == 429 {
-			wait := parseRetryHeaders(response.Header)
-			if debug {
-				fmt.Printf("[debug] 429 received. sleeping %.1fs (attempt %d/%d)\n", wait.Seconds(), attemptIndex, maxAttempts)
-			}
-			time.Sleep(wait)
-			continue
-		}
-
-		// Transient 5xx → exponential backoff
-		if response.StatusCode/100 == 5 {
-			if debug {
-				fmt.Printf("[debug] %d received. retrying (attempt %d/%d)\n", response.StatusCode, attemptIndex, maxAttempts)
-			}
-			sleepExponential(attemptIndex, debug, "5xx")
-			continue
+	response, err := apiLimiter.Do(client, req)
+	if err != nil {
+		if debug {
+			fmt.Printf("[debug] giving up on %s %s: %v\n", req.Method, req.URL.Path, err)
 		}
-
-		// Non-retryable
-		return response, body, nil
+		return nil, nil, err
 	}
-
-	if lastErr == nil {
-		lastErr = errors.New("exhausted retries")
-	}
-	return nil, nil, lastErr
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	return response, body, nil
 }
 
-func parseRetryHeaders(headers http.Header) time.Duration {
-	// Prefer Retry-After seconds
-	if retryAfter := headers.Get("Retry-After"); retryAfter != "" {
-		if seconds, parseErr := strconv.Atoi(strings.TrimSpace(retryAfter)); parseErr == nil {
-			return time.Duration(seconds)*time.Second + jitter(300*time.Millisecond)
-		}
+func newAPILimiterConfig(sleepMillis int, maxRetries int, maxWait time.Duration, debug bool) ratelimit.Config {
+	requestsPerSecond := -1.0
+	if sleepMillis > 0 {
+		requestsPerSecond = 1000 / float64(sleepMillis)
 	}
-	// Fallback to x-rate-limit-reset (epoch seconds)
-	if reset := headers.Get("x-rate-limit-reset"); reset != "" {
-		if unix, parseErr := strconv.ParseInt(strings.TrimSpace(reset), 10, 64); parseErr == nil {
-			wait := time.Until(time.Unix(unix, 0))
-			if wait < 0 {
-				wait = 2 * time.Second
-			}
-			return wait + jitter(300*time.Millisecond)
-		}
+	config := ratelimit.Config{
+		RequestsPerSecond: requestsPerSecond,
+		MaxRetries:        maxRetries,
+		MaxWait:           maxWait,
 	}
-	// Default backoff if headers absent
-	return 20*time.Second + jitter(500*time.Millisecond)
-}
-
-func sleepExponential(attemptIndex int, debug bool, tag string) {
-	// 0.5s * 2^(attempt-1) capped ~8s + jitter
-	base := 500 * time.Millisecond
-	multiplier := math.Pow(2, float64(attemptIndex-1))
-	duration := time.Duration(multiplier) * base
-	if duration > 8*time.Second {
-		duration = 8 * time.Second
-	}
-	duration += jitter(200 * time.Millisecond)
 	if debug {
-		fmt.Printf("[debug] backoff(%s) sleeping %s\n", tag, duration)
-	}
-	time.Sleep(duration)
-}
-
-func jitter(max time.Duration) time.Duration {
-	if max <= 0 {
-		return 0
+		config.OnRetry = func(retry ratelimit.Retry) {
+			switch {
+			case retry.Err != nil:
+				fmt.Printf("[debug] http error: %v. sleeping %s (retry %d/%d)\n", retry.Err, retry.Wait, retry.Attempt, maxRetries)
+			default:
+				fmt.Printf("[debug] %d received. sleeping %s (retry %d/%d)\n", retry.StatusCode, retry.Wait, retry.Attempt, maxRetries)
+			}
+		}
 	}
-	return time.Duration(mrand.Int63n(int64(max)))
+	return config
 }
 
 /* ============================ Utilities ============================ */
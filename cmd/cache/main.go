@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	commandUse                 = "cache"
+	commandShortDescription    = "Inspect the persistent handle cache"
+	historyUse                 = "history <account-id>..."
+	historyShortDescription    = "List the handles and display names seen for accounts, oldest first"
+	importUse                  = "import <file>..."
+	importShortDescription     = "Merge resolve, xresolve or cresolve output into the cache, keeping the most recent outcome per account"
+	exportUse                  = "export <file>"
+	exportShortDescription     = "Write the cached outcomes in the resolve command's csv or jsonl format, or a legacy xresolve or cresolve format; - writes to standard output"
+	flagHandleCacheName        = "handle-cache"
+	flagHandleCacheDescription = "Handle cache file written by the dump and server commands"
+	flagFormatName             = "format"
+	flagFormatDescription      = "Output format: text or json"
+	importFormatDescription    = "Input format: csv, jsonl, xresolve-csv, cresolve-csv or cresolve-jsonl; detected from each file when empty"
+	exportFormatDescription    = "Output format: csv, jsonl, xresolve-csv, cresolve-csv or cresolve-jsonl"
+	standardOutputPath         = "-"
+	importSummaryFormat        = "%s: %d read, %d merged, %d kept\n"
+	formatText                 = "text"
+	formatJSON                 = "json"
+	historyDateLayout          = "2006-01-02"
+	historyUnknownDate         = "unknown date"
+	historyHeaderFormat        = "%s\n"
+	historyLineFormat          = "  %s  @%s%s  (%s%s)\n"
+	historyDisplayNameFormat   = "  %q"
+	historyOriginFormat        = " %s"
+	historyEmptyLine           = "  no history recorded\n"
+	errMessageMissingCache     = "--handle-cache is required"
+	errMessageUnknownFormat    = "unknown format"
+	errMessageOpenCache        = "open handle cache"
+	errMessageImportFile       = "import %s: %w"
+	errMessageExportFile       = "export %s: %w"
+)
+
+var (
+	errMissingCache  = errors.New(errMessageMissingCache)
+	errUnknownFormat = errors.New(errMessageUnknownFormat)
+)
+
+// accountHistory is the JSON form of one account's history.
+type accountHistory struct {
+	AccountID string                      `json:"accountId"`
+	History   []handles.HandleObservation `json:"history"`
+}
+
+func main() {
+	cobra.CheckErr(newCacheCommand().Execute())
+}
+
+func newCacheCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   commandUse,
+		Short: commandShortDescription,
+	}
+	command.PersistentFlags().String(flagHandleCacheName, "", flagHandleCacheDescription)
+	command.AddCommand(newHistoryCommand(), newImportCommand(), newExportCommand())
+	return command
+}
+
+func newHistoryCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   historyUse,
+		Short: historyShortDescription,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runHistoryCommand,
+	}
+	command.Flags().String(flagFormatName, formatText, flagFormatDescription)
+	return command
+}
+
+func runHistoryCommand(command *cobra.Command, accountIDs []string) error {
+	format, _ := command.Flags().GetString(flagFormatName)
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("%w: %q", errUnknownFormat, format)
+	}
+	cache, err := openCache(command)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	histories := make([]accountHistory, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		trimmed := strings.TrimSpace(accountID)
+		histories = append(histories, accountHistory{AccountID: trimmed, History: cache.History(trimmed)})
+	}
+	if format == formatJSON {
+		encoder := json.NewEncoder(command.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(histories)
+	}
+	for _, history := range histories {
+		printHistory(command.OutOrStdout(), history)
+	}
+	return nil
+}
+
+func newImportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   importUse,
+		Short: importShortDescription,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runImportCommand,
+	}
+	command.Flags().String(flagFormatName, "", importFormatDescription)
+	return command
+}
+
+func runImportCommand(command *cobra.Command, paths []string) error {
+	formatValue, _ := command.Flags().GetString(flagFormatName)
+	format, err := handles.ParseExchangeFormat(formatValue)
+	if err != nil {
+		return err
+	}
+	cache, err := openCache(command)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		entries, readErr := handles.ReadExchangeFile(path, format)
+		if readErr != nil {
+			_ = cache.Close()
+			return fmt.Errorf(errMessageImportFile, path, readErr)
+		}
+		summary := handles.MergeExchange(cache, entries)
+		fmt.Fprintf(command.OutOrStdout(), importSummaryFormat, path, summary.Read, summary.Merged, summary.Kept)
+	}
+	return cache.Close()
+}
+
+func newExportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   exportUse,
+		Short: exportShortDescription,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runExportCommand,
+	}
+	command.Flags().String(flagFormatName, string(handles.ExchangeFormatCSV), exportFormatDescription)
+	return command
+}
+
+func runExportCommand(command *cobra.Command, arguments []string) error {
+	formatValue, _ := command.Flags().GetString(flagFormatName)
+	format, err := handles.ParseExchangeFormat(formatValue)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		return fmt.Errorf("%w: %q", errUnknownFormat, formatValue)
+	}
+	cache, err := openCache(command)
+	if err != nil {
+		return err
+	}
+	entries := cache.Entries()
+	if err := cache.Close(); err != nil {
+		return err
+	}
+
+	outputPath := arguments[0]
+	if outputPath == standardOutputPath {
+		return handles.WriteExchange(command.OutOrStdout(), format, entries)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf(errMessageExportFile, outputPath, err)
+	}
+	if err := handles.WriteExchange(file, format, entries); err != nil {
+		_ = file.Close()
+		return fmt.Errorf(errMessageExportFile, outputPath, err)
+	}
+	return file.Close()
+}
+
+func openCache(command *cobra.Command) (*handles.FileCache, error) {
+	cachePath, _ := command.Flags().GetString(flagHandleCacheName)
+	if strings.TrimSpace(cachePath) == "" {
+		return nil, errMissingCache
+	}
+	cache, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessageOpenCache, err)
+	}
+	return cache, nil
+}
+
+func printHistory(output io.Writer, history accountHistory) {
+	fmt.Fprintf(output, historyHeaderFormat, history.AccountID)
+	if len(history.History) == 0 {
+		fmt.Fprint(output, historyEmptyLine)
+		return
+	}
+	for _, observation := range history.History {
+		displayName := ""
+		if observation.DisplayName != "" {
+			displayName = fmt.Sprintf(historyDisplayNameFormat, observation.DisplayName)
+		}
+		origin := ""
+		if observation.Origin != "" {
+			origin = fmt.Sprintf(historyOriginFormat, observation.Origin)
+		}
+		fmt.Fprintf(output, historyLineFormat, formatObservedAt(observation.ObservedAt), observation.UserName, displayName, observation.Source, origin)
+	}
+}
+
+func formatObservedAt(observedAt time.Time) string {
+	if observedAt.IsZero() {
+		return historyUnknownDate
+	}
+	return observedAt.UTC().Format(historyDateLayout)
+}
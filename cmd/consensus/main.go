@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+const (
+	flagZipName                   = "zip"
+	flagZipDescription            = "Path to a team member's Twitter data zip (repeatable)"
+	flagMinFollowedByName         = "min-followed-by"
+	flagMinFollowedByDescription  = "Keep accounts followed by at least this many team members"
+	flagMinFollowsBackName        = "min-follows-back"
+	flagMinFollowsBackDescription = "Keep accounts that follow at least this many team members"
+	flagNotFollowedByName         = "not-followed-by"
+	flagNotFollowedByDescription  = "Drop accounts already followed by this member (account ID or @handle)"
+	flagExcludeBlockedName        = "exclude-blocked"
+	flagExcludeBlockedDescription = "Drop accounts blocked or muted by any team member"
+	flagLimitName                 = "limit"
+	flagLimitDescription          = "Maximum number of ranked accounts to print; 0 prints every match"
+	flagOutName                   = "out"
+	flagOutDescription            = "Optional HTML file path for the ranked list"
+	flagResolveHandlesName        = "resolve-handles"
+	flagResolveHandlesDescription = "Resolve missing handles, and a --not-followed-by handle absent from every archive, over the network"
+	defaultMinFollowedBy          = 1
+	defaultLimit                  = 100
+	minimumArchiveCount           = 2
+	zipListSeparator              = ","
+	missingZipErrorMessage        = "error: at least two --zip archives are required"
+	loadErrorFormat               = "read %s: %v"
+	rankErrorFormat               = "rank: %v"
+	renderErrorFormat             = "render: %v"
+	createFileErrorFormat         = "create %s: %v"
+	writeFileErrorFormat          = "write %s: %v"
+	handlesResolverErrorFormat    = "handles resolver: %v"
+	handleResolutionErrorFormat   = "warning: handle lookup for %s failed: %v\n"
+	labelConflictFormat           = "note: account %s has conflicting %s values %s; using %q\n"
+	labelConflictSeparator        = ", "
+	summaryFormat                 = "%d of %d matches across %d archives: %s\n"
+	tableHeader                   = "RANK\tFOLLOWED BY\tFOLLOWS\tBLOCKED\tMUTED\tACCOUNT\tFOLLOW"
+	tableRowFormat                = "%d\t%d\t%d\t%d\t%d\t%s\t%s\n"
+	accountHandlePrefix           = "@"
+	accountLabelFormat            = "%s (%s%s)"
+)
+
+// zipPaths collects repeated --zip flags; each value may also hold a comma-separated list.
+type zipPaths []string
+
+func (paths *zipPaths) String() string {
+	return strings.Join(*paths, zipListSeparator)
+}
+
+func (paths *zipPaths) Set(value string) error {
+	for _, path := range strings.Split(value, zipListSeparator) {
+		if trimmed := strings.TrimSpace(path); trimmed != "" {
+			*paths = append(*paths, trimmed)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var archivePaths zipPaths
+	var filter matrix.ConsensusFilter
+	var outputPath string
+	var resolveHandles bool
+
+	flag.Var(&archivePaths, flagZipName, flagZipDescription)
+	flag.IntVar(&filter.MinFollowedBy, flagMinFollowedByName, defaultMinFollowedBy, flagMinFollowedByDescription)
+	flag.IntVar(&filter.MinFollowsBack, flagMinFollowsBackName, 0, flagMinFollowsBackDescription)
+	flag.StringVar(&filter.ExcludeFollowedBy, flagNotFollowedByName, "", flagNotFollowedByDescription)
+	flag.BoolVar(&filter.ExcludeBlockedOrMuted, flagExcludeBlockedName, false, flagExcludeBlockedDescription)
+	flag.IntVar(&filter.Limit, flagLimitName, defaultLimit, flagLimitDescription)
+	flag.StringVar(&outputPath, flagOutName, "", flagOutDescription)
+	flag.BoolVar(&resolveHandles, flagResolveHandlesName, false, flagResolveHandlesDescription)
+	flag.Parse()
+
+	if len(archivePaths) < minimumArchiveCount {
+		fmt.Fprintln(os.Stderr, missingZipErrorMessage)
+		os.Exit(2)
+	}
+
+	archives := make([]matrix.TeamArchive, 0, len(archivePaths))
+	for _, archivePath := range archivePaths {
+		accountSets, owner, err := matrix.ReadTwitterZip(archivePath)
+		if err != nil {
+			dief(loadErrorFormat, archivePath, err)
+		}
+		archives = append(archives, matrix.TeamArchive{Owner: owner, AccountSets: accountSets})
+	}
+
+	accountSets := make([]*matrix.AccountSets, 0, len(archives))
+	for index := range archives {
+		accountSets = append(accountSets, &archives[index].AccountSets)
+	}
+	reconciliation := matrix.ReconcileLabels(accountSets...)
+	for _, conflict := range reconciliation.Conflicts {
+		fmt.Fprintf(os.Stderr, labelConflictFormat, conflict.AccountID, conflict.Field, strings.Join(conflict.Values, labelConflictSeparator), conflict.Chosen)
+	}
+
+	if resolveHandles {
+		resolver, err := handles.NewResolver(handles.Config{})
+		if err != nil {
+			dief(handlesResolverErrorFormat, err)
+		}
+		resolutionErrors := matrix.MaybeResolveHandles(context.Background(), resolver, true, accountSets...)
+		for accountID, resolutionErr := range resolutionErrors {
+			fmt.Fprintf(os.Stderr, handleResolutionErrorFormat, accountID, resolutionErr)
+		}
+		filter = matrix.ResolveExcludedMember(context.Background(), resolver, archives, filter)
+	}
+
+	result, err := matrix.RankConsensus(archives, filter)
+	if err != nil {
+		dief(rankErrorFormat, err)
+	}
+
+	fmt.Printf(summaryFormat, len(result.Entries), result.Matches, len(result.Members), strings.Join(result.Members, labelConflictSeparator))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, tableHeader)
+	for index, entry := range result.Entries {
+		fmt.Fprintf(writer, tableRowFormat,
+			index+1,
+			len(entry.FollowedBy),
+			len(entry.FollowsBack),
+			len(entry.BlockedBy),
+			len(entry.MutedBy),
+			accountLabel(entry.Record),
+			matrix.FollowIntentURL(entry.Record),
+		)
+	}
+	if err := writer.Flush(); err != nil {
+		dief(writeFileErrorFormat, os.Stdout.Name(), err)
+	}
+
+	if outputPath == "" {
+		return
+	}
+	pageHTML, err := matrix.RenderConsensusPage(matrix.ConsensusPageData{Result: &result})
+	if err != nil {
+		dief(renderErrorFormat, err)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		dief(createFileErrorFormat, outputPath, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(pageHTML); err != nil {
+		dief(writeFileErrorFormat, outputPath, err)
+	}
+	fmt.Println("Wrote", outputPath)
+}
+
+func accountLabel(record matrix.AccountRecord) string {
+	display := strings.TrimSpace(record.DisplayName)
+	handle := strings.TrimSpace(record.UserName)
+	switch {
+	case display != "" && handle != "":
+		return fmt.Sprintf(accountLabelFormat, display, accountHandlePrefix, handle)
+	case handle != "":
+		return accountHandlePrefix + handle
+	case display != "":
+		return display
+	default:
+		return record.AccountID
+	}
+}
+
+func dief(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
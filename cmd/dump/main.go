@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
+	"github.com/f-sync/fsync/internal/ratelimit"
 )
 
 const (
@@ -19,14 +24,74 @@ const (
 	flagOutDescription          = "Output HTML file path"
 	flagResolveHandlesName      = "resolve-handles"
 	flagResolveHandlesDesc      = "Resolve missing handles over the network"
+	flagSortName                = "sort"
+	flagSortDescription         = "Bucket ordering: name, handle, id, or recency"
+	flagSortLocaleName          = "sort-locale"
+	flagSortLocaleDescription   = "BCP 47 locale used to collate names and handles"
+	flagFilterName              = "filter"
+	flagFilterDescription       = "Accounts kept in every bucket: all, protected, public, or verified"
+	flagResolvePriorityName     = "resolve-priority"
+	flagResolvePriorityDesc     = "Comma-separated buckets whose accounts are resolved first, e.g. friends,leaders,blocked,groupies"
+	flagResolveBudgetName       = "resolve-budget"
+	flagResolveBudgetDesc       = "Most accounts looked up over the network in one run (0 means no limit)"
+	flagResolveTimeBudgetName   = "resolve-time-budget"
+	flagResolveTimeBudgetDesc   = "Wall-clock time after which no new lookup starts (0 means no limit)"
+	flagResolverBackendsName    = "resolver-backends"
+	flagResolverBackendsDesc    = "Comma-separated handle lookup backends tried in order: chrome, chrome-pool, redirect, api"
+	flagResolverWorkersName     = "resolver-workers"
+	flagResolverWorkersDesc     = "Accounts looked up concurrently; the chrome-pool backend keeps one browser tab per worker"
+	flagAPIBearerTokenName      = "api-bearer-token"
+	flagAPIBearerTokenDesc      = "X API bearer token for the api backend; the token file is read when empty"
+	flagAPITokenFileName        = "api-token-file"
+	flagAPITokenFileDesc        = "Token file written by the api command, used by the api backend"
+	flagBreakerThresholdName    = "breaker-threshold"
+	flagBreakerThresholdDesc    = "Consecutive failures after which a backend is skipped"
+	flagBreakerCooldownName     = "breaker-cooldown"
+	flagBreakerCooldownDesc     = "How long a failing backend is skipped before it is tried again"
+	flagRateLimitName           = "rate-limit"
+	flagRateLimitDesc           = "Requests per second sent by each lookup backend (negative disables pacing)"
+	flagRateBurstName           = "rate-burst"
+	flagRateBurstDesc           = "Requests each lookup backend may send back to back"
+	flagMaxRetriesName          = "max-retries"
+	flagMaxRetriesDesc          = "Retries of a request after 429, 5xx, or network errors"
+	flagMaxRateLimitWaitName    = "max-rate-limit-wait"
+	flagMaxRateLimitWaitDesc    = "Longest server-advised rate limit pause that is waited out (0 uses each backend's default)"
+	flagChromeUserDataDirName   = "chrome-user-data-dir"
+	flagChromeUserDataDirDesc   = "Persistent Chrome profile used by the chrome backends; log into X in it once to resolve protected accounts"
+	flagChromeCookieFileName    = "chrome-cookie-file"
+	flagChromeCookieFileDesc    = "Netscape cookie file imported into the chrome backends; the chrome backend also needs --chrome-user-data-dir"
+	flagHandleCacheName         = "handle-cache"
+	flagHandleCacheDescription  = "File that persists resolved handles between runs"
+	flagHandleSeedName          = "handle-seed"
+	flagHandleSeedDescription   = "Comma-separated resolve, xresolve or cresolve output files merged into the handle cache; without --resolve-handles, handles are filled from them and the cache alone, without network access"
+	flagSuccessTTLName          = "handle-cache-ttl"
+	flagSuccessTTLDescription   = "How long resolved handles are reused before refetching"
+	flagFailureTTLName          = "handle-cache-failure-ttl"
+	flagFailureTTLDescription   = "How long failed lookups are reused before retrying"
 	defaultOutputFileName       = "twitter_relationship_matrix.html"
 	missingZipErrorMessage      = "error: both --zip-a and --zip-b are required"
 	handleResolutionErrorFormat = "warning: handle lookup for %s failed: %v\n"
+	budgetExhaustedFormat       = "note: the resolution budget ran out with %d accounts unresolved\n"
+	notCachedFormat             = "note: %d accounts were not found in the handle seed or cache\n"
+	loginWallFormat             = "note: %d accounts showed a login prompt; --chrome-user-data-dir or --chrome-cookie-file can get past it\n"
+	handleSeedSeparator         = ","
 	renderErrorFormat           = "render: %v"
 	loadErrorFormat             = "read %s: %v"
 	createFileErrorFormat       = "create %s: %v"
 	writeFileErrorFormat        = "write %s: %v"
 	handlesResolverErrorFormat  = "handles resolver: %v"
+	handleCacheErrorFormat      = "handle cache: %v"
+	resolverCloseFormat         = "warning: close handles resolver: %v\n"
+	handleSeedErrorFormat       = "handle seed %s: %v"
+	apiTokenErrorFormat         = "api token: %v"
+	sortOptionsErrorFormat      = "sort options: %v"
+	labelConflictFormat         = "note: account %s has conflicting %s values %s; using %q\n"
+	labelConflictSeparator      = ", "
+	progressLineFormat          = "\rresolving handles: %d/%d (%d resolved, %d failed)"
+	progressSkippedFormat       = " (%d skipped)"
+	progressETAFormat           = " ETA %s"
+	progressRetryingFormat      = " retrying %s"
+	progressClearToEndOfLine    = "\033[K"
 )
 
 func main() {
@@ -34,11 +99,51 @@ func main() {
 	var zipPathB string
 	var outputPath string
 	var resolveHandles bool
+	var sortModeValue string
+	var sortLocale string
+	var filterValue string
+	var resolvePriority string
+	var resolveBudget handles.Budget
+	var resolverBackends string
+	var resolverWorkers int
+	var breakerThreshold int
+	var breakerCooldown time.Duration
+	var rateLimit ratelimit.Config
+	var apiBearerToken string
+	var apiTokenFile string
+	var chromeUserDataDir string
+	var chromeCookieFile string
+	var handleCachePath string
+	var handleSeedPaths string
+	var successTTL time.Duration
+	var failureTTL time.Duration
 
 	flag.StringVar(&zipPathA, flagZipAName, "", flagZipADescription)
 	flag.StringVar(&zipPathB, flagZipBName, "", flagZipBDescription)
 	flag.StringVar(&outputPath, flagOutName, defaultOutputFileName, flagOutDescription)
 	flag.BoolVar(&resolveHandles, flagResolveHandlesName, false, flagResolveHandlesDesc)
+	flag.StringVar(&sortModeValue, flagSortName, string(matrix.DefaultSortMode), flagSortDescription)
+	flag.StringVar(&sortLocale, flagSortLocaleName, "", flagSortLocaleDescription)
+	flag.StringVar(&filterValue, flagFilterName, string(matrix.DefaultAccountFilter), flagFilterDescription)
+	flag.StringVar(&resolvePriority, flagResolvePriorityName, "", flagResolvePriorityDesc)
+	flag.IntVar(&resolveBudget.MaxLookups, flagResolveBudgetName, 0, flagResolveBudgetDesc)
+	flag.DurationVar(&resolveBudget.MaxDuration, flagResolveTimeBudgetName, 0, flagResolveTimeBudgetDesc)
+	flag.StringVar(&resolverBackends, flagResolverBackendsName, string(handles.DefaultBackend), flagResolverBackendsDesc)
+	flag.IntVar(&resolverWorkers, flagResolverWorkersName, handles.DefaultMaxConcurrent, flagResolverWorkersDesc)
+	flag.IntVar(&breakerThreshold, flagBreakerThresholdName, handles.DefaultBreakerThreshold, flagBreakerThresholdDesc)
+	flag.DurationVar(&breakerCooldown, flagBreakerCooldownName, handles.DefaultBreakerCooldown, flagBreakerCooldownDesc)
+	flag.Float64Var(&rateLimit.RequestsPerSecond, flagRateLimitName, ratelimit.DefaultRequestsPerSecond, flagRateLimitDesc)
+	flag.IntVar(&rateLimit.Burst, flagRateBurstName, ratelimit.DefaultBurst, flagRateBurstDesc)
+	flag.IntVar(&rateLimit.MaxRetries, flagMaxRetriesName, ratelimit.DefaultMaxRetries, flagMaxRetriesDesc)
+	flag.DurationVar(&rateLimit.MaxWait, flagMaxRateLimitWaitName, 0, flagMaxRateLimitWaitDesc)
+	flag.StringVar(&apiBearerToken, flagAPIBearerTokenName, "", flagAPIBearerTokenDesc)
+	flag.StringVar(&apiTokenFile, flagAPITokenFileName, handles.DefaultAPITokenFile, flagAPITokenFileDesc)
+	flag.StringVar(&chromeUserDataDir, flagChromeUserDataDirName, "", flagChromeUserDataDirDesc)
+	flag.StringVar(&chromeCookieFile, flagChromeCookieFileName, "", flagChromeCookieFileDesc)
+	flag.StringVar(&handleCachePath, flagHandleCacheName, "", flagHandleCacheDescription)
+	flag.StringVar(&handleSeedPaths, flagHandleSeedName, "", flagHandleSeedDescription)
+	flag.DurationVar(&successTTL, flagSuccessTTLName, handles.DefaultSuccessTTL, flagSuccessTTLDescription)
+	flag.DurationVar(&failureTTL, flagFailureTTLName, handles.DefaultFailureTTL, flagFailureTTLDescription)
 	flag.Parse()
 
 	if zipPathA == "" || zipPathB == "" {
@@ -46,6 +151,26 @@ func main() {
 		os.Exit(2)
 	}
 
+	sortMode, err := matrix.ParseSortMode(sortModeValue)
+	if err != nil {
+		dief(sortOptionsErrorFormat, err)
+	}
+	if _, err := matrix.ParseSortLocale(sortLocale); err != nil {
+		dief(sortOptionsErrorFormat, err)
+	}
+	filter, err := matrix.ParseAccountFilter(filterValue)
+	if err != nil {
+		dief(sortOptionsErrorFormat, err)
+	}
+	backends, err := handles.ParseBackends(resolverBackends)
+	if err != nil {
+		dief(handlesResolverErrorFormat, err)
+	}
+	priority, err := matrix.ParseResolutionPriority(resolvePriority)
+	if err != nil {
+		dief(handlesResolverErrorFormat, err)
+	}
+
 	accountSetsA, ownerA, err := matrix.ReadTwitterZip(zipPathA)
 	if err != nil {
 		dief(loadErrorFormat, zipPathA, err)
@@ -55,20 +180,100 @@ func main() {
 		dief(loadErrorFormat, zipPathB, err)
 	}
 
-	if resolveHandles {
-		resolver, err := handles.NewResolver(handles.Config{})
+	reconciliation := matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+
+	if resolveHandles || handleSeedPaths != "" {
+		resolverConfig := handles.Config{
+			Backends:          backends,
+			MaxConcurrent:     resolverWorkers,
+			BreakerThreshold:  breakerThreshold,
+			BreakerCooldown:   breakerCooldown,
+			RateLimit:         rateLimit,
+			ChromeUserDataDir: chromeUserDataDir,
+			ChromeCookieFile:  chromeCookieFile,
+			SuccessTTL:        successTTL,
+			FailureTTL:        failureTTL,
+			Offline:           !resolveHandles,
+		}
+		if resolveHandles && slices.Contains(backends, handles.BackendAPI) {
+			resolverConfig.APIBearerToken, err = handles.LoadAPIBearerToken(apiBearerToken, apiTokenFile)
+			if err != nil {
+				dief(apiTokenErrorFormat, err)
+			}
+		}
+		var handleCache *handles.FileCache
+		if handleCachePath != "" {
+			handleCache, err = handles.OpenFileCache(handleCachePath)
+			if err != nil {
+				dief(handleCacheErrorFormat, err)
+			}
+			resolverConfig.Cache = handleCache
+			matrix.RecordArchiveHistory(handleCache, &accountSetsA, &accountSetsB)
+		} else if handleSeedPaths != "" {
+			resolverConfig.Cache = handles.NewMemoryCache()
+		}
+		for _, seedPath := range strings.Split(handleSeedPaths, handleSeedSeparator) {
+			if seedPath = strings.TrimSpace(seedPath); seedPath == "" {
+				continue
+			}
+			seedEntries, err := handles.ReadExchangeFile(seedPath, "")
+			if err != nil {
+				dief(handleSeedErrorFormat, seedPath, err)
+			}
+			handles.MergeExchange(resolverConfig.Cache, seedEntries)
+		}
+		resolver, err := handles.NewResolver(resolverConfig)
 		if err != nil {
 			dief(handlesResolverErrorFormat, err)
 		}
-		resolutionErrors := matrix.MaybeResolveHandles(context.Background(), resolver, true, &accountSetsA, &accountSetsB)
+		defer func() {
+			if err := resolver.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, resolverCloseFormat, err)
+			}
+		}()
+		resolutionContext := handles.WithProgress(context.Background(), printResolutionProgress)
+		resolutionPolicy := matrix.ResolutionPolicy{Priority: priority, Budget: resolveBudget}
+		resolutionErrors := matrix.MaybeResolveHandlesWithPolicy(resolutionContext, resolver, true, resolutionPolicy, &accountSetsA, &accountSetsB)
+		unresolvedCount := 0
+		notCachedCount := 0
+		loginWallCount := 0
 		for accountID, resolutionErr := range resolutionErrors {
-			fmt.Fprintf(os.Stderr, handleResolutionErrorFormat, accountID, resolutionErr)
+			switch {
+			case errors.Is(resolutionErr, handles.ErrBudgetExhausted):
+				unresolvedCount++
+			case errors.Is(resolutionErr, handles.ErrNotCached):
+				notCachedCount++
+			case errors.Is(resolutionErr, handles.ErrLoginWall):
+				loginWallCount++
+			default:
+				fmt.Fprintf(os.Stderr, handleResolutionErrorFormat, accountID, resolutionErr)
+			}
+		}
+		if unresolvedCount > 0 {
+			fmt.Fprintf(os.Stderr, budgetExhaustedFormat, unresolvedCount)
+		}
+		if notCachedCount > 0 {
+			fmt.Fprintf(os.Stderr, notCachedFormat, notCachedCount)
 		}
+		if loginWallCount > 0 {
+			fmt.Fprintf(os.Stderr, loginWallFormat, loginWallCount)
+		}
+		if handleCache != nil {
+			if err := handleCache.Close(); err != nil {
+				dief(handleCacheErrorFormat, err)
+			}
+		}
+		// Reconcile again so resolved handles, including those of muted and blocked accounts, are compared with the
+		// archives.
+		reconciliation = matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+	}
+	for _, conflict := range reconciliation.Conflicts {
+		fmt.Fprintf(os.Stderr, labelConflictFormat, conflict.AccountID, conflict.Field, strings.Join(conflict.Values, labelConflictSeparator), conflict.Chosen)
 	}
 
-	comparison := matrix.BuildComparison(accountSetsA, accountSetsB, ownerA, ownerB)
+	comparison := matrix.BuildComparisonWithOptions(accountSetsA, accountSetsB, ownerA, ownerB, matrix.ComparisonOptions{SortMode: sortMode, Locale: sortLocale, Filter: filter})
 
-	pageHTML, err := matrix.RenderComparisonPage(matrix.ComparisonPageData{Comparison: &comparison})
+	pageHTML, err := matrix.RenderComparisonPage(matrix.ComparisonPageData{Comparison: &comparison, LabelConflicts: reconciliation.Conflicts})
 	if err != nil {
 		dief(renderErrorFormat, err)
 	}
@@ -86,6 +291,26 @@ func main() {
 	fmt.Println("Wrote", outputPath)
 }
 
+// printResolutionProgress redraws a single terminal line on stderr as handles resolve and ends it once every account
+// has settled.
+func printResolutionProgress(event handles.ProgressEvent) {
+	progress := event.Progress
+	line := fmt.Sprintf(progressLineFormat, progress.Completed, progress.Total, progress.Resolved, progress.Failed)
+	if progress.Skipped > 0 {
+		line += fmt.Sprintf(progressSkippedFormat, progress.Skipped)
+	}
+	if progress.ETA > 0 {
+		line += fmt.Sprintf(progressETAFormat, progress.ETA.Round(time.Second))
+	}
+	if event.Kind == handles.ProgressRetrying {
+		line += fmt.Sprintf(progressRetryingFormat, event.AccountID)
+	}
+	fmt.Fprint(os.Stderr, line+progressClearToEndOfLine)
+	if progress.Remaining() == 0 && event.Kind != handles.ProgressRetrying {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
 func dief(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 	os.Exit(1)
@@ -0,0 +1,440 @@
+// Command resolve looks numeric X account IDs up with the handles resolver and writes one line per account.
+//
+// Usage:
+//
+//	resolve [flags] [id...]
+//
+// IDs come from the arguments, from --in (- reads standard input), or from standard input when neither is given.
+// Output is text, CSV or JSON Lines; with --resume, IDs already present in --out are skipped and new rows are
+// appended, so an interrupted run picks up where it stopped.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	flagInName                 = "in"
+	flagInDescription          = "File with one account ID per line; - reads standard input"
+	flagOutName                = "out"
+	flagOutDescription         = "Output file; standard output when empty"
+	flagFormatName             = "format"
+	flagFormatDescription      = "Output format: text, csv or jsonl"
+	flagResumeName             = "resume"
+	flagResumeDescription      = "Skip IDs already present in --out and append new rows to it"
+	flagBackendsName           = "backends"
+	flagBackendsDescription    = "Comma-separated lookup backends tried in order: chrome, chrome-pool, redirect, api"
+	flagWorkersName            = "workers"
+	flagWorkersDescription     = "Accounts looked up concurrently; the chrome-pool backend keeps one browser tab per worker"
+	flagBaseURLName            = "base-url"
+	flagBaseURLDescription     = "Site serving intent pages, profile pages and /i/user/<id> redirects"
+	flagAPIBaseURLName         = "api-base-url"
+	flagAPIBaseURLDescription  = "X API host used by the api backend"
+	flagAPIBearerTokenName     = "api-bearer-token"
+	flagAPIBearerTokenDesc     = "X API bearer token for the api backend; the token file is read when empty"
+	flagAPITokenFileName       = "api-token-file"
+	flagAPITokenFileDesc       = "Token file written by the api command, used by the api backend"
+	flagChromeBinaryName       = "chrome-binary"
+	flagChromeBinaryDesc       = "Chrome or Chromium binary; CHROME_BIN or the usual install locations when empty"
+	flagChromeUserDataDirName  = "chrome-user-data-dir"
+	flagChromeUserDataDirDesc  = "Persistent Chrome profile used by the chrome backends; log into X in it once to resolve protected accounts"
+	flagChromeCookieFileName   = "chrome-cookie-file"
+	flagChromeCookieFileDesc   = "Netscape cookie file imported into the chrome backends; the chrome backend also needs --chrome-user-data-dir"
+	flagBreakerThresholdName   = "breaker-threshold"
+	flagBreakerThresholdDesc   = "Consecutive failures after which a backend is skipped"
+	flagBreakerCooldownName    = "breaker-cooldown"
+	flagBreakerCooldownDesc    = "How long a failing backend is skipped before it is tried again"
+	flagRateLimitName          = "rate-limit"
+	flagRateLimitDesc          = "Requests per second sent by each lookup backend (negative disables pacing)"
+	flagRateBurstName          = "rate-burst"
+	flagRateBurstDesc          = "Requests each lookup backend may send back to back"
+	flagMaxRetriesName         = "max-retries"
+	flagMaxRetriesDesc         = "Retries of a request after 429, 5xx, or network errors"
+	flagMaxRateLimitWaitName   = "max-rate-limit-wait"
+	flagMaxRateLimitWaitDesc   = "Longest server-advised rate limit pause that is waited out (0 uses each backend's default)"
+	flagHandleCacheName        = "handle-cache"
+	flagHandleCacheDescription = "File that persists resolved handles between runs; accounts cached there are not fetched again"
+	formatText                 = "text"
+	standardStreamPath         = "-"
+	commentPrefix              = "#"
+	resolveChunkSize           = 100
+	outputFileMode             = 0o644
+	exitInterrupted            = 130
+	textSuccessFormat          = "%s\t@%s\t%s\n"
+	textFailureFormat          = "%s\t!%s\t%s\n"
+	missingOutputErrorMessage  = "--resume needs --out"
+	unknownFormatErrorFormat   = "unknown format %q"
+	invalidIDWarningFormat     = "warning: skipping %q: not a numeric account ID\n"
+	readInputErrorFormat       = "read input: %v"
+	resumeErrorFormat          = "resume from %s: %v"
+	openOutputErrorFormat      = "open %s: %v"
+	writeOutputErrorFormat     = "write %s: %v"
+	backendsErrorFormat        = "backends: %v"
+	apiTokenErrorFormat        = "api token: %v"
+	handleCacheErrorFormat     = "handle cache: %v"
+	handlesResolverErrorFormat = "handles resolver: %v"
+	resolverCloseFormat        = "warning: close handles resolver: %v\n"
+	summaryFormat              = "resolved %d of %d accounts (%d failed)\n"
+	resumedFormat              = "skipped %d accounts already in %s\n"
+	interruptedFormat          = "interrupted with %d accounts left; rerun with --resume to continue\n"
+	standardOutputName         = "standard output"
+)
+
+// outcomeWriter writes the outcome of one account at a time.
+type outcomeWriter interface {
+	Write(accountEntry handles.AccountEntry) error
+	Flush() error
+}
+
+// textWriter writes "id<TAB>@handle<TAB>display name" for resolved accounts and "id<TAB>!status<TAB>error" for
+// failures.
+type textWriter struct {
+	writer *bufio.Writer
+}
+
+// runSummary counts what a run did.
+type runSummary struct {
+	resolved int
+	failed   int
+	// remaining counts accounts left unwritten because the run was interrupted.
+	remaining int
+}
+
+func main() {
+	var inputPath string
+	var outputPath string
+	var format string
+	var resume bool
+	var backendsValue string
+	var workers int
+	var baseURL string
+	var apiBaseURL string
+	var apiBearerToken string
+	var apiTokenFile string
+	var chromeBinary string
+	var chromeUserDataDir string
+	var chromeCookieFile string
+	var breakerThreshold int
+	var breakerCooldown time.Duration
+	var rateLimit ratelimit.Config
+	var handleCachePath string
+
+	flag.StringVar(&inputPath, flagInName, "", flagInDescription)
+	flag.StringVar(&outputPath, flagOutName, "", flagOutDescription)
+	flag.StringVar(&format, flagFormatName, formatText, flagFormatDescription)
+	flag.BoolVar(&resume, flagResumeName, false, flagResumeDescription)
+	flag.StringVar(&backendsValue, flagBackendsName, string(handles.DefaultBackend), flagBackendsDescription)
+	flag.IntVar(&workers, flagWorkersName, handles.DefaultMaxConcurrent, flagWorkersDescription)
+	flag.StringVar(&baseURL, flagBaseURLName, "", flagBaseURLDescription)
+	flag.StringVar(&apiBaseURL, flagAPIBaseURLName, "", flagAPIBaseURLDescription)
+	flag.StringVar(&apiBearerToken, flagAPIBearerTokenName, "", flagAPIBearerTokenDesc)
+	flag.StringVar(&apiTokenFile, flagAPITokenFileName, handles.DefaultAPITokenFile, flagAPITokenFileDesc)
+	flag.StringVar(&chromeBinary, flagChromeBinaryName, "", flagChromeBinaryDesc)
+	flag.StringVar(&chromeUserDataDir, flagChromeUserDataDirName, "", flagChromeUserDataDirDesc)
+	flag.StringVar(&chromeCookieFile, flagChromeCookieFileName, "", flagChromeCookieFileDesc)
+	flag.IntVar(&breakerThreshold, flagBreakerThresholdName, handles.DefaultBreakerThreshold, flagBreakerThresholdDesc)
+	flag.DurationVar(&breakerCooldown, flagBreakerCooldownName, handles.DefaultBreakerCooldown, flagBreakerCooldownDesc)
+	flag.Float64Var(&rateLimit.RequestsPerSecond, flagRateLimitName, ratelimit.DefaultRequestsPerSecond, flagRateLimitDesc)
+	flag.IntVar(&rateLimit.Burst, flagRateBurstName, ratelimit.DefaultBurst, flagRateBurstDesc)
+	flag.IntVar(&rateLimit.MaxRetries, flagMaxRetriesName, ratelimit.DefaultMaxRetries, flagMaxRetriesDesc)
+	flag.DurationVar(&rateLimit.MaxWait, flagMaxRateLimitWaitName, 0, flagMaxRateLimitWaitDesc)
+	flag.StringVar(&handleCachePath, flagHandleCacheName, "", flagHandleCacheDescription)
+	flag.Parse()
+
+	switch format {
+	case formatText, string(handles.ExchangeFormatCSV), string(handles.ExchangeFormatJSONL):
+	default:
+		dief(unknownFormatErrorFormat, format)
+	}
+	if resume && outputPath == "" {
+		dief(missingOutputErrorMessage)
+	}
+	backends, err := handles.ParseBackends(backendsValue)
+	if err != nil {
+		dief(backendsErrorFormat, err)
+	}
+
+	accountIDs, err := readInput(flag.Args(), inputPath)
+	if err != nil {
+		dief(readInputErrorFormat, err)
+	}
+	var writtenIDs map[string]struct{}
+	if resume {
+		writtenIDs, err = writtenAccountIDs(outputPath, format)
+		if err != nil {
+			dief(resumeErrorFormat, outputPath, err)
+		}
+	}
+	pendingIDs := make([]string, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		if _, written := writtenIDs[accountID]; !written {
+			pendingIDs = append(pendingIDs, accountID)
+		}
+	}
+
+	resolverConfig := handles.Config{
+		Backends:          backends,
+		BaseURL:           baseURL,
+		APIBaseURL:        apiBaseURL,
+		MaxConcurrent:     workers,
+		BreakerThreshold:  breakerThreshold,
+		BreakerCooldown:   breakerCooldown,
+		RateLimit:         rateLimit,
+		ChromeBinaryPath:  chromeBinary,
+		ChromeUserDataDir: chromeUserDataDir,
+		ChromeCookieFile:  chromeCookieFile,
+		Cache:             handles.NewMemoryCache(),
+	}
+	if slices.Contains(backends, handles.BackendAPI) {
+		resolverConfig.APIBearerToken, err = handles.LoadAPIBearerToken(apiBearerToken, apiTokenFile)
+		if err != nil {
+			dief(apiTokenErrorFormat, err)
+		}
+	}
+	var handleCache *handles.FileCache
+	if handleCachePath != "" {
+		handleCache, err = handles.OpenFileCache(handleCachePath)
+		if err != nil {
+			dief(handleCacheErrorFormat, err)
+		}
+		resolverConfig.Cache = handleCache
+	}
+	resolver, err := handles.NewResolver(resolverConfig)
+	if err != nil {
+		dief(handlesResolverErrorFormat, err)
+	}
+	defer func() {
+		if err := resolver.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, resolverCloseFormat, err)
+		}
+	}()
+
+	outputName := standardOutputName
+	output := io.Writer(os.Stdout)
+	writeHeader := true
+	if outputPath != "" {
+		outputName = outputPath
+		openFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if resume {
+			openFlags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := os.OpenFile(outputPath, openFlags, outputFileMode)
+		if err != nil {
+			dief(openOutputErrorFormat, outputPath, err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && info.Size() > 0 {
+			writeHeader = false
+		}
+		output = file
+	}
+	writer, err := newOutcomeWriter(output, format, writeHeader)
+	if err != nil {
+		dief(writeOutputErrorFormat, outputName, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	summary, err := resolveAll(ctx, resolver, resolverConfig.Cache, pendingIDs, writer)
+	if err != nil {
+		dief(writeOutputErrorFormat, outputName, err)
+	}
+	if handleCache != nil {
+		if err := handleCache.Close(); err != nil {
+			dief(handleCacheErrorFormat, err)
+		}
+	}
+	if resume {
+		fmt.Fprintf(os.Stderr, resumedFormat, len(accountIDs)-len(pendingIDs), outputName)
+	}
+	fmt.Fprintf(os.Stderr, summaryFormat, summary.resolved, len(pendingIDs), summary.failed)
+	if summary.remaining > 0 {
+		fmt.Fprintf(os.Stderr, interruptedFormat, summary.remaining)
+		os.Exit(exitInterrupted)
+	}
+}
+
+// readInput collects account IDs from the arguments, then from inputPath, or from standard input when neither is
+// given. Blank lines and # comments are skipped, IDs that are not numeric are reported and skipped, and duplicates
+// keep their first position.
+func readInput(arguments []string, inputPath string) ([]string, error) {
+	candidates := append([]string(nil), arguments...)
+	var reader io.Reader
+	switch {
+	case inputPath == standardStreamPath, inputPath == "" && len(arguments) == 0:
+		reader = os.Stdin
+	case inputPath != "":
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+	if reader != nil {
+		lines, err := readLines(reader)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, lines...)
+	}
+
+	accountIDs := make([]string, 0, len(candidates))
+	seen := make(map[string]struct{}, len(candidates))
+	for _, candidate := range candidates {
+		accountID := strings.TrimSpace(candidate)
+		if !isAccountID(accountID) {
+			fmt.Fprintf(os.Stderr, invalidIDWarningFormat, accountID)
+			continue
+		}
+		if _, duplicate := seen[accountID]; duplicate {
+			continue
+		}
+		seen[accountID] = struct{}{}
+		accountIDs = append(accountIDs, accountID)
+	}
+	return accountIDs, nil
+}
+
+func readLines(reader io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// writtenAccountIDs lists the accounts already present in an earlier output file; a missing or empty file has none.
+func writtenAccountIDs(outputPath string, format string) (map[string]struct{}, error) {
+	info, err := os.Stat(outputPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	accountIDs := make(map[string]struct{})
+	if format == formatText {
+		file, err := os.Open(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		lines, err := readLines(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			accountIDs[strings.Fields(line)[0]] = struct{}{}
+		}
+		return accountIDs, nil
+	}
+	entries, err := handles.ReadExchangeFile(outputPath, handles.ExchangeFormat(format))
+	if err != nil {
+		return nil, err
+	}
+	for _, accountEntry := range entries {
+		accountIDs[accountEntry.AccountID] = struct{}{}
+	}
+	return accountIDs, nil
+}
+
+func newOutcomeWriter(output io.Writer, format string, writeHeader bool) (outcomeWriter, error) {
+	if format == formatText {
+		return &textWriter{writer: bufio.NewWriter(output)}, nil
+	}
+	return handles.NewExchangeWriter(output, handles.ExchangeFormat(format), writeHeader)
+}
+
+// resolveAll resolves accountIDs in chunks and writes each chunk in input order once it settles, so that an
+// interrupted run keeps every finished chunk. Accounts cut short by cancellation are not written.
+func resolveAll(ctx context.Context, resolver *handles.Resolver, cache handles.Cache, accountIDs []string, writer outcomeWriter) (runSummary, error) {
+	var summary runSummary
+	for chunkStart := 0; chunkStart < len(accountIDs); chunkStart += resolveChunkSize {
+		chunk := accountIDs[chunkStart:min(chunkStart+resolveChunkSize, len(accountIDs))]
+		results := resolver.ResolveMany(ctx, chunk)
+		for _, accountID := range chunk {
+			result := results[accountID]
+			if errors.Is(result.Err, context.Canceled) || (ctx.Err() != nil && result.Err != nil) {
+				summary.remaining++
+				continue
+			}
+			accountEntry := handles.AccountEntry{AccountID: accountID, Entry: handles.CacheEntry{Record: result.Record, Err: result.Err, ResolvedAt: time.Now().UTC()}}
+			if cachedEntry, found := cache.Lookup(accountID); found {
+				accountEntry.Entry = cachedEntry
+			}
+			if err := writer.Write(accountEntry); err != nil {
+				return summary, err
+			}
+			if result.Err != nil {
+				summary.failed++
+			} else {
+				summary.resolved++
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return summary, err
+		}
+		if ctx.Err() != nil {
+			summary.remaining += len(accountIDs) - chunkStart - len(chunk)
+			break
+		}
+	}
+	return summary, nil
+}
+
+// Write writes one account.
+func (writer *textWriter) Write(accountEntry handles.AccountEntry) error {
+	if accountEntry.Entry.Err != nil {
+		status := handles.StatusOf(accountEntry.Entry.Err)
+		if status == "" {
+			status = handles.AccountStatusTransient
+		}
+		_, err := fmt.Fprintf(writer.writer, textFailureFormat, accountEntry.AccountID, status, accountEntry.Entry.Err)
+		return err
+	}
+	record := accountEntry.Entry.Record
+	_, err := fmt.Fprintf(writer.writer, textSuccessFormat, accountEntry.AccountID, record.UserName, record.DisplayName)
+	return err
+}
+
+// Flush writes buffered lines to the output.
+func (writer *textWriter) Flush() error {
+	return writer.writer.Flush()
+}
+
+func isAccountID(candidate string) bool {
+	if candidate == "" {
+		return false
+	}
+	for _, character := range candidate {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func dief(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
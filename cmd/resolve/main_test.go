@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+	"github.com/f-sync/fsync/internal/testing/fakex"
+)
+
+const (
+	resolveTestActiveID    = "60001"
+	resolveTestSuspendedID = "60002"
+	resolveTestMissingID   = "60003"
+)
+
+func newFakeXResolver(t *testing.T, server *fakex.Server) (*handles.Resolver, handles.Cache) {
+	t.Helper()
+	cache := handles.NewMemoryCache()
+	resolver, err := handles.NewResolver(handles.Config{
+		Backends:    []handles.Backend{handles.BackendRedirect},
+		BaseURL:     server.URL,
+		Cache:       cache,
+		MaxAttempts: 1,
+		RateLimit:   ratelimit.Config{RequestsPerSecond: -1},
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	return resolver, cache
+}
+
+func TestResolveAllWritesEveryFormat(t *testing.T) {
+	server := fakex.NewServer([]fakex.Account{
+		{ID: resolveTestActiveID, UserName: "fake_active"},
+		{ID: resolveTestSuspendedID, UserName: "fake_suspended", Status: fakex.StatusSuspended},
+		{ID: resolveTestMissingID, UserName: "fake_gone", Status: fakex.StatusNotFound},
+	})
+	defer server.Close()
+	accountIDs := []string{resolveTestMissingID, resolveTestActiveID, resolveTestSuspendedID}
+
+	testCases := []struct {
+		format        string
+		expectedLines []string
+	}{
+		{format: formatText, expectedLines: []string{
+			resolveTestMissingID + "\t!does-not-exist\taccount " + resolveTestMissingID + ": account does not exist",
+			resolveTestActiveID + "\t@fake_active\t",
+			resolveTestSuspendedID + "\t!suspended\taccount " + resolveTestSuspendedID + ": account suspended",
+		}},
+		{format: string(handles.ExchangeFormatCSV)},
+		{format: string(handles.ExchangeFormatJSONL)},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.format, func(t *testing.T) {
+			resolver, cache := newFakeXResolver(t, server)
+			var output bytes.Buffer
+			writer, err := newOutcomeWriter(&output, testCase.format, true)
+			if err != nil {
+				t.Fatalf("create writer: %v", err)
+			}
+			summary, err := resolveAll(context.Background(), resolver, cache, accountIDs, writer)
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if summary != (runSummary{resolved: 1, failed: 2}) {
+				t.Fatalf("unexpected summary %+v", summary)
+			}
+			if testCase.expectedLines != nil {
+				if lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"); !reflect.DeepEqual(lines, testCase.expectedLines) {
+					t.Fatalf("expected lines %q, got %q", testCase.expectedLines, lines)
+				}
+			}
+
+			outputPath := filepath.Join(t.TempDir(), "out")
+			if err := os.WriteFile(outputPath, output.Bytes(), 0o600); err != nil {
+				t.Fatalf("write output: %v", err)
+			}
+			writtenIDs, err := writtenAccountIDs(outputPath, testCase.format)
+			if err != nil {
+				t.Fatalf("read written IDs: %v", err)
+			}
+			var resumedIDs []string
+			for accountID := range writtenIDs {
+				resumedIDs = append(resumedIDs, accountID)
+			}
+			sort.Strings(resumedIDs)
+			if expectedIDs := []string{resolveTestActiveID, resolveTestSuspendedID, resolveTestMissingID}; !reflect.DeepEqual(resumedIDs, expectedIDs) {
+				t.Fatalf("expected %v to be skipped on resume, got %v", expectedIDs, resumedIDs)
+			}
+		})
+	}
+}
+
+func TestResolveAllSkipsInterruptedAccounts(t *testing.T) {
+	server := fakex.NewServer([]fakex.Account{{ID: resolveTestActiveID, UserName: "fake_active"}})
+	defer server.Close()
+	resolver, cache := newFakeXResolver(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var output bytes.Buffer
+	writer, err := newOutcomeWriter(&output, string(handles.ExchangeFormatCSV), false)
+	if err != nil {
+		t.Fatalf("create writer: %v", err)
+	}
+	summary, err := resolveAll(ctx, resolver, cache, []string{resolveTestActiveID}, writer)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if summary.remaining != 1 || output.Len() != 0 {
+		t.Fatalf("expected the cancelled account to stay unwritten, got %+v and %q", summary, output.String())
+	}
+}
+
+func TestWrittenAccountIDsOfMissingOutput(t *testing.T) {
+	writtenIDs, err := writtenAccountIDs(filepath.Join(t.TempDir(), "missing.csv"), string(handles.ExchangeFormatCSV))
+	if err != nil || len(writtenIDs) != 0 {
+		t.Fatalf("expected nothing to resume, got %v, %v", writtenIDs, err)
+	}
+}
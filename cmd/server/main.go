@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,6 +19,7 @@ import (
 
 	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
+	"github.com/f-sync/fsync/internal/ratelimit"
 	"github.com/f-sync/fsync/internal/server"
 )
 
@@ -25,16 +33,79 @@ const (
 	flagHostDescription           = "Host interface for the HTTP server"
 	flagPortName                  = "port"
 	flagPortDescription           = "Port for the HTTP server"
+	flagSortName                  = "sort"
+	flagSortDescription           = "Default bucket ordering: name, handle, id, or recency"
+	flagSortLocaleName            = "sort-locale"
+	flagSortLocaleDescription     = "BCP 47 locale used to collate names and handles"
+	flagPageLimitName             = "page-limit"
+	flagPageLimitDescription      = "Maximum accounts rendered per bucket before loading more (0 renders all)"
+	flagResolvePriorityName       = "resolve-priority"
+	flagResolvePriorityDesc       = "Comma-separated buckets whose accounts are resolved first, e.g. friends,leaders,blocked,groupies"
+	flagResolveBudgetName         = "resolve-budget"
+	flagResolveBudgetDesc         = "Most accounts looked up over the network per resolution run (0 means no limit)"
+	flagResolveTimeBudgetName     = "resolve-time-budget"
+	flagResolveTimeBudgetDesc     = "Wall-clock time after which a resolution run starts no new lookup (0 means no limit)"
+	flagResolverBackendsName      = "resolver-backends"
+	flagResolverBackendsDesc      = "Comma-separated handle lookup backends tried in order: chrome, chrome-pool, redirect, api"
+	flagResolverWorkersName       = "resolver-workers"
+	flagResolverWorkersDesc       = "Accounts looked up concurrently; the chrome-pool backend keeps one browser tab per worker"
+	flagAPIBearerTokenName        = "api-bearer-token"
+	flagAPIBearerTokenDesc        = "X API bearer token for the api backend; the token file is read when empty"
+	flagAPITokenFileName          = "api-token-file"
+	flagAPITokenFileDesc          = "Token file written by the api command, used by the api backend"
+	flagChromeUserDataDirName     = "chrome-user-data-dir"
+	flagChromeUserDataDirDesc     = "Persistent Chrome profile used by the chrome backends; log into X in it once to resolve protected accounts"
+	flagChromeCookieFileName      = "chrome-cookie-file"
+	flagChromeCookieFileDesc      = "Netscape cookie file imported into the chrome backends; the chrome backend also needs --chrome-user-data-dir"
+	flagBreakerThresholdName      = "breaker-threshold"
+	flagBreakerThresholdDesc      = "Consecutive failures after which a backend is skipped"
+	flagBreakerCooldownName       = "breaker-cooldown"
+	flagBreakerCooldownDesc       = "How long a failing backend is skipped before it is tried again"
+	flagRateLimitName             = "rate-limit"
+	flagRateLimitDesc             = "Requests per second sent by each lookup backend (negative disables pacing)"
+	flagRateBurstName             = "rate-burst"
+	flagRateBurstDesc             = "Requests each lookup backend may send back to back"
+	flagMaxRetriesName            = "max-retries"
+	flagMaxRetriesDesc            = "Retries of a request after 429, 5xx, or network errors"
+	flagMaxRateLimitWaitName      = "max-rate-limit-wait"
+	flagMaxRateLimitWaitDesc      = "Longest server-advised rate limit pause that is waited out (0 uses each backend's default)"
+	flagHandleCacheName           = "handle-cache"
+	flagHandleCacheDescription    = "File that persists resolved handles between restarts"
+	flagHandleSeedName            = "handle-seed"
+	flagHandleSeedDescription     = "resolve, xresolve or cresolve output merged into the handle cache (repeatable); without --resolve-handles, handles are filled from it and the cache alone, without network access"
+	flagSuccessTTLName            = "handle-cache-ttl"
+	flagSuccessTTLDescription     = "How long resolved handles are reused before refetching"
+	flagFailureTTLName            = "handle-cache-failure-ttl"
+	flagFailureTTLDescription     = "How long failed lookups are reused before retrying"
+	flagTeamZipName               = "team-zip"
+	flagTeamZipDescription        = "Team member archive ranked in the consensus view (repeatable)"
+	defaultPageLimit              = 500
 	defaultHost                   = "127.0.0.1"
 	defaultPort                   = 8080
+	shutdownTimeout               = 10 * time.Second
 	errMessageLoggerCreate        = "create logger"
 	errMessageResolverCreate      = "create resolver"
 	errMessageListenAndServe      = "listen and serve"
+	errMessageShutdown            = "shut down"
+	errMessageTeamArchiveLoad     = "load team archive"
+	errMessageHandleCacheOpen     = "open handle cache"
+	errMessageAPITokenLoad        = "load api token"
+	errMessageHandleSeedLoad      = "load handle seed"
+	logMessageHandleCacheClose    = "handle cache close failure"
+	logMessageResolverClose       = "handle resolver close failure"
+	logMessageTeamArchiveLoaded   = "loaded team archive"
 	logMessageResolvingHandles    = "resolving handles"
+	logMessageHandleSeedLoaded    = "loaded handle seed"
 	logMessageStartingServer      = "starting HTTP server"
 	logMessageServerStopped       = "server stopped"
+	logMessageShuttingDown        = "shutting down"
 	logMessageListenError         = "server listen failure"
+	logMessageShutdownError       = "server shutdown failure"
 	logFieldAddress               = "address"
+	logFieldArchivePath           = "archive"
+	logFieldSeedPath              = "seed"
+	logFieldMerged                = "merged"
+	logFieldKept                  = "kept"
 )
 
 func main() {
@@ -51,10 +122,56 @@ func newServerCommand() *cobra.Command {
 	command.Flags().Bool(flagResolveHandlesName, false, flagResolveHandlesDescription)
 	command.Flags().String(flagHostName, defaultHost, flagHostDescription)
 	command.Flags().Int(flagPortName, defaultPort, flagPortDescription)
+	command.Flags().String(flagSortName, string(matrix.DefaultSortMode), flagSortDescription)
+	command.Flags().String(flagSortLocaleName, "", flagSortLocaleDescription)
+	command.Flags().Int(flagPageLimitName, defaultPageLimit, flagPageLimitDescription)
+	command.Flags().StringSlice(flagTeamZipName, nil, flagTeamZipDescription)
+	command.Flags().String(flagResolvePriorityName, "", flagResolvePriorityDesc)
+	command.Flags().Int(flagResolveBudgetName, 0, flagResolveBudgetDesc)
+	command.Flags().Duration(flagResolveTimeBudgetName, 0, flagResolveTimeBudgetDesc)
+	command.Flags().String(flagResolverBackendsName, string(handles.DefaultBackend), flagResolverBackendsDesc)
+	command.Flags().Int(flagResolverWorkersName, handles.DefaultMaxConcurrent, flagResolverWorkersDesc)
+	command.Flags().Int(flagBreakerThresholdName, handles.DefaultBreakerThreshold, flagBreakerThresholdDesc)
+	command.Flags().Duration(flagBreakerCooldownName, handles.DefaultBreakerCooldown, flagBreakerCooldownDesc)
+	command.Flags().Float64(flagRateLimitName, ratelimit.DefaultRequestsPerSecond, flagRateLimitDesc)
+	command.Flags().Int(flagRateBurstName, ratelimit.DefaultBurst, flagRateBurstDesc)
+	command.Flags().Int(flagMaxRetriesName, ratelimit.DefaultMaxRetries, flagMaxRetriesDesc)
+	command.Flags().Duration(flagMaxRateLimitWaitName, 0, flagMaxRateLimitWaitDesc)
+	command.Flags().String(flagAPIBearerTokenName, "", flagAPIBearerTokenDesc)
+	command.Flags().String(flagAPITokenFileName, handles.DefaultAPITokenFile, flagAPITokenFileDesc)
+	command.Flags().String(flagChromeUserDataDirName, "", flagChromeUserDataDirDesc)
+	command.Flags().String(flagChromeCookieFileName, "", flagChromeCookieFileDesc)
+	command.Flags().String(flagHandleCacheName, "", flagHandleCacheDescription)
+	command.Flags().StringSlice(flagHandleSeedName, nil, flagHandleSeedDescription)
+	command.Flags().Duration(flagSuccessTTLName, handles.DefaultSuccessTTL, flagSuccessTTLDescription)
+	command.Flags().Duration(flagFailureTTLName, handles.DefaultFailureTTL, flagFailureTTLDescription)
 
 	bindFlagToViper(command, flagResolveHandlesName)
 	bindFlagToViper(command, flagHostName)
 	bindFlagToViper(command, flagPortName)
+	bindFlagToViper(command, flagSortName)
+	bindFlagToViper(command, flagSortLocaleName)
+	bindFlagToViper(command, flagPageLimitName)
+	bindFlagToViper(command, flagTeamZipName)
+	bindFlagToViper(command, flagResolvePriorityName)
+	bindFlagToViper(command, flagResolveBudgetName)
+	bindFlagToViper(command, flagResolveTimeBudgetName)
+	bindFlagToViper(command, flagResolverBackendsName)
+	bindFlagToViper(command, flagResolverWorkersName)
+	bindFlagToViper(command, flagBreakerThresholdName)
+	bindFlagToViper(command, flagBreakerCooldownName)
+	bindFlagToViper(command, flagRateLimitName)
+	bindFlagToViper(command, flagRateBurstName)
+	bindFlagToViper(command, flagMaxRetriesName)
+	bindFlagToViper(command, flagMaxRateLimitWaitName)
+	bindFlagToViper(command, flagAPIBearerTokenName)
+	bindFlagToViper(command, flagAPITokenFileName)
+	bindFlagToViper(command, flagChromeUserDataDirName)
+	bindFlagToViper(command, flagChromeCookieFileName)
+	bindFlagToViper(command, flagHandleCacheName)
+	bindFlagToViper(command, flagHandleSeedName)
+	bindFlagToViper(command, flagSuccessTTLName)
+	bindFlagToViper(command, flagFailureTTLName)
 
 	cobra.OnInitialize(configureEnvironment)
 
@@ -80,20 +197,105 @@ func runServerCommand(*cobra.Command, []string) error {
 		_ = logger.Sync()
 	}()
 
+	// Deferred closes run after the server stopped and the signal cancelled the background jobs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	resolutionPriority, err := matrix.ParseResolutionPriority(viper.GetString(flagResolvePriorityName))
+	if err != nil {
+		return fmt.Errorf("%s: %w", errMessageResolverCreate, err)
+	}
+	resolutionPolicy := matrix.ResolutionPolicy{
+		Priority: resolutionPriority,
+		Budget: handles.Budget{
+			MaxLookups:  viper.GetInt(flagResolveBudgetName),
+			MaxDuration: viper.GetDuration(flagResolveTimeBudgetName),
+		},
+	}
+
 	var resolver matrix.AccountHandleResolver
-	if viper.GetBool(flagResolveHandlesName) {
+	var handleHistory handles.HistoryStore
+	resolveHandles := viper.GetBool(flagResolveHandlesName)
+	handleSeedPaths := viper.GetStringSlice(flagHandleSeedName)
+	if resolveHandles || len(handleSeedPaths) > 0 {
 		logger.Info(logMessageResolvingHandles)
-		handlesResolver, resolverErr := handles.NewResolver(handles.Config{})
+		backends, backendErr := handles.ParseBackends(viper.GetString(flagResolverBackendsName))
+		if backendErr != nil {
+			return fmt.Errorf("%s: %w", errMessageResolverCreate, backendErr)
+		}
+		resolverConfig := handles.Config{
+			Backends:         backends,
+			MaxConcurrent:    viper.GetInt(flagResolverWorkersName),
+			BreakerThreshold: viper.GetInt(flagBreakerThresholdName),
+			BreakerCooldown:  viper.GetDuration(flagBreakerCooldownName),
+			RateLimit: ratelimit.Config{
+				RequestsPerSecond: viper.GetFloat64(flagRateLimitName),
+				Burst:             viper.GetInt(flagRateBurstName),
+				MaxRetries:        viper.GetInt(flagMaxRetriesName),
+				MaxWait:           viper.GetDuration(flagMaxRateLimitWaitName),
+			},
+			ChromeUserDataDir: viper.GetString(flagChromeUserDataDirName),
+			ChromeCookieFile:  viper.GetString(flagChromeCookieFileName),
+			SuccessTTL:        viper.GetDuration(flagSuccessTTLName),
+			FailureTTL:        viper.GetDuration(flagFailureTTLName),
+			Offline:           !resolveHandles,
+		}
+		if resolveHandles && slices.Contains(backends, handles.BackendAPI) {
+			apiToken, tokenErr := handles.LoadAPIBearerToken(viper.GetString(flagAPIBearerTokenName), viper.GetString(flagAPITokenFileName))
+			if tokenErr != nil {
+				return fmt.Errorf("%s: %w", errMessageAPITokenLoad, tokenErr)
+			}
+			resolverConfig.APIBearerToken = apiToken
+		}
+		if handleCachePath := viper.GetString(flagHandleCacheName); handleCachePath != "" {
+			handleCache, cacheErr := handles.OpenFileCache(handleCachePath)
+			if cacheErr != nil {
+				return fmt.Errorf("%s: %w", errMessageHandleCacheOpen, cacheErr)
+			}
+			defer func() {
+				if closeErr := handleCache.Close(); closeErr != nil {
+					logger.Error(logMessageHandleCacheClose, zap.Error(closeErr))
+				}
+			}()
+			resolverConfig.Cache = handleCache
+			handleHistory = handleCache
+		} else if len(handleSeedPaths) > 0 {
+			resolverConfig.Cache = handles.NewMemoryCache()
+		}
+		if err := loadHandleSeeds(logger, resolverConfig.Cache, handleSeedPaths); err != nil {
+			return err
+		}
+		handlesResolver, resolverErr := handles.NewResolver(resolverConfig)
 		if resolverErr != nil {
 			return fmt.Errorf("%s: %w", errMessageResolverCreate, resolverErr)
 		}
+		defer func() {
+			if closeErr := handlesResolver.Close(); closeErr != nil {
+				logger.Error(logMessageResolverClose, zap.Error(closeErr))
+			}
+		}()
 		resolver = handlesResolver
 	}
 
+	teamArchives, err := loadTeamArchives(logger, viper.GetStringSlice(flagTeamZipName))
+	if err != nil {
+		return err
+	}
+	for index := range teamArchives {
+		matrix.RecordArchiveHistory(handleHistory, &teamArchives[index].AccountSets)
+	}
+
 	router, err := server.NewRouter(server.RouterConfig{
-		Logger:         logger,
-		ResolveHandles: viper.GetBool(flagResolveHandlesName),
-		HandleResolver: resolver,
+		Logger:           logger,
+		ResolveHandles:   resolver != nil,
+		HandleResolver:   resolver,
+		HandleHistory:    handleHistory,
+		ResolutionPolicy: resolutionPolicy,
+		SortMode:         matrix.SortMode(viper.GetString(flagSortName)),
+		SortLocale:       viper.GetString(flagSortLocaleName),
+		PageLimit:        viper.GetInt(flagPageLimitName),
+		TeamArchives:     teamArchives,
+		Context:          ctx,
 	})
 	if err != nil {
 		return err
@@ -104,12 +306,54 @@ func runServerCommand(*cobra.Command, []string) error {
 	address := fmt.Sprintf("%s:%d", host, port)
 	logger.Info(logMessageStartingServer, zap.String(logFieldAddress, address))
 
-	httpServer := &http.Server{Addr: address, Handler: router}
-	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logger.Error(logMessageListenError, zap.Error(err))
-		return fmt.Errorf("%s: %w", errMessageListenAndServe, err)
+	// Requests share the signal context so that open event streams end once shutdown starts.
+	httpServer := &http.Server{Addr: address, Handler: router, BaseContext: func(net.Listener) context.Context { return ctx }}
+	serveErrs := make(chan error, 1)
+	go func() {
+		serveErrs <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErrs:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(logMessageListenError, zap.Error(err))
+			return fmt.Errorf("%s: %w", errMessageListenAndServe, err)
+		}
+	case <-ctx.Done():
+		logger.Info(logMessageShuttingDown)
+		shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownContext); err != nil {
+			logger.Error(logMessageShutdownError, zap.Error(err))
+			return fmt.Errorf("%s: %w", errMessageShutdown, err)
+		}
 	}
 
 	logger.Info(logMessageServerStopped)
 	return nil
 }
+
+// loadHandleSeeds merges resolve, xresolve or cresolve output files into cache.
+func loadHandleSeeds(logger *zap.Logger, cache handles.Cache, seedPaths []string) error {
+	for _, seedPath := range seedPaths {
+		entries, err := handles.ReadExchangeFile(seedPath, "")
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", errMessageHandleSeedLoad, seedPath, err)
+		}
+		summary := handles.MergeExchange(cache, entries)
+		logger.Info(logMessageHandleSeedLoaded, zap.String(logFieldSeedPath, seedPath), zap.Int(logFieldMerged, summary.Merged), zap.Int(logFieldKept, summary.Kept))
+	}
+	return nil
+}
+
+func loadTeamArchives(logger *zap.Logger, archivePaths []string) ([]matrix.TeamArchive, error) {
+	archives := make([]matrix.TeamArchive, 0, len(archivePaths))
+	for _, archivePath := range archivePaths {
+		accountSets, owner, err := matrix.ReadTwitterZip(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", errMessageTeamArchiveLoad, archivePath, err)
+		}
+		logger.Info(logMessageTeamArchiveLoaded, zap.String(logFieldArchivePath, archivePath))
+		archives = append(archives, matrix.TeamArchive{Owner: owner, AccountSets: accountSets})
+	}
+	return archives, nil
+}
@@ -0,0 +1,346 @@
+package handles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	// APIBatchSize is the largest number of IDs the users lookup endpoint accepts per request.
+	APIBatchSize = 100
+	// DefaultAPITokenFile is the token file written by cmd/api.
+	DefaultAPITokenFile = "token.json"
+
+	defaultAPIBaseURLString      = "https://api.twitter.com"
+	apiUsersLookupPath           = "/2/users"
+	apiUsernamesLookupPath       = "/2/users/by"
+	apiQueryIDs                  = "ids"
+	apiQueryUsernames            = "usernames"
+	apiQueryUserFields           = "user.fields"
+	apiUserFields                = "protected,verified,description,profile_image_url,public_metrics"
+	apiAuthorizationHeader       = "Authorization"
+	apiBearerPrefix              = "Bearer "
+	apiIDSeparator               = ","
+	apiProblemResourceNotFound   = "resource-not-found"
+	apiSuspendedDetailMarker     = "suspended"
+	apiResponseBodyLimit         = 4 << 20
+	apiRequestTimeoutSeconds     = 20
+	apiMaxRateLimitWaitMinutes   = 15
+	errMessageMissingAPIToken    = "api backend requires a bearer token or token file"
+	errMessageAPIStatusFormat    = "users lookup returned status %d"
+	errMessageAPIMissingUser     = "users lookup returned no data for account"
+	errMessageAPIProblemFormat   = "%s: %s"
+	errMessageTokenFileNoAccess  = "token file has no access_token"
+	errMessageAPIUnauthorizedFmt = "users lookup unauthorized (status %d)"
+)
+
+var (
+	// ErrMissingAPIToken indicates that the API backend was selected without credentials.
+	ErrMissingAPIToken = errors.New(errMessageMissingAPIToken)
+
+	errAPIMissingUser    = errors.New(errMessageAPIMissingUser)
+	errTokenFileNoAccess = errors.New(errMessageTokenFileNoAccess)
+)
+
+// APIFetcherConfig configures an APIIntentFetcher instance.
+type APIFetcherConfig struct {
+	// BaseURL is the API host; https://api.twitter.com is used when empty.
+	BaseURL string
+	// BearerToken is an app bearer token or an OAuth 2.0 user access token.
+	BearerToken string
+	// HTTPClient performs requests; a client with a 20 second timeout is used when nil.
+	HTTPClient *http.Client
+	// RateLimit paces requests and bounds how 429 responses are waited out; its MaxWait defaults to the fifteen
+	// minute users lookup window.
+	RateLimit ratelimit.Config
+	// Limiter is shared with other API clients when set, and RateLimit is ignored.
+	Limiter *ratelimit.Limiter
+}
+
+// APIIntentFetcher resolves accounts through the X API v2 users lookup endpoint, up to APIBatchSize per request.
+type APIIntentFetcher struct {
+	baseURL     *url.URL
+	bearerToken string
+	httpClient  *http.Client
+	limiter     *ratelimit.Limiter
+}
+
+type apiUsersResponse struct {
+	Data   []apiUser    `json:"data"`
+	Errors []apiProblem `json:"errors"`
+}
+
+type apiUser struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Username        string            `json:"username"`
+	Protected       bool              `json:"protected"`
+	Verified        bool              `json:"verified"`
+	Description     string            `json:"description"`
+	ProfileImageURL string            `json:"profile_image_url"`
+	PublicMetrics   *apiPublicMetrics `json:"public_metrics"`
+}
+
+type apiPublicMetrics struct {
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+}
+
+// profile maps the optional user fields onto the profile details of a record.
+func (user apiUser) profile() *Profile {
+	profile := Profile{
+		AvatarURL: user.ProfileImageURL,
+		Bio:       user.Description,
+		Protected: user.Protected,
+		Verified:  user.Verified,
+	}
+	if user.PublicMetrics != nil {
+		profile.FollowersCount = &user.PublicMetrics.FollowersCount
+		profile.FollowingCount = &user.PublicMetrics.FollowingCount
+	}
+	return &profile
+}
+
+type apiProblem struct {
+	Value      string `json:"value"`
+	ResourceID string `json:"resource_id"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Type       string `json:"type"`
+}
+
+type apiTokenFile struct {
+	AccessToken string `json:"access_token"`
+}
+
+// NewAPIIntentFetcher constructs an APIIntentFetcher from configuration values.
+func NewAPIIntentFetcher(configuration APIFetcherConfig) (*APIIntentFetcher, error) {
+	bearerToken := strings.TrimSpace(configuration.BearerToken)
+	if bearerToken == "" {
+		return nil, ErrMissingAPIToken
+	}
+	baseURLString := strings.TrimSpace(configuration.BaseURL)
+	if baseURLString == "" {
+		baseURLString = defaultAPIBaseURLString
+	}
+	parsedBaseURL, parseErr := url.Parse(baseURLString)
+	if parseErr != nil {
+		return nil, fmt.Errorf("parse api base url: %w", parseErr)
+	}
+	httpClient := configuration.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Duration(apiRequestTimeoutSeconds) * time.Second}
+	}
+	limiter := configuration.Limiter
+	if limiter == nil {
+		rateLimit := configuration.RateLimit
+		if rateLimit.MaxWait <= 0 {
+			rateLimit.MaxWait = time.Duration(apiMaxRateLimitWaitMinutes) * time.Minute
+		}
+		limiter = ratelimit.New(rateLimit)
+	}
+	return &APIIntentFetcher{
+		baseURL:     parsedBaseURL,
+		bearerToken: bearerToken,
+		httpClient:  httpClient,
+		limiter:     limiter,
+	}, nil
+}
+
+// ReadAPITokenFile returns the access token stored by cmd/api in its token file.
+func ReadAPITokenFile(path string) (string, error) {
+	contents, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return "", fmt.Errorf("read token file: %w", readErr)
+	}
+	var token apiTokenFile
+	if err := json.Unmarshal(contents, &token); err != nil {
+		return "", fmt.Errorf("decode token file: %w", err)
+	}
+	if strings.TrimSpace(token.AccessToken) == "" {
+		return "", errTokenFileNoAccess
+	}
+	return strings.TrimSpace(token.AccessToken), nil
+}
+
+// LoadAPIBearerToken returns bearerToken when it is set and otherwise the access token stored in tokenFilePath.
+func LoadAPIBearerToken(bearerToken string, tokenFilePath string) (string, error) {
+	if trimmed := strings.TrimSpace(bearerToken); trimmed != "" {
+		return trimmed, nil
+	}
+	if strings.TrimSpace(tokenFilePath) == "" {
+		return "", ErrMissingAPIToken
+	}
+	return ReadAPITokenFile(tokenFilePath)
+}
+
+// FetchIntentPage looks up a single account by identifier, or by handle through the usernames lookup.
+func (fetcher *APIIntentFetcher) FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error) {
+	if request.isHandleLookup() {
+		return fetcher.lookupUserName(ctx, request)
+	}
+	outcome, found := fetcher.FetchIntentPages(ctx, []IntentRequest{request})[request.AccountID]
+	if !found {
+		return IntentPage{}, errAPIMissingUser
+	}
+	return outcome.Page, outcome.Err
+}
+
+// FetchIntentPages looks up accounts in batches of APIBatchSize and reports an outcome for every request.
+func (fetcher *APIIntentFetcher) FetchIntentPages(ctx context.Context, requests []IntentRequest) map[string]IntentOutcome {
+	outcomes := make(map[string]IntentOutcome, len(requests))
+	for start := 0; start < len(requests); start += APIBatchSize {
+		end := start + APIBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		accountIDs := make([]string, 0, end-start)
+		for _, request := range requests[start:end] {
+			accountIDs = append(accountIDs, request.AccountID)
+		}
+		for accountID, outcome := range fetcher.lookupBatch(ctx, accountIDs) {
+			outcomes[accountID] = outcome
+		}
+	}
+	return outcomes
+}
+
+// lookupBatch maps one users lookup onto per-account outcomes; accounts the response does not mention fail as
+// transient.
+func (fetcher *APIIntentFetcher) lookupBatch(ctx context.Context, accountIDs []string) map[string]IntentOutcome {
+	response, lookupErr := fetcher.requestBatch(ctx, accountIDs)
+	outcomes := make(map[string]IntentOutcome, len(accountIDs))
+	if lookupErr != nil {
+		var resolutionErr *ResolutionError
+		batchFailed := errors.As(lookupErr, &resolutionErr)
+		for _, accountID := range accountIDs {
+			accountErr := lookupErr
+			if batchFailed {
+				accountErr = newResolutionError(accountID, resolutionErr.Status, resolutionErr.Err)
+			}
+			outcomes[accountID] = IntentOutcome{Err: accountErr}
+		}
+		return outcomes
+	}
+
+	for _, user := range response.Data {
+		outcomes[user.ID] = IntentOutcome{Page: user.page()}
+	}
+	for _, problem := range response.Errors {
+		accountID := problem.ResourceID
+		if accountID == "" {
+			accountID = problem.Value
+		}
+		outcomes[accountID] = IntentOutcome{Err: problem.resolutionError(accountID)}
+	}
+	for _, accountID := range accountIDs {
+		if _, answered := outcomes[accountID]; !answered {
+			outcomes[accountID] = IntentOutcome{Err: errAPIMissingUser}
+		}
+	}
+	return outcomes
+}
+
+// lookupUserName resolves one handle through the usernames lookup.
+func (fetcher *APIIntentFetcher) lookupUserName(ctx context.Context, request IntentRequest) (IntentPage, error) {
+	response, lookupErr := fetcher.requestUsers(ctx, apiUsernamesLookupPath, apiQueryUsernames, []string{request.UserName})
+	if lookupErr != nil {
+		var resolutionErr *ResolutionError
+		if errors.As(lookupErr, &resolutionErr) {
+			return IntentPage{}, newResolutionError(request.reference(), resolutionErr.Status, resolutionErr.Err)
+		}
+		return IntentPage{}, lookupErr
+	}
+	for _, user := range response.Data {
+		if strings.EqualFold(user.Username, request.UserName) {
+			return user.page(), nil
+		}
+	}
+	if len(response.Errors) > 0 {
+		return IntentPage{}, response.Errors[0].resolutionError(request.reference())
+	}
+	return IntentPage{}, errAPIMissingUser
+}
+
+// page maps a users lookup result onto the page the resolver reads records from.
+func (user apiUser) page() IntentPage {
+	page := IntentPage{
+		AccountID:   user.ID,
+		UserName:    user.Username,
+		DisplayName: user.Name,
+		SourceURL:   defaultIntentBaseURLString + "/" + user.Username,
+		Profile:     user.profile(),
+	}
+	if user.Protected {
+		page.Status = AccountStatusProtected
+	}
+	return page
+}
+
+// requestBatch performs one users lookup by identifier.
+func (fetcher *APIIntentFetcher) requestBatch(ctx context.Context, accountIDs []string) (apiUsersResponse, error) {
+	return fetcher.requestUsers(ctx, apiUsersLookupPath, apiQueryIDs, accountIDs)
+}
+
+// requestUsers performs one users lookup through the rate limiter, passing values under queryKey. A rate limit that
+// cannot be waited out is reported as a ResolutionError without an account ID for the caller to fan out.
+func (fetcher *APIIntentFetcher) requestUsers(ctx context.Context, lookupPath string, queryKey string, values []string) (apiUsersResponse, error) {
+	query := url.Values{}
+	query.Set(queryKey, strings.Join(values, apiIDSeparator))
+	query.Set(apiQueryUserFields, apiUserFields)
+	requestURL := fetcher.baseURL.ResolveReference(&url.URL{Path: lookupPath, RawQuery: query.Encode()}).String()
+
+	httpRequest, requestErr := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if requestErr != nil {
+		return apiUsersResponse{}, requestErr
+	}
+	httpRequest.Header.Set(apiAuthorizationHeader, apiBearerPrefix+fetcher.bearerToken)
+	response, doErr := fetcher.limiter.Do(fetcher.httpClient, httpRequest)
+	if errors.Is(doErr, ratelimit.ErrRateLimited) {
+		return apiUsersResponse{}, newResolutionError("", AccountStatusRateLimited, doErr)
+	}
+	if doErr != nil {
+		return apiUsersResponse{}, doErr
+	}
+	body, readErr := io.ReadAll(io.LimitReader(response.Body, apiResponseBodyLimit))
+	_ = response.Body.Close()
+	if readErr != nil {
+		return apiUsersResponse{}, readErr
+	}
+
+	switch {
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return apiUsersResponse{}, fmt.Errorf(errMessageAPIUnauthorizedFmt, response.StatusCode)
+	case response.StatusCode != http.StatusOK:
+		return apiUsersResponse{}, fmt.Errorf(errMessageAPIStatusFormat, response.StatusCode)
+	}
+
+	var decoded apiUsersResponse
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return apiUsersResponse{}, fmt.Errorf("decode users lookup: %w", err)
+	}
+	return decoded, nil
+}
+
+// resolutionError maps a users lookup problem onto the resolver's failure taxonomy.
+func (problem apiProblem) resolutionError(accountID string) error {
+	cause := fmt.Errorf(errMessageAPIProblemFormat, problem.Title, problem.Detail)
+	switch {
+	case strings.Contains(strings.ToLower(problem.Detail), apiSuspendedDetailMarker):
+		return newResolutionError(accountID, AccountStatusSuspended, cause)
+	case strings.HasSuffix(problem.Type, apiProblemResourceNotFound):
+		return newResolutionError(accountID, AccountStatusDoesNotExist, cause)
+	default:
+		return newResolutionError(accountID, AccountStatusTransient, cause)
+	}
+}
@@ -0,0 +1,330 @@
+package handles_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	apiTestBearerToken          = "test-bearer-token"
+	apiTestUsersPath            = "/2/users"
+	apiTestAccountIDSuspended   = "60001"
+	apiTestAccountIDMissing     = "60002"
+	apiTestAccountIDProtected   = "60003"
+	apiTestGeneratedIDBase      = 61000
+	apiTestGeneratedAccounts    = 150
+	apiTestExpectedBatches      = 2
+	apiTestUserNamePrefix       = "user"
+	apiTestDisplayNamePrefix    = "User "
+	apiTestProtectedUserName    = "locked"
+	apiTestAvatarURL            = "https://pbs.twimg.com/profile_images/1/locked_normal.jpg"
+	apiTestFollowersCount       = 12
+	apiTestFollowingCount       = 34
+	apiTestTokenFileName        = "token.json"
+	apiTestTokenFileContents    = `{"access_token":"stored-token","refresh_token":"refresh","scope":"users.read"}`
+	apiTestTokenFileNoAccess    = `{"refresh_token":"refresh"}`
+	apiTestStoredAccessToken    = "stored-token"
+	apiTestSuspendedDetail      = "User has been suspended: [60001]."
+	apiTestNotFoundDetail       = "Could not find user with ids: [60002]."
+	apiTestResourceNotFoundType = "https://api.twitter.com/2/problems/resource-not-found"
+)
+
+type apiTestServer struct {
+	mutex          sync.Mutex
+	requests       int
+	batchSizes     []int
+	rateLimitFirst bool
+	unauthorized   bool
+}
+
+func (server *apiTestServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	server.requests++
+	call := server.requests
+	server.mutex.Unlock()
+
+	if request.URL.Path != apiTestUsersPath {
+		http.NotFound(writer, request)
+		return
+	}
+	if server.unauthorized || request.Header.Get("Authorization") != "Bearer "+apiTestBearerToken {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if server.rateLimitFirst && call == 1 {
+		writer.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+		writer.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	accountIDs := strings.Split(request.URL.Query().Get("ids"), ",")
+	server.mutex.Lock()
+	server.batchSizes = append(server.batchSizes, len(accountIDs))
+	server.mutex.Unlock()
+
+	response := map[string][]map[string]any{"data": {}, "errors": {}}
+	for _, accountID := range accountIDs {
+		switch accountID {
+		case apiTestAccountIDSuspended:
+			response["errors"] = append(response["errors"], map[string]any{
+				"value": accountID, "resource_id": accountID, "title": "Forbidden", "detail": apiTestSuspendedDetail,
+				"type": apiTestResourceNotFoundType,
+			})
+		case apiTestAccountIDMissing:
+			response["errors"] = append(response["errors"], map[string]any{
+				"value": accountID, "resource_id": accountID, "title": "Not Found Error", "detail": apiTestNotFoundDetail,
+				"type": apiTestResourceNotFoundType,
+			})
+		case apiTestAccountIDProtected:
+			response["data"] = append(response["data"], map[string]any{
+				"id": accountID, "username": apiTestProtectedUserName, "name": apiTestDisplayNamePrefix + accountID, "protected": true,
+				"verified": true, "profile_image_url": apiTestAvatarURL,
+				"public_metrics": map[string]int{"followers_count": apiTestFollowersCount, "following_count": apiTestFollowingCount},
+			})
+		default:
+			response["data"] = append(response["data"], map[string]any{
+				"id": accountID, "username": apiTestUserNamePrefix + accountID, "name": apiTestDisplayNamePrefix + accountID,
+			})
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(response)
+}
+
+func (server *apiTestServer) requestCount() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.requests
+}
+
+func newAPITestResolver(t *testing.T, server *httptest.Server) *handles.Resolver {
+	t.Helper()
+	resolver, err := handles.NewResolver(handles.Config{
+		Backends:       []handles.Backend{handles.BackendAPI},
+		APIBaseURL:     server.URL,
+		APIBearerToken: apiTestBearerToken,
+		HTTPClient:     server.Client(),
+		Cache:          handles.NewMemoryCache(),
+		MaxAttempts:    1,
+		RateLimit:      ratelimit.Config{RequestsPerSecond: -1},
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	return resolver
+}
+
+func TestAPIBackendResolvesInBatches(t *testing.T) {
+	handler := &apiTestServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resolver := newAPITestResolver(t, server)
+
+	accountIDs := []string{apiTestAccountIDSuspended, apiTestAccountIDMissing, apiTestAccountIDProtected}
+	for offset := 0; len(accountIDs) < apiTestGeneratedAccounts; offset++ {
+		accountIDs = append(accountIDs, strconv.Itoa(apiTestGeneratedIDBase+offset))
+	}
+	results := resolver.ResolveMany(context.Background(), accountIDs)
+
+	if len(results) != len(accountIDs) {
+		t.Fatalf("expected %d results, got %d", len(accountIDs), len(results))
+	}
+	if handler.requestCount() != apiTestExpectedBatches {
+		t.Fatalf("expected %d batched requests, got %d (%v)", apiTestExpectedBatches, handler.requestCount(), handler.batchSizes)
+	}
+
+	testCases := []struct {
+		name             string
+		accountID        string
+		expectedUserName string
+		expectedStatus   handles.AccountStatus
+		expectedSentinel error
+		expectProfile    bool
+	}{
+		{
+			name:             "active account resolves with display name",
+			accountID:        strconv.Itoa(apiTestGeneratedIDBase),
+			expectedUserName: apiTestUserNamePrefix + strconv.Itoa(apiTestGeneratedIDBase),
+		},
+		{
+			name:             "protected account resolves with status",
+			accountID:        apiTestAccountIDProtected,
+			expectedUserName: apiTestProtectedUserName,
+			expectedStatus:   handles.AccountStatusProtected,
+			expectProfile:    true,
+		},
+		{
+			name:             "suspended problem is classified",
+			accountID:        apiTestAccountIDSuspended,
+			expectedStatus:   handles.AccountStatusSuspended,
+			expectedSentinel: handles.ErrAccountSuspended,
+		},
+		{
+			name:             "not found problem is classified",
+			accountID:        apiTestAccountIDMissing,
+			expectedStatus:   handles.AccountStatusDoesNotExist,
+			expectedSentinel: handles.ErrAccountDoesNotExist,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			result := results[testCase.accountID]
+			if testCase.expectedSentinel != nil {
+				if !errors.Is(result.Err, testCase.expectedSentinel) {
+					t.Fatalf("expected %v, got %v", testCase.expectedSentinel, result.Err)
+				}
+			} else if result.Err != nil {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+			if result.Record.UserName != testCase.expectedUserName {
+				t.Fatalf("expected username %q, got %q", testCase.expectedUserName, result.Record.UserName)
+			}
+			if result.Record.Status != testCase.expectedStatus {
+				t.Fatalf("expected status %q, got %q", testCase.expectedStatus, result.Record.Status)
+			}
+			if testCase.expectedUserName == "" {
+				return
+			}
+			if result.Record.DisplayName != apiTestDisplayNamePrefix+testCase.accountID {
+				t.Fatalf("expected display name from the API, got %q", result.Record.DisplayName)
+			}
+			if origin := result.Record.Provenance.UserName.Origin; origin != string(handles.BackendAPI) {
+				t.Fatalf("expected origin %q, got %q", handles.BackendAPI, origin)
+			}
+			if !testCase.expectProfile {
+				return
+			}
+			profile := result.Record.Profile
+			if profile == nil || !profile.Protected || !profile.Verified || profile.AvatarURL != apiTestAvatarURL || profile.FetchedAt.IsZero() {
+				t.Fatalf("expected the API profile fields, got %+v", profile)
+			}
+			if profile.FollowersCount == nil || *profile.FollowersCount != apiTestFollowersCount || profile.FollowingCount == nil || *profile.FollowingCount != apiTestFollowingCount {
+				t.Fatalf("expected public metrics %d/%d, got %+v", apiTestFollowersCount, apiTestFollowingCount, profile)
+			}
+		})
+	}
+
+	resolver.ResolveMany(context.Background(), accountIDs)
+	if handler.requestCount() != apiTestExpectedBatches {
+		t.Fatalf("expected cached accounts to skip the API, got %d requests", handler.requestCount())
+	}
+}
+
+func TestAPIBackendHonorsRateLimitReset(t *testing.T) {
+	handler := &apiTestServer{rateLimitFirst: true}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resolver := newAPITestResolver(t, server)
+
+	accountID := strconv.Itoa(apiTestGeneratedIDBase)
+	record, err := resolver.ResolveAccount(context.Background(), accountID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.UserName != apiTestUserNamePrefix+accountID {
+		t.Fatalf("expected username %q, got %q", apiTestUserNamePrefix+accountID, record.UserName)
+	}
+	if handler.requestCount() != 2 {
+		t.Fatalf("expected the rate limited request to be retried once, got %d requests", handler.requestCount())
+	}
+}
+
+func TestAPIBackendSharesLimiter(t *testing.T) {
+	handler := &apiTestServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	sharedLimiter := ratelimit.New(ratelimit.Config{RequestsPerSecond: -1, MaxWait: time.Minute})
+	sharedLimiter.Pause(time.Hour)
+	resolver, err := handles.NewResolver(handles.Config{
+		Backends:       []handles.Backend{handles.BackendAPI},
+		APIBaseURL:     server.URL,
+		APIBearerToken: apiTestBearerToken,
+		HTTPClient:     server.Client(),
+		Cache:          handles.NewMemoryCache(),
+		MaxAttempts:    1,
+		APILimiter:     sharedLimiter,
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	accountID := strconv.Itoa(apiTestGeneratedIDBase)
+	result := resolver.ResolveMany(context.Background(), []string{accountID})[accountID]
+	if handles.StatusOf(result.Err) != handles.AccountStatusRateLimited {
+		t.Fatalf("expected the paused shared limiter to hold the request back, got %v", result.Err)
+	}
+	if handler.requestCount() != 0 {
+		t.Fatalf("expected no request while the shared limiter is paused, got %d", handler.requestCount())
+	}
+}
+
+func TestAPIBackendUnauthorizedIsTransient(t *testing.T) {
+	handler := &apiTestServer{unauthorized: true}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resolver := newAPITestResolver(t, server)
+
+	accountID := strconv.Itoa(apiTestGeneratedIDBase)
+	result := resolver.ResolveMany(context.Background(), []string{accountID})[accountID]
+	if !errors.Is(result.Err, handles.ErrTransientFailure) {
+		t.Fatalf("expected transient failure, got %v", result.Err)
+	}
+	if strings.Contains(result.Err.Error(), apiTestBearerToken) {
+		t.Fatalf("error must not reveal the bearer token: %v", result.Err)
+	}
+}
+
+func TestAPIBackendRequiresToken(t *testing.T) {
+	_, err := handles.NewResolver(handles.Config{Backends: []handles.Backend{handles.BackendAPI}})
+	if !errors.Is(err, handles.ErrMissingAPIToken) {
+		t.Fatalf("expected missing token error, got %v", err)
+	}
+}
+
+func TestReadAPITokenFile(t *testing.T) {
+	testCases := []struct {
+		name          string
+		contents      string
+		expectedToken string
+		expectError   bool
+	}{
+		{name: "access token is read", contents: apiTestTokenFileContents, expectedToken: apiTestStoredAccessToken},
+		{name: "missing access token fails", contents: apiTestTokenFileNoAccess, expectError: true},
+		{name: "malformed file fails", contents: "{", expectError: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			tokenPath := filepath.Join(t.TempDir(), apiTestTokenFileName)
+			if err := os.WriteFile(tokenPath, []byte(testCase.contents), 0o600); err != nil {
+				t.Fatalf("write token file: %v", err)
+			}
+			token, err := handles.ReadAPITokenFile(tokenPath)
+			if testCase.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got token of length %d", len(token))
+				}
+				return
+			}
+			if err != nil || token != testCase.expectedToken {
+				t.Fatalf("expected token %q, got %q (%v)", testCase.expectedToken, token, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Backend selects how a Resolver fetches account pages when no IntentFetcher is supplied.
+type Backend string
+
+const (
+	// BackendChrome renders intent pages with headless Chrome.
+	BackendChrome Backend = "chrome"
+	// BackendChromePool renders intent pages in a pool of long-lived headless Chrome tabs.
+	BackendChromePool Backend = "chrome-pool"
+	// BackendRedirect reads the profile redirect over plain HTTP and needs no browser.
+	BackendRedirect Backend = "redirect"
+	// BackendAPI looks accounts up in batches through the X API v2 users endpoint and needs a bearer token.
+	BackendAPI Backend = "api"
+
+	// DefaultBackend is used when Config.Backends is empty.
+	DefaultBackend = BackendChrome
+
+	errMessageUnknownBackend = "unknown resolver backend"
+	backendListSeparator     = ","
+)
+
+// ErrUnknownBackend indicates that a backend name is not recognized.
+var ErrUnknownBackend = errors.New(errMessageUnknownBackend)
+
+// ParseBackend converts user input into a Backend; an empty value selects DefaultBackend.
+func ParseBackend(value string) (Backend, error) {
+	switch backend := Backend(strings.ToLower(strings.TrimSpace(value))); backend {
+	case "":
+		return DefaultBackend, nil
+	case BackendChrome, BackendChromePool, BackendRedirect, BackendAPI:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownBackend, value)
+	}
+}
+
+// ParseBackends converts a comma-separated list into an ordered fallback chain without duplicates; an empty value
+// selects DefaultBackend alone.
+func ParseBackends(value string) ([]Backend, error) {
+	var backends []Backend
+	seen := make(map[Backend]struct{})
+	for _, part := range strings.Split(value, backendListSeparator) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		backend, parseErr := ParseBackend(part)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		if _, duplicate := seen[backend]; duplicate {
+			continue
+		}
+		seen[backend] = struct{}{}
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		return []Backend{DefaultBackend}, nil
+	}
+	return backends, nil
+}
+
+// newBackendFetcher builds a fallback chain over the configured backends.
+func newBackendFetcher(configuration Config) (IntentFetcher, error) {
+	backends := configuration.Backends
+	if len(backends) == 0 {
+		backends = []Backend{DefaultBackend}
+	}
+	links := make([]FallbackLink, 0, len(backends))
+	for _, backend := range backends {
+		fetcher, fetcherErr := newSingleBackendFetcher(backend, configuration)
+		if fetcherErr != nil {
+			return nil, fetcherErr
+		}
+		links = append(links, FallbackLink{Backend: backend, Fetcher: fetcher})
+	}
+	return NewFallbackFetcher(links, FallbackConfig{
+		BreakerThreshold: configuration.BreakerThreshold,
+		BreakerCooldown:  configuration.BreakerCooldown,
+	})
+}
+
+func newSingleBackendFetcher(backend Backend, configuration Config) (IntentFetcher, error) {
+	switch backend {
+	case BackendRedirect:
+		return NewRedirectIntentFetcher(RedirectFetcherConfig{
+			BaseURL:    configuration.BaseURL,
+			HTTPClient: configuration.HTTPClient,
+			RateLimit:  configuration.RateLimit,
+		})
+	case BackendAPI:
+		return NewAPIIntentFetcher(APIFetcherConfig{
+			BaseURL:     configuration.APIBaseURL,
+			BearerToken: configuration.APIBearerToken,
+			HTTPClient:  configuration.HTTPClient,
+			RateLimit:   configuration.RateLimit,
+			Limiter:     configuration.APILimiter,
+		})
+	case BackendChrome:
+		return NewChromeIntentFetcher(ChromeFetcherConfig{
+			BinaryPath:        resolveChromeBinaryPath(configuration),
+			UserAgent:         configuration.ChromeUserAgent,
+			VirtualTimeBudget: configuration.ChromeVirtualTimeBudget,
+			RequestDelay:      configuration.ChromeRequestDelay,
+			RateLimit:         configuration.RateLimit,
+			UserDataDir:       configuration.ChromeUserDataDir,
+			CookieFile:        configuration.ChromeCookieFile,
+		})
+	case BackendChromePool:
+		poolSize := configuration.ChromePoolSize
+		if poolSize <= 0 {
+			poolSize = configuration.MaxConcurrent
+		}
+		return NewChromePoolFetcher(ChromePoolConfig{
+			BinaryPath:  resolveChromeBinaryPath(configuration),
+			UserAgent:   configuration.ChromeUserAgent,
+			PoolSize:    poolSize,
+			PageTimeout: configuration.ChromeVirtualTimeBudget,
+			RateLimit:   configuration.RateLimit,
+			UserDataDir: configuration.ChromeUserDataDir,
+			CookieFile:  configuration.ChromeCookieFile,
+		})
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+	}
+}
@@ -0,0 +1,93 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const errMessageBudgetExhausted = "resolution budget exhausted"
+
+// ErrBudgetExhausted marks accounts that ResolveMany left unresolved because its Budget ran out.
+var ErrBudgetExhausted = errors.New(errMessageBudgetExhausted)
+
+// Budget bounds the work of one ResolveMany call. Accounts are admitted in the order they are passed, so callers
+// list the accounts they care about most first.
+type Budget struct {
+	// MaxLookups caps the accounts that need a fresh lookup; fresh cache hits do not count. Zero means no cap.
+	MaxLookups int
+	// MaxDuration caps the wall-clock time after which no new lookup starts; lookups already running finish. Zero
+	// means no cap.
+	MaxDuration time.Duration
+}
+
+// IsUnlimited reports whether the budget leaves ResolveMany unbounded.
+func (budget Budget) IsUnlimited() bool {
+	return budget.MaxLookups <= 0 && budget.MaxDuration <= 0
+}
+
+type budgetContextKey struct{}
+
+// WithBudget returns a context that makes ResolveMany stop starting lookups once budget runs out.
+func WithBudget(ctx context.Context, budget Budget) context.Context {
+	if budget.IsUnlimited() {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetContextKey{}, budget)
+}
+
+// Unresolved lists, in ascending numeric order, the accounts of a ResolveMany result that the budget left unresolved.
+func Unresolved(results map[string]Result) []string {
+	var accountIDs []string
+	for accountID, result := range results {
+		if errors.Is(result.Err, ErrBudgetExhausted) {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	sort.Slice(accountIDs, func(firstIndex, secondIndex int) bool {
+		return accountIDLess(accountIDs[firstIndex], accountIDs[secondIndex])
+	})
+	return accountIDs
+}
+
+// budgetTracker enforces the Budget of one ResolveMany call. A nil tracker admits everything.
+type budgetTracker struct {
+	mutex    sync.Mutex
+	budget   Budget
+	deadline time.Time
+	lookups  int
+}
+
+// startBudget creates a tracker for the Budget carried by ctx, or returns nil when there is none.
+func startBudget(ctx context.Context) *budgetTracker {
+	budget, found := ctx.Value(budgetContextKey{}).(Budget)
+	if !found {
+		return nil
+	}
+	tracker := &budgetTracker{budget: budget}
+	if budget.MaxDuration > 0 {
+		tracker.deadline = time.Now().Add(budget.MaxDuration)
+	}
+	return tracker
+}
+
+// admitLookup reserves one lookup and reports whether the budget still allows it.
+func (tracker *budgetTracker) admitLookup() bool {
+	if tracker == nil {
+		return true
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if tracker.budget.MaxLookups > 0 && tracker.lookups >= tracker.budget.MaxLookups {
+		return false
+	}
+	tracker.lookups++
+	return true
+}
+
+// expired reports whether the wall-clock budget has run out.
+func (tracker *budgetTracker) expired() bool {
+	return tracker != nil && !tracker.deadline.IsZero() && !time.Now().Before(tracker.deadline)
+}
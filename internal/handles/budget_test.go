@@ -0,0 +1,110 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	budgetTestAccountIDCached = "71000"
+	budgetTestAccountIDFirst  = "71001"
+	budgetTestAccountIDSecond = "71002"
+	budgetTestAccountIDThird  = "71003"
+	budgetTestFetchDelay      = 50 * time.Millisecond
+)
+
+// delayedIntentFetcher serves the same intent page for every account after a fixed delay.
+type delayedIntentFetcher struct {
+	delay time.Duration
+}
+
+func (fetcher delayedIntentFetcher) FetchIntentPage(ctx context.Context, request handles.IntentRequest) (handles.IntentPage, error) {
+	select {
+	case <-time.After(fetcher.delay):
+	case <-ctx.Done():
+		return handles.IntentPage{}, ctx.Err()
+	}
+	return handles.IntentPage{HTML: resolverTestIntentHTMLSuccess, SourceURL: resolverTestIntentURLPrefix + request.AccountID}, nil
+}
+
+func TestResolveManyStopsWhenTheBudgetRunsOut(t *testing.T) {
+	accountIDs := []string{budgetTestAccountIDCached, budgetTestAccountIDFirst, budgetTestAccountIDSecond, budgetTestAccountIDThird}
+
+	testCases := []struct {
+		name               string
+		budget             handles.Budget
+		fetchDelay         time.Duration
+		expectedUnresolved []string
+		expectedSkipped    int
+	}{
+		{
+			name:               "no budget resolves everything",
+			expectedUnresolved: nil,
+		},
+		{
+			name:               "lookup budget skips the accounts listed last and ignores cache hits",
+			budget:             handles.Budget{MaxLookups: 2},
+			expectedUnresolved: []string{budgetTestAccountIDThird},
+			expectedSkipped:    1,
+		},
+		{
+			name:               "time budget starts no lookup after the deadline",
+			budget:             handles.Budget{MaxDuration: budgetTestFetchDelay / 2},
+			fetchDelay:         budgetTestFetchDelay,
+			expectedUnresolved: []string{budgetTestAccountIDSecond, budgetTestAccountIDThird},
+			expectedSkipped:    2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cache := handles.NewMemoryCache()
+			cache.Store(budgetTestAccountIDCached, handles.CacheEntry{
+				Record:     handles.AccountRecord{AccountID: budgetTestAccountIDCached, UserName: "cached"},
+				ResolvedAt: time.Now().UTC(),
+			})
+			resolver, err := handles.NewResolver(handles.Config{
+				IntentFetcher: delayedIntentFetcher{delay: testCase.fetchDelay},
+				Cache:         cache,
+				MaxConcurrent: 1,
+				MaxAttempts:   1,
+			})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+			var lastProgress handles.Progress
+			ctx := handles.WithProgress(handles.WithBudget(context.Background(), testCase.budget), func(event handles.ProgressEvent) {
+				lastProgress = event.Progress
+			})
+
+			results := resolver.ResolveMany(ctx, accountIDs)
+
+			if unresolved := handles.Unresolved(results); !reflect.DeepEqual(unresolved, testCase.expectedUnresolved) {
+				t.Fatalf("expected unresolved %v, got %v", testCase.expectedUnresolved, unresolved)
+			}
+			if len(results) != len(accountIDs) {
+				t.Fatalf("expected a result for every account, got %d", len(results))
+			}
+			for _, accountID := range accountIDs {
+				result := results[accountID]
+				if errors.Is(result.Err, handles.ErrBudgetExhausted) {
+					if result.Record.AccountID != accountID {
+						t.Fatalf("expected the skipped result of %s to carry its account, got %+v", accountID, result.Record)
+					}
+					continue
+				}
+				if result.Err != nil || result.Record.UserName == "" {
+					t.Fatalf("expected %s to resolve, got %+v", accountID, result)
+				}
+			}
+			if lastProgress.Skipped != testCase.expectedSkipped || lastProgress.Remaining() != 0 {
+				t.Fatalf("expected %d skipped and nothing remaining, got %+v", testCase.expectedSkipped, lastProgress)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handles
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSuccessTTL is how long a successful resolution is reused before the account is fetched again.
+	DefaultSuccessTTL = 30 * 24 * time.Hour
+	// DefaultFailureTTL is how long a failed resolution is reused before the account is retried.
+	DefaultFailureTTL = time.Hour
+)
+
+// CacheEntry is a stored resolution outcome.
+type CacheEntry struct {
+	Record     AccountRecord
+	Err        error
+	ResolvedAt time.Time
+}
+
+// Cache stores resolution outcomes keyed by account identifier. Implementations must be safe for concurrent use;
+// expiry is decided by the Resolver from ResolvedAt.
+type Cache interface {
+	Lookup(accountID string) (CacheEntry, bool)
+	Store(accountID string, entry CacheEntry)
+}
+
+// HandleIndex is implemented by caches that can find a stored account by its handle. ResolveHandle consults it before
+// fetching a profile page.
+type HandleIndex interface {
+	// LookupHandle returns the most recent successful entry whose record carries userName, compared without regard
+	// to case.
+	LookupHandle(userName string) (CacheEntry, bool)
+}
+
+// MemoryCache keeps resolution outcomes and handle histories in process memory.
+type MemoryCache struct {
+	entries   map[string]CacheEntry
+	histories map[string][]HandleObservation
+	mutex     sync.RWMutex
+}
+
+// NewMemoryCache initializes an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]CacheEntry), histories: make(map[string][]HandleObservation)}
+}
+
+// Lookup retrieves the cached entry for the supplied account identifier.
+func (cache *MemoryCache) Lookup(accountID string) (CacheEntry, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	entry, found := cache.entries[accountID]
+	return entry, found
+}
+
+// Store saves the provided resolution outcome for reuse and adds a successful one to the account's history.
+func (cache *MemoryCache) Store(accountID string, entry CacheEntry) {
+	cache.mutex.Lock()
+	cache.entries[accountID] = entry
+	if observation, observed := resolvedObservation(entry); observed {
+		cache.histories[accountID] = withObservation(cache.histories[accountID], observation)
+	}
+	cache.mutex.Unlock()
+}
+
+// History returns the observations of the account ordered from oldest to newest.
+func (cache *MemoryCache) History(accountID string) []HandleObservation {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return append([]HandleObservation(nil), cache.histories[accountID]...)
+}
+
+// Observe records a sighting of the account without changing its cached resolution.
+func (cache *MemoryCache) Observe(accountID string, observation HandleObservation) {
+	cache.mutex.Lock()
+	cache.histories[accountID] = withObservation(cache.histories[accountID], observation)
+	cache.mutex.Unlock()
+}
+
+// LookupHandle returns the most recent successful entry for the handle.
+func (cache *MemoryCache) LookupHandle(userName string) (CacheEntry, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return lookupHandle(cache.entries, userName)
+}
+
+// Entries returns every cached outcome ordered by account identifier.
+func (cache *MemoryCache) Entries() []AccountEntry {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return sortedEntries(cache.entries)
+}
+
+// sortedEntries lists entries ordered by account identifier.
+func sortedEntries(entries map[string]CacheEntry) []AccountEntry {
+	accountEntries := make([]AccountEntry, 0, len(entries))
+	for accountID, entry := range entries {
+		accountEntries = append(accountEntries, AccountEntry{AccountID: accountID, Entry: entry})
+	}
+	sort.Slice(accountEntries, func(firstIndex, secondIndex int) bool {
+		return accountIDLess(accountEntries[firstIndex].AccountID, accountEntries[secondIndex].AccountID)
+	})
+	return accountEntries
+}
+
+// lookupHandle scans entries for the most recent success whose handle matches userName.
+func lookupHandle(entries map[string]CacheEntry, userName string) (CacheEntry, bool) {
+	var latest CacheEntry
+	found := false
+	for _, entry := range entries {
+		if entry.Err != nil || !strings.EqualFold(entry.Record.UserName, userName) {
+			continue
+		}
+		if !found || entry.ResolvedAt.After(latest.ResolvedAt) {
+			latest = entry
+			found = true
+		}
+	}
+	return latest, found
+}
@@ -0,0 +1,177 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	cacheTestAccountIDSuccess  = "30001"
+	cacheTestAccountIDFailure  = "30002"
+	cacheTestAccountIDCanceled = "30003"
+	cacheTestCachedUserName    = "cached"
+	cacheTestFetchedUserName   = "example"
+	cacheTestFailureMessage    = "account unavailable"
+	cacheTestFileName          = "handles.jsonl"
+	cacheTestMalformedLine     = "{not json"
+)
+
+func TestResolverHonorsCacheTTLs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		entry         handles.CacheEntry
+		age           time.Duration
+		expectedCalls int
+		expectedUser  string
+		expectErr     bool
+	}{
+		{
+			name:          "fresh success is reused",
+			entry:         handles.CacheEntry{Record: handles.AccountRecord{AccountID: cacheTestAccountIDSuccess, UserName: cacheTestCachedUserName}},
+			age:           time.Hour,
+			expectedCalls: 0,
+			expectedUser:  cacheTestCachedUserName,
+		},
+		{
+			name:          "expired success is refetched",
+			entry:         handles.CacheEntry{Record: handles.AccountRecord{AccountID: cacheTestAccountIDSuccess, UserName: cacheTestCachedUserName}},
+			age:           48 * time.Hour,
+			expectedCalls: 1,
+			expectedUser:  cacheTestFetchedUserName,
+		},
+		{
+			name:          "fresh failure is reused",
+			entry:         handles.CacheEntry{Record: handles.AccountRecord{AccountID: cacheTestAccountIDSuccess}, Err: errors.New(cacheTestFailureMessage)},
+			age:           time.Minute,
+			expectedCalls: 0,
+			expectErr:     true,
+		},
+		{
+			name:          "expired failure is retried",
+			entry:         handles.CacheEntry{Record: handles.AccountRecord{AccountID: cacheTestAccountIDSuccess}, Err: errors.New(cacheTestFailureMessage)},
+			age:           2 * time.Hour,
+			expectedCalls: 1,
+			expectedUser:  cacheTestFetchedUserName,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			cache := handles.NewMemoryCache()
+			entry := testCase.entry
+			entry.ResolvedAt = time.Now().Add(-testCase.age)
+			cache.Store(cacheTestAccountIDSuccess, entry)
+
+			fetcher := newRecordingIntentFetcher(map[string]handles.IntentPage{
+				cacheTestAccountIDSuccess: {
+					HTML:      resolverTestIntentHTMLSuccess,
+					SourceURL: resolverTestIntentURLPrefix + cacheTestAccountIDSuccess,
+				},
+			}, nil)
+			resolver, err := handles.NewResolver(handles.Config{
+				IntentFetcher: fetcher,
+				Cache:         cache,
+				SuccessTTL:    24 * time.Hour,
+				FailureTTL:    time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+
+			record, resolveErr := resolver.ResolveAccount(context.Background(), cacheTestAccountIDSuccess)
+			if testCase.expectErr != (resolveErr != nil) {
+				t.Fatalf("unexpected error state: %v", resolveErr)
+			}
+			if record.UserName != testCase.expectedUser {
+				t.Fatalf("expected username %q, got %q", testCase.expectedUser, record.UserName)
+			}
+			if fetcher.calls[cacheTestAccountIDSuccess] != testCase.expectedCalls {
+				t.Fatalf("expected %d fetches, got %d", testCase.expectedCalls, fetcher.calls[cacheTestAccountIDSuccess])
+			}
+		})
+	}
+}
+
+func TestResolverDoesNotCacheCancellation(t *testing.T) {
+	cache := handles.NewMemoryCache()
+	fetcher := newRecordingIntentFetcher(nil, map[string]error{cacheTestAccountIDCanceled: context.Canceled})
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: cache})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	if _, resolveErr := resolver.ResolveAccount(context.Background(), cacheTestAccountIDCanceled); resolveErr == nil {
+		t.Fatalf("expected cancellation error")
+	}
+	if _, found := cache.Lookup(cacheTestAccountIDCanceled); found {
+		t.Fatalf("expected cancellation to stay out of the cache")
+	}
+}
+
+func TestFileCachePersistsAcrossReopen(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), cacheTestFileName)
+	resolvedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	cache, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	cache.Store(cacheTestAccountIDSuccess, handles.CacheEntry{
+		Record:     handles.AccountRecord{AccountID: cacheTestAccountIDSuccess, UserName: cacheTestCachedUserName},
+		ResolvedAt: resolvedAt.Add(-time.Hour),
+	})
+	cache.Store(cacheTestAccountIDSuccess, handles.CacheEntry{
+		Record:     handles.AccountRecord{AccountID: cacheTestAccountIDSuccess, UserName: cacheTestFetchedUserName},
+		ResolvedAt: resolvedAt,
+	})
+	cache.Store(cacheTestAccountIDFailure, handles.CacheEntry{
+		Record:     handles.AccountRecord{AccountID: cacheTestAccountIDFailure},
+		Err:        errors.New(cacheTestFailureMessage),
+		ResolvedAt: resolvedAt,
+	})
+	if err := cache.Close(); err != nil {
+		t.Fatalf("close cache: %v", err)
+	}
+
+	file, err := os.OpenFile(cachePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open cache file: %v", err)
+	}
+	if _, err := file.WriteString(cacheTestMalformedLine); err != nil {
+		t.Fatalf("append malformed line: %v", err)
+	}
+	file.Close()
+
+	reopened, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		t.Fatalf("reopen cache: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Len() != 2 {
+		t.Fatalf("expected 2 cached accounts, got %d", reopened.Len())
+	}
+	successEntry, found := reopened.Lookup(cacheTestAccountIDSuccess)
+	if !found || successEntry.Record.UserName != cacheTestFetchedUserName || !successEntry.ResolvedAt.Equal(resolvedAt) {
+		t.Fatalf("unexpected success entry %+v", successEntry)
+	}
+	failureEntry, found := reopened.Lookup(cacheTestAccountIDFailure)
+	if !found || failureEntry.Err == nil || failureEntry.Err.Error() != cacheTestFailureMessage {
+		t.Fatalf("unexpected failure entry %+v", failureEntry)
+	}
+
+	contents, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("read cache file: %v", err)
+	}
+	if lines := strings.Count(string(contents), "\n"); lines != 2 {
+		t.Fatalf("expected compacted file with 2 lines, got %d", lines)
+	}
+}
@@ -0,0 +1,303 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	chromePoolDefaultSize             = 1
+	chromePoolDefaultPageTimeout      = 15 * time.Second
+	chromePoolPollInterval            = 100 * time.Millisecond
+	chromePoolDocumentSelector        = "html"
+	chromePoolProfileHeaderScript     = `document.querySelector('[` + extractAttributeTestID + `="` + extractTestIDUserName + `"]') !== null`
+	errMessageChromePoolClosed        = "chrome tab pool is closed"
+	errMessageChromePoolStart         = "start chrome"
+	errMessageChromePoolOpenTab       = "open chrome tab"
+	errMessageProfileHeaderTimeoutFmt = "no profile header appeared within %s"
+	errMessageChromePoolRenderFormat  = "render %s: %w"
+)
+
+var errChromePoolClosed = errors.New(errMessageChromePoolClosed)
+
+// ChromePoolConfig configures a ChromePoolFetcher instance.
+type ChromePoolConfig struct {
+	BinaryPath string
+	UserAgent  string
+	// PoolSize is the number of browser tabs rendering pages concurrently; one tab is used when zero.
+	PoolSize int
+	// PageTimeout bounds how long a tab waits for the profile header or a failure page; fifteen seconds apply when zero.
+	PageTimeout time.Duration
+	// RateLimit paces page loads across all tabs; a rendered rate-limit page pauses them like a 429 response and fails
+	// the lookup as rate limited.
+	RateLimit ratelimit.Config
+	// UserDataDir is a persistent Chrome profile, typically one the user has logged into once; the browser runs on a
+	// throwaway profile when empty.
+	UserDataDir string
+	// CookieFile is a Netscape cookie file whose cookies are set in the browser each time it starts. Cookie values
+	// are never logged or cached.
+	CookieFile string
+}
+
+// ChromePoolFetcher renders intent pages in a pool of long-lived headless Chrome tabs driven over the DevTools
+// protocol. The browser starts on first use, each tab waits for the profile header element rather than a fixed time
+// budget, and a tab that fails is closed and replaced by a fresh one.
+type ChromePoolFetcher struct {
+	binaryPath  string
+	userAgent   string
+	pageTimeout time.Duration
+	limiter     *ratelimit.Limiter
+	userDataDir string
+	cookies     []*http.Cookie
+
+	slots    chan struct{}
+	idleTabs chan *chromeTab
+
+	browserMutex    sync.Mutex
+	browserContext  context.Context
+	browserCancel   context.CancelFunc
+	allocatorCancel context.CancelFunc
+	closed          bool
+}
+
+// chromeTab is one browser tab; cancel closes it.
+type chromeTab struct {
+	context context.Context
+	cancel  context.CancelFunc
+}
+
+// NewChromePoolFetcher constructs a ChromePoolFetcher from configuration values. Chrome is not started until the
+// first page is fetched.
+func NewChromePoolFetcher(configuration ChromePoolConfig) (*ChromePoolFetcher, error) {
+	trimmedBinaryPath := strings.TrimSpace(configuration.BinaryPath)
+	if trimmedBinaryPath == "" {
+		return nil, errors.New(errMessageMissingChromeBinaryPath)
+	}
+	userAgent := strings.TrimSpace(configuration.UserAgent)
+	if userAgent == "" {
+		userAgent = defaultChromeUserAgent
+	}
+	poolSize := configuration.PoolSize
+	if poolSize <= 0 {
+		poolSize = chromePoolDefaultSize
+	}
+	pageTimeout := configuration.PageTimeout
+	if pageTimeout <= 0 {
+		pageTimeout = chromePoolDefaultPageTimeout
+	}
+	var cookies []*http.Cookie
+	if cookieFile := strings.TrimSpace(configuration.CookieFile); cookieFile != "" {
+		var cookieErr error
+		if cookies, cookieErr = ReadCookieFile(cookieFile); cookieErr != nil {
+			return nil, cookieErr
+		}
+	}
+	return &ChromePoolFetcher{
+		binaryPath:  trimmedBinaryPath,
+		userAgent:   userAgent,
+		pageTimeout: pageTimeout,
+		limiter:     ratelimit.New(configuration.RateLimit),
+		userDataDir: strings.TrimSpace(configuration.UserDataDir),
+		cookies:     cookies,
+		slots:       make(chan struct{}, poolSize),
+		idleTabs:    make(chan *chromeTab, poolSize),
+	}, nil
+}
+
+// FetchIntentPage renders the intent URL in a pooled tab and returns its HTML, from which the resolver extracts the
+// handle; a page left showing a login prompt fails with an error matching ErrLoginWall.
+func (fetcher *ChromePoolFetcher) FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error) {
+	select {
+	case fetcher.slots <- struct{}{}:
+	case <-ctx.Done():
+		return IntentPage{}, ctx.Err()
+	}
+	defer func() { <-fetcher.slots }()
+
+	if waitErr := fetcher.limiter.Wait(ctx); waitErr != nil {
+		if errors.Is(waitErr, ratelimit.ErrRateLimited) {
+			return IntentPage{}, newResolutionError(request.reference(), AccountStatusRateLimited, waitErr)
+		}
+		return IntentPage{}, waitErr
+	}
+
+	tab, tabErr := fetcher.acquireTab()
+	if tabErr != nil {
+		return IntentPage{}, tabErr
+	}
+	page, renderErr := fetcher.render(ctx, tab, request)
+	if renderErr != nil {
+		tab.cancel()
+		if ctx.Err() != nil {
+			return IntentPage{}, ctx.Err()
+		}
+		if errors.Is(renderErr, ErrLoginWall) {
+			return IntentPage{}, newResolutionError(request.reference(), AccountStatusTransient, renderErr)
+		}
+		return IntentPage{}, fmt.Errorf(errMessageChromePoolRenderFormat, request.URL, renderErr)
+	}
+	fetcher.releaseTab(tab)
+
+	if classifyIntentPage(page.HTML, request.AccountID) == AccountStatusRateLimited {
+		fetcher.limiter.Pause(ratelimit.DefaultRateLimitWait)
+		return IntentPage{}, newResolutionError(request.reference(), AccountStatusRateLimited, fmt.Errorf("%w: %s", errRateLimitPage, request.URL))
+	}
+	fetcher.limiter.RecordSuccess()
+	return page, nil
+}
+
+// render loads the requested URL and polls until the page shows the profile header or is recognised as a failure page.
+// A login prompt is only reported once the page budget runs out, since logged-out profiles show one too.
+func (fetcher *ChromePoolFetcher) render(ctx context.Context, tab *chromeTab, request IntentRequest) (IntentPage, error) {
+	requestURL := request.URL
+	renderContext, cancelRender := context.WithTimeout(tab.context, fetcher.pageTimeout)
+	defer cancelRender()
+	stopAfterCancel := context.AfterFunc(ctx, cancelRender)
+	defer stopAfterCancel()
+
+	page := IntentPage{SourceURL: requestURL}
+	waitForProfile := chromedp.ActionFunc(func(actionContext context.Context) error {
+		for {
+			var headerShown bool
+			if err := chromedp.Evaluate(chromePoolProfileHeaderScript, &headerShown).Do(actionContext); err != nil {
+				return err
+			}
+			if err := chromedp.OuterHTML(chromePoolDocumentSelector, &page.HTML, chromedp.ByQuery).Do(actionContext); err != nil {
+				return err
+			}
+			if headerShown || classifyIntentPage(page.HTML, request.AccountID) != "" {
+				return nil
+			}
+			if err := ratelimit.Sleep(actionContext, chromePoolPollInterval); err != nil {
+				return err
+			}
+		}
+	})
+	if err := chromedp.Run(renderContext, chromedp.Navigate(requestURL), waitForProfile); err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			// The page budget ran out, not the caller's; report it as a page failure rather than a cancellation.
+			if isLoginWall(page.HTML, request.AccountID) {
+				return IntentPage{}, fmt.Errorf("%w: %s", ErrLoginWall, requestURL)
+			}
+			return IntentPage{}, fmt.Errorf(errMessageProfileHeaderTimeoutFmt, fetcher.pageTimeout)
+		}
+		return IntentPage{}, err
+	}
+	if strings.TrimSpace(page.HTML) == "" {
+		return IntentPage{}, fmt.Errorf("%w: %s", errEmptyIntentHTML, requestURL)
+	}
+	return page, nil
+}
+
+// acquireTab returns an idle tab or opens a new one, starting the browser when needed. The caller holds a slot, so
+// at most PoolSize tabs exist at once.
+func (fetcher *ChromePoolFetcher) acquireTab() (*chromeTab, error) {
+	select {
+	case tab := <-fetcher.idleTabs:
+		return tab, nil
+	default:
+	}
+
+	fetcher.browserMutex.Lock()
+	defer fetcher.browserMutex.Unlock()
+	if fetcher.closed {
+		return nil, errChromePoolClosed
+	}
+	if fetcher.browserContext == nil {
+		if err := fetcher.startBrowser(); err != nil {
+			return nil, err
+		}
+	}
+	tabContext, tabCancel := chromedp.NewContext(fetcher.browserContext)
+	if err := chromedp.Run(tabContext); err != nil {
+		tabCancel()
+		// A browser that cannot open tabs has most likely exited; start a fresh one next time.
+		fetcher.stopBrowser()
+		return nil, fmt.Errorf("%s: %w", errMessageChromePoolOpenTab, err)
+	}
+	return &chromeTab{context: tabContext, cancel: tabCancel}, nil
+}
+
+// releaseTab returns a healthy tab to the pool.
+func (fetcher *ChromePoolFetcher) releaseTab(tab *chromeTab) {
+	fetcher.browserMutex.Lock()
+	closed := fetcher.closed
+	fetcher.browserMutex.Unlock()
+	if closed {
+		tab.cancel()
+		return
+	}
+	select {
+	case fetcher.idleTabs <- tab:
+	default:
+		tab.cancel()
+	}
+}
+
+// startBrowser launches Chrome; the caller holds browserMutex.
+func (fetcher *ChromePoolFetcher) startBrowser() error {
+	options := append(slices.Clone(chromedp.DefaultExecAllocatorOptions[:]),
+		chromedp.ExecPath(fetcher.binaryPath),
+		chromedp.UserAgent(fetcher.userAgent),
+		chromedp.DisableGPU,
+		chromedp.Flag("hide-scrollbars", true),
+	)
+	if fetcher.userDataDir != "" {
+		options = append(options, chromedp.UserDataDir(fetcher.userDataDir))
+	}
+	var startActions []chromedp.Action
+	if len(fetcher.cookies) > 0 {
+		startActions = append(startActions, network.SetCookies(chromeCookieParams(fetcher.cookies, time.Now())))
+	}
+	allocatorContext, allocatorCancel := chromedp.NewExecAllocator(context.Background(), options...)
+	browserContext, browserCancel := chromedp.NewContext(allocatorContext)
+	if err := chromedp.Run(browserContext, startActions...); err != nil {
+		browserCancel()
+		allocatorCancel()
+		return fmt.Errorf("%s: %w", errMessageChromePoolStart, err)
+	}
+	fetcher.browserContext = browserContext
+	fetcher.browserCancel = browserCancel
+	fetcher.allocatorCancel = allocatorCancel
+	return nil
+}
+
+// stopBrowser closes every idle tab and the browser; the caller holds browserMutex.
+func (fetcher *ChromePoolFetcher) stopBrowser() {
+	for drained := false; !drained; {
+		select {
+		case tab := <-fetcher.idleTabs:
+			tab.cancel()
+		default:
+			drained = true
+		}
+	}
+	if fetcher.browserContext == nil {
+		return
+	}
+	fetcher.browserCancel()
+	fetcher.allocatorCancel()
+	fetcher.browserContext = nil
+	fetcher.browserCancel = nil
+	fetcher.allocatorCancel = nil
+}
+
+// Close shuts the browser down. Fetches that are still running fail and later fetches report an error.
+func (fetcher *ChromePoolFetcher) Close() error {
+	fetcher.browserMutex.Lock()
+	defer fetcher.browserMutex.Unlock()
+	fetcher.closed = true
+	fetcher.stopBrowser()
+	return nil
+}
@@ -0,0 +1,168 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	chromePoolTestDelayedPath   = "/delayed"
+	chromePoolTestSuspendedPath = "/suspended"
+	chromePoolTestBlankPath     = "/blank"
+	chromePoolTestHeaderText    = "@delayed_user"
+	chromePoolTestPageTimeout   = 2 * time.Second
+	chromePoolTestSize          = 2
+	chromePoolTestSkipFormat    = "chrome pool test skipped because no Chrome binary is available: %v"
+
+	// chromePoolTestDelayedHTML links another profile straight away and adds the profile header well after the load
+	// event, as the live intent page does.
+	chromePoolTestDelayedHTML = `<html><head><title>Delayed User (@delayed_user) / X</title></head><body><a href="https://x.com/quoted_user">@quoted_user</a><script>
+setTimeout(() => { const header = document.createElement("div"); header.dataset.testid = "UserName"; header.textContent = "@delayed_user"; document.body.appendChild(header); }, 300);
+</script></body></html>`
+	chromePoolTestSuspendedText = "Account suspended"
+	chromePoolTestSuspendedHTML = `<html><body><a href="https://x.com/tos">Terms</a><p>Account suspended</p></body></html>`
+	chromePoolTestBlankHTML     = `<html><body><a href="https://x.com/home">Home</a><a href="https://x.com/quoted_user">@quoted_user</a></body></html>`
+)
+
+func newChromePoolTestServer() *httptest.Server {
+	pages := map[string]string{
+		chromePoolTestDelayedPath:   chromePoolTestDelayedHTML,
+		chromePoolTestSuspendedPath: chromePoolTestSuspendedHTML,
+		chromePoolTestBlankPath:     chromePoolTestBlankHTML,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		page, found := pages[request.URL.Path]
+		if !found {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = writer.Write([]byte(page))
+	}))
+}
+
+func newChromePoolTestFetcher(tb testing.TB, pageTimeout time.Duration) *handles.ChromePoolFetcher {
+	tb.Helper()
+	binaryPath, lookupErr := resolverIntegrationChromeBinaryPath()
+	if lookupErr != nil {
+		tb.Skipf(chromePoolTestSkipFormat, lookupErr)
+	}
+	fetcher, err := handles.NewChromePoolFetcher(handles.ChromePoolConfig{
+		BinaryPath:  binaryPath,
+		PoolSize:    chromePoolTestSize,
+		PageTimeout: pageTimeout,
+		RateLimit:   ratelimit.Config{RequestsPerSecond: -1},
+	})
+	if err != nil {
+		tb.Fatalf("create chrome pool fetcher: %v", err)
+	}
+	tb.Cleanup(func() { _ = fetcher.Close() })
+	return fetcher
+}
+
+func TestNewChromePoolFetcherRequiresBinary(t *testing.T) {
+	if _, err := handles.NewChromePoolFetcher(handles.ChromePoolConfig{BinaryPath: " "}); err == nil {
+		t.Fatalf("expected an error for an empty binary path")
+	}
+}
+
+func TestChromePoolFetcherRendersPages(t *testing.T) {
+	server := newChromePoolTestServer()
+	defer server.Close()
+	fetcher := newChromePoolTestFetcher(t, chromePoolTestPageTimeout)
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedText string
+		expectError  bool
+	}{
+		{name: "waits for a late profile header", path: chromePoolTestDelayedPath, expectedText: chromePoolTestHeaderText},
+		{name: "failure page ends the wait", path: chromePoolTestSuspendedPath, expectedText: chromePoolTestSuspendedText},
+		{name: "missing header times out", path: chromePoolTestBlankPath, expectError: true},
+		{name: "tab is replaced after a failure", path: chromePoolTestDelayedPath, expectedText: chromePoolTestHeaderText},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			page, err := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{URL: server.URL + testCase.path})
+			if testCase.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got page %q", page.HTML)
+				}
+				if errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("page timeouts must not look like caller cancellation: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(page.HTML, testCase.expectedText) {
+				t.Fatalf("expected the rendered page to contain %q, got %q", testCase.expectedText, page.HTML)
+			}
+		})
+	}
+}
+
+func TestChromePoolFetcherHonorsCancellation(t *testing.T) {
+	server := newChromePoolTestServer()
+	defer server.Close()
+	fetcher := newChromePoolTestFetcher(t, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := fetcher.FetchIntentPage(ctx, handles.IntentRequest{URL: server.URL + chromePoolTestBlankPath})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the caller deadline, got %v", err)
+	}
+}
+
+func benchmarkIntentFetcher(b *testing.B, fetcher handles.IntentFetcher, requestURL string) {
+	b.Helper()
+	// Warm up so that browser start-up is not counted against the pool.
+	if _, err := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{URL: requestURL}); err != nil {
+		b.Fatalf("warm up: %v", err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{URL: requestURL}); err != nil {
+				b.Errorf("fetch: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkChromeExecFetcher(b *testing.B) {
+	server := newChromePoolTestServer()
+	defer server.Close()
+	binaryPath, lookupErr := resolverIntegrationChromeBinaryPath()
+	if lookupErr != nil {
+		b.Skipf(chromePoolTestSkipFormat, lookupErr)
+	}
+	fetcher, err := handles.NewChromeIntentFetcher(handles.ChromeFetcherConfig{
+		BinaryPath:        binaryPath,
+		VirtualTimeBudget: time.Second,
+		RequestDelay:      -1,
+	})
+	if err != nil {
+		b.Fatalf("create chrome fetcher: %v", err)
+	}
+	benchmarkIntentFetcher(b, fetcher, server.URL+chromePoolTestDelayedPath)
+}
+
+func BenchmarkChromePoolFetcher(b *testing.B) {
+	server := newChromePoolTestServer()
+	defer server.Close()
+	fetcher := newChromePoolTestFetcher(b, chromePoolTestPageTimeout)
+	benchmarkIntentFetcher(b, fetcher, server.URL+chromePoolTestDelayedPath)
+}
@@ -0,0 +1,151 @@
+package handles
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	chromeUserDataDirFlagFormat = "--user-data-dir=%s"
+	cookieFileFieldSeparator    = "\t"
+	cookieFileFieldCount        = 7
+	cookieFileCommentPrefix     = "#"
+	cookieFileHTTPOnlyPrefix    = "#HttpOnly_"
+	cookieFileTrue              = "TRUE"
+	cookieFileDomainDot         = "."
+	// cookieSessionLifetime keeps session cookies alive in a persistent profile across the separate Chrome runs of
+	// the chrome backend.
+	cookieSessionLifetime = 24 * time.Hour
+
+	errMessageLoginWall            = "intent page asked to log in"
+	errMessageCookieFile           = "invalid cookie file"
+	errMessageCookieFileLineFormat = "%w: line %d"
+	errMessageCookieImport         = "import cookies into chrome"
+	errMessageCookiesNeedProfile   = "the chrome backend needs a user data dir to import cookies"
+)
+
+var (
+	// ErrLoginWall matches fetch errors for pages that showed a login prompt instead of the profile; a logged-in
+	// Chrome profile or imported session cookies usually get past it.
+	ErrLoginWall = errors.New(errMessageLoginWall)
+	// ErrCookieFile matches errors reading a Netscape cookie file. The errors name the offending line, never its
+	// content.
+	ErrCookieFile = errors.New(errMessageCookieFile)
+
+	errCookiesNeedProfile = errors.New(errMessageCookiesNeedProfile)
+
+	// loginWallPhrases lists lower-cased phrases of the login prompt shown instead of a profile to anonymous visitors.
+	loginWallPhrases = []string{"/i/flow/login", "log in to x", "sign in to x", "log in to twitter", "sign in to twitter"}
+)
+
+// ReadCookieFile reads cookies from a Netscape cookie file, the format written by curl and most cookie export
+// extensions. Lines prefixed with #HttpOnly_ hold HTTP-only cookies; other comments and blank lines are skipped.
+func ReadCookieFile(path string) ([]*http.Cookie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readNetscapeCookies(file)
+}
+
+func readNetscapeCookies(reader io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, cookieFileHTTPOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, cookieFileHTTPOnlyPrefix)
+		} else if strings.TrimSpace(line) == "" || strings.HasPrefix(line, cookieFileCommentPrefix) {
+			continue
+		}
+		fields := strings.Split(line, cookieFileFieldSeparator)
+		if len(fields) != cookieFileFieldCount || fields[0] == "" || fields[5] == "" {
+			return nil, fmt.Errorf(errMessageCookieFileLineFormat, ErrCookieFile, lineNumber)
+		}
+		expiresUnix, parseErr := strconv.ParseInt(fields[4], 10, 64)
+		if parseErr != nil {
+			return nil, fmt.Errorf(errMessageCookieFileLineFormat, ErrCookieFile, lineNumber)
+		}
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   fields[3] == cookieFileTrue,
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expiresUnix > 0 {
+			cookie.Expires = time.Unix(expiresUnix, 0).UTC()
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCookieFile, err)
+	}
+	return cookies, nil
+}
+
+// chromeCookieParams converts cookies for the DevTools protocol, giving session cookies a lifetime from now.
+func chromeCookieParams(cookies []*http.Cookie, now time.Time) []*network.CookieParam {
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, cookie := range cookies {
+		expires := cookie.Expires
+		if expires.IsZero() {
+			expires = now.Add(cookieSessionLifetime)
+		}
+		expiresAt := cdp.TimeSinceEpoch(expires)
+		params = append(params, &network.CookieParam{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HttpOnly,
+			Expires:  &expiresAt,
+		})
+	}
+	return params
+}
+
+// importChromeCookies starts Chrome on userDataDir, stores the cookies in the profile and closes the browser so that
+// later headless runs on the same profile send them.
+func importChromeCookies(ctx context.Context, binaryPath string, userDataDir string, cookies []*http.Cookie) error {
+	options := append(slices.Clone(chromedp.DefaultExecAllocatorOptions[:]),
+		chromedp.ExecPath(binaryPath),
+		chromedp.UserDataDir(userDataDir),
+	)
+	allocatorContext, allocatorCancel := chromedp.NewExecAllocator(ctx, options...)
+	defer allocatorCancel()
+	browserContext, browserCancel := chromedp.NewContext(allocatorContext)
+	if err := chromedp.Run(browserContext, network.SetCookies(chromeCookieParams(cookies, time.Now()))); err != nil {
+		browserCancel()
+		// The cause comes from Chrome and never carries cookie values.
+		return fmt.Errorf("%s: %w", errMessageCookieImport, err)
+	}
+	// Cancelling the first tab closes the browser gracefully, which flushes the cookie store to disk.
+	browserCancel()
+	return nil
+}
+
+// isLoginWall reports whether a rendered page shows a login prompt instead of the looked up profile.
+func isLoginWall(htmlContent string, accountID string) bool {
+	if _, err := ExtractProfile(htmlContent, accountID); err == nil {
+		return false
+	}
+	return containsAnyPhrase(normalizeIntentText(htmlContent), loginWallPhrases)
+}
@@ -0,0 +1,126 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/testing/fakex"
+)
+
+const (
+	cookieTestSecret   = "s3cr3t-session-value"
+	cookieTestFileName = "cookies.txt"
+	fakeXLoginWallID   = "70007"
+	fakeXWalledName    = "fake_walled"
+)
+
+func TestReadCookieFile(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedNames []string
+		expectedErr   error
+	}{
+		{
+			name: "netscape export",
+			content: "# Netscape HTTP Cookie File\n\n" +
+				".x.com\tTRUE\t/\tTRUE\t1893456000\tauth_token\t" + cookieTestSecret + "\n" +
+				"#HttpOnly_.x.com\tTRUE\t/\tTRUE\t0\tct0\tcsrf\r\n",
+			expectedNames: []string{"auth_token", "ct0"},
+		},
+		{
+			name:        "missing fields",
+			content:     ".x.com\tTRUE\t/\tTRUE\tauth_token\t" + cookieTestSecret + "\n",
+			expectedErr: handles.ErrCookieFile,
+		},
+		{
+			name:        "invalid expiry",
+			content:     ".x.com\tTRUE\t/\tTRUE\tsoon\tauth_token\t" + cookieTestSecret + "\n",
+			expectedErr: handles.ErrCookieFile,
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			cookiePath := filepath.Join(t.TempDir(), cookieTestFileName)
+			if err := os.WriteFile(cookiePath, []byte(testCase.content), 0o600); err != nil {
+				t.Fatalf("write cookie file: %v", err)
+			}
+			cookies, err := handles.ReadCookieFile(cookiePath)
+			if testCase.expectedErr != nil {
+				if !errors.Is(err, testCase.expectedErr) {
+					t.Fatalf("expected %v, got %v", testCase.expectedErr, err)
+				}
+				if strings.Contains(err.Error(), cookieTestSecret) {
+					t.Fatalf("error leaks the cookie value: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read cookie file: %v", err)
+			}
+			var names []string
+			for _, cookie := range cookies {
+				names = append(names, cookie.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(testCase.expectedNames, ",") {
+				t.Fatalf("expected cookies %v, got %v", testCase.expectedNames, names)
+			}
+			if !cookies[0].Secure || cookies[0].HttpOnly || !cookies[0].Expires.Equal(time.Unix(1893456000, 0)) {
+				t.Fatalf("unexpected first cookie %+v", cookies[0])
+			}
+			if !cookies[1].HttpOnly || !cookies[1].Expires.IsZero() {
+				t.Fatalf("expected an HTTP-only session cookie, got %+v", cookies[1])
+			}
+		})
+	}
+}
+
+func TestChromeFetcherNeedsAProfileForCookies(t *testing.T) {
+	cookiePath := filepath.Join(t.TempDir(), cookieTestFileName)
+	if err := os.WriteFile(cookiePath, []byte(".x.com\tTRUE\t/\tTRUE\t0\tauth_token\t"+cookieTestSecret+"\n"), 0o600); err != nil {
+		t.Fatalf("write cookie file: %v", err)
+	}
+	if _, err := handles.NewChromeIntentFetcher(handles.ChromeFetcherConfig{BinaryPath: "chrome", CookieFile: cookiePath}); err == nil {
+		t.Fatalf("expected cookies without a user data dir to be rejected")
+	}
+	if _, err := handles.NewChromeIntentFetcher(handles.ChromeFetcherConfig{BinaryPath: "chrome", CookieFile: cookiePath, UserDataDir: t.TempDir()}); err != nil {
+		t.Fatalf("create fetcher: %v", err)
+	}
+}
+
+func TestChromeBackendReportsLoginWalls(t *testing.T) {
+	server := fakex.NewServer([]fakex.Account{
+		{ID: fakeXActiveID, UserName: fakeXActiveUserName, DisplayName: fakeXActiveName},
+		{ID: fakeXLoginWallID, UserName: fakeXWalledName, Status: fakex.StatusLoginWall},
+	})
+	defer server.Close()
+	resolver := newFakeXResolver(t, server, handles.Config{
+		Backends:          []handles.Backend{handles.BackendChrome},
+		ChromeBinaryPath:  fakex.ChromeBinary(t),
+		ChromeUserDataDir: t.TempDir(),
+	})
+
+	results := resolver.ResolveMany(context.Background(), []string{fakeXActiveID, fakeXLoginWallID})
+	if result := results[fakeXActiveID]; result.Err != nil || result.Record.UserName != fakeXActiveUserName {
+		t.Fatalf("expected a logged-out profile page to resolve, got %+v", result)
+	}
+	if err := results[fakeXLoginWallID].Err; !errors.Is(err, handles.ErrLoginWall) {
+		t.Fatalf("expected a login wall, got %v", err)
+	}
+
+	fallback := newFakeXResolver(t, server, handles.Config{
+		Backends:         []handles.Backend{handles.BackendChrome, handles.BackendRedirect},
+		ChromeBinaryPath: fakex.ChromeBinary(t),
+	})
+	record, err := fallback.ResolveAccount(context.Background(), fakeXLoginWallID)
+	if err != nil || record.UserName != fakeXWalledName {
+		t.Fatalf("expected the redirect backend to answer past the login wall, got %+v, %v", record, err)
+	}
+}
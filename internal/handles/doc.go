@@ -1,2 +1,2 @@
-// Package handles resolves Twitter account handles and display names for numeric IDs.
+// Package handles resolves Twitter account handles and display names for numeric IDs, and numeric IDs for handles.
 package handles
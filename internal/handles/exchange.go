@@ -0,0 +1,492 @@
+package handles
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExchangeFormat names a file layout for resolution outcomes: the output of cmd/resolve, and of the xresolve and
+// cresolve tools it replaced.
+type ExchangeFormat string
+
+const (
+	// ExchangeFormatCSV is the id,handle,display_name,status,resolved_at_utc,source,error CSV written by cmd/resolve.
+	ExchangeFormatCSV ExchangeFormat = "csv"
+	// ExchangeFormatJSONL is the JSON Lines output of cmd/resolve, one object per account with the CSV columns as
+	// keys; resolved_at_utc is named resolved_at.
+	ExchangeFormatJSONL ExchangeFormat = "jsonl"
+	// ExchangeFormatXResolveCSV is the id,handle,resolved_at_utc,source,error CSV written by the former xresolve tool.
+	ExchangeFormatXResolveCSV ExchangeFormat = "xresolve-csv"
+	// ExchangeFormatCResolveCSV is the id,handle,display_name CSV written by the former cresolve tool with -csv.
+	ExchangeFormatCResolveCSV ExchangeFormat = "cresolve-csv"
+	// ExchangeFormatCResolveJSONL is the JSON Lines output of the former cresolve tool with -json.
+	ExchangeFormatCResolveJSONL ExchangeFormat = "cresolve-jsonl"
+
+	exchangeColumnID          = "id"
+	exchangeColumnHandle      = "handle"
+	exchangeColumnResolvedAt  = "resolved_at_utc"
+	exchangeColumnSource      = "source"
+	exchangeColumnError       = "error"
+	exchangeColumnDisplayName = "display_name"
+	exchangeColumnStatus      = "status"
+	exchangeJSONPrefix        = '{'
+	exchangeLeadingSpace      = " \t\r\n"
+	exchangeByteOrderMark     = "\ufeff"
+	exchangeMaxLineBytes      = 1 << 20
+
+	errMessageUnknownExchangeFormat = "unknown exchange format"
+	errMessageExchangeHeader        = "unrecognized exchange header"
+	errMessageExchangeLine          = "exchange line %d: %w"
+)
+
+var (
+	// ErrUnknownExchangeFormat indicates that an exchange format name is not recognized.
+	ErrUnknownExchangeFormat = errors.New(errMessageUnknownExchangeFormat)
+	// ErrExchangeHeader indicates that a CSV header matches none of the known schemas, or not the schema of the
+	// requested format.
+	ErrExchangeHeader = errors.New(errMessageExchangeHeader)
+
+	exchangeHeaders = map[ExchangeFormat][]string{
+		ExchangeFormatCSV:         {exchangeColumnID, exchangeColumnHandle, exchangeColumnDisplayName, exchangeColumnStatus, exchangeColumnResolvedAt, exchangeColumnSource, exchangeColumnError},
+		ExchangeFormatXResolveCSV: {exchangeColumnID, exchangeColumnHandle, exchangeColumnResolvedAt, exchangeColumnSource, exchangeColumnError},
+		ExchangeFormatCResolveCSV: {exchangeColumnID, exchangeColumnHandle, exchangeColumnDisplayName},
+	}
+)
+
+// AccountEntry pairs a cached outcome with the account it belongs to.
+type AccountEntry struct {
+	AccountID string
+	Entry     CacheEntry
+}
+
+// ExchangeSummary counts what MergeExchange did with the entries it was given.
+type ExchangeSummary struct {
+	// Read is the number of entries offered.
+	Read int
+	// Merged counts entries stored because they were newer than what the cache held.
+	Merged int
+	// Kept counts entries skipped because the cache held a newer outcome or a success they would only replace with
+	// a transient failure.
+	Kept int
+}
+
+// cresolveLine is one line of cresolve -json output.
+type cresolveLine struct {
+	ID          string `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	FromURL     string `json:"from_url"`
+	Err         string `json:"error,omitempty"`
+}
+
+// exchangeLine is one line of cmd/resolve JSON Lines output. cresolve lines decode into it too, leaving the status,
+// time and source empty.
+type exchangeLine struct {
+	ID          string `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Status      string `json:"status"`
+	ResolvedAt  string `json:"resolved_at"`
+	Source      string `json:"source"`
+	Err         string `json:"error"`
+}
+
+// ExchangeWriter writes entries one at a time, in the order they are given, so that output survives an interrupted
+// run.
+type ExchangeWriter struct {
+	format      ExchangeFormat
+	csvWriter   *csv.Writer
+	jsonEncoder *json.Encoder
+}
+
+// ParseExchangeFormat converts a format name into an ExchangeFormat. An empty name yields the empty format, which
+// ReadExchange detects from the content.
+func ParseExchangeFormat(value string) (ExchangeFormat, error) {
+	format := ExchangeFormat(strings.ToLower(strings.TrimSpace(value)))
+	switch format {
+	case "", ExchangeFormatCSV, ExchangeFormatJSONL, ExchangeFormatXResolveCSV, ExchangeFormatCResolveCSV, ExchangeFormatCResolveJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownExchangeFormat, value)
+	}
+}
+
+// ReadExchange parses exchanged outcomes into cache entries. An empty format is detected from the content: a leading {
+// selects JSON Lines, which read alike in both JSON formats, and a CSV header selects the schema it matches. Rows
+// without a resolution time are dated observedAt, which callers usually take from the file's modification time. Rows
+// with an error, or without a handle, become failures; without a status column, error texts naming a suspended or
+// deleted account keep that status.
+func ReadExchange(reader io.Reader, format ExchangeFormat, observedAt time.Time) ([]AccountEntry, error) {
+	bufferedReader := bufio.NewReader(reader)
+	if format == "" {
+		detected, err := detectExchangeFormat(bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+	switch format {
+	case ExchangeFormatJSONL, ExchangeFormatCResolveJSONL:
+		return readExchangeJSONL(bufferedReader, observedAt)
+	case ExchangeFormatCSV, ExchangeFormatXResolveCSV, ExchangeFormatCResolveCSV:
+		return readExchangeCSV(bufferedReader, format, observedAt)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExchangeFormat, format)
+	}
+}
+
+// ReadExchangeFile reads an exchange file with ReadExchange, dating undated rows with the file's modification time.
+func ReadExchangeFile(path string, format ExchangeFormat) ([]AccountEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	observedAt := time.Now().UTC()
+	if info, statErr := file.Stat(); statErr == nil {
+		observedAt = info.ModTime().UTC()
+	}
+	return ReadExchange(file, format, observedAt)
+}
+
+// MergeExchange stores entries into cache by recency: an entry replaces the cached outcome only when it is newer, and
+// a transient failure never replaces a success. Successes that are not stored are still added to the handle history
+// when the cache keeps one.
+func MergeExchange(cache Cache, entries []AccountEntry) ExchangeSummary {
+	summary := ExchangeSummary{Read: len(entries)}
+	historyStore, tracked := cache.(HistoryStore)
+	for _, accountEntry := range entries {
+		cachedEntry, found := cache.Lookup(accountEntry.AccountID)
+		if !found || supersedes(accountEntry.Entry, cachedEntry) {
+			cache.Store(accountEntry.AccountID, accountEntry.Entry)
+			summary.Merged++
+			continue
+		}
+		summary.Kept++
+		if observation, observed := resolvedObservation(accountEntry.Entry); observed && tracked {
+			historyStore.Observe(accountEntry.AccountID, observation)
+		}
+	}
+	return summary
+}
+
+// WriteExchange writes entries in the layout of format, ordered by account identifier. Failures are written only by
+// the formats that carry an error column; entries without a handle and without an error are skipped.
+func WriteExchange(writer io.Writer, format ExchangeFormat, entries []AccountEntry) error {
+	orderedEntries := append([]AccountEntry(nil), entries...)
+	sort.SliceStable(orderedEntries, func(firstIndex, secondIndex int) bool {
+		return accountIDLess(orderedEntries[firstIndex].AccountID, orderedEntries[secondIndex].AccountID)
+	})
+	exchangeWriter, err := NewExchangeWriter(writer, format, true)
+	if err != nil {
+		return err
+	}
+	for _, accountEntry := range orderedEntries {
+		if err := exchangeWriter.Write(accountEntry); err != nil {
+			return err
+		}
+	}
+	return exchangeWriter.Flush()
+}
+
+// NewExchangeWriter prepares writer for entries in the layout of format. The CSV header is written only when
+// writeHeader is set, so that output can be appended to an earlier file.
+func NewExchangeWriter(writer io.Writer, format ExchangeFormat, writeHeader bool) (*ExchangeWriter, error) {
+	exchangeWriter := &ExchangeWriter{format: format}
+	switch format {
+	case ExchangeFormatJSONL, ExchangeFormatCResolveJSONL:
+		exchangeWriter.jsonEncoder = json.NewEncoder(writer)
+	case ExchangeFormatCSV, ExchangeFormatXResolveCSV, ExchangeFormatCResolveCSV:
+		exchangeWriter.csvWriter = csv.NewWriter(writer)
+		if writeHeader {
+			if err := exchangeWriter.csvWriter.Write(exchangeHeaders[format]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExchangeFormat, format)
+	}
+	return exchangeWriter, nil
+}
+
+// Write writes one entry. Failures are written only by the formats that carry an error column; entries without a
+// handle and without an error are skipped.
+func (exchangeWriter *ExchangeWriter) Write(accountEntry AccountEntry) error {
+	switch exchangeWriter.format {
+	case ExchangeFormatJSONL:
+		line, exportable := exchangeLineOf(accountEntry)
+		if !exportable {
+			return nil
+		}
+		return exchangeWriter.jsonEncoder.Encode(line)
+	case ExchangeFormatCResolveJSONL:
+		line, exportable := cresolveLineOf(accountEntry)
+		if !exportable {
+			return nil
+		}
+		return exchangeWriter.jsonEncoder.Encode(line)
+	default:
+		row, exportable := exchangeRowOf(exchangeWriter.format, accountEntry)
+		if !exportable {
+			return nil
+		}
+		return exchangeWriter.csvWriter.Write(row)
+	}
+}
+
+// Flush writes any buffered CSV rows to the underlying writer.
+func (exchangeWriter *ExchangeWriter) Flush() error {
+	if exchangeWriter.csvWriter == nil {
+		return nil
+	}
+	exchangeWriter.csvWriter.Flush()
+	return exchangeWriter.csvWriter.Error()
+}
+
+// supersedes reports whether an imported entry should replace the cached one.
+func supersedes(imported CacheEntry, cached CacheEntry) bool {
+	if !imported.ResolvedAt.After(cached.ResolvedAt) {
+		return false
+	}
+	return imported.Err == nil || cached.Err != nil || StatusOf(imported.Err).IsGhost()
+}
+
+func detectExchangeFormat(reader *bufio.Reader) (ExchangeFormat, error) {
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", fmt.Errorf("%w: empty input", ErrExchangeHeader)
+			}
+			return "", err
+		}
+		switch {
+		case next[0] == exchangeJSONPrefix:
+			return ExchangeFormatJSONL, nil
+		case strings.ContainsRune(exchangeLeadingSpace, rune(next[0])):
+			_, _ = reader.ReadByte()
+			continue
+		}
+		line, err := reader.Peek(reader.Buffered())
+		if err != nil {
+			return "", err
+		}
+		header := normalizeExchangeHeader(strings.Split(strings.SplitN(string(line), "\n", 2)[0], ","))
+		for format, columns := range exchangeHeaders {
+			if equalColumns(header, columns) {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %q", ErrExchangeHeader, strings.Join(header, ","))
+	}
+}
+
+func readExchangeCSV(reader io.Reader, format ExchangeFormat, observedAt time.Time) ([]AccountEntry, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeHeader, err)
+	}
+	if columns := normalizeExchangeHeader(header); !equalColumns(columns, exchangeHeaders[format]) {
+		return nil, fmt.Errorf("%w: %q is not %s", ErrExchangeHeader, strings.Join(columns, ","), format)
+	}
+
+	var entries []AccountEntry
+	for {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var accountEntry AccountEntry
+		var valid bool
+		switch format {
+		case ExchangeFormatCSV:
+			accountEntry, valid = exchangeEntry(row[0], row[1], row[2], row[3], row[5], row[6], exchangeTime(row[4], observedAt))
+		case ExchangeFormatXResolveCSV:
+			accountEntry, valid = exchangeEntry(row[0], row[1], "", "", row[3], row[4], exchangeTime(row[2], observedAt))
+		default:
+			accountEntry, valid = exchangeEntry(row[0], row[1], row[2], "", string(BackendChrome), "", observedAt)
+		}
+		if valid {
+			entries = append(entries, accountEntry)
+		}
+	}
+}
+
+func readExchangeJSONL(reader io.Reader, observedAt time.Time) ([]AccountEntry, error) {
+	var entries []AccountEntry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), exchangeMaxLineBytes)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var line exchangeLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf(errMessageExchangeLine, lineNumber, err)
+		}
+		if line.Source == "" {
+			line.Source = string(BackendChrome)
+		}
+		if accountEntry, valid := exchangeEntry(line.ID, line.Handle, line.DisplayName, line.Status, line.Source, line.Err, exchangeTime(line.ResolvedAt, observedAt)); valid {
+			entries = append(entries, accountEntry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// exchangeEntry builds the cache entry of one exchanged row, rejecting rows without a numeric account identifier. An
+// empty status is recovered from the error text.
+func exchangeEntry(accountID string, userName string, displayName string, statusText string, origin string, errorText string, resolvedAt time.Time) (AccountEntry, bool) {
+	accountID = strings.TrimSpace(accountID)
+	if !isNumericID(accountID) {
+		return AccountEntry{}, false
+	}
+	record := AccountRecord{AccountID: accountID}
+	handle, validHandle := NormalizeHandle(userName)
+	errorText = strings.TrimSpace(errorText)
+	status := AccountStatus(strings.TrimSpace(statusText))
+	if errorText != "" || !validHandle {
+		recoveredStatus, cause := exchangeErrorStatus(errorText)
+		if _, known := statusErrors[status]; !known {
+			status = recoveredStatus
+		}
+		if status.IsGhost() {
+			record = record.withFailureStatus(status)
+		}
+		return AccountEntry{AccountID: accountID, Entry: CacheEntry{Record: record, Err: newResolutionError(accountID, status, cause), ResolvedAt: resolvedAt}}, true
+	}
+	provenance := FieldProvenance{Source: LabelSourceLiveFetch, Origin: strings.TrimSpace(origin), ObservedAt: resolvedAt}
+	record = record.WithUserName(handle, provenance)
+	if status == AccountStatusProtected {
+		record.Status = status
+	}
+	if trimmedDisplayName := strings.TrimSpace(displayName); trimmedDisplayName != "" {
+		record = record.WithDisplayName(trimmedDisplayName, provenance)
+	}
+	return AccountEntry{AccountID: accountID, Entry: CacheEntry{Record: record, ResolvedAt: resolvedAt}}, true
+}
+
+// exchangeErrorStatus recovers the status of an exported failure from its text; anything else is transient.
+func exchangeErrorStatus(errorText string) (AccountStatus, error) {
+	loweredText := strings.ToLower(errorText)
+	for _, status := range []AccountStatus{AccountStatusSuspended, AccountStatusDoesNotExist, AccountStatusRateLimited} {
+		if strings.Contains(loweredText, statusErrors[status].Error()) {
+			return status, nil
+		}
+	}
+	if errorText == "" {
+		return AccountStatusTransient, errMissingHandle
+	}
+	return AccountStatusTransient, errors.New(errorText)
+}
+
+// exchangeTime parses an RFC 3339 resolution time, falling back to observedAt.
+func exchangeTime(value string, observedAt time.Time) time.Time {
+	if parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil {
+		return parsed.UTC()
+	}
+	return observedAt
+}
+
+func exchangeRowOf(format ExchangeFormat, accountEntry AccountEntry) ([]string, bool) {
+	record := accountEntry.Entry.Record
+	if format == ExchangeFormatCResolveCSV {
+		if accountEntry.Entry.Err != nil || record.UserName == "" {
+			return nil, false
+		}
+		return []string{accountEntry.AccountID, record.UserName, record.DisplayName}, true
+	}
+	line, exportable := exchangeLineOf(accountEntry)
+	if !exportable {
+		return nil, false
+	}
+	if format == ExchangeFormatXResolveCSV {
+		return []string{line.ID, line.Handle, line.ResolvedAt, line.Source, line.Err}, true
+	}
+	return []string{line.ID, line.Handle, line.DisplayName, line.Status, line.ResolvedAt, line.Source, line.Err}, true
+}
+
+// exchangeLineOf describes an entry in the columns of cmd/resolve output; failures keep their status and error text.
+func exchangeLineOf(accountEntry AccountEntry) (exchangeLine, bool) {
+	record := accountEntry.Entry.Record
+	line := exchangeLine{
+		ID:          accountEntry.AccountID,
+		Handle:      record.UserName,
+		DisplayName: record.DisplayName,
+		Status:      string(record.Status),
+		ResolvedAt:  accountEntry.Entry.ResolvedAt.UTC().Format(time.RFC3339),
+		Source:      string(LabelSourceResolverCache),
+	}
+	if provenance, known := record.UserNameProvenance(); known && provenance.Origin != "" {
+		line.Source = provenance.Origin
+	}
+	if accountEntry.Entry.Err != nil {
+		line.Handle, line.DisplayName = "", ""
+		line.Status = string(StatusOf(accountEntry.Entry.Err))
+		if line.Status == "" {
+			line.Status = string(AccountStatusTransient)
+		}
+		line.Err = accountEntry.Entry.Err.Error()
+		return line, true
+	}
+	return line, record.UserName != ""
+}
+
+func cresolveLineOf(accountEntry AccountEntry) (cresolveLine, bool) {
+	record := accountEntry.Entry.Record
+	line := cresolveLine{
+		ID:          accountEntry.AccountID,
+		Handle:      record.UserName,
+		DisplayName: record.DisplayName,
+		FromURL:     defaultIntentBaseURLString + fmt.Sprintf(intentPathFormat, accountEntry.AccountID),
+	}
+	if accountEntry.Entry.Err != nil {
+		line.Handle, line.DisplayName, line.Err = "", "", accountEntry.Entry.Err.Error()
+		return line, true
+	}
+	return line, record.UserName != ""
+}
+
+func normalizeExchangeHeader(header []string) []string {
+	columns := make([]string, 0, len(header))
+	for _, column := range header {
+		columns = append(columns, strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, exchangeByteOrderMark))))
+	}
+	return columns
+}
+
+func equalColumns(first []string, second []string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for index := range first {
+		if first[index] != second[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// accountIDLess orders numeric account identifiers by value without parsing them.
+func accountIDLess(first string, second string) bool {
+	if len(first) != len(second) {
+		return len(first) < len(second)
+	}
+	return first < second
+}
@@ -0,0 +1,227 @@
+package handles_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	exchangeTestXResolveCSV = "id,handle,resolved_at_utc,source,error\n" +
+		"50001,old_name,2023-01-02T03:04:05Z,redirect,\n" +
+		"50002,,2023-01-02T03:04:05Z,redirect,no Location header\n" +
+		"50003,,2023-01-02T03:04:05Z,redirect,account 50003: account suspended\n" +
+		"not-an-id,ignored,2023-01-02T03:04:05Z,redirect,\n"
+	exchangeTestCResolveCSV   = "id,handle,display_name\n50001,new_name,New Name\n50004,,\n"
+	exchangeTestCResolveJSONL = "{\"id\":\"50001\",\"handle\":\"new_name\",\"display_name\":\"New Name\",\"from_url\":\"https://x.com/intent/user?user_id=50001\"}\n" +
+		"\n{\"id\":\"50005\",\"from_url\":\"https://x.com/intent/user?user_id=50005\",\"error\":\"rate limited\"}\n"
+	exchangeTestResolveCSV = "id,handle,display_name,status,resolved_at_utc,source,error\n" +
+		"50001,locked_name,Locked Name,protected,2024-02-03T04:05:06Z,api,\n" +
+		"50006,,,does-not-exist,2024-02-03T04:05:06Z,redirect,account 50006: gone\n"
+	exchangeTestUnknownHeader = "account,name\n1,a\n"
+)
+
+func TestReadExchangeDetectsFormats(t *testing.T) {
+	observedAt := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		input         string
+		format        handles.ExchangeFormat
+		expectedIDs   []string
+		expectedNames map[string]string
+		expectedTimes map[string]time.Time
+		failedIDs     map[string]handles.AccountStatus
+	}{
+		{
+			name:          "xresolve csv",
+			input:         exchangeTestXResolveCSV,
+			expectedIDs:   []string{"50001", "50002", "50003"},
+			expectedNames: map[string]string{"50001": "old_name"},
+			expectedTimes: map[string]time.Time{"50001": time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)},
+			failedIDs:     map[string]handles.AccountStatus{"50002": handles.AccountStatusTransient, "50003": handles.AccountStatusSuspended},
+		},
+		{
+			name:          "cresolve csv",
+			input:         exchangeTestCResolveCSV,
+			expectedIDs:   []string{"50001", "50004"},
+			expectedNames: map[string]string{"50001": "new_name"},
+			expectedTimes: map[string]time.Time{"50001": observedAt},
+			failedIDs:     map[string]handles.AccountStatus{"50004": handles.AccountStatusTransient},
+		},
+		{
+			name:          "cresolve jsonl",
+			input:         exchangeTestCResolveJSONL,
+			expectedIDs:   []string{"50001", "50005"},
+			expectedNames: map[string]string{"50001": "new_name"},
+			expectedTimes: map[string]time.Time{"50001": observedAt},
+			failedIDs:     map[string]handles.AccountStatus{"50005": handles.AccountStatusTransient},
+		},
+		{
+			name:          "resolve csv",
+			input:         exchangeTestResolveCSV,
+			expectedIDs:   []string{"50001", "50006"},
+			expectedNames: map[string]string{"50001": "locked_name"},
+			expectedTimes: map[string]time.Time{"50001": time.Date(2024, time.February, 3, 4, 5, 6, 0, time.UTC)},
+			failedIDs:     map[string]handles.AccountStatus{"50006": handles.AccountStatusDoesNotExist},
+		},
+		{
+			name:          "explicit format",
+			input:         exchangeTestCResolveCSV,
+			format:        handles.ExchangeFormatCResolveCSV,
+			expectedIDs:   []string{"50001", "50004"},
+			expectedNames: map[string]string{"50001": "new_name"},
+			failedIDs:     map[string]handles.AccountStatus{"50004": handles.AccountStatusTransient},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			entries, err := handles.ReadExchange(strings.NewReader(testCase.input), testCase.format, observedAt)
+			if err != nil {
+				t.Fatalf("read exchange: %v", err)
+			}
+			var accountIDs []string
+			for _, accountEntry := range entries {
+				accountIDs = append(accountIDs, accountEntry.AccountID)
+				if expectedName, resolved := testCase.expectedNames[accountEntry.AccountID]; resolved {
+					if accountEntry.Entry.Err != nil || accountEntry.Entry.Record.UserName != expectedName {
+						t.Fatalf("unexpected entry for %s: %+v", accountEntry.AccountID, accountEntry.Entry)
+					}
+					provenance, known := accountEntry.Entry.Record.UserNameProvenance()
+					if !known || provenance.Source != handles.LabelSourceLiveFetch {
+						t.Fatalf("expected live-fetch provenance, got %+v", provenance)
+					}
+				}
+				if expectedTime, dated := testCase.expectedTimes[accountEntry.AccountID]; dated && !accountEntry.Entry.ResolvedAt.Equal(expectedTime) {
+					t.Fatalf("expected %s to be dated %v, got %v", accountEntry.AccountID, expectedTime, accountEntry.Entry.ResolvedAt)
+				}
+				if expectedStatus, failed := testCase.failedIDs[accountEntry.AccountID]; failed && handles.StatusOf(accountEntry.Entry.Err) != expectedStatus {
+					t.Fatalf("expected %s to fail with %s, got %v", accountEntry.AccountID, expectedStatus, accountEntry.Entry.Err)
+				}
+			}
+			if !reflect.DeepEqual(accountIDs, testCase.expectedIDs) {
+				t.Fatalf("expected accounts %v, got %v", testCase.expectedIDs, accountIDs)
+			}
+		})
+	}
+}
+
+func TestReadExchangeRejectsUnknownHeaders(t *testing.T) {
+	if _, err := handles.ReadExchange(strings.NewReader(exchangeTestUnknownHeader), "", time.Now()); !errors.Is(err, handles.ErrExchangeHeader) {
+		t.Fatalf("expected ErrExchangeHeader, got %v", err)
+	}
+	if _, err := handles.ReadExchange(strings.NewReader(exchangeTestCResolveCSV), handles.ExchangeFormatXResolveCSV, time.Now()); !errors.Is(err, handles.ErrExchangeHeader) {
+		t.Fatalf("expected a schema mismatch to fail, got %v", err)
+	}
+	if _, err := handles.ParseExchangeFormat("tsv"); !errors.Is(err, handles.ErrUnknownExchangeFormat) {
+		t.Fatalf("expected ErrUnknownExchangeFormat, got %v", err)
+	}
+}
+
+func TestMergeExchangeKeepsTheMostRecentOutcome(t *testing.T) {
+	older := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(1, 0, 0)
+	cache := handles.NewMemoryCache()
+	success := func(userName string, resolvedAt time.Time) handles.AccountEntry {
+		record := handles.AccountRecord{AccountID: historyTestAccountID}.WithUserName(userName, handles.FieldProvenance{Source: handles.LabelSourceLiveFetch, ObservedAt: resolvedAt})
+		return handles.AccountEntry{AccountID: historyTestAccountID, Entry: handles.CacheEntry{Record: record, ResolvedAt: resolvedAt}}
+	}
+	failure := handles.AccountEntry{AccountID: historyTestAccountID, Entry: handles.CacheEntry{
+		Record:     handles.AccountRecord{AccountID: historyTestAccountID},
+		Err:        &handles.ResolutionError{AccountID: historyTestAccountID, Status: handles.AccountStatusTransient},
+		ResolvedAt: newer.AddDate(0, 1, 0),
+	}}
+
+	summary := handles.MergeExchange(cache, []handles.AccountEntry{success(historyTestNewUserName, newer), success(historyTestOldUserName, older), failure})
+	if summary != (handles.ExchangeSummary{Read: 3, Merged: 1, Kept: 2}) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	entry, found := cache.Lookup(historyTestAccountID)
+	if !found || entry.Err != nil || entry.Record.UserName != historyTestNewUserName {
+		t.Fatalf("expected the newest success to win, got %+v", entry)
+	}
+	if former := handles.FormerUserNames(cache.History(historyTestAccountID), historyTestNewUserName); !reflect.DeepEqual(former, []string{historyTestOldUserName}) {
+		t.Fatalf("expected the older handle in the history, got %v", former)
+	}
+}
+
+func TestWriteExchangeRoundTrips(t *testing.T) {
+	observedAt := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := handles.ReadExchange(strings.NewReader(exchangeTestXResolveCSV), "", observedAt)
+	if err != nil {
+		t.Fatalf("read exchange: %v", err)
+	}
+	for _, format := range []handles.ExchangeFormat{handles.ExchangeFormatCSV, handles.ExchangeFormatJSONL, handles.ExchangeFormatXResolveCSV, handles.ExchangeFormatCResolveCSV, handles.ExchangeFormatCResolveJSONL} {
+		var buffer bytes.Buffer
+		if err := handles.WriteExchange(&buffer, format, entries); err != nil {
+			t.Fatalf("%s: write exchange: %v", format, err)
+		}
+		reread, err := handles.ReadExchange(&buffer, "", observedAt)
+		if err != nil {
+			t.Fatalf("%s: reread exchange: %v", format, err)
+		}
+		statuses := make(map[string]handles.AccountStatus)
+		for _, accountEntry := range reread {
+			statuses[accountEntry.AccountID] = handles.StatusOf(accountEntry.Entry.Err)
+			if accountEntry.AccountID == "50001" && accountEntry.Entry.Record.UserName != "old_name" {
+				t.Fatalf("%s: lost the handle, got %+v", format, accountEntry.Entry)
+			}
+		}
+		if format == handles.ExchangeFormatCResolveCSV {
+			if len(reread) != 1 {
+				t.Fatalf("%s: expected failures to be left out, got %+v", format, reread)
+			}
+			continue
+		}
+		if statuses["50003"] != handles.AccountStatusSuspended || statuses["50002"] != handles.AccountStatusTransient {
+			t.Fatalf("%s: expected failure statuses to survive, got %v", format, statuses)
+		}
+	}
+}
+
+func TestOfflineResolverAnswersOnlyFromTheCache(t *testing.T) {
+	cache := handles.NewMemoryCache()
+	record := handles.AccountRecord{AccountID: historyTestAccountID}.WithUserName(historyTestOldUserName, handles.FieldProvenance{Source: handles.LabelSourceLiveFetch})
+	cache.Store(historyTestAccountID, handles.CacheEntry{Record: record, ResolvedAt: time.Now().AddDate(-5, 0, 0)})
+	fetcher := newRecordingIntentFetcher(nil, nil)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: cache, Offline: true})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	var skipped int
+	ctx := handles.WithProgress(context.Background(), func(event handles.ProgressEvent) {
+		if event.Kind == handles.ProgressSkipped && errors.Is(event.Err, handles.ErrNotCached) {
+			skipped++
+		}
+	})
+	results := resolver.ResolveMany(ctx, []string{historyTestAccountID, "50009"})
+	if result := results[historyTestAccountID]; result.Err != nil || result.Record.UserName != historyTestOldUserName {
+		t.Fatalf("expected the stale entry to be served, got %+v", result)
+	}
+	if result := results["50009"]; !errors.Is(result.Err, handles.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached, got %+v", result)
+	}
+	if skipped != 1 {
+		t.Fatalf("expected one skipped account, got %d", skipped)
+	}
+	if _, err := resolver.ResolveHandle(context.Background(), historyTestOldUserName); err != nil {
+		t.Fatalf("expected the handle index to answer, got %v", err)
+	}
+	if _, err := resolver.ResolveHandle(context.Background(), historyTestNewUserName); !errors.Is(err, handles.ErrNotCached) {
+		t.Fatalf("expected ErrNotCached for an unknown handle, got %v", err)
+	}
+	if _, found := cache.Lookup("50009"); found {
+		t.Fatalf("expected misses to stay uncached")
+	}
+	if len(fetcher.calls) != 0 {
+		t.Fatalf("expected no fetches, got %v", fetcher.calls)
+	}
+}
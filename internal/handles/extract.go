@@ -0,0 +1,503 @@
+package handles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ExtractionRule names the page feature that supplied a handle.
+type ExtractionRule string
+
+const (
+	// ExtractionRuleCanonical reads the handle from <link rel="canonical">.
+	ExtractionRuleCanonical ExtractionRule = "canonical"
+	// ExtractionRuleOpenGraphURL reads the handle from the og:url meta property.
+	ExtractionRuleOpenGraphURL ExtractionRule = "og:url"
+	// ExtractionRuleOpenGraphTitle reads the handle from the "(@handle)" part of the og:title meta property.
+	ExtractionRuleOpenGraphTitle ExtractionRule = "og:title"
+	// ExtractionRuleJSONLD reads the handle from the Person entity of a ProfilePage JSON-LD block.
+	ExtractionRuleJSONLD ExtractionRule = "json-ld"
+	// ExtractionRuleTestID reads the handle from the element marked data-testid="UserName".
+	ExtractionRuleTestID ExtractionRule = "data-testid"
+	// ExtractionRuleProfileLink falls back to the first link to a profile anywhere on the page.
+	ExtractionRuleProfileLink ExtractionRule = "profile-link"
+
+	extractRelCanonical          = "canonical"
+	extractPropertyOpenGraphURL  = "og:url"
+	extractPropertyOGTitle       = "og:title"
+	extractPropertyOGImage       = "og:image"
+	extractPropertyOGDescription = "og:description"
+	extractAvatarPathMarker      = "profile_images"
+	extractStatisticFollowers    = "Follows"
+	extractStatisticFollowing    = "Friends"
+	extractTestIDVerifiedIcon    = "icon-verified"
+	extractTestIDProtectedIcon   = "icon-lock"
+	extractScriptTypeJSONLD      = "application/ld+json"
+	extractTestIDUserName        = "UserName"
+	extractAttributeRel          = "rel"
+	extractAttributeHref         = "href"
+	extractAttributeProperty     = "property"
+	extractAttributeName         = "name"
+	extractAttributeContent      = "content"
+	extractAttributeType         = "type"
+	extractAttributeTestID       = "data-testid"
+	extractHandlePrefix          = "@"
+	extractTitleHandleOpen       = "(@"
+	extractTitleHandleClose      = ")"
+	extractHostPrefixWWW         = "www."
+	extractHostPrefixMobile      = "mobile."
+	extractHandlePattern         = `^[A-Za-z0-9_]{1,15}$`
+	extractAccountIDBoundaryFmt  = `(^|[^0-9])%s([^0-9]|$)`
+	extractRestIDPattern         = `"rest_id"\s*:\s*"([0-9]+)"`
+	extractScreenNameFmt         = `"screen_name"\s*:\s*"(?i:%s)"`
+	errMessageAccountIDMismatch  = "page describes a different account"
+	errMessageMismatchDetailsFmt = "%w: expected %s, page names %s"
+)
+
+var (
+	// ErrAccountIDMismatch indicates that a page carries structured data for an account other than the requested one.
+	ErrAccountIDMismatch = errors.New(errMessageAccountIDMismatch)
+
+	extractHandleRegex = regexp.MustCompile(extractHandlePattern)
+	extractRestIDRegex = regexp.MustCompile(extractRestIDPattern)
+
+	profileHosts = map[string]struct{}{"x.com": {}, "twitter.com": {}}
+)
+
+// ProfileExtraction is what ExtractProfile learned from a rendered intent or profile page.
+type ProfileExtraction struct {
+	UserName    string
+	DisplayName string
+	// Rule is the rule that supplied UserName.
+	Rule ExtractionRule
+	// AccountIDVerified reports whether the page references the requested account ID.
+	AccountIDVerified bool
+	// AccountID is the numeric identifier of the profile: the requested one when it was verified, and otherwise the
+	// JSON-LD identifier or the rest_id that the page's embedded data gives next to the handle. It is empty when the
+	// page names no identifier for the handle.
+	AccountID string
+	// Profile holds the avatar, bio, badges and counts the page shows; FetchedAt is left for the caller to set.
+	Profile Profile
+}
+
+// ExtractProfile reads the handle and display name of accountID from a rendered page. Handle rules are tried in
+// order: canonical link, og:url, og:title, JSON-LD, data-testid, and finally the first profile link on the page.
+// Display names come from JSON-LD, data-testid, og:title, or <title>, cutting titles at "(@handle)" so that localized
+// suffixes do not matter. Profile details come from JSON-LD, the og:image and og:description of the profile, and the
+// badges inside the data-testid="UserName" element. A page whose JSON-LD names a different account fails with
+// ErrAccountIDMismatch; a page that does not mention any account ID is accepted with AccountIDVerified unset.
+func ExtractProfile(htmlContent string, accountID string) (ProfileExtraction, error) {
+	document, parseErr := html.Parse(strings.NewReader(htmlContent))
+	if parseErr != nil {
+		return ProfileExtraction{}, fmt.Errorf("parse page: %w", parseErr)
+	}
+	facts := collectPageFacts(document)
+
+	extraction := ProfileExtraction{AccountIDVerified: facts.referencesAccountID(accountID)}
+	if person, found := facts.person(); found && person.Identifier != "" && accountID != "" {
+		if person.Identifier != accountID {
+			return ProfileExtraction{}, fmt.Errorf(errMessageMismatchDetailsFmt, ErrAccountIDMismatch, accountID, person.Identifier)
+		}
+		extraction.AccountIDVerified = true
+	}
+
+	handleRules := []struct {
+		rule   ExtractionRule
+		handle func() string
+	}{
+		{rule: ExtractionRuleCanonical, handle: func() string { return handleFromProfileURL(facts.canonicalURL) }},
+		{rule: ExtractionRuleOpenGraphURL, handle: func() string { return handleFromProfileURL(facts.openGraphURL) }},
+		{rule: ExtractionRuleOpenGraphTitle, handle: func() string { return handleFromTitle(facts.openGraphTitle) }},
+		{rule: ExtractionRuleJSONLD, handle: func() string {
+			person, _ := facts.person()
+			return validHandle(person.AdditionalName)
+		}},
+		{rule: ExtractionRuleTestID, handle: func() string {
+			handle, _ := facts.testIDUserName()
+			return handle
+		}},
+		{rule: ExtractionRuleProfileLink, handle: func() string {
+			for _, href := range facts.linkURLs {
+				if handle := handleFromProfileURL(href); handle != "" {
+					return handle
+				}
+			}
+			return ""
+		}},
+	}
+	for _, handleRule := range handleRules {
+		if handle := handleRule.handle(); handle != "" {
+			extraction.UserName = handle
+			extraction.Rule = handleRule.rule
+			break
+		}
+	}
+	if extraction.UserName == "" {
+		return extraction, errMissingHandle
+	}
+
+	extraction.DisplayName = facts.displayName(extraction.UserName)
+	extraction.Profile = facts.profile(extraction.UserName)
+	extraction.AccountID = accountID
+	if !extraction.AccountIDVerified {
+		extraction.AccountID = facts.accountID(extraction.UserName)
+	}
+	return extraction, nil
+}
+
+// pageFacts holds the parts of a page the extraction rules look at.
+type pageFacts struct {
+	canonicalURL   string
+	openGraphURL   string
+	openGraphTitle string
+	openGraphImage string
+	openGraphBio   string
+	title          string
+	linkURLs       []string
+	jsonLDBlocks   []string
+	testIDTexts    []string
+	verifiedBadge  bool
+	protectedBadge bool
+	// machineText collects attribute values and script bodies, where account IDs appear.
+	machineText []string
+}
+
+// jsonLDPerson is the subset of a schema.org Person used by X profile pages.
+type jsonLDPerson struct {
+	AdditionalName       string              `json:"additionalName"`
+	GivenName            string              `json:"givenName"`
+	Name                 string              `json:"name"`
+	Identifier           string              `json:"identifier"`
+	Description          string              `json:"description"`
+	Image                jsonLDImage         `json:"image"`
+	InteractionStatistic []jsonLDInteraction `json:"interactionStatistic"`
+}
+
+// jsonLDImage accepts both an ImageObject and a bare URL; images of any other shape are ignored.
+type jsonLDImage struct {
+	ContentURL   string `json:"contentUrl"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+func (image *jsonLDImage) UnmarshalJSON(data []byte) error {
+	var imageURL string
+	if json.Unmarshal(data, &imageURL) == nil {
+		image.ContentURL = imageURL
+		return nil
+	}
+	type imageObject jsonLDImage
+	var object imageObject
+	if json.Unmarshal(data, &object) == nil {
+		*image = jsonLDImage(object)
+	}
+	return nil
+}
+
+func (image jsonLDImage) url() string {
+	if strings.TrimSpace(image.ContentURL) != "" {
+		return strings.TrimSpace(image.ContentURL)
+	}
+	return strings.TrimSpace(image.ThumbnailURL)
+}
+
+type jsonLDInteraction struct {
+	Name                 string `json:"name"`
+	UserInteractionCount *int   `json:"userInteractionCount"`
+}
+
+type jsonLDProfilePage struct {
+	Type       string        `json:"@type"`
+	MainEntity *jsonLDPerson `json:"mainEntity"`
+	Author     *jsonLDPerson `json:"author"`
+}
+
+func collectPageFacts(document *html.Node) pageFacts {
+	var facts pageFacts
+	var visit func(node *html.Node, insideUserName bool)
+	visit = func(node *html.Node, insideUserName bool) {
+		switch node.Type {
+		case html.TextNode:
+			if insideUserName {
+				if text := strings.TrimSpace(node.Data); text != "" {
+					facts.testIDTexts = append(facts.testIDTexts, text)
+				}
+			}
+		case html.ElementNode:
+			for _, attribute := range node.Attr {
+				facts.machineText = append(facts.machineText, attribute.Val)
+			}
+			switch node.DataAtom {
+			case atom.Link:
+				if hasToken(attributeValue(node, extractAttributeRel), extractRelCanonical) && facts.canonicalURL == "" {
+					facts.canonicalURL = attributeValue(node, extractAttributeHref)
+				}
+			case atom.Meta:
+				property := attributeValue(node, extractAttributeProperty)
+				if property == "" {
+					property = attributeValue(node, extractAttributeName)
+				}
+				switch {
+				case property == extractPropertyOpenGraphURL && facts.openGraphURL == "":
+					facts.openGraphURL = attributeValue(node, extractAttributeContent)
+				case property == extractPropertyOGTitle && facts.openGraphTitle == "":
+					facts.openGraphTitle = attributeValue(node, extractAttributeContent)
+				case property == extractPropertyOGImage && facts.openGraphImage == "":
+					facts.openGraphImage = attributeValue(node, extractAttributeContent)
+				case property == extractPropertyOGDescription && facts.openGraphBio == "":
+					facts.openGraphBio = attributeValue(node, extractAttributeContent)
+				}
+			case atom.A:
+				if href := attributeValue(node, extractAttributeHref); href != "" {
+					facts.linkURLs = append(facts.linkURLs, href)
+				}
+			case atom.Title:
+				if facts.title == "" {
+					facts.title = strings.TrimSpace(nodeText(node))
+				}
+			case atom.Script:
+				body := nodeText(node)
+				facts.machineText = append(facts.machineText, body)
+				if strings.EqualFold(attributeValue(node, extractAttributeType), extractScriptTypeJSONLD) {
+					facts.jsonLDBlocks = append(facts.jsonLDBlocks, body)
+				}
+				return
+			}
+			switch testID := attributeValue(node, extractAttributeTestID); {
+			case testID == extractTestIDUserName && len(facts.testIDTexts) == 0:
+				insideUserName = true
+			case testID == extractTestIDVerifiedIcon && insideUserName:
+				facts.verifiedBadge = true
+			case testID == extractTestIDProtectedIcon && insideUserName:
+				facts.protectedBadge = true
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child, insideUserName)
+		}
+	}
+	visit(document, false)
+	return facts
+}
+
+// person returns the Person entity of the first ProfilePage JSON-LD block.
+func (facts pageFacts) person() (jsonLDPerson, bool) {
+	for _, block := range facts.jsonLDBlocks {
+		var page jsonLDProfilePage
+		if err := json.Unmarshal([]byte(block), &page); err != nil {
+			continue
+		}
+		if page.MainEntity != nil {
+			return *page.MainEntity, true
+		}
+		if page.Author != nil {
+			return *page.Author, true
+		}
+	}
+	return jsonLDPerson{}, false
+}
+
+// testIDUserName splits the text of the data-testid="UserName" element into a handle and the display name before it.
+func (facts pageFacts) testIDUserName() (string, string) {
+	var displayName string
+	for _, text := range facts.testIDTexts {
+		if strings.HasPrefix(text, extractHandlePrefix) {
+			return validHandle(strings.TrimPrefix(text, extractHandlePrefix)), displayName
+		}
+		if displayName == "" {
+			displayName = text
+		}
+	}
+	return "", ""
+}
+
+func (facts pageFacts) displayName(handle string) string {
+	if person, found := facts.person(); found && strings.EqualFold(person.AdditionalName, handle) {
+		if name := strings.TrimSpace(person.Name); name != "" {
+			return name
+		}
+		if name := strings.TrimSpace(person.GivenName); name != "" {
+			return name
+		}
+	}
+	if testIDHandle, name := facts.testIDUserName(); strings.EqualFold(testIDHandle, handle) && name != "" {
+		return name
+	}
+	for _, title := range []string{facts.openGraphTitle, facts.title} {
+		if name := displayNameFromTitle(title, handle); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// profile collects the profile details shown for handle. The og:image and og:description are used only when the
+// og:title names the handle, because pages that are not profiles carry generic values there.
+func (facts pageFacts) profile(handle string) Profile {
+	profile := Profile{Verified: facts.verifiedBadge, Protected: facts.protectedBadge}
+	if person, found := facts.person(); found && strings.EqualFold(person.AdditionalName, handle) {
+		profile.AvatarURL = person.Image.url()
+		profile.Bio = strings.TrimSpace(person.Description)
+		for _, statistic := range person.InteractionStatistic {
+			switch statistic.Name {
+			case extractStatisticFollowers:
+				profile.FollowersCount = statistic.UserInteractionCount
+			case extractStatisticFollowing:
+				profile.FollowingCount = statistic.UserInteractionCount
+			}
+		}
+	}
+	if strings.EqualFold(handleFromTitle(facts.openGraphTitle), handle) {
+		if profile.AvatarURL == "" && strings.Contains(facts.openGraphImage, extractAvatarPathMarker) {
+			profile.AvatarURL = strings.TrimSpace(facts.openGraphImage)
+		}
+		if profile.Bio == "" {
+			profile.Bio = strings.TrimSpace(facts.openGraphBio)
+		}
+	}
+	return profile
+}
+
+// accountID returns the numeric identifier the page gives for handle, from the JSON-LD Person or from embedded user
+// data, where the rest_id closest before the handle's screen_name belongs to it.
+func (facts pageFacts) accountID(handle string) string {
+	if person, found := facts.person(); found && isNumericID(person.Identifier) &&
+		(person.AdditionalName == "" || strings.EqualFold(person.AdditionalName, handle)) {
+		return person.Identifier
+	}
+	screenName := regexp.MustCompile(fmt.Sprintf(extractScreenNameFmt, regexp.QuoteMeta(handle)))
+	for _, text := range facts.machineText {
+		screenNameLocation := screenName.FindStringIndex(text)
+		if screenNameLocation == nil {
+			continue
+		}
+		accountID := ""
+		for _, match := range extractRestIDRegex.FindAllStringSubmatchIndex(text, -1) {
+			if match[0] > screenNameLocation[0] {
+				break
+			}
+			accountID = text[match[2]:match[3]]
+		}
+		if accountID != "" {
+			return accountID
+		}
+	}
+	return ""
+}
+
+// referencesAccountID reports whether accountID appears as a whole number in an attribute or script.
+func (facts pageFacts) referencesAccountID(accountID string) bool {
+	if accountID == "" {
+		return false
+	}
+	boundedID := regexp.MustCompile(fmt.Sprintf(extractAccountIDBoundaryFmt, regexp.QuoteMeta(accountID)))
+	for _, text := range facts.machineText {
+		if strings.Contains(text, accountID) && boundedID.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFromProfileURL returns the handle of an absolute or root-relative x.com or twitter.com profile URL.
+func handleFromProfileURL(rawURL string) string {
+	parsedURL, parseErr := url.Parse(strings.TrimSpace(rawURL))
+	if parseErr != nil {
+		return ""
+	}
+	if parsedURL.Host != "" {
+		host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(parsedURL.Host), extractHostPrefixWWW), extractHostPrefixMobile)
+		if _, profileHost := profileHosts[host]; !profileHost {
+			return ""
+		}
+	} else if parsedURL.Scheme != "" || !strings.HasPrefix(parsedURL.Path, "/") {
+		return ""
+	}
+	segment := strings.Trim(parsedURL.Path, "/")
+	if strings.Contains(segment, "/") {
+		return ""
+	}
+	return validHandle(segment)
+}
+
+// handleFromTitle returns the handle inside "(@handle)" in a page title.
+func handleFromTitle(title string) string {
+	start := strings.Index(title, extractTitleHandleOpen)
+	if start < 0 {
+		return ""
+	}
+	rest := title[start+len(extractTitleHandleOpen):]
+	end := strings.Index(rest, extractTitleHandleClose)
+	if end < 0 {
+		return ""
+	}
+	return validHandle(rest[:end])
+}
+
+// displayNameFromTitle returns the part of a title before "(@handle)"; titles without the handle yield nothing.
+func displayNameFromTitle(title string, handle string) string {
+	marker := strings.ToLower(extractTitleHandleOpen + handle + extractTitleHandleClose)
+	index := strings.Index(strings.ToLower(title), marker)
+	if index <= 0 {
+		return ""
+	}
+	return strings.TrimSpace(title[:index])
+}
+
+func validHandle(candidate string) string {
+	candidate = strings.TrimSpace(candidate)
+	if !extractHandleRegex.MatchString(candidate) {
+		return ""
+	}
+	if _, reserved := reservedHandleNames[strings.ToLower(candidate)]; reserved {
+		return ""
+	}
+	return candidate
+}
+
+// isNumericID reports whether candidate is a non-empty string of ASCII digits.
+func isNumericID(candidate string) bool {
+	if candidate == "" {
+		return false
+	}
+	for _, character := range candidate {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func attributeValue(node *html.Node, name string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Namespace == "" && strings.EqualFold(attribute.Key, name) {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+func hasToken(value string, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeText(node *html.Node) string {
+	var builder strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			builder.WriteString(child.Data)
+		}
+	}
+	return builder.String()
+}
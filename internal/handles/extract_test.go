@@ -0,0 +1,130 @@
+package handles_test
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	extractGoldenFlagName        = "update_extract_golden"
+	extractGoldenFlagDescription = "rewrite the golden files of the intent page extraction corpus"
+	extractCorpusDirectory       = "testdata/intent_pages"
+	extractCorpusPagePattern     = "*.html"
+	extractCorpusGoldenExtension = ".golden"
+	extractCorpusIDSeparator     = "_"
+)
+
+var extractGoldenUpdateFlag = flag.Bool(extractGoldenFlagName, false, extractGoldenFlagDescription)
+
+// extractGolden is the recorded outcome of extracting one corpus page.
+type extractGolden struct {
+	UserName          string `json:"userName"`
+	DisplayName       string `json:"displayName"`
+	Rule              string `json:"rule"`
+	AccountIDVerified bool   `json:"accountIDVerified"`
+	AvatarURL         string `json:"avatarURL,omitempty"`
+	Bio               string `json:"bio,omitempty"`
+	Protected         bool   `json:"protected,omitempty"`
+	Verified          bool   `json:"verified,omitempty"`
+	FollowersCount    *int   `json:"followersCount,omitempty"`
+	FollowingCount    *int   `json:"followingCount,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// TestExtractProfileGoldenCorpus extracts every saved intent page in testdata/intent_pages and compares the outcome
+// with its golden file. Page files are named <account id>_<description>.html.
+func TestExtractProfileGoldenCorpus(t *testing.T) {
+	pagePaths, globErr := filepath.Glob(filepath.Join(extractCorpusDirectory, extractCorpusPagePattern))
+	if globErr != nil {
+		t.Fatalf("list corpus: %v", globErr)
+	}
+	if len(pagePaths) == 0 {
+		t.Fatalf("no corpus pages found in %s", extractCorpusDirectory)
+	}
+
+	for _, pagePath := range pagePaths {
+		pageName := strings.TrimSuffix(filepath.Base(pagePath), filepath.Ext(pagePath))
+		t.Run(pageName, func(t *testing.T) {
+			pageContent, readErr := os.ReadFile(pagePath)
+			if readErr != nil {
+				t.Fatalf("read page: %v", readErr)
+			}
+			accountID, _, _ := strings.Cut(pageName, extractCorpusIDSeparator)
+
+			extraction, extractErr := handles.ExtractProfile(string(pageContent), accountID)
+			actual := extractGolden{
+				UserName:          extraction.UserName,
+				DisplayName:       extraction.DisplayName,
+				Rule:              string(extraction.Rule),
+				AccountIDVerified: extraction.AccountIDVerified,
+				AvatarURL:         extraction.Profile.AvatarURL,
+				Bio:               extraction.Profile.Bio,
+				Protected:         extraction.Profile.Protected,
+				Verified:          extraction.Profile.Verified,
+				FollowersCount:    extraction.Profile.FollowersCount,
+				FollowingCount:    extraction.Profile.FollowingCount,
+			}
+			if extractErr != nil {
+				actual.Error = extractErr.Error()
+			}
+
+			goldenPath := strings.TrimSuffix(pagePath, filepath.Ext(pagePath)) + extractCorpusGoldenExtension
+			if *extractGoldenUpdateFlag {
+				encoded, marshalErr := json.MarshalIndent(actual, "", "  ")
+				if marshalErr != nil {
+					t.Fatalf("encode golden: %v", marshalErr)
+				}
+				if writeErr := os.WriteFile(goldenPath, append(encoded, '\n'), 0o644); writeErr != nil {
+					t.Fatalf("write golden: %v", writeErr)
+				}
+				return
+			}
+
+			goldenContent, readGoldenErr := os.ReadFile(goldenPath)
+			if readGoldenErr != nil {
+				t.Fatalf("read golden (run with -%s to create it): %v", extractGoldenFlagName, readGoldenErr)
+			}
+			var expected extractGolden
+			if unmarshalErr := json.Unmarshal(goldenContent, &expected); unmarshalErr != nil {
+				t.Fatalf("decode golden: %v", unmarshalErr)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("extraction mismatch\nexpected %+v\ngot      %+v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtractProfileRejectsOtherAccounts(t *testing.T) {
+	const pageHTML = `<html><head><script type="application/ld+json">{"@type":"ProfilePage","mainEntity":{"additionalName":"other","identifier":"2"}}</script></head></html>`
+
+	testCases := []struct {
+		name        string
+		accountID   string
+		expectedErr error
+	}{
+		{name: "matching identifier", accountID: "2"},
+		{name: "different identifier", accountID: "1", expectedErr: handles.ErrAccountIDMismatch},
+		{name: "identifier prefixed by the request", accountID: "22", expectedErr: handles.ErrAccountIDMismatch},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			extraction, err := handles.ExtractProfile(pageHTML, testCase.accountID)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("expected error %v, got %v", testCase.expectedErr, err)
+			}
+			if testCase.expectedErr == nil && (extraction.UserName != "other" || !extraction.AccountIDVerified) {
+				t.Fatalf("expected a verified extraction of other, got %+v", extraction)
+			}
+		})
+	}
+}
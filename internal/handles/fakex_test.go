@@ -0,0 +1,256 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+	"github.com/f-sync/fsync/internal/testing/fakex"
+)
+
+const (
+	fakeXActiveID        = "70001"
+	fakeXProtectedID     = "70002"
+	fakeXSuspendedID     = "70003"
+	fakeXNotFoundID      = "70004"
+	fakeXRateLimitedID   = "70005"
+	fakeXSlowID          = "70006"
+	fakeXActiveUserName  = "fake_active"
+	fakeXActiveName      = "Fake Active"
+	fakeXLockedUserName  = "fake_locked"
+	fakeXLockedName      = "Fake Locked"
+	fakeXLimitedUserName = "fake_limited"
+	fakeXSlowUserName    = "fake_slow"
+	fakeXSlowDelay       = 2 * time.Second
+	fakeXClientTimeout   = 200 * time.Millisecond
+	fakeXLongRetryAfter  = time.Minute
+)
+
+func TestMain(m *testing.M) {
+	fakex.RunChromeIfRequested()
+	os.Exit(m.Run())
+}
+
+func fakeXAccounts() []fakex.Account {
+	return []fakex.Account{
+		{ID: fakeXActiveID, UserName: fakeXActiveUserName, DisplayName: fakeXActiveName},
+		{ID: fakeXProtectedID, UserName: fakeXLockedUserName, DisplayName: fakeXLockedName, Status: fakex.StatusProtected},
+		{ID: fakeXSuspendedID, UserName: "fake_suspended", Status: fakex.StatusSuspended},
+		{ID: fakeXNotFoundID, UserName: "fake_gone", Status: fakex.StatusNotFound},
+	}
+}
+
+// newFakeXResolver builds a resolver whose backends all talk to server.
+func newFakeXResolver(t *testing.T, server *fakex.Server, configuration handles.Config) *handles.Resolver {
+	t.Helper()
+	configuration.BaseURL = server.URL
+	configuration.APIBaseURL = server.URL
+	configuration.APIBearerToken = fakex.BearerToken
+	configuration.Cache = handles.NewMemoryCache()
+	if configuration.MaxAttempts == 0 {
+		configuration.MaxAttempts = 1
+	}
+	if configuration.RateLimit.RequestsPerSecond == 0 {
+		configuration.RateLimit.RequestsPerSecond = -1
+	}
+	resolver, err := handles.NewResolver(configuration)
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	return resolver
+}
+
+func TestBackendsResolveAgainstFakeX(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backend handles.Backend
+		// chromeBinary returns the Chrome binary for the backend, skipping the test when none can be used.
+		chromeBinary func(t *testing.T) string
+		// seesProfiles reports whether the backend reads display names and the protected flag.
+		seesProfiles bool
+	}{
+		{name: "redirect", backend: handles.BackendRedirect},
+		{name: "api", backend: handles.BackendAPI, seesProfiles: true},
+		{name: "chrome", backend: handles.BackendChrome, chromeBinary: func(t *testing.T) string { return fakex.ChromeBinary(t) }, seesProfiles: true},
+		{name: "chrome-pool", backend: handles.BackendChromePool, chromeBinary: func(t *testing.T) string {
+			binaryPath, err := resolverIntegrationChromeBinaryPath()
+			if err != nil {
+				t.Skipf(chromePoolTestSkipFormat, err)
+			}
+			return binaryPath
+		}, seesProfiles: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			server := fakex.NewServer(fakeXAccounts())
+			defer server.Close()
+			configuration := handles.Config{Backends: []handles.Backend{testCase.backend}, ChromeVirtualTimeBudget: chromePoolTestPageTimeout}
+			if testCase.chromeBinary != nil {
+				configuration.ChromeBinaryPath = testCase.chromeBinary(t)
+			}
+			resolver := newFakeXResolver(t, server, configuration)
+
+			results := resolver.ResolveMany(context.Background(), []string{fakeXActiveID, fakeXProtectedID, fakeXSuspendedID, fakeXNotFoundID})
+			active := results[fakeXActiveID]
+			if active.Err != nil || active.Record.UserName != fakeXActiveUserName {
+				t.Fatalf("unexpected active result %+v", active)
+			}
+			if provenance, known := active.Record.UserNameProvenance(); !known || provenance.Origin != string(testCase.backend) {
+				t.Fatalf("expected the %s backend in the provenance, got %+v", testCase.backend, provenance)
+			}
+			protected := results[fakeXProtectedID]
+			if protected.Err != nil || protected.Record.UserName != fakeXLockedUserName {
+				t.Fatalf("unexpected protected result %+v", protected)
+			}
+			if testCase.seesProfiles {
+				if active.Record.DisplayName != fakeXActiveName {
+					t.Fatalf("expected display name %q, got %q", fakeXActiveName, active.Record.DisplayName)
+				}
+				if protected.Record.Status != handles.AccountStatusProtected {
+					t.Fatalf("expected a protected status, got %q", protected.Record.Status)
+				}
+			}
+			if err := results[fakeXSuspendedID].Err; !errors.Is(err, handles.ErrAccountSuspended) {
+				t.Fatalf("expected a suspended account, got %v", err)
+			}
+			if err := results[fakeXNotFoundID].Err; !errors.Is(err, handles.ErrAccountDoesNotExist) {
+				t.Fatalf("expected a missing account, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleLookupsAgainstFakeX(t *testing.T) {
+	testCases := []struct {
+		name         string
+		backend      handles.Backend
+		chromeBinary bool
+	}{
+		{name: "api", backend: handles.BackendAPI},
+		{name: "chrome", backend: handles.BackendChrome, chromeBinary: true},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			server := fakex.NewServer(fakeXAccounts())
+			defer server.Close()
+			configuration := handles.Config{Backends: []handles.Backend{testCase.backend}}
+			if testCase.chromeBinary {
+				configuration.ChromeBinaryPath = fakex.ChromeBinary(t)
+			}
+			resolver := newFakeXResolver(t, server, configuration)
+
+			record, err := resolver.ResolveHandle(context.Background(), "@"+fakeXActiveUserName)
+			if err != nil || record.AccountID != fakeXActiveID || record.DisplayName != fakeXActiveName {
+				t.Fatalf("unexpected handle lookup %+v, %v", record, err)
+			}
+			if _, err := resolver.ResolveHandle(context.Background(), "nobody_here"); !errors.Is(err, handles.ErrAccountDoesNotExist) {
+				t.Fatalf("expected an unknown handle to be missing, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFakeXRateLimits(t *testing.T) {
+	testCases := []struct {
+		name           string
+		retryAfter     time.Duration
+		expectedStatus handles.AccountStatus
+	}{
+		{name: "short retry-after is waited out", retryAfter: 0},
+		{name: "long retry-after fails as rate limited", retryAfter: fakeXLongRetryAfter, expectedStatus: handles.AccountStatusRateLimited},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			server := fakex.NewServer([]fakex.Account{{ID: fakeXRateLimitedID, UserName: fakeXLimitedUserName, RateLimited: 1, RetryAfter: testCase.retryAfter}})
+			defer server.Close()
+			resolver := newFakeXResolver(t, server, handles.Config{
+				Backends:  []handles.Backend{handles.BackendRedirect},
+				RateLimit: ratelimit.Config{MaxRetries: 1, MaxWait: time.Second},
+			})
+
+			record, err := resolver.ResolveAccount(context.Background(), fakeXRateLimitedID)
+			if status := handles.StatusOf(err); status != testCase.expectedStatus {
+				t.Fatalf("expected status %q, got %q (%v)", testCase.expectedStatus, status, err)
+			}
+			if testCase.expectedStatus == "" && record.UserName != fakeXLimitedUserName {
+				t.Fatalf("expected the handle after the retry, got %+v", record)
+			}
+			if requests := server.RequestCount(fakex.RedirectPathPrefix); testCase.expectedStatus == "" && requests != 2 {
+				t.Fatalf("expected one retry, got %d requests", requests)
+			}
+		})
+	}
+}
+
+func TestFakeXFallsBackWhenRateLimited(t *testing.T) {
+	testCases := []struct {
+		name            string
+		backends        []handles.Backend
+		chromeBinary    bool
+		expectedBackend handles.Backend
+	}{
+		{
+			name:            "429 response",
+			backends:        []handles.Backend{handles.BackendRedirect, handles.BackendAPI},
+			expectedBackend: handles.BackendAPI,
+		},
+		{
+			name:            "rendered rate limit page",
+			backends:        []handles.Backend{handles.BackendChrome, handles.BackendRedirect},
+			chromeBinary:    true,
+			expectedBackend: handles.BackendRedirect,
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			server := fakex.NewServer([]fakex.Account{{ID: fakeXRateLimitedID, UserName: fakeXLimitedUserName, RateLimited: 1, RetryAfter: fakeXLongRetryAfter}})
+			defer server.Close()
+			configuration := handles.Config{
+				Backends:         testCase.backends,
+				BreakerThreshold: 1,
+				RateLimit:        ratelimit.Config{MaxWait: time.Second},
+			}
+			if testCase.chromeBinary {
+				configuration.ChromeBinaryPath = fakex.ChromeBinary(t)
+			}
+			resolver := newFakeXResolver(t, server, configuration)
+
+			record, err := resolver.ResolveAccount(context.Background(), fakeXRateLimitedID)
+			if err != nil || record.UserName != fakeXLimitedUserName {
+				t.Fatalf("expected the %s backend to answer, got %+v, %v", testCase.expectedBackend, record, err)
+			}
+			if provenance, _ := record.UserNameProvenance(); provenance.Origin != string(testCase.expectedBackend) {
+				t.Fatalf("expected the %s backend in the provenance, got %+v", testCase.expectedBackend, provenance)
+			}
+		})
+	}
+}
+
+func TestFakeXSlowResponsesTimeOut(t *testing.T) {
+	server := fakex.NewServer([]fakex.Account{{ID: fakeXSlowID, UserName: fakeXSlowUserName, Delay: fakeXSlowDelay}})
+	defer server.Close()
+	resolver := newFakeXResolver(t, server, handles.Config{
+		Backends:   []handles.Backend{handles.BackendRedirect},
+		HTTPClient: &http.Client{Timeout: fakeXClientTimeout},
+		RateLimit:  ratelimit.Config{MaxRetries: -1},
+	})
+
+	startedAt := time.Now()
+	_, err := resolver.ResolveAccount(context.Background(), fakeXSlowID)
+	if err == nil {
+		t.Fatalf("expected the slow response to fail")
+	}
+	if elapsed := time.Since(startedAt); elapsed >= fakeXSlowDelay {
+		t.Fatalf("expected the client timeout to cut the request short, took %v", elapsed)
+	}
+}
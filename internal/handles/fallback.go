@@ -0,0 +1,216 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is how many consecutive failures open a backend's circuit breaker.
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is how long an open breaker skips its backend before allowing a trial request.
+	DefaultBreakerCooldown = time.Minute
+
+	errMessageNoBackends          = "no resolver backends configured"
+	errMessageBackendsUnavailable = "every resolver backend is unavailable"
+	errMessageHandleLookup        = "backend cannot look accounts up by handle"
+)
+
+var (
+	errNoBackends = errors.New(errMessageNoBackends)
+	// ErrBackendsUnavailable reports that every backend in a fallback chain has an open circuit breaker.
+	ErrBackendsUnavailable = errors.New(errMessageBackendsUnavailable)
+	// ErrHandleLookupUnsupported is returned by fetchers that can only look accounts up by numeric identifier. A
+	// fallback chain moves on to its next backend without counting the answer as a failure.
+	ErrHandleLookupUnsupported = errors.New(errMessageHandleLookup)
+)
+
+// FallbackLink names one backend in a fallback chain.
+type FallbackLink struct {
+	Backend Backend
+	Fetcher IntentFetcher
+}
+
+// FallbackConfig configures the circuit breakers of a FallbackFetcher.
+type FallbackConfig struct {
+	// BreakerThreshold is how many consecutive failures open a backend's breaker; DefaultBreakerThreshold applies when
+	// zero.
+	BreakerThreshold int
+	// BreakerCooldown is how long an open breaker skips its backend; DefaultBreakerCooldown applies when zero.
+	BreakerCooldown time.Duration
+}
+
+// BackendState reports the health of one backend in a fallback chain.
+type BackendState struct {
+	Backend             Backend
+	ConsecutiveFailures int
+	// OpenUntil is when the breaker next allows a request; it is zero while the breaker is closed.
+	OpenUntil time.Time
+}
+
+// FallbackFetcher tries an ordered list of backends for each account until one answers. Each backend has a circuit
+// breaker that skips it after consecutive failures, and the answering backend is recorded on the returned page.
+type FallbackFetcher struct {
+	links            []FallbackLink
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	mutex    sync.Mutex
+	breakers []circuitBreaker
+}
+
+type circuitBreaker struct {
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// NewFallbackFetcher constructs a FallbackFetcher trying links in order.
+func NewFallbackFetcher(links []FallbackLink, configuration FallbackConfig) (*FallbackFetcher, error) {
+	if len(links) == 0 {
+		return nil, errNoBackends
+	}
+	breakerThreshold := configuration.BreakerThreshold
+	if breakerThreshold <= 0 {
+		breakerThreshold = DefaultBreakerThreshold
+	}
+	breakerCooldown := configuration.BreakerCooldown
+	if breakerCooldown <= 0 {
+		breakerCooldown = DefaultBreakerCooldown
+	}
+	return &FallbackFetcher{
+		links:            append([]FallbackLink(nil), links...),
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+		breakers:         make([]circuitBreaker, len(links)),
+	}, nil
+}
+
+// FetchIntentPage asks each available backend in turn. Answers about the account itself, such as a suspension, end
+// the chain; rate limits and transient failures move on to the next backend.
+func (fetcher *FallbackFetcher) FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error) {
+	var lastErr error
+	for index, link := range fetcher.links {
+		if !fetcher.allow(index) {
+			continue
+		}
+		page, fetchErr := link.Fetcher.FetchIntentPage(ctx, request)
+		if errors.Is(fetchErr, context.Canceled) || errors.Is(fetchErr, context.DeadlineExceeded) {
+			return IntentPage{}, fetchErr
+		}
+		if errors.Is(fetchErr, ErrHandleLookupUnsupported) {
+			lastErr = fmt.Errorf("%s: %w", link.Backend, fetchErr)
+			continue
+		}
+		if fetchErr == nil || StatusOf(fetchErr).IsGhost() {
+			fetcher.recordSuccess(index)
+			page.Backend = link.Backend
+			return page, fetchErr
+		}
+		fetcher.recordFailure(index)
+		lastErr = fmt.Errorf("%s: %w", link.Backend, fetchErr)
+	}
+	if lastErr == nil {
+		return IntentPage{}, newResolutionError(request.reference(), AccountStatusTransient, ErrBackendsUnavailable)
+	}
+	return IntentPage{}, lastErr
+}
+
+// FetchIntentPages hands the batch to the leading batch-capable backends of the chain in order. Only settled
+// answers, meaning handles and ghost statuses, are returned; the remaining accounts are left for FetchIntentPage so
+// that later backends are still tried one account at a time.
+func (fetcher *FallbackFetcher) FetchIntentPages(ctx context.Context, requests []IntentRequest) map[string]IntentOutcome {
+	settled := make(map[string]IntentOutcome, len(requests))
+	pending := requests
+	for index, link := range fetcher.links {
+		batchFetcher, batchCapable := link.Fetcher.(BatchIntentFetcher)
+		if !batchCapable || len(pending) == 0 {
+			break
+		}
+		if !fetcher.allow(index) {
+			continue
+		}
+		outcomes := batchFetcher.FetchIntentPages(ctx, pending)
+		if ctx.Err() != nil {
+			return settled
+		}
+		var unsettled []IntentRequest
+		for _, request := range pending {
+			outcome, answered := outcomes[request.AccountID]
+			if answered && (outcome.Err == nil || StatusOf(outcome.Err).IsGhost()) {
+				outcome.Page.Backend = link.Backend
+				settled[request.AccountID] = outcome
+				continue
+			}
+			unsettled = append(unsettled, request)
+		}
+		if len(unsettled) < len(pending) {
+			fetcher.recordSuccess(index)
+		} else {
+			fetcher.recordFailure(index)
+		}
+		pending = unsettled
+	}
+	return settled
+}
+
+// States reports the breaker state of every backend in chain order.
+func (fetcher *FallbackFetcher) States() []BackendState {
+	fetcher.mutex.Lock()
+	defer fetcher.mutex.Unlock()
+	states := make([]BackendState, 0, len(fetcher.links))
+	for index, link := range fetcher.links {
+		states = append(states, BackendState{
+			Backend:             link.Backend,
+			ConsecutiveFailures: fetcher.breakers[index].consecutiveFailures,
+			OpenUntil:           fetcher.breakers[index].openUntil,
+		})
+	}
+	return states
+}
+
+// allow reports whether the backend's breaker is closed or its cooldown has elapsed, in which case one trial request
+// is let through.
+func (fetcher *FallbackFetcher) allow(index int) bool {
+	fetcher.mutex.Lock()
+	defer fetcher.mutex.Unlock()
+	breaker := &fetcher.breakers[index]
+	if breaker.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(breaker.openUntil) {
+		return false
+	}
+	breaker.openUntil = time.Now().Add(fetcher.breakerCooldown)
+	return true
+}
+
+func (fetcher *FallbackFetcher) recordSuccess(index int) {
+	fetcher.mutex.Lock()
+	fetcher.breakers[index] = circuitBreaker{}
+	fetcher.mutex.Unlock()
+}
+
+func (fetcher *FallbackFetcher) recordFailure(index int) {
+	fetcher.mutex.Lock()
+	defer fetcher.mutex.Unlock()
+	breaker := &fetcher.breakers[index]
+	breaker.consecutiveFailures++
+	if breaker.consecutiveFailures >= fetcher.breakerThreshold {
+		breaker.openUntil = time.Now().Add(fetcher.breakerCooldown)
+	}
+}
+
+// Close closes every backend holding resources, such as a running browser, and returns their errors joined.
+func (fetcher *FallbackFetcher) Close() error {
+	var closeErrs []error
+	for _, link := range fetcher.links {
+		if closer, closable := link.Fetcher.(io.Closer); closable {
+			closeErrs = append(closeErrs, closer.Close())
+		}
+	}
+	return errors.Join(closeErrs...)
+}
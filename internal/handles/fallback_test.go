@@ -0,0 +1,229 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	fallbackTestAccountIDFirst  = "60001"
+	fallbackTestAccountIDSecond = "60002"
+	fallbackTestAccountIDThird  = "60003"
+	fallbackTestFailureMessage  = "backend down"
+	fallbackTestBreakerCooldown = 20 * time.Millisecond
+)
+
+func TestFallbackFetcherTriesBackendsInOrder(t *testing.T) {
+	successPage := handles.IntentPage{HTML: resolverTestIntentHTMLSuccess, SourceURL: resolverTestIntentURLPrefix + fallbackTestAccountIDFirst}
+	suspendedErr := &handles.ResolutionError{AccountID: fallbackTestAccountIDFirst, Status: handles.AccountStatusSuspended}
+
+	testCases := []struct {
+		name            string
+		primaryErr      error
+		expectedBackend handles.Backend
+		expectedErr     error
+		expectedCalls   [2]int
+	}{
+		{
+			name:            "primary answers",
+			expectedBackend: handles.BackendRedirect,
+			expectedCalls:   [2]int{1, 0},
+		},
+		{
+			name:            "transient failure falls through",
+			primaryErr:      errors.New(fallbackTestFailureMessage),
+			expectedBackend: handles.BackendChrome,
+			expectedCalls:   [2]int{1, 1},
+		},
+		{
+			name:            "rate limit falls through",
+			primaryErr:      &handles.ResolutionError{AccountID: fallbackTestAccountIDFirst, Status: handles.AccountStatusRateLimited},
+			expectedBackend: handles.BackendChrome,
+			expectedCalls:   [2]int{1, 1},
+		},
+		{
+			name:            "suspension ends the chain",
+			primaryErr:      suspendedErr,
+			expectedBackend: handles.BackendRedirect,
+			expectedErr:     handles.ErrAccountSuspended,
+			expectedCalls:   [2]int{1, 0},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			primaryErrors := map[string]error{}
+			if testCase.primaryErr != nil {
+				primaryErrors[fallbackTestAccountIDFirst] = testCase.primaryErr
+			}
+			primary := newRecordingIntentFetcher(map[string]handles.IntentPage{fallbackTestAccountIDFirst: successPage}, primaryErrors)
+			secondary := newRecordingIntentFetcher(map[string]handles.IntentPage{fallbackTestAccountIDFirst: successPage}, nil)
+			fetcher, err := handles.NewFallbackFetcher([]handles.FallbackLink{
+				{Backend: handles.BackendRedirect, Fetcher: primary},
+				{Backend: handles.BackendChrome, Fetcher: secondary},
+			}, handles.FallbackConfig{})
+			if err != nil {
+				t.Fatalf("create fallback fetcher: %v", err)
+			}
+
+			page, fetchErr := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{AccountID: fallbackTestAccountIDFirst})
+			if testCase.expectedErr != nil {
+				if !errors.Is(fetchErr, testCase.expectedErr) {
+					t.Fatalf("expected %v, got %v", testCase.expectedErr, fetchErr)
+				}
+			} else if fetchErr != nil {
+				t.Fatalf("unexpected error: %v", fetchErr)
+			}
+			if page.Backend != testCase.expectedBackend {
+				t.Fatalf("expected backend %q, got %q", testCase.expectedBackend, page.Backend)
+			}
+			calls := [2]int{primary.calls[fallbackTestAccountIDFirst], secondary.calls[fallbackTestAccountIDFirst]}
+			if calls != testCase.expectedCalls {
+				t.Fatalf("expected calls %v, got %v", testCase.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestFallbackFetcherCircuitBreaker(t *testing.T) {
+	failing := map[string]error{
+		fallbackTestAccountIDFirst:  errors.New(fallbackTestFailureMessage),
+		fallbackTestAccountIDSecond: errors.New(fallbackTestFailureMessage),
+		fallbackTestAccountIDThird:  errors.New(fallbackTestFailureMessage),
+	}
+	primary := newRecordingIntentFetcher(nil, failing)
+	fetcher, err := handles.NewFallbackFetcher([]handles.FallbackLink{
+		{Backend: handles.BackendRedirect, Fetcher: primary},
+	}, handles.FallbackConfig{BreakerThreshold: 2, BreakerCooldown: fallbackTestBreakerCooldown})
+	if err != nil {
+		t.Fatalf("create fallback fetcher: %v", err)
+	}
+
+	for _, accountID := range []string{fallbackTestAccountIDFirst, fallbackTestAccountIDSecond} {
+		if _, fetchErr := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{AccountID: accountID}); fetchErr == nil {
+			t.Fatalf("expected failure for %s", accountID)
+		}
+	}
+	states := fetcher.States()
+	if len(states) != 1 || states[0].ConsecutiveFailures != 2 || states[0].OpenUntil.IsZero() {
+		t.Fatalf("expected open breaker after two failures, got %+v", states)
+	}
+
+	_, fetchErr := fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{AccountID: fallbackTestAccountIDThird})
+	if !errors.Is(fetchErr, handles.ErrBackendsUnavailable) || !handles.IsRetryable(fetchErr) {
+		t.Fatalf("expected unavailable backends error, got %v", fetchErr)
+	}
+	if primary.calls[fallbackTestAccountIDThird] != 0 {
+		t.Fatalf("expected open breaker to skip backend, got %d calls", primary.calls[fallbackTestAccountIDThird])
+	}
+
+	time.Sleep(2 * fallbackTestBreakerCooldown)
+	_, _ = fetcher.FetchIntentPage(context.Background(), handles.IntentRequest{AccountID: fallbackTestAccountIDThird})
+	if primary.calls[fallbackTestAccountIDThird] != 1 {
+		t.Fatalf("expected trial request after cooldown, got %d calls", primary.calls[fallbackTestAccountIDThird])
+	}
+}
+
+func TestResolverRecordsAnsweringBackend(t *testing.T) {
+	primary := newRecordingIntentFetcher(nil, map[string]error{fallbackTestAccountIDSecond: errors.New(fallbackTestFailureMessage)})
+	secondary := newRecordingIntentFetcher(map[string]handles.IntentPage{
+		fallbackTestAccountIDSecond: {HTML: resolverTestIntentHTMLSuccess, SourceURL: resolverTestIntentURLPrefix + fallbackTestAccountIDSecond},
+	}, nil)
+	fetcher, err := handles.NewFallbackFetcher([]handles.FallbackLink{
+		{Backend: handles.BackendRedirect, Fetcher: primary},
+		{Backend: handles.BackendChrome, Fetcher: secondary},
+	}, handles.FallbackConfig{})
+	if err != nil {
+		t.Fatalf("create fallback fetcher: %v", err)
+	}
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	record, resolveErr := resolver.ResolveAccount(context.Background(), fallbackTestAccountIDSecond)
+	if resolveErr != nil {
+		t.Fatalf("unexpected error: %v", resolveErr)
+	}
+	provenance, known := record.UserNameProvenance()
+	if !known || provenance.Origin != string(handles.BackendChrome) {
+		t.Fatalf("expected chrome to be recorded as the answering backend, got %+v", provenance)
+	}
+}
+
+// closingIntentFetcher is an intent fetcher holding resources, recording whether it was closed.
+type closingIntentFetcher struct {
+	*recordingIntentFetcher
+	closed   bool
+	closeErr error
+}
+
+func (fetcher *closingIntentFetcher) Close() error {
+	fetcher.closed = true
+	return fetcher.closeErr
+}
+
+func TestResolverClosesBackends(t *testing.T) {
+	closeFailure := errors.New("close failure")
+	first := &closingIntentFetcher{recordingIntentFetcher: newRecordingIntentFetcher(nil, nil), closeErr: closeFailure}
+	second := &closingIntentFetcher{recordingIntentFetcher: newRecordingIntentFetcher(nil, nil)}
+	fetcher, err := handles.NewFallbackFetcher([]handles.FallbackLink{
+		{Backend: handles.BackendChromePool, Fetcher: first},
+		{Backend: handles.BackendRedirect, Fetcher: newRecordingIntentFetcher(nil, nil)},
+		{Backend: handles.BackendChrome, Fetcher: second},
+	}, handles.FallbackConfig{})
+	if err != nil {
+		t.Fatalf("create fallback fetcher: %v", err)
+	}
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	if closeErr := resolver.Close(); !errors.Is(closeErr, closeFailure) {
+		t.Fatalf("expected the backend close error, got %v", closeErr)
+	}
+	if !first.closed || !second.closed {
+		t.Fatalf("expected every closable backend to be closed, got first %t second %t", first.closed, second.closed)
+	}
+}
+
+func TestParseBackends(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expected    []handles.Backend
+		expectError bool
+	}{
+		{name: "empty selects default", value: "", expected: []handles.Backend{handles.DefaultBackend}},
+		{name: "ordered list", value: "redirect, chrome", expected: []handles.Backend{handles.BackendRedirect, handles.BackendChrome}},
+		{name: "duplicates dropped", value: "redirect,redirect,chrome", expected: []handles.Backend{handles.BackendRedirect, handles.BackendChrome}},
+		{name: "unknown backend", value: "redirect,fax", expectError: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			backends, err := handles.ParseBackends(testCase.value)
+			if testCase.expectError {
+				if !errors.Is(err, handles.ErrUnknownBackend) {
+					t.Fatalf("expected unknown backend error, got %v", err)
+				}
+				return
+			}
+			if err != nil || len(backends) != len(testCase.expected) {
+				t.Fatalf("expected %v, got %v (%v)", testCase.expected, backends, err)
+			}
+			for index, backend := range backends {
+				if backend != testCase.expected[index] {
+					t.Fatalf("expected %v, got %v", testCase.expected, backends)
+				}
+			}
+		})
+	}
+}
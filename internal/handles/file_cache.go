@@ -0,0 +1,300 @@
+package handles
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileCachePermissions     = 0o600
+	fileCacheDirPermissions  = 0o700
+	fileCacheTempPattern     = ".handle-cache-*"
+	fileCacheMaxLineBytes    = 1 << 20
+	errMessageEmptyCachePath = "handle cache path cannot be empty"
+)
+
+var errEmptyCachePath = errors.New(errMessageEmptyCachePath)
+
+// fileCacheLine is one JSON line of the cache file holding everything known about an account; later lines for the
+// same account supersede earlier ones. Lines written only to record history have a zero ResolvedAt.
+type fileCacheLine struct {
+	AccountID string        `json:"accountId"`
+	Record    AccountRecord `json:"record"`
+	Error     string        `json:"error,omitempty"`
+	// ErrorStatus preserves the classification of a ResolutionError; Error then holds only its cause.
+	ErrorStatus AccountStatus       `json:"errorStatus,omitempty"`
+	ResolvedAt  time.Time           `json:"resolvedAt"`
+	History     []HandleObservation `json:"history,omitempty"`
+}
+
+// FileCache persists resolution outcomes and handle histories as an append-only JSON Lines file so lookups survive
+// restarts. The file is compacted when opened, and each Store or Observe appends a single line.
+type FileCache struct {
+	file      *os.File
+	entries   map[string]CacheEntry
+	histories map[string][]HandleObservation
+	writeErr  error
+	mutex     sync.RWMutex
+}
+
+// OpenFileCache loads the cache stored at path, creating the file and its directory when missing.
+func OpenFileCache(path string) (*FileCache, error) {
+	trimmedPath := strings.TrimSpace(path)
+	if trimmedPath == "" {
+		return nil, errEmptyCachePath
+	}
+	if err := os.MkdirAll(filepath.Dir(trimmedPath), fileCacheDirPermissions); err != nil {
+		return nil, fmt.Errorf("create handle cache directory: %w", err)
+	}
+	cache := &FileCache{entries: make(map[string]CacheEntry), histories: make(map[string][]HandleObservation)}
+	lineCount, err := cache.read(trimmedPath)
+	if err != nil {
+		return nil, err
+	}
+	if lineCount > len(cache.accountIDs()) {
+		if err := cache.compact(trimmedPath); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(trimmedPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileCachePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("open handle cache: %w", err)
+	}
+	cache.file = file
+	return cache, nil
+}
+
+// Lookup retrieves the cached entry for the supplied account identifier.
+func (cache *FileCache) Lookup(accountID string) (CacheEntry, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	entry, found := cache.entries[accountID]
+	return entry, found
+}
+
+// LookupHandle returns the most recent successful entry for the handle.
+func (cache *FileCache) LookupHandle(userName string) (CacheEntry, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return lookupHandle(cache.entries, userName)
+}
+
+// Store saves the outcome in memory, adds a successful one to the account's history, and appends both to the cache
+// file. Write failures keep the entry in memory and are reported by Close.
+func (cache *FileCache) Store(accountID string, entry CacheEntry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[accountID] = entry
+	if observation, observed := resolvedObservation(entry); observed {
+		cache.histories[accountID] = withObservation(cache.histories[accountID], observation)
+	}
+	cache.appendLocked(accountID)
+}
+
+// History returns the observations of the account ordered from oldest to newest.
+func (cache *FileCache) History(accountID string) []HandleObservation {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return append([]HandleObservation(nil), cache.histories[accountID]...)
+}
+
+// Observe records a sighting of the account without changing its cached resolution and appends it to the cache file
+// when it extends the history.
+func (cache *FileCache) Observe(accountID string, observation HandleObservation) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	history := withObservation(cache.histories[accountID], observation)
+	if sameHistory(history, cache.histories[accountID]) {
+		return
+	}
+	cache.histories[accountID] = history
+	cache.appendLocked(accountID)
+}
+
+// appendLocked writes the account's current state as one line; the caller holds the write lock.
+func (cache *FileCache) appendLocked(accountID string) {
+	if cache.file == nil || cache.writeErr != nil {
+		return
+	}
+	encoded, err := json.Marshal(cache.lineLocked(accountID))
+	if err != nil {
+		cache.writeErr = fmt.Errorf("encode handle cache entry: %w", err)
+		return
+	}
+	if _, err := cache.file.Write(append(encoded, '\n')); err != nil {
+		cache.writeErr = fmt.Errorf("write handle cache: %w", err)
+	}
+}
+
+// Entries returns every cached outcome ordered by account identifier.
+func (cache *FileCache) Entries() []AccountEntry {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return sortedEntries(cache.entries)
+}
+
+// Len reports the number of accounts with a cached resolution.
+func (cache *FileCache) Len() int {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	return len(cache.entries)
+}
+
+// Close releases the cache file and reports the first write failure, if any.
+func (cache *FileCache) Close() error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.file == nil {
+		return cache.writeErr
+	}
+	closeErr := cache.file.Close()
+	cache.file = nil
+	if cache.writeErr != nil {
+		return cache.writeErr
+	}
+	if closeErr != nil {
+		return fmt.Errorf("close handle cache: %w", closeErr)
+	}
+	return nil
+}
+
+// lineLocked encodes the cached resolution and history of the account; the caller holds the lock.
+func (cache *FileCache) lineLocked(accountID string) fileCacheLine {
+	line := newFileCacheLine(accountID, cache.entries[accountID])
+	line.History = cache.histories[accountID]
+	return line
+}
+
+// accountIDs lists every account with a cached resolution or a history.
+func (cache *FileCache) accountIDs() []string {
+	accountIDs := make([]string, 0, len(cache.entries)+len(cache.histories))
+	for accountID := range cache.entries {
+		accountIDs = append(accountIDs, accountID)
+	}
+	for accountID := range cache.histories {
+		if _, resolved := cache.entries[accountID]; !resolved {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	return accountIDs
+}
+
+func newFileCacheLine(accountID string, entry CacheEntry) fileCacheLine {
+	line := fileCacheLine{AccountID: accountID, Record: entry.Record, ResolvedAt: entry.ResolvedAt.UTC()}
+	var resolutionErr *ResolutionError
+	switch {
+	case errors.As(entry.Err, &resolutionErr):
+		line.ErrorStatus = resolutionErr.Status
+		if resolutionErr.Err != nil {
+			line.Error = resolutionErr.Err.Error()
+		}
+	case entry.Err != nil:
+		line.Error = entry.Err.Error()
+	}
+	return line
+}
+
+func (line fileCacheLine) entry() CacheEntry {
+	entry := CacheEntry{Record: line.Record, ResolvedAt: line.ResolvedAt}
+	var cause error
+	if line.Error != "" {
+		cause = errors.New(line.Error)
+	}
+	if line.ErrorStatus != "" {
+		entry.Err = newResolutionError(line.AccountID, line.ErrorStatus, cause)
+	} else {
+		entry.Err = cause
+	}
+	return entry
+}
+
+// read parses the cache file into the cache, skipping malformed lines so a torn final write does not discard the
+// cache, and reports how many lines the file holds.
+func (cache *FileCache) read(path string) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open handle cache: %w", err)
+	}
+	defer file.Close()
+
+	lineCount := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), fileCacheMaxLineBytes)
+	for scanner.Scan() {
+		lineCount++
+		var line fileCacheLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || strings.TrimSpace(line.AccountID) == "" {
+			continue
+		}
+		delete(cache.entries, line.AccountID)
+		if !line.ResolvedAt.IsZero() {
+			cache.entries[line.AccountID] = line.entry()
+		}
+		delete(cache.histories, line.AccountID)
+		if len(line.History) > 0 {
+			cache.histories[line.AccountID] = line.History
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read handle cache: %w", err)
+	}
+	return lineCount, nil
+}
+
+// compact atomically rewrites the cache file with one line per account.
+func (cache *FileCache) compact(path string) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), fileCacheTempPattern)
+	if err != nil {
+		return fmt.Errorf("create handle cache: %w", err)
+	}
+	tempPath := tempFile.Name()
+	writer := bufio.NewWriter(tempFile)
+	encoder := json.NewEncoder(writer)
+	for _, accountID := range cache.accountIDs() {
+		if err := encoder.Encode(cache.lineLocked(accountID)); err != nil {
+			tempFile.Close()
+			_ = os.Remove(tempPath)
+			return fmt.Errorf("encode handle cache entry: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tempFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("write handle cache: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("close handle cache: %w", err)
+	}
+	if err := os.Chmod(tempPath, fileCachePermissions); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("chmod handle cache: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("replace handle cache: %w", err)
+	}
+	return nil
+}
+
+func sameHistory(first []HandleObservation, second []HandleObservation) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for index := range first {
+		if first[index] != second[index] {
+			return false
+		}
+	}
+	return true
+}
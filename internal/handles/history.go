@@ -0,0 +1,135 @@
+package handles
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// HandleObservation records a handle and display name seen for an account, and when they were seen.
+type HandleObservation struct {
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName,omitempty"`
+	// ObservedAt is the earliest time the pair was seen in a row: the lookup time for resolver observations and the
+	// export date for archive observations.
+	ObservedAt time.Time   `json:"observedAt"`
+	Source     LabelSource `json:"source"`
+	// Origin names the archive owner or the answering backend, like FieldProvenance.Origin.
+	Origin string `json:"origin,omitempty"`
+}
+
+// HistoryStore is implemented by caches that keep every handle and display name seen for an account instead of only
+// the latest resolution. Successful resolutions are recorded by Store; other sightings, such as archive labels, are
+// recorded with Observe.
+type HistoryStore interface {
+	// History returns the observations of the account ordered from oldest to newest.
+	History(accountID string) []HandleObservation
+	// Observe records a sighting of the account without changing its cached resolution.
+	Observe(accountID string, observation HandleObservation)
+}
+
+// ObservationOf returns the observation a record carries through its handle provenance. Records without a handle
+// or without a known provenance yield nothing.
+func ObservationOf(record AccountRecord) (HandleObservation, bool) {
+	userName := strings.TrimSpace(record.UserName)
+	provenance, known := record.UserNameProvenance()
+	if userName == "" || !known {
+		return HandleObservation{}, false
+	}
+	return HandleObservation{
+		UserName:    userName,
+		DisplayName: strings.TrimSpace(record.DisplayName),
+		ObservedAt:  provenance.ObservedAt.UTC(),
+		Source:      provenance.Source,
+		Origin:      provenance.Origin,
+	}, true
+}
+
+// FormerUserNames lists the handles in history other than current, most recently observed first.
+func FormerUserNames(history []HandleObservation, current string) []string {
+	var former []string
+	seen := map[string]struct{}{strings.ToLower(strings.TrimSpace(current)): {}}
+	for index := len(history) - 1; index >= 0; index-- {
+		key := strings.ToLower(history[index].UserName)
+		if _, duplicate := seen[key]; duplicate {
+			continue
+		}
+		seen[key] = struct{}{}
+		former = append(former, history[index].UserName)
+	}
+	return former
+}
+
+// WithFormerUserNames returns a copy of the record that also lists the supplied former handles, skipping its current
+// handle and names it already lists.
+func (record AccountRecord) WithFormerUserNames(userNames ...string) AccountRecord {
+	seen := map[string]struct{}{strings.ToLower(strings.TrimSpace(record.UserName)): {}}
+	for _, userName := range record.FormerUserNames {
+		seen[strings.ToLower(userName)] = struct{}{}
+	}
+	var added []string
+	for _, userName := range userNames {
+		trimmed := strings.TrimSpace(userName)
+		key := strings.ToLower(trimmed)
+		if _, duplicate := seen[key]; duplicate || trimmed == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+		added = append(added, trimmed)
+	}
+	if len(added) == 0 {
+		return record
+	}
+	record.FormerUserNames = append(append([]string(nil), record.FormerUserNames...), added...)
+	return record
+}
+
+// withObservation merges an observation into a history ordered from oldest to newest. Neighbouring observations of the
+// same handle and display name collapse into the earliest one, so a history only grows when an account changes.
+func withObservation(history []HandleObservation, observation HandleObservation) []HandleObservation {
+	if strings.TrimSpace(observation.UserName) == "" {
+		return history
+	}
+	merged := make([]HandleObservation, 0, len(history)+1)
+	merged = append(merged, history...)
+	position := sort.Search(len(merged), func(index int) bool {
+		return merged[index].ObservedAt.After(observation.ObservedAt)
+	})
+	merged = append(merged, HandleObservation{})
+	copy(merged[position+1:], merged[position:])
+	merged[position] = observation
+
+	collapsed := merged[:1]
+	for _, candidate := range merged[1:] {
+		if candidate.sameLabels(collapsed[len(collapsed)-1]) {
+			continue
+		}
+		collapsed = append(collapsed, candidate)
+	}
+	return collapsed
+}
+
+func (observation HandleObservation) sameLabels(other HandleObservation) bool {
+	return strings.EqualFold(observation.UserName, other.UserName) && observation.DisplayName == other.DisplayName
+}
+
+// resolvedObservation returns the observation recorded when a resolution is stored.
+func resolvedObservation(entry CacheEntry) (HandleObservation, bool) {
+	if entry.Err != nil {
+		return HandleObservation{}, false
+	}
+	observation, known := ObservationOf(entry.Record)
+	if !known {
+		if strings.TrimSpace(entry.Record.UserName) == "" {
+			return HandleObservation{}, false
+		}
+		observation = HandleObservation{UserName: strings.TrimSpace(entry.Record.UserName), DisplayName: strings.TrimSpace(entry.Record.DisplayName), Source: LabelSourceLiveFetch}
+	}
+	if observation.Source == LabelSourceResolverCache {
+		observation.Source = LabelSourceLiveFetch
+	}
+	if observation.ObservedAt.IsZero() {
+		observation.ObservedAt = entry.ResolvedAt.UTC()
+	}
+	return observation, true
+}
@@ -0,0 +1,115 @@
+package handles_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	historyTestAccountID     = "40001"
+	historyTestOldUserName   = "old_name"
+	historyTestNewUserName   = "new_name"
+	historyTestDisplayName   = "History Name"
+	historyTestArchiveOrigin = "owner"
+	historyTestNewIntentHTML = "<html><head><title>History Name (@new_name) / X</title></head><body><a href=\"https://x.com/new_name\">profile</a></body></html>"
+)
+
+func TestCachesKeepHandleHistory(t *testing.T) {
+	exportedAt := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+	resolvedAt := exportedAt.AddDate(1, 0, 0)
+	openers := map[string]func(t *testing.T) handles.Cache{
+		"memory": func(t *testing.T) handles.Cache { return handles.NewMemoryCache() },
+		"file": func(t *testing.T) handles.Cache {
+			cache, err := handles.OpenFileCache(filepath.Join(t.TempDir(), cacheTestFileName))
+			if err != nil {
+				t.Fatalf("open cache: %v", err)
+			}
+			t.Cleanup(func() { _ = cache.Close() })
+			return cache
+		},
+	}
+
+	for name, open := range openers {
+		open := open
+		t.Run(name, func(t *testing.T) {
+			cache := open(t)
+			historyStore := cache.(handles.HistoryStore)
+			historyStore.Observe(historyTestAccountID, handles.HandleObservation{UserName: historyTestOldUserName, ObservedAt: exportedAt, Source: handles.LabelSourceArchive, Origin: historyTestArchiveOrigin})
+			historyStore.Observe(historyTestAccountID, handles.HandleObservation{UserName: historyTestOldUserName, ObservedAt: exportedAt.AddDate(0, 1, 0), Source: handles.LabelSourceArchive})
+			if _, found := cache.Lookup(historyTestAccountID); found {
+				t.Fatalf("expected archive observations to leave the resolution uncached")
+			}
+
+			record := handles.AccountRecord{AccountID: historyTestAccountID}.
+				WithUserName(historyTestNewUserName, handles.FieldProvenance{Source: handles.LabelSourceLiveFetch, ObservedAt: resolvedAt})
+			cache.Store(historyTestAccountID, handles.CacheEntry{Record: record, ResolvedAt: resolvedAt})
+			cache.Store(historyTestAccountID, handles.CacheEntry{Record: record, ResolvedAt: resolvedAt.Add(time.Hour)})
+
+			history := historyStore.History(historyTestAccountID)
+			if len(history) != 2 {
+				t.Fatalf("expected a rename to add exactly one observation, got %+v", history)
+			}
+			if history[0].UserName != historyTestOldUserName || !history[0].ObservedAt.Equal(exportedAt) {
+				t.Fatalf("expected the earliest archive sighting first, got %+v", history[0])
+			}
+			if history[1].UserName != historyTestNewUserName || history[1].Source != handles.LabelSourceLiveFetch {
+				t.Fatalf("expected the lookup last, got %+v", history[1])
+			}
+			if former := handles.FormerUserNames(history, historyTestNewUserName); !reflect.DeepEqual(former, []string{historyTestOldUserName}) {
+				t.Fatalf("unexpected former handles %v", former)
+			}
+		})
+	}
+}
+
+func TestFileCachePersistsHistory(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), cacheTestFileName)
+	cache, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	observedAt := time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC)
+	cache.Observe(historyTestAccountID, handles.HandleObservation{UserName: historyTestOldUserName, DisplayName: historyTestDisplayName, ObservedAt: observedAt, Source: handles.LabelSourceArchive})
+	cache.Store(historyTestAccountID, handles.CacheEntry{Record: handles.AccountRecord{AccountID: historyTestAccountID, UserName: historyTestNewUserName}, ResolvedAt: observedAt.AddDate(1, 0, 0)})
+	if err := cache.Close(); err != nil {
+		t.Fatalf("close cache: %v", err)
+	}
+
+	reopened, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		t.Fatalf("reopen cache: %v", err)
+	}
+	defer reopened.Close()
+	history := reopened.History(historyTestAccountID)
+	if len(history) != 2 || history[0].DisplayName != historyTestDisplayName || history[1].UserName != historyTestNewUserName {
+		t.Fatalf("unexpected history after reopen %+v", history)
+	}
+	if entry, found := reopened.Lookup(historyTestAccountID); !found || entry.Record.UserName != historyTestNewUserName {
+		t.Fatalf("expected the resolution to survive reopening, got %+v", entry)
+	}
+}
+
+func TestResolverReportsFormerUserNames(t *testing.T) {
+	cache := handles.NewMemoryCache()
+	cache.Observe(historyTestAccountID, handles.HandleObservation{UserName: historyTestOldUserName, ObservedAt: time.Now().AddDate(-1, 0, 0), Source: handles.LabelSourceArchive})
+	fetcher := newRecordingIntentFetcher(map[string]handles.IntentPage{historyTestAccountID: {HTML: historyTestNewIntentHTML}}, nil)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: cache})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		record, resolveErr := resolver.ResolveAccount(context.Background(), historyTestAccountID)
+		if resolveErr != nil {
+			t.Fatalf("resolve account: %v", resolveErr)
+		}
+		if record.UserName != historyTestNewUserName || !reflect.DeepEqual(record.FormerUserNames, []string{historyTestOldUserName}) {
+			t.Fatalf("attempt %d: unexpected record %+v", attempt, record)
+		}
+	}
+}
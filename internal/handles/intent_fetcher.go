@@ -3,13 +3,17 @@ package handles
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
 )
 
 const (
@@ -68,16 +72,46 @@ var (
 	}
 )
 
-// IntentRequest describes the request to fetch an intent page for a numeric account identifier.
+// IntentRequest describes the request to fetch an intent page for a numeric account identifier, or the profile page
+// of a handle.
 type IntentRequest struct {
 	AccountID string
-	URL       string
+	// UserName is set instead of AccountID for handle lookups, whose URL is the profile page.
+	UserName string
+	URL      string
+}
+
+// isHandleLookup reports whether the request looks an account up by handle.
+func (request IntentRequest) isHandleLookup() bool {
+	return request.AccountID == "" && request.UserName != ""
+}
+
+// reference names the looked up account in errors: its identifier, or its handle with the leading @.
+func (request IntentRequest) reference() string {
+	if request.isHandleLookup() {
+		return extractHandlePrefix + request.UserName
+	}
+	return request.AccountID
 }
 
 // IntentPage captures the rendered HTML for a Twitter intent page.
 type IntentPage struct {
 	HTML      string
 	SourceURL string
+	// AccountID is set by fetchers that learn the numeric identifier directly, such as from an API user, and takes
+	// precedence over the identifier found in the HTML of a handle lookup.
+	AccountID string
+	// UserName is set by fetchers that learn the handle directly, such as from a redirect, and takes precedence over
+	// the handle extracted from HTML.
+	UserName string
+	// DisplayName is set by fetchers that read structured profile data and takes precedence over the page title.
+	DisplayName string
+	// Status is set by fetchers that learn the account status directly, such as the protected flag of an API user.
+	Status AccountStatus
+	// Profile is set by fetchers that read structured profile data and takes precedence over details in the HTML.
+	Profile *Profile
+	// Backend names the backend that answered when the page came from a fallback chain.
+	Backend Backend
 }
 
 // IntentFetcher retrieves rendered intent pages.
@@ -85,12 +119,34 @@ type IntentFetcher interface {
 	FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error)
 }
 
+// IntentOutcome pairs a fetched page with the error reported for one account of a batch.
+type IntentOutcome struct {
+	Page IntentPage
+	Err  error
+}
+
+// BatchIntentFetcher is implemented by fetchers that can look up many accounts per request. Accounts missing from
+// the returned map were not attempted and are looked up one at a time.
+type BatchIntentFetcher interface {
+	IntentFetcher
+	FetchIntentPages(ctx context.Context, requests []IntentRequest) map[string]IntentOutcome
+}
+
 // ChromeFetcherConfig configures a ChromeIntentFetcher instance.
 type ChromeFetcherConfig struct {
 	BinaryPath        string
 	UserAgent         string
 	VirtualTimeBudget time.Duration
-	RequestDelay      time.Duration
+	// RequestDelay sets the pace when RateLimit.RequestsPerSecond is zero; negative disables pacing.
+	RequestDelay time.Duration
+	// RateLimit paces Chrome invocations; a rendered rate-limit page pauses them like a 429 response.
+	RateLimit ratelimit.Config
+	// UserDataDir is a persistent Chrome profile passed as --user-data-dir, typically one the user has logged into
+	// once so that protected and age-restricted accounts render. Chrome runs on a throwaway profile when empty.
+	UserDataDir string
+	// CookieFile is a Netscape cookie file imported into UserDataDir before the first page is rendered; it requires
+	// UserDataDir. Cookie values are never logged or cached.
+	CookieFile string
 }
 
 // ChromeIntentFetcher renders intent pages using a headless Chrome invocation.
@@ -98,10 +154,12 @@ type ChromeIntentFetcher struct {
 	chromeBinaryPath  string
 	userAgent         string
 	virtualTimeBudget time.Duration
-	requestDelay      time.Duration
+	limiter           *ratelimit.Limiter
+	userDataDir       string
+	// pendingCookies are imported into the profile before the next render and cleared once they are.
+	pendingCookies []*http.Cookie
 
 	executionMutex sync.Mutex
-	lastInvocation time.Time
 }
 
 // NewChromeIntentFetcher constructs a ChromeIntentFetcher from configuration values.
@@ -127,23 +185,56 @@ func NewChromeIntentFetcher(configuration ChromeFetcherConfig) (*ChromeIntentFet
 	} else if requestDelay == 0 {
 		requestDelay = time.Duration(chromeRequestDelayDefaultMillis) * time.Millisecond
 	}
+	rateLimit := configuration.RateLimit
+	if rateLimit.RequestsPerSecond == 0 {
+		rateLimit.RequestsPerSecond = -1
+		if requestDelay > 0 {
+			rateLimit.RequestsPerSecond = 1 / requestDelay.Seconds()
+		}
+	}
+
+	userDataDir := strings.TrimSpace(configuration.UserDataDir)
+	var cookies []*http.Cookie
+	if cookieFile := strings.TrimSpace(configuration.CookieFile); cookieFile != "" {
+		if userDataDir == "" {
+			return nil, errCookiesNeedProfile
+		}
+		var cookieErr error
+		if cookies, cookieErr = ReadCookieFile(cookieFile); cookieErr != nil {
+			return nil, cookieErr
+		}
+	}
 
 	fetcher := &ChromeIntentFetcher{
 		chromeBinaryPath:  trimmedBinaryPath,
 		userAgent:         userAgent,
 		virtualTimeBudget: virtualTimeBudget,
-		requestDelay:      requestDelay,
+		limiter:           ratelimit.New(rateLimit),
+		userDataDir:       userDataDir,
+		pendingCookies:    cookies,
 	}
 	return fetcher, nil
 }
 
-// FetchIntentPage renders the provided intent URL using headless Chrome.
+// FetchIntentPage renders the provided intent URL using headless Chrome. A page that asks to log in instead of
+// showing the account fails with an error matching ErrLoginWall.
 func (fetcher *ChromeIntentFetcher) FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error) {
 	fetcher.executionMutex.Lock()
 	defer fetcher.executionMutex.Unlock()
 
-	fetcher.enforceDelay()
-	defer fetcher.recordInvocation()
+	if waitErr := fetcher.limiter.Wait(ctx); waitErr != nil {
+		if errors.Is(waitErr, ratelimit.ErrRateLimited) {
+			return IntentPage{}, newResolutionError(request.reference(), AccountStatusRateLimited, waitErr)
+		}
+		return IntentPage{}, waitErr
+	}
+
+	if len(fetcher.pendingCookies) > 0 {
+		if importErr := importChromeCookies(ctx, fetcher.chromeBinaryPath, fetcher.userDataDir, fetcher.pendingCookies); importErr != nil {
+			return IntentPage{}, importErr
+		}
+		fetcher.pendingCookies = nil
+	}
 
 	htmlContent, renderErr := fetcher.renderIntentPage(ctx, request.URL)
 	if renderErr != nil {
@@ -152,25 +243,18 @@ func (fetcher *ChromeIntentFetcher) FetchIntentPage(ctx context.Context, request
 	if strings.TrimSpace(htmlContent) == "" {
 		return IntentPage{}, fmt.Errorf("%w: %s", errEmptyIntentHTML, request.URL)
 	}
-
-	return IntentPage{HTML: htmlContent, SourceURL: request.URL}, nil
-}
-
-func (fetcher *ChromeIntentFetcher) enforceDelay() {
-	if fetcher.requestDelay <= 0 {
-		return
-	}
-	if fetcher.lastInvocation.IsZero() {
-		return
+	pageStatus := classifyIntentPage(htmlContent, request.AccountID)
+	if pageStatus == AccountStatusRateLimited {
+		// Fail the lookup rather than return the page, so that a fallback chain moves on to its next backend.
+		fetcher.limiter.Pause(ratelimit.DefaultRateLimitWait)
+		return IntentPage{}, newResolutionError(request.reference(), AccountStatusRateLimited, fmt.Errorf("%w: %s", errRateLimitPage, request.URL))
 	}
-	elapsed := time.Since(fetcher.lastInvocation)
-	if elapsed < fetcher.requestDelay {
-		time.Sleep(fetcher.requestDelay - elapsed)
+	fetcher.limiter.RecordSuccess()
+	if pageStatus == "" && isLoginWall(htmlContent, request.AccountID) {
+		return IntentPage{}, newResolutionError(request.reference(), AccountStatusTransient, fmt.Errorf("%w: %s", ErrLoginWall, request.URL))
 	}
-}
 
-func (fetcher *ChromeIntentFetcher) recordInvocation() {
-	fetcher.lastInvocation = time.Now()
+	return IntentPage{HTML: htmlContent, SourceURL: request.URL}, nil
 }
 
 func (fetcher *ChromeIntentFetcher) renderIntentPage(ctx context.Context, requestURL string) (string, error) {
@@ -206,6 +290,9 @@ func (fetcher *ChromeIntentFetcher) buildCommandArguments(requestURL string) []s
 	arguments := append([]string{}, baseChromeArguments...)
 	userAgentArgument := fmt.Sprintf(chromeUserAgentFlagFormat, fetcher.userAgent)
 	arguments = append(arguments, userAgentArgument)
+	if fetcher.userDataDir != "" {
+		arguments = append(arguments, fmt.Sprintf(chromeUserDataDirFlagFormat, fetcher.userDataDir))
+	}
 	if fetcher.virtualTimeBudget > 0 {
 		budgetMillis := int(fetcher.virtualTimeBudget / time.Millisecond)
 		arguments = append(arguments, fmt.Sprintf(chromeVirtualTimeBudgetFlagFormat, budgetMillis))
@@ -0,0 +1,67 @@
+package handles
+
+import (
+	"strings"
+	"time"
+)
+
+// Profile holds the optional profile details observed while resolving an account.
+type Profile struct {
+	AvatarURL string `json:"avatarURL,omitempty"`
+	Bio       string `json:"bio,omitempty"`
+	Protected bool   `json:"protected,omitempty"`
+	Verified  bool   `json:"verified,omitempty"`
+	// FollowersCount and FollowingCount are nil when the source did not report them.
+	FollowersCount *int `json:"followersCount,omitempty"`
+	FollowingCount *int `json:"followingCount,omitempty"`
+	// FetchedAt is when the details were observed; it is zero for details that did not come from a lookup.
+	FetchedAt time.Time `json:"fetchedAt,omitzero"`
+}
+
+// IsEmpty reports whether the profile carries no details besides its fetch time.
+func (profile Profile) IsEmpty() bool {
+	return strings.TrimSpace(profile.AvatarURL) == "" &&
+		strings.TrimSpace(profile.Bio) == "" &&
+		!profile.Protected &&
+		!profile.Verified &&
+		profile.FollowersCount == nil &&
+		profile.FollowingCount == nil
+}
+
+// mergedWith fills the details missing from profile with those of fallback.
+func (profile Profile) mergedWith(fallback Profile) Profile {
+	if strings.TrimSpace(profile.AvatarURL) == "" {
+		profile.AvatarURL = fallback.AvatarURL
+	}
+	if strings.TrimSpace(profile.Bio) == "" {
+		profile.Bio = fallback.Bio
+	}
+	profile.Protected = profile.Protected || fallback.Protected
+	profile.Verified = profile.Verified || fallback.Verified
+	if profile.FollowersCount == nil {
+		profile.FollowersCount = fallback.FollowersCount
+	}
+	if profile.FollowingCount == nil {
+		profile.FollowingCount = fallback.FollowingCount
+	}
+	if profile.FetchedAt.IsZero() {
+		profile.FetchedAt = fallback.FetchedAt
+	}
+	return profile
+}
+
+// IsProtected reports whether the account's posts are protected, either from its profile or from its lookup status.
+func (record AccountRecord) IsProtected() bool {
+	return record.Status == AccountStatusProtected || (record.Profile != nil && record.Profile.Protected)
+}
+
+// IsVerified reports whether the account's profile shows a verification badge.
+func (record AccountRecord) IsVerified() bool {
+	return record.Profile != nil && record.Profile.Verified
+}
+
+// WithProfile returns a copy of the record carrying the supplied profile details.
+func (record AccountRecord) WithProfile(profile Profile) AccountRecord {
+	record.Profile = &profile
+	return record
+}
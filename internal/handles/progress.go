@@ -0,0 +1,162 @@
+package handles
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProgressEventKind identifies a step reported while ResolveMany works through a batch.
+type ProgressEventKind string
+
+const (
+	// ProgressStarted is emitted once before any account is resolved and carries the batch total.
+	ProgressStarted ProgressEventKind = "started"
+	// ProgressResolved is emitted when an account settles with a handle.
+	ProgressResolved ProgressEventKind = "resolved"
+	// ProgressFailed is emitted when an account settles with an error, including suspended and deleted accounts.
+	ProgressFailed ProgressEventKind = "failed"
+	// ProgressRetrying is emitted before a transient failure is retried.
+	ProgressRetrying ProgressEventKind = "retrying"
+	// ProgressSkipped is emitted when an account settles unresolved because the resolution budget ran out or an offline
+	// resolver has no cached outcome for it.
+	ProgressSkipped ProgressEventKind = "skipped"
+)
+
+// Progress summarises how far a ResolveMany call has come.
+type Progress struct {
+	// Total is the number of distinct accounts in the batch.
+	Total int
+	// Completed counts accounts that settled, successfully, with an error, or skipped.
+	Completed int
+	// Resolved counts accounts that settled with a handle.
+	Resolved int
+	// Failed counts accounts that settled with an error.
+	Failed int
+	// Skipped counts accounts left unresolved because the resolution budget ran out or they were not cached offline.
+	Skipped int
+	// StartedAt is when the batch started.
+	StartedAt time.Time
+	// Elapsed is the time spent on the batch so far.
+	Elapsed time.Duration
+	// ETA estimates the time left from the average pace so far; it is zero until an account completes.
+	ETA time.Duration
+}
+
+// Remaining returns the number of accounts that have not settled yet.
+func (progress Progress) Remaining() int {
+	return progress.Total - progress.Completed
+}
+
+// ProgressEvent describes one step of a ResolveMany call along with the batch counts after that step.
+type ProgressEvent struct {
+	Kind ProgressEventKind
+	// AccountID is the account the event refers to; it is empty for ProgressStarted.
+	AccountID string
+	// Err is the failure for ProgressFailed, the failure being retried for ProgressRetrying, and ErrBudgetExhausted or
+	// ErrNotCached for ProgressSkipped.
+	Err error
+	// Attempt is the attempt that failed for ProgressRetrying.
+	Attempt  int
+	Progress Progress
+}
+
+// ProgressFunc receives progress events. Calls are serialised, so implementations need no locking of their own, but
+// they run on resolver goroutines and should return quickly.
+type ProgressFunc func(ProgressEvent)
+
+type progressContextKey struct{}
+
+// WithProgress returns a context that makes ResolveMany report its progress to progressFunc.
+func WithProgress(ctx context.Context, progressFunc ProgressFunc) context.Context {
+	if progressFunc == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressContextKey{}, progressFunc)
+}
+
+// progressTracker keeps the counts for one ResolveMany call and forwards events to its ProgressFunc.
+type progressTracker struct {
+	mutex        sync.Mutex
+	progressFunc ProgressFunc
+	progress     Progress
+}
+
+type progressTrackerContextKey struct{}
+
+// startProgress creates a tracker for a batch of total accounts when ctx carries a ProgressFunc, emits the started
+// event and returns a context through which retries are reported. A nil tracker is returned otherwise; its methods are
+// no-ops.
+func startProgress(ctx context.Context, total int) (context.Context, *progressTracker) {
+	progressFunc, found := ctx.Value(progressContextKey{}).(ProgressFunc)
+	if !found {
+		return ctx, nil
+	}
+	tracker := &progressTracker{
+		progressFunc: progressFunc,
+		progress:     Progress{Total: total, StartedAt: time.Now()},
+	}
+	tracker.emit(ProgressEvent{Kind: ProgressStarted}, nil)
+	return context.WithValue(ctx, progressTrackerContextKey{}, tracker), tracker
+}
+
+// progressFromContext returns the tracker installed by startProgress, if any.
+func progressFromContext(ctx context.Context) *progressTracker {
+	tracker, _ := ctx.Value(progressTrackerContextKey{}).(*progressTracker)
+	return tracker
+}
+
+// settled records the outcome of one account.
+func (tracker *progressTracker) settled(accountID string, settleErr error) {
+	if tracker == nil {
+		return
+	}
+	event := ProgressEvent{Kind: ProgressResolved, AccountID: accountID}
+	if settleErr != nil {
+		event.Kind = ProgressFailed
+		event.Err = settleErr
+	}
+	tracker.emit(event, func(progress *Progress) {
+		progress.Completed++
+		if settleErr != nil {
+			progress.Failed++
+		} else {
+			progress.Resolved++
+		}
+	})
+}
+
+// skipped records an account left unresolved without a lookup; reason is ErrBudgetExhausted or ErrNotCached.
+func (tracker *progressTracker) skipped(accountID string, reason error) {
+	if tracker == nil {
+		return
+	}
+	tracker.emit(ProgressEvent{Kind: ProgressSkipped, AccountID: accountID, Err: reason}, func(progress *Progress) {
+		progress.Completed++
+		progress.Skipped++
+	})
+}
+
+// retrying reports that a failed attempt is about to be retried.
+func (tracker *progressTracker) retrying(accountID string, attempt int, attemptErr error) {
+	if tracker == nil {
+		return
+	}
+	tracker.emit(ProgressEvent{Kind: ProgressRetrying, AccountID: accountID, Err: attemptErr, Attempt: attempt}, nil)
+}
+
+func (tracker *progressTracker) emit(event ProgressEvent, update func(*Progress)) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if update != nil {
+		update(&tracker.progress)
+	}
+	tracker.progress.Elapsed = time.Since(tracker.progress.StartedAt)
+	tracker.progress.ETA = 0
+	if tracker.progress.Completed > 0 {
+		averagePerAccount := tracker.progress.Elapsed / time.Duration(tracker.progress.Completed)
+		tracker.progress.ETA = averagePerAccount * time.Duration(tracker.progress.Remaining())
+	}
+	event.Progress = tracker.progress
+	tracker.progressFunc(event)
+}
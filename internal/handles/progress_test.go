@@ -0,0 +1,115 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	progressTestAccountIDFirst    = "70001"
+	progressTestAccountIDSecond   = "70002"
+	progressTestAccountIDFailing  = "70003"
+	progressTestErrorMessage      = "intent page unavailable"
+	progressTestMaxAttempts       = 2
+	progressTestExpectedTotal     = 3
+	progressTestExpectedResolved  = 2
+	progressTestExpectedFailed    = 1
+	progressTestExpectedRetryings = 1
+)
+
+func TestResolveManyReportsProgress(t *testing.T) {
+	fetcher := newRecordingIntentFetcher(
+		map[string]handles.IntentPage{
+			progressTestAccountIDFirst: {
+				HTML:      resolverTestIntentHTMLSuccess,
+				SourceURL: resolverTestIntentURLPrefix + progressTestAccountIDFirst,
+			},
+			progressTestAccountIDSecond: {
+				HTML:      strings.ReplaceAll(resolverTestIntentHTMLSuccess, "example", "second"),
+				SourceURL: resolverTestIntentURLPrefix + progressTestAccountIDSecond,
+			},
+		},
+		map[string]error{progressTestAccountIDFailing: errors.New(progressTestErrorMessage)},
+	)
+	resolver, err := handles.NewResolver(handles.Config{
+		IntentFetcher: fetcher,
+		MaxConcurrent: 2,
+		MaxAttempts:   progressTestMaxAttempts,
+		RetryDelay:    -1,
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	var events []handles.ProgressEvent
+	ctx := handles.WithProgress(context.Background(), func(event handles.ProgressEvent) {
+		events = append(events, event)
+	})
+	resolver.ResolveMany(ctx, []string{progressTestAccountIDFirst, progressTestAccountIDSecond, progressTestAccountIDFailing, progressTestAccountIDFirst})
+
+	if len(events) == 0 || events[0].Kind != handles.ProgressStarted {
+		t.Fatalf("expected the first event to be %q, got %+v", handles.ProgressStarted, events)
+	}
+	if events[0].Progress.Total != progressTestExpectedTotal {
+		t.Fatalf("expected total %d, got %d", progressTestExpectedTotal, events[0].Progress.Total)
+	}
+
+	counts := make(map[handles.ProgressEventKind]int)
+	previousCompleted := 0
+	for _, event := range events {
+		counts[event.Kind]++
+		if event.Progress.Completed < previousCompleted {
+			t.Fatalf("completed count went backwards: %+v", event)
+		}
+		previousCompleted = event.Progress.Completed
+		switch event.Kind {
+		case handles.ProgressFailed:
+			if event.AccountID != progressTestAccountIDFailing || !errors.Is(event.Err, handles.ErrTransientFailure) {
+				t.Fatalf("unexpected failed event: %+v", event)
+			}
+		case handles.ProgressRetrying:
+			if event.AccountID != progressTestAccountIDFailing || event.Attempt != 1 {
+				t.Fatalf("unexpected retrying event: %+v", event)
+			}
+		}
+	}
+
+	testCases := []struct {
+		kind     handles.ProgressEventKind
+		expected int
+	}{
+		{kind: handles.ProgressStarted, expected: 1},
+		{kind: handles.ProgressResolved, expected: progressTestExpectedResolved},
+		{kind: handles.ProgressFailed, expected: progressTestExpectedFailed},
+		{kind: handles.ProgressRetrying, expected: progressTestExpectedRetryings},
+	}
+	for _, testCase := range testCases {
+		if counts[testCase.kind] != testCase.expected {
+			t.Fatalf("expected %d %q events, got %d", testCase.expected, testCase.kind, counts[testCase.kind])
+		}
+	}
+
+	final := events[len(events)-1].Progress
+	if final.Completed != final.Total || final.Resolved != progressTestExpectedResolved || final.Failed != progressTestExpectedFailed {
+		t.Fatalf("unexpected final progress: %+v", final)
+	}
+	if final.Remaining() != 0 || final.ETA != 0 {
+		t.Fatalf("expected nothing remaining once done, got %d remaining and ETA %s", final.Remaining(), final.ETA)
+	}
+}
+
+func TestResolveManyWithoutProgressFunc(t *testing.T) {
+	fetcher := newRecordingIntentFetcher(nil, nil)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	ctx := handles.WithProgress(context.Background(), nil)
+	if results := resolver.ResolveMany(ctx, []string{progressTestAccountIDFailing + "0"}); len(results) != 1 {
+		t.Fatalf("expected one result, got %d", len(results))
+	}
+}
@@ -0,0 +1,92 @@
+package handles
+
+import "time"
+
+// LabelSource identifies where an account label was obtained.
+type LabelSource string
+
+const (
+	// LabelSourceArchive marks labels read from a Twitter export archive.
+	LabelSourceArchive LabelSource = "archive"
+	// LabelSourceResolverCache marks labels served from a previously resolved lookup.
+	LabelSourceResolverCache LabelSource = "resolver-cache"
+	// LabelSourceLiveFetch marks labels obtained by fetching the intent page during the current lookup.
+	LabelSourceLiveFetch LabelSource = "live-fetch"
+)
+
+// FieldProvenance records where a single label came from and when it was observed.
+type FieldProvenance struct {
+	Source LabelSource `json:"source"`
+	// Origin names the archive owner for archive labels and the answering backend, when known, for resolver labels.
+	Origin     string    `json:"origin,omitempty"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// Provenance records per-field label provenance for an account record.
+type Provenance struct {
+	UserName    *FieldProvenance `json:"userName,omitempty"`
+	DisplayName *FieldProvenance `json:"displayName,omitempty"`
+}
+
+// UserNameProvenance returns the provenance of the handle when it is known.
+func (record AccountRecord) UserNameProvenance() (FieldProvenance, bool) {
+	if record.Provenance == nil || record.Provenance.UserName == nil {
+		return FieldProvenance{}, false
+	}
+	return *record.Provenance.UserName, true
+}
+
+// DisplayNameProvenance returns the provenance of the display name when it is known.
+func (record AccountRecord) DisplayNameProvenance() (FieldProvenance, bool) {
+	if record.Provenance == nil || record.Provenance.DisplayName == nil {
+		return FieldProvenance{}, false
+	}
+	return *record.Provenance.DisplayName, true
+}
+
+// WithUserName returns a copy of the record carrying the supplied handle and its provenance.
+// Provenance values are shared between copies, so records are updated copy-on-write.
+func (record AccountRecord) WithUserName(userName string, provenance FieldProvenance) AccountRecord {
+	updatedProvenance := record.copyProvenance()
+	updatedProvenance.UserName = &provenance
+	record.UserName = userName
+	record.Provenance = &updatedProvenance
+	return record
+}
+
+// WithDisplayName returns a copy of the record carrying the supplied display name and its provenance.
+func (record AccountRecord) WithDisplayName(displayName string, provenance FieldProvenance) AccountRecord {
+	updatedProvenance := record.copyProvenance()
+	updatedProvenance.DisplayName = &provenance
+	record.DisplayName = displayName
+	record.Provenance = &updatedProvenance
+	return record
+}
+
+// WithSource returns a copy of the record whose recorded provenance reports the supplied source while keeping the
+// original observation times.
+func (record AccountRecord) WithSource(source LabelSource) AccountRecord {
+	if record.Provenance == nil {
+		return record
+	}
+	updatedProvenance := Provenance{}
+	if record.Provenance.UserName != nil {
+		userNameProvenance := *record.Provenance.UserName
+		userNameProvenance.Source = source
+		updatedProvenance.UserName = &userNameProvenance
+	}
+	if record.Provenance.DisplayName != nil {
+		displayNameProvenance := *record.Provenance.DisplayName
+		displayNameProvenance.Source = source
+		updatedProvenance.DisplayName = &displayNameProvenance
+	}
+	record.Provenance = &updatedProvenance
+	return record
+}
+
+func (record AccountRecord) copyProvenance() Provenance {
+	if record.Provenance == nil {
+		return Provenance{}
+	}
+	return *record.Provenance
+}
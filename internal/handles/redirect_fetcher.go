@@ -0,0 +1,143 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	redirectUserPathFormat           = "/i/user/%s"
+	redirectSuspendedPath            = "account/suspended"
+	redirectLocationHeader           = "Location"
+	redirectUserAgentHeader          = "User-Agent"
+	redirectRequestTimeoutSeconds    = 12
+	redirectBodyDrainLimit           = 4096
+	errMessageNoRedirect             = "profile lookup did not redirect"
+	errMessageEmptyRedirectHandle    = "redirect location did not contain a handle"
+	errMessageUnexpectedStatusFormat = "unexpected status %d"
+	handlePattern                    = `^[A-Za-z0-9_]{1,15}$`
+)
+
+var (
+	handleRegex = regexp.MustCompile(handlePattern)
+
+	errNoRedirect          = errors.New(errMessageNoRedirect)
+	errEmptyRedirectHandle = errors.New(errMessageEmptyRedirectHandle)
+)
+
+// RedirectFetcherConfig configures a RedirectIntentFetcher instance.
+type RedirectFetcherConfig struct {
+	// BaseURL is the site whose /i/user/<id> path redirects to the profile; https://x.com is used when empty.
+	BaseURL string
+	// HTTPClient performs requests; a client with a 12 second timeout is used when nil. Redirects are never followed.
+	HTTPClient *http.Client
+	UserAgent  string
+	// RateLimit paces requests and bounds how 429 responses are waited out.
+	RateLimit ratelimit.Config
+}
+
+// RedirectIntentFetcher resolves handles without a browser by reading the Location header returned for
+// https://x.com/i/user/<id>.
+type RedirectIntentFetcher struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+	limiter    *ratelimit.Limiter
+}
+
+// NewRedirectIntentFetcher constructs a RedirectIntentFetcher from configuration values.
+func NewRedirectIntentFetcher(configuration RedirectFetcherConfig) (*RedirectIntentFetcher, error) {
+	baseURLString := strings.TrimSpace(configuration.BaseURL)
+	if baseURLString == "" {
+		baseURLString = defaultIntentBaseURLString
+	}
+	parsedBaseURL, parseErr := url.Parse(baseURLString)
+	if parseErr != nil {
+		return nil, fmt.Errorf("parse base url: %w", parseErr)
+	}
+
+	baseClient := configuration.HTTPClient
+	if baseClient == nil {
+		baseClient = &http.Client{Timeout: time.Duration(redirectRequestTimeoutSeconds) * time.Second}
+	}
+	httpClient := *baseClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	userAgent := strings.TrimSpace(configuration.UserAgent)
+	if userAgent == "" {
+		userAgent = defaultChromeUserAgent
+	}
+
+	fetcher := &RedirectIntentFetcher{
+		baseURL:    parsedBaseURL,
+		httpClient: &httpClient,
+		userAgent:  userAgent,
+		limiter:    ratelimit.New(configuration.RateLimit),
+	}
+	return fetcher, nil
+}
+
+// FetchIntentPage requests the account's redirect and reports the handle found in the Location header. Redirects only
+// lead from identifiers to handles, so handle lookups fail with ErrHandleLookupUnsupported.
+func (fetcher *RedirectIntentFetcher) FetchIntentPage(ctx context.Context, request IntentRequest) (IntentPage, error) {
+	if request.isHandleLookup() {
+		return IntentPage{}, ErrHandleLookupUnsupported
+	}
+	requestURL := fetcher.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf(redirectUserPathFormat, request.AccountID)}).String()
+	httpRequest, requestErr := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if requestErr != nil {
+		return IntentPage{}, requestErr
+	}
+	httpRequest.Header.Set(redirectUserAgentHeader, fetcher.userAgent)
+	response, doErr := fetcher.limiter.Do(fetcher.httpClient, httpRequest)
+	if errors.Is(doErr, ratelimit.ErrRateLimited) {
+		return IntentPage{}, newResolutionError(request.AccountID, AccountStatusRateLimited, doErr)
+	}
+	if doErr != nil {
+		return IntentPage{}, doErr
+	}
+	drainAndClose(response.Body)
+	return fetcher.interpretResponse(request.AccountID, response)
+}
+
+func (fetcher *RedirectIntentFetcher) interpretResponse(accountID string, response *http.Response) (IntentPage, error) {
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return IntentPage{}, newResolutionError(accountID, AccountStatusDoesNotExist, nil)
+	case response.StatusCode >= http.StatusMultipleChoices && response.StatusCode < http.StatusBadRequest:
+	case response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices:
+		return IntentPage{}, errNoRedirect
+	default:
+		return IntentPage{}, fmt.Errorf(errMessageUnexpectedStatusFormat, response.StatusCode)
+	}
+
+	location, parseErr := response.Location()
+	if parseErr != nil {
+		return IntentPage{}, fmt.Errorf("parse %s: %w", redirectLocationHeader, parseErr)
+	}
+	path := strings.Trim(location.Path, "/")
+	if strings.EqualFold(path, redirectSuspendedPath) {
+		return IntentPage{}, newResolutionError(accountID, AccountStatusSuspended, nil)
+	}
+	handle, _, _ := strings.Cut(path, "/")
+	if _, reserved := reservedHandleNames[strings.ToLower(handle)]; reserved || !handleRegex.MatchString(handle) {
+		return IntentPage{}, errEmptyRedirectHandle
+	}
+	return IntentPage{SourceURL: location.String(), UserName: handle}, nil
+}
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, redirectBodyDrainLimit)
+	_ = body.Close()
+}
@@ -0,0 +1,205 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	redirectTestPathPrefix           = "/i/user/"
+	redirectTestAccountIDSuccess     = "50001"
+	redirectTestAccountIDSuspended   = "50002"
+	redirectTestAccountIDMissing     = "50003"
+	redirectTestAccountIDRetry       = "50004"
+	redirectTestAccountIDLimited     = "50005"
+	redirectTestAccountIDNoRedirect  = "50006"
+	redirectTestAccountIDLoginWall   = "50007"
+	redirectTestAccountIDResetHeader = "50008"
+	redirectTestProfileLocation      = "https://x.com/example"
+	redirectTestSuspendedLocation    = "https://x.com/account/suspended"
+	redirectTestLoginLocation        = "https://x.com/i/flow/login"
+	redirectTestLongRetryAfter       = "120"
+)
+
+type redirectTestServer struct {
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func (server *redirectTestServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	accountID := strings.TrimPrefix(request.URL.Path, redirectTestPathPrefix)
+	server.mutex.Lock()
+	server.calls[accountID]++
+	call := server.calls[accountID]
+	server.mutex.Unlock()
+
+	switch accountID {
+	case redirectTestAccountIDSuccess:
+		http.Redirect(writer, request, redirectTestProfileLocation, http.StatusFound)
+	case redirectTestAccountIDSuspended:
+		http.Redirect(writer, request, redirectTestSuspendedLocation, http.StatusFound)
+	case redirectTestAccountIDRetry:
+		if call == 1 {
+			writer.Header().Set("Retry-After", "0")
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		http.Redirect(writer, request, redirectTestProfileLocation, http.StatusFound)
+	case redirectTestAccountIDResetHeader:
+		if call == 1 {
+			writer.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+			writer.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		http.Redirect(writer, request, redirectTestProfileLocation, http.StatusFound)
+	case redirectTestAccountIDLimited:
+		writer.Header().Set("Retry-After", redirectTestLongRetryAfter)
+		writer.WriteHeader(http.StatusTooManyRequests)
+	case redirectTestAccountIDNoRedirect:
+		writer.WriteHeader(http.StatusOK)
+	case redirectTestAccountIDLoginWall:
+		http.Redirect(writer, request, redirectTestLoginLocation, http.StatusFound)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+func TestRedirectBackendResolvesAccounts(t *testing.T) {
+	handler := &redirectTestServer{calls: make(map[string]int)}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	testCases := []struct {
+		name             string
+		accountID        string
+		expectedUserName string
+		expectedSentinel error
+		expectedCalls    int
+	}{
+		{
+			name:             "redirect location yields handle",
+			accountID:        redirectTestAccountIDSuccess,
+			expectedUserName: "example",
+			expectedCalls:    1,
+		},
+		{
+			name:             "suspended redirect is classified",
+			accountID:        redirectTestAccountIDSuspended,
+			expectedSentinel: handles.ErrAccountSuspended,
+			expectedCalls:    1,
+		},
+		{
+			name:             "not found is classified",
+			accountID:        redirectTestAccountIDMissing,
+			expectedSentinel: handles.ErrAccountDoesNotExist,
+			expectedCalls:    1,
+		},
+		{
+			name:             "retry after is honored",
+			accountID:        redirectTestAccountIDRetry,
+			expectedUserName: "example",
+			expectedCalls:    2,
+		},
+		{
+			name:             "rate limit reset in the past retries immediately",
+			accountID:        redirectTestAccountIDResetHeader,
+			expectedUserName: "example",
+			expectedCalls:    2,
+		},
+		{
+			name:             "long rate limit fails without waiting",
+			accountID:        redirectTestAccountIDLimited,
+			expectedSentinel: handles.ErrRateLimited,
+			expectedCalls:    1,
+		},
+		{
+			name:             "missing redirect is transient",
+			accountID:        redirectTestAccountIDNoRedirect,
+			expectedSentinel: handles.ErrTransientFailure,
+			expectedCalls:    1,
+		},
+		{
+			name:             "login redirect is transient",
+			accountID:        redirectTestAccountIDLoginWall,
+			expectedSentinel: handles.ErrTransientFailure,
+			expectedCalls:    1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			resolver, err := handles.NewResolver(handles.Config{
+				Backends:    []handles.Backend{handles.BackendRedirect},
+				BaseURL:     server.URL,
+				HTTPClient:  server.Client(),
+				Cache:       handles.NewMemoryCache(),
+				MaxAttempts: 1,
+				RateLimit:   ratelimit.Config{RequestsPerSecond: -1},
+			})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+
+			record, resolveErr := resolver.ResolveAccount(context.Background(), testCase.accountID)
+			if testCase.expectedSentinel != nil {
+				if !errors.Is(resolveErr, testCase.expectedSentinel) {
+					t.Fatalf("expected %v, got %v", testCase.expectedSentinel, resolveErr)
+				}
+			} else if resolveErr != nil {
+				t.Fatalf("unexpected error: %v", resolveErr)
+			}
+			if record.UserName != testCase.expectedUserName {
+				t.Fatalf("expected username %q, got %q", testCase.expectedUserName, record.UserName)
+			}
+			handler.mutex.Lock()
+			calls := handler.calls[testCase.accountID]
+			handler.mutex.Unlock()
+			if calls != testCase.expectedCalls {
+				t.Fatalf("expected %d requests, got %d", testCase.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expected    handles.Backend
+		expectError bool
+	}{
+		{name: "empty selects default", value: "", expected: handles.DefaultBackend},
+		{name: "redirect", value: " Redirect ", expected: handles.BackendRedirect},
+		{name: "chrome", value: "chrome", expected: handles.BackendChrome},
+		{name: "api", value: "API", expected: handles.BackendAPI},
+		{name: "chrome pool", value: "chrome-pool", expected: handles.BackendChromePool},
+		{name: "unknown", value: "carrier-pigeon", expectError: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			backend, err := handles.ParseBackend(testCase.value)
+			if testCase.expectError {
+				if !errors.Is(err, handles.ErrUnknownBackend) {
+					t.Fatalf("expected unknown backend error, got %v", err)
+				}
+				return
+			}
+			if err != nil || backend != testCase.expected {
+				t.Fatalf("expected %q, got %q (%v)", testCase.expected, backend, err)
+			}
+		})
+	}
+}
@@ -4,31 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/singleflight"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
 )
 
+// DefaultMaxConcurrent is the number of accounts resolved at once when Config.MaxConcurrent is zero.
+const DefaultMaxConcurrent = 1
+
 const (
 	defaultIntentBaseURLString      = "https://x.com"
 	intentPathFormat                = "/intent/user?user_id=%s"
-	profileURLPattern               = `https://(?:x|twitter)\.com/([A-Za-z0-9_]{1,15})`
-	htmlSingleQuoteCharacter        = "'"
-	htmlDoubleQuoteCharacter        = `"`
-	titleStartTag                   = "<title>"
-	titleEndTag                     = "</title>"
-	titleHandleDelimiter            = "(@"
-	titleSuffixDelimiter            = " / "
-	whitespaceCharacters            = " \t\r\n"
+	intentPath                      = "/intent/user"
+	intentQueryUserID               = "user_id"
 	errMessageEmptyAccountID        = "account id cannot be empty"
 	errMessageMissingHandle         = "twitter intent page did not contain a handle"
 	errMessageEmptyIntentHTML       = "twitter intent page did not return any HTML"
-	defaultWorkerConcurrency        = 1
+	errMessageNotCached             = "not in the handle cache"
+	errMessageRateLimitPage         = "page reported a rate limit"
+	defaultMaxAttempts              = 3
+	defaultRetryDelayMillis         = 250
 	reservedHandlePathAnalytics     = "i"
 	reservedHandlePathIntent        = "intent"
 	reservedHandlePathHome          = "home"
@@ -47,11 +49,13 @@ const (
 )
 
 var (
+	// ErrNotCached marks accounts and handles that an offline resolver could not answer from its cache.
+	ErrNotCached = errors.New(errMessageNotCached)
+
 	errEmptyAccountID  = errors.New(errMessageEmptyAccountID)
 	errMissingHandle   = errors.New(errMessageMissingHandle)
 	errEmptyIntentHTML = errors.New(errMessageEmptyIntentHTML)
-
-	profileURLRegex = regexp.MustCompile(profileURLPattern)
+	errRateLimitPage   = errors.New(errMessageRateLimitPage)
 
 	reservedHandleNames = map[string]struct{}{
 		reservedHandlePathAnalytics:     {},
@@ -71,8 +75,7 @@ var (
 		reservedHandlePathSearch:        {},
 	}
 
-	globalAccountCache      = newAccountCache()
-	globalAccountFetchGroup singleflight.Group
+	globalAccountCache = NewMemoryCache()
 )
 
 // AccountRecord captures the resolved handle information for a Twitter account.
@@ -80,6 +83,14 @@ type AccountRecord struct {
 	AccountID   string
 	UserName    string
 	DisplayName string
+	// Provenance records where UserName and DisplayName came from; it is nil when unknown.
+	Provenance *Provenance `json:",omitempty"`
+	// Status reports what the last lookup revealed about the account; it is empty for active or unknown accounts.
+	Status AccountStatus `json:",omitempty"`
+	// Profile holds the avatar, bio, flags and counts seen by the last lookup; it is nil when none were observed.
+	Profile *Profile `json:",omitempty"`
+	// FormerUserNames lists handles the account was seen with before UserName, most recent first.
+	FormerUserNames []string `json:",omitempty"`
 }
 
 // Result represents the outcome of a resolve attempt.
@@ -90,13 +101,53 @@ type Result struct {
 
 // Config customizes a Resolver instance.
 type Config struct {
-	BaseURL                 string
-	IntentFetcher           IntentFetcher
+	BaseURL       string
+	IntentFetcher IntentFetcher
+	// Backends lists the built-in fetchers tried in order when IntentFetcher is nil; DefaultBackend alone applies
+	// when empty. The resolver cache is always consulted first.
+	Backends []Backend
+	// BreakerThreshold and BreakerCooldown tune the per-backend circuit breakers of the fallback chain.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// HTTPClient performs requests for HTTP backends; a default client is used when nil.
+	HTTPClient *http.Client
+	// APIBaseURL overrides the X API host used by BackendAPI.
+	APIBaseURL string
+	// APIBearerToken authenticates BackendAPI requests with an app bearer token or a user access token.
+	APIBearerToken string
+	// APILimiter paces BackendAPI requests with a limiter the caller already uses for its own API requests, so that
+	// both stay within one quota; RateLimit applies to the API backend when nil.
+	APILimiter              *ratelimit.Limiter
 	ChromeBinaryPath        string
 	ChromeUserAgent         string
 	ChromeVirtualTimeBudget time.Duration
 	ChromeRequestDelay      time.Duration
-	MaxConcurrent           int
+	// ChromePoolSize is the number of tabs kept open by BackendChromePool; MaxConcurrent applies when zero. The
+	// pool waits up to ChromeVirtualTimeBudget for each page.
+	ChromePoolSize int
+	// ChromeUserDataDir is a persistent Chrome profile used by the Chrome backends, typically one the user has logged
+	// into once so that protected and age-restricted accounts resolve.
+	ChromeUserDataDir string
+	// ChromeCookieFile is a Netscape cookie file imported into the Chrome backends; BackendChrome also needs
+	// ChromeUserDataDir to keep them between runs.
+	ChromeCookieFile string
+	// RateLimit paces and retries the requests of each backend; every backend gets its own limiter so that one
+	// service pushing back does not slow the others. Chrome falls back to ChromeRequestDelay when no pace is set.
+	RateLimit     ratelimit.Config
+	MaxConcurrent int
+	// Cache stores resolution outcomes; a process-wide memory cache is used when nil.
+	Cache Cache
+	// SuccessTTL bounds how long successful resolutions are reused; DefaultSuccessTTL applies when zero.
+	SuccessTTL time.Duration
+	// FailureTTL bounds how long failed resolutions are reused; DefaultFailureTTL applies when zero.
+	FailureTTL time.Duration
+	// MaxAttempts bounds how many times a transient failure is attempted; three attempts are made when zero.
+	MaxAttempts int
+	// RetryDelay is the pause before the first retry and grows linearly with each attempt; negative disables it.
+	RetryDelay time.Duration
+	// Offline answers only from Cache, whatever the age of an entry, and never fetches; no backend is built. Accounts
+	// and handles missing from the cache settle with ErrNotCached, which is not cached itself.
+	Offline bool
 }
 
 // Resolver resolves Twitter handles for numeric account identifiers.
@@ -104,19 +155,13 @@ type Resolver struct {
 	baseURL       *url.URL
 	workerCount   int
 	intentFetcher IntentFetcher
-	accountCache  *accountCache
-	fetchGroup    *singleflight.Group
-}
-
-type accountCacheEntry struct {
-	record AccountRecord
-	err    error
-}
-
-// accountCache provides concurrency-safe storage for resolved account data.
-type accountCache struct {
-	entries map[string]accountCacheEntry
-	mutex   sync.RWMutex
+	accountCache  Cache
+	successTTL    time.Duration
+	failureTTL    time.Duration
+	maxAttempts   int
+	retryDelay    time.Duration
+	offline       bool
+	fetches       *sharedFetches
 }
 
 // NewResolver constructs a Resolver with sensible defaults for intent lookups.
@@ -132,54 +177,123 @@ func NewResolver(configuration Config) (*Resolver, error) {
 
 	workerCount := configuration.MaxConcurrent
 	if workerCount <= 0 {
-		workerCount = defaultWorkerConcurrency
+		workerCount = DefaultMaxConcurrent
 	}
 
 	intentFetcher := configuration.IntentFetcher
-	if intentFetcher == nil {
-		chromeBinaryPath := resolveChromeBinaryPath(configuration)
-		chromeFetcher, chromeErr := NewChromeIntentFetcher(ChromeFetcherConfig{
-			BinaryPath:        chromeBinaryPath,
-			UserAgent:         configuration.ChromeUserAgent,
-			VirtualTimeBudget: configuration.ChromeVirtualTimeBudget,
-			RequestDelay:      configuration.ChromeRequestDelay,
-		})
-		if chromeErr != nil {
-			return nil, chromeErr
+	if intentFetcher == nil && !configuration.Offline {
+		backendFetcher, backendErr := newBackendFetcher(configuration)
+		if backendErr != nil {
+			return nil, backendErr
 		}
-		intentFetcher = chromeFetcher
+		intentFetcher = backendFetcher
+	}
+
+	accountCache := configuration.Cache
+	if accountCache == nil {
+		accountCache = globalAccountCache
+	}
+	successTTL := configuration.SuccessTTL
+	if successTTL <= 0 {
+		successTTL = DefaultSuccessTTL
+	}
+	failureTTL := configuration.FailureTTL
+	if failureTTL <= 0 {
+		failureTTL = DefaultFailureTTL
+	}
+
+	maxAttempts := configuration.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	retryDelay := configuration.RetryDelay
+	if retryDelay < 0 {
+		retryDelay = 0
+	} else if retryDelay == 0 {
+		retryDelay = time.Duration(defaultRetryDelayMillis) * time.Millisecond
 	}
 
 	resolver := &Resolver{
 		baseURL:       parsedBaseURL,
 		workerCount:   workerCount,
 		intentFetcher: intentFetcher,
-		accountCache:  globalAccountCache,
-		fetchGroup:    &globalAccountFetchGroup,
+		accountCache:  accountCache,
+		successTTL:    successTTL,
+		failureTTL:    failureTTL,
+		maxAttempts:   maxAttempts,
+		retryDelay:    retryDelay,
+		offline:       configuration.Offline,
+		fetches:       newSharedFetches(),
 	}
 	return resolver, nil
 }
 
-// ResolveMany resolves a batch of account identifiers using a bounded worker pool.
+// Close releases the resources held by the intent fetcher, such as the browser of the chrome-pool backend. Commands
+// should defer it once the resolver is created, since a browser left running may outlive the process.
+func (resolver *Resolver) Close() error {
+	if closer, closable := resolver.intentFetcher.(io.Closer); closable {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ResolveMany resolves a batch of account identifiers using a bounded worker pool. When the fetcher supports batch
+// lookups, accounts missing from the cache are first looked up in bulk and only the unsettled remainder goes through
+// the worker pool. A ProgressFunc installed with WithProgress observes every account as it settles. Accounts start in
+// the order they are passed; when a Budget installed with WithBudget runs out, the accounts that were not looked up
+// settle with ErrBudgetExhausted. An offline resolver settles uncached accounts with ErrNotCached instead.
 func (resolver *Resolver) ResolveMany(ctx context.Context, accountIDs []string) map[string]Result {
 	uniqueAccountIDs := resolver.uniqueIDs(accountIDs)
 	results := make(map[string]Result, len(uniqueAccountIDs))
 	if len(uniqueAccountIDs) == 0 {
 		return results
 	}
+	ctx, tracker := startProgress(ctx, len(uniqueAccountIDs))
+	budget := startBudget(ctx)
+	admittedAccountIDs := make([]string, 0, len(uniqueAccountIDs))
+	for _, accountID := range uniqueAccountIDs {
+		if resolver.offline && !resolver.isCachedFresh(accountID) {
+			results[accountID] = Result{Record: AccountRecord{AccountID: accountID}, Err: ErrNotCached}
+			tracker.skipped(accountID, ErrNotCached)
+			continue
+		}
+		if resolver.isCachedFresh(accountID) || budget.admitLookup() {
+			admittedAccountIDs = append(admittedAccountIDs, accountID)
+			continue
+		}
+		results[accountID] = Result{Record: AccountRecord{AccountID: accountID}, Err: ErrBudgetExhausted}
+		tracker.skipped(accountID, ErrBudgetExhausted)
+	}
+	resolver.prefetchBatch(ctx, admittedAccountIDs, results)
+	pendingAccountIDs := make([]string, 0, len(admittedAccountIDs))
+	for _, accountID := range admittedAccountIDs {
+		if result, settled := results[accountID]; settled {
+			tracker.settled(accountID, result.Err)
+			continue
+		}
+		pendingAccountIDs = append(pendingAccountIDs, accountID)
+	}
 
 	var (
 		resultsMutex sync.Mutex
 		group        errgroup.Group
 	)
 	group.SetLimit(resolver.workerCount)
-	for _, accountID := range uniqueAccountIDs {
+	for _, accountID := range pendingAccountIDs {
 		accountID := accountID
 		group.Go(func() error {
+			if budget.expired() && !resolver.isCachedFresh(accountID) {
+				resultsMutex.Lock()
+				results[accountID] = Result{Record: AccountRecord{AccountID: accountID}, Err: ErrBudgetExhausted}
+				resultsMutex.Unlock()
+				tracker.skipped(accountID, ErrBudgetExhausted)
+				return nil
+			}
 			record, resolveErr := resolver.ResolveAccount(ctx, accountID)
 			resultsMutex.Lock()
 			results[accountID] = Result{Record: record, Err: resolveErr}
 			resultsMutex.Unlock()
+			tracker.settled(accountID, resolveErr)
 			return nil
 		})
 	}
@@ -187,106 +301,181 @@ func (resolver *Resolver) ResolveMany(ctx context.Context, accountIDs []string)
 	return results
 }
 
-// ResolveAccount resolves a single numeric account identifier into handle metadata.
+// isCachedFresh reports whether the cache holds a fresh entry for the account, which costs no lookup.
+func (resolver *Resolver) isCachedFresh(accountID string) bool {
+	cachedEntry, found := resolver.accountCache.Lookup(accountID)
+	return found && resolver.isFresh(cachedEntry)
+}
+
+// prefetchBatch settles stale or uncached accounts through a BatchIntentFetcher, caching and recording handles and
+// ghost statuses in results.
+func (resolver *Resolver) prefetchBatch(ctx context.Context, accountIDs []string, results map[string]Result) {
+	batchFetcher, batchCapable := resolver.intentFetcher.(BatchIntentFetcher)
+	if !batchCapable || resolver.offline {
+		return
+	}
+	var requests []IntentRequest
+	for _, accountID := range accountIDs {
+		if resolver.isCachedFresh(accountID) {
+			continue
+		}
+		requests = append(requests, IntentRequest{AccountID: accountID, URL: resolver.intentURL(accountID)})
+	}
+	if len(requests) == 0 {
+		return
+	}
+	for accountID, outcome := range batchFetcher.FetchIntentPages(ctx, requests) {
+		record, recordErr := resolver.recordFromPage(accountID, outcome.Page, outcome.Err)
+		if recordErr != nil && !StatusOf(recordErr).IsGhost() {
+			continue
+		}
+		resolver.accountCache.Store(accountID, CacheEntry{Record: record, Err: recordErr, ResolvedAt: time.Now().UTC()})
+		results[accountID] = Result{Record: resolver.withHistory(record), Err: recordErr}
+	}
+}
+
+// ResolveAccount resolves a single numeric account identifier into handle metadata. When the cache keeps handle
+// histories, the record lists the handles the account was seen with before.
 func (resolver *Resolver) ResolveAccount(ctx context.Context, accountID string) (AccountRecord, error) {
 	normalizedAccountID := strings.TrimSpace(accountID)
 	if normalizedAccountID == "" {
 		return AccountRecord{}, errEmptyAccountID
 	}
 
-	if cachedEntry, found := resolver.accountCache.Lookup(normalizedAccountID); found {
-		return cachedEntry.record, cachedEntry.err
+	if cachedEntry, found := resolver.accountCache.Lookup(normalizedAccountID); found && resolver.isFresh(cachedEntry) {
+		return resolver.withHistory(cachedEntry.Record.WithSource(LabelSourceResolverCache)), cachedEntry.Err
+	}
+	if resolver.offline {
+		return resolver.withHistory(AccountRecord{AccountID: normalizedAccountID}), ErrNotCached
 	}
 
-	resultChannel := resolver.fetchGroup.DoChan(normalizedAccountID, func() (interface{}, error) {
-		record, fetchErr := resolver.fetchAccount(ctx, normalizedAccountID)
-		resolver.accountCache.Store(normalizedAccountID, record, fetchErr)
-		if fetchErr != nil {
-			return record, fetchErr
+	record, err := resolver.fetches.do(ctx, normalizedAccountID, func(fetchCtx context.Context) (AccountRecord, error) {
+		record, fetchErr := resolver.fetchAccount(fetchCtx, normalizedAccountID)
+		if !errors.Is(fetchErr, context.Canceled) && !errors.Is(fetchErr, context.DeadlineExceeded) {
+			resolver.accountCache.Store(normalizedAccountID, CacheEntry{Record: record, Err: fetchErr, ResolvedAt: time.Now().UTC()})
 		}
-		return record, nil
+		return record, fetchErr
 	})
-
-	select {
-	case <-ctx.Done():
-		return AccountRecord{}, ctx.Err()
-	case result := <-resultChannel:
-		if result.Err != nil {
-			return AccountRecord{}, result.Err
-		}
-		record, _ := result.Val.(AccountRecord)
-		return record, nil
-	}
+	return resolver.withHistory(record), err
 }
 
-func (resolver *Resolver) fetchAccount(ctx context.Context, accountID string) (AccountRecord, error) {
-	accountRecord := AccountRecord{AccountID: accountID}
-	intentRequest := IntentRequest{AccountID: accountID, URL: resolver.intentURL(accountID)}
-	intentPage, fetchErr := resolver.intentFetcher.FetchIntentPage(ctx, intentRequest)
-	if fetchErr != nil {
-		return accountRecord, fetchErr
+// withHistory lists the handles the cache saw for the account before its current one.
+func (resolver *Resolver) withHistory(record AccountRecord) AccountRecord {
+	historyStore, tracked := resolver.accountCache.(HistoryStore)
+	if !tracked || record.AccountID == "" {
+		return record
 	}
+	return record.WithFormerUserNames(FormerUserNames(historyStore.History(record.AccountID), record.UserName)...)
+}
 
-	handle, handleErr := resolver.extractHandle(intentPage.HTML)
-	if handleErr != nil {
-		return accountRecord, handleErr
+// isFresh reports whether a cached outcome is still within its success or failure TTL. Suspended and deleted
+// accounts are settled facts and share the success TTL. Every entry is fresh to an offline resolver.
+func (resolver *Resolver) isFresh(entry CacheEntry) bool {
+	if resolver.offline {
+		return true
 	}
-	accountRecord.UserName = handle
-
-	displayName := parseDisplayName(intentPage.HTML)
-	if strings.TrimSpace(displayName) != "" {
-		accountRecord.DisplayName = displayName
+	ttl := resolver.successTTL
+	if entry.Err != nil && !StatusOf(entry.Err).IsGhost() {
+		ttl = resolver.failureTTL
 	}
-	return accountRecord, nil
+	return time.Now().Sub(entry.ResolvedAt) < ttl
 }
 
-func (resolver *Resolver) intentURL(accountID string) string {
-	return resolver.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf(intentPathFormat, accountID)}).String()
+// fetchAccount looks up an account, retrying transient failures with a linearly growing delay.
+func (resolver *Resolver) fetchAccount(ctx context.Context, accountID string) (AccountRecord, error) {
+	return resolver.withRetries(ctx, accountID, func() (AccountRecord, error) {
+		return resolver.fetchAccountOnce(ctx, accountID)
+	})
 }
 
-func (resolver *Resolver) extractHandle(htmlContent string) (string, error) {
-	normalizedHTML := strings.ReplaceAll(htmlContent, htmlSingleQuoteCharacter, htmlDoubleQuoteCharacter)
-	matches := profileURLRegex.FindAllStringSubmatch(normalizedHTML, -1)
-	for _, match := range matches {
-		if len(match) < 2 {
-			continue
-		}
-		candidate := strings.TrimSpace(match[1])
-		if candidate == "" {
-			continue
+// withRetries runs attempt until it succeeds, fails for good, or runs out of attempts, pausing for a linearly growing
+// delay between attempts. Retries are reported to the progress tracker under reference.
+func (resolver *Resolver) withRetries(ctx context.Context, reference string, attempt func() (AccountRecord, error)) (AccountRecord, error) {
+	for attemptNumber := 1; ; attemptNumber++ {
+		record, fetchErr := attempt()
+		if fetchErr == nil || !IsRetryable(fetchErr) || attemptNumber >= resolver.maxAttempts {
+			return record, fetchErr
 		}
-		if resolver.isReservedHandle(candidate) {
-			continue
+		progressFromContext(ctx).retrying(reference, attemptNumber, fetchErr)
+		if waitErr := ratelimit.Sleep(ctx, resolver.retryDelay*time.Duration(attemptNumber)); waitErr != nil {
+			return record, waitErr
 		}
-		return candidate, nil
 	}
-	return "", errMissingHandle
 }
 
-func (resolver *Resolver) isReservedHandle(handle string) bool {
-	_, reserved := reservedHandleNames[strings.ToLower(handle)]
-	return reserved
+func (resolver *Resolver) fetchAccountOnce(ctx context.Context, accountID string) (AccountRecord, error) {
+	intentRequest := IntentRequest{AccountID: accountID, URL: resolver.intentURL(accountID)}
+	intentPage, fetchErr := resolver.intentFetcher.FetchIntentPage(ctx, intentRequest)
+	return resolver.recordFromPage(accountID, intentPage, fetchErr)
 }
 
-func parseDisplayName(htmlContent string) string {
-	startIndex := strings.Index(htmlContent, titleStartTag)
-	if startIndex == -1 {
-		return ""
+// recordFromPage classifies a fetched page, or the error reported instead, into an account record.
+func (resolver *Resolver) recordFromPage(accountID string, intentPage IntentPage, fetchErr error) (AccountRecord, error) {
+	accountRecord := AccountRecord{AccountID: accountID}
+	if fetchErr != nil {
+		if errors.Is(fetchErr, context.Canceled) || errors.Is(fetchErr, context.DeadlineExceeded) {
+			return accountRecord, fetchErr
+		}
+		if status := StatusOf(fetchErr); status != "" {
+			return accountRecord.withFailureStatus(status), fetchErr
+		}
+		return accountRecord, newResolutionError(accountID, AccountStatusTransient, fetchErr)
+	}
+
+	pageStatus := intentPage.Status
+	if pageStatus == "" {
+		pageStatus = classifyIntentPage(intentPage.HTML, accountID)
 	}
-	startIndex += len(titleStartTag)
-	endIndex := strings.Index(htmlContent[startIndex:], titleEndTag)
-	if endIndex == -1 {
-		return ""
+	if pageStatus != "" && pageStatus != AccountStatusProtected {
+		return accountRecord.withFailureStatus(pageStatus), newResolutionError(accountID, pageStatus, nil)
 	}
-	endIndex += startIndex
-	titleContent := strings.Trim(htmlContent[startIndex:endIndex], whitespaceCharacters)
-	if cutIndex := strings.Index(titleContent, titleSuffixDelimiter); cutIndex >= 0 {
-		titleContent = strings.Trim(titleContent[:cutIndex], whitespaceCharacters)
+
+	handle := strings.TrimSpace(intentPage.UserName)
+	displayName := strings.TrimSpace(intentPage.DisplayName)
+	var profile Profile
+	if intentPage.Profile != nil {
+		profile = *intentPage.Profile
 	}
-	if cutIndex := strings.Index(titleContent, titleHandleDelimiter); cutIndex >= 0 {
-		titleContent = strings.Trim(titleContent[:cutIndex], whitespaceCharacters)
+	if strings.TrimSpace(intentPage.HTML) != "" {
+		extraction, extractErr := ExtractProfile(intentPage.HTML, accountID)
+		if errors.Is(extractErr, ErrAccountIDMismatch) || (handle == "" && extractErr != nil) {
+			return accountRecord, newResolutionError(accountID, AccountStatusTransient, extractErr)
+		}
+		// The page itself names the profile it shows, so its handle wins over one a fetcher read from any link.
+		if extraction.UserName != "" && !strings.EqualFold(extraction.UserName, handle) {
+			handle = extraction.UserName
+			displayName = ""
+			profile = Profile{}
+		}
+		if strings.EqualFold(extraction.UserName, handle) {
+			if displayName == "" {
+				displayName = extraction.DisplayName
+			}
+			profile = profile.mergedWith(extraction.Profile)
+		}
+	}
+	if handle == "" {
+		return accountRecord, newResolutionError(accountID, AccountStatusTransient, errMissingHandle)
+	}
+	observedAt := time.Now().UTC()
+	provenance := FieldProvenance{Source: LabelSourceLiveFetch, Origin: string(intentPage.Backend), ObservedAt: observedAt}
+	accountRecord = accountRecord.WithUserName(handle, provenance)
+	accountRecord.Status = pageStatus
+	profile.Protected = profile.Protected || pageStatus == AccountStatusProtected
+	if profile.FetchedAt.IsZero() {
+		profile.FetchedAt = observedAt
+	}
+	accountRecord = accountRecord.WithProfile(profile)
+
+	if strings.TrimSpace(displayName) != "" {
+		accountRecord = accountRecord.WithDisplayName(displayName, provenance)
 	}
-	return titleContent
+	return accountRecord, nil
+}
+
+// intentURL returns the intent page of accountID on the configured site, keeping user_id in the query string.
+func (resolver *Resolver) intentURL(accountID string) string {
+	return resolver.baseURL.ResolveReference(&url.URL{Path: intentPath, RawQuery: url.Values{intentQueryUserID: {accountID}}.Encode()}).String()
 }
 
 func (resolver *Resolver) uniqueIDs(accountIDs []string) []string {
@@ -305,23 +494,3 @@ func (resolver *Resolver) uniqueIDs(accountIDs []string) []string {
 	}
 	return unique
 }
-
-// newAccountCache initializes an empty accountCache instance.
-func newAccountCache() *accountCache {
-	return &accountCache{entries: make(map[string]accountCacheEntry)}
-}
-
-// Lookup retrieves the cached entry for the supplied account identifier.
-func (cache *accountCache) Lookup(accountID string) (accountCacheEntry, bool) {
-	cache.mutex.RLock()
-	defer cache.mutex.RUnlock()
-	entry, found := cache.entries[accountID]
-	return entry, found
-}
-
-// Store saves the provided account record and error result for reuse.
-func (cache *accountCache) Store(accountID string, record AccountRecord, resolveErr error) {
-	cache.mutex.Lock()
-	cache.entries[accountID] = accountCacheEntry{record: record, err: resolveErr}
-	cache.mutex.Unlock()
-}
@@ -19,6 +19,8 @@ const (
 	resolverTestIntentHTMLSuccess          = "<html><head><title>Example Name (@example) / X</title></head><body><a href=\"https://x.com/example\">profile</a></body></html>"
 	resolverTestIntentHTMLMissingHandle    = "<html><head><title>Example Name (@example) / X</title></head><body>No links</body></html>"
 	resolverTestIntentHTMLNoTitle          = "<html><body><a href=\"https://x.com/example\">profile</a></body></html>"
+	resolverTestIntentHTMLOtherLinkFirst   = "<html><head><title>Example Name (@example) / X</title><link rel=\"canonical\" href=\"https://x.com/example\"></head><body><a href=\"https://x.com/quoted_user\">@quoted_user</a></body></html>"
+	resolverTestReportedUserNameOther      = "quoted_user"
 	resolverTestIntentURLPrefix            = "https://x.com/intent/user?user_id="
 	resolverTestErrorMessageMissingAccount = "no stub intent response for account"
 
@@ -26,10 +28,13 @@ const (
 	resolverTestAccountIDMissingHandle     = "10002"
 	resolverTestAccountIDNoTitle           = "10003"
 	resolverTestAccountIDFetcherError      = "10004"
+	resolverTestAccountIDOtherLinkFirst    = "10005"
 	resolverTestAccountIDPrimaryDedup      = "20001"
 	resolverTestAccountIDSecondaryDedup    = "20002"
 	resolverTestAccountIDCacheReuse        = "20003"
 	resolverTestAccountIDSharedAcrossCache = "20004"
+	resolverTestAccountIDCancelledCaller   = "20005"
+	resolverTestAccountIDSeparateGroups    = "20006"
 
 	resolverIntegrationFlagName                 = "twitter_integration"
 	resolverIntegrationFlagDescription          = "enable live Twitter intent resolution integration test"
@@ -108,6 +113,7 @@ func TestResolverResolveAccount(t *testing.T) {
 		name                string
 		accountID           string
 		htmlContent         string
+		reportedUserName    string
 		fetchError          error
 		expectError         bool
 		expectedUserName    string
@@ -133,6 +139,14 @@ func TestResolverResolveAccount(t *testing.T) {
 			expectedUserName:    "example",
 			expectedDisplayName: "",
 		},
+		{
+			name:                "page handle wins over the handle the fetcher reported",
+			accountID:           resolverTestAccountIDOtherLinkFirst,
+			htmlContent:         resolverTestIntentHTMLOtherLinkFirst,
+			reportedUserName:    resolverTestReportedUserNameOther,
+			expectedUserName:    "example",
+			expectedDisplayName: "Example Name",
+		},
 		{
 			name:        "fetcher returns error",
 			accountID:   resolverTestAccountIDFetcherError,
@@ -147,7 +161,11 @@ func TestResolverResolveAccount(t *testing.T) {
 			responses := make(map[string]handles.IntentPage)
 			errors := make(map[string]error)
 			if testCase.htmlContent != "" {
-				responses[testCase.accountID] = handles.IntentPage{HTML: testCase.htmlContent, SourceURL: resolverTestIntentURLPrefix + testCase.accountID}
+				responses[testCase.accountID] = handles.IntentPage{
+					UserName:  testCase.reportedUserName,
+					HTML:      testCase.htmlContent,
+					SourceURL: resolverTestIntentURLPrefix + testCase.accountID,
+				}
 			}
 			if testCase.fetchError != nil {
 				errors[testCase.accountID] = testCase.fetchError
@@ -217,6 +235,136 @@ func TestResolverResolveManyDeduplicates(t *testing.T) {
 	}
 }
 
+// gatedIntentFetcher blocks every fetch until release is closed, recording the context of the latest fetch.
+type gatedIntentFetcher struct {
+	started chan struct{}
+	release chan struct{}
+	mu      sync.Mutex
+	calls   int
+	ctx     context.Context
+}
+
+func newGatedIntentFetcher() *gatedIntentFetcher {
+	return &gatedIntentFetcher{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (fetcher *gatedIntentFetcher) FetchIntentPage(ctx context.Context, request handles.IntentRequest) (handles.IntentPage, error) {
+	fetcher.mu.Lock()
+	fetcher.calls++
+	fetcher.ctx = ctx
+	fetcher.mu.Unlock()
+	select {
+	case fetcher.started <- struct{}{}:
+	default:
+	}
+	<-fetcher.release
+	if err := ctx.Err(); err != nil {
+		return handles.IntentPage{}, err
+	}
+	return handles.IntentPage{HTML: resolverTestIntentHTMLSuccess, SourceURL: resolverTestIntentURLPrefix + request.AccountID}, nil
+}
+
+func (fetcher *gatedIntentFetcher) fetchContext() context.Context {
+	fetcher.mu.Lock()
+	defer fetcher.mu.Unlock()
+	return fetcher.ctx
+}
+
+// waitingContext signals waiting the first time a caller asks for its Done channel, which the resolver does once it
+// waits for a lookup.
+type waitingContext struct {
+	context.Context
+	once    sync.Once
+	waiting chan struct{}
+}
+
+func newWaitingContext(parent context.Context) *waitingContext {
+	return &waitingContext{Context: parent, waiting: make(chan struct{})}
+}
+
+func (ctx *waitingContext) Done() <-chan struct{} {
+	ctx.once.Do(func() { close(ctx.waiting) })
+	return ctx.Context.Done()
+}
+
+func TestResolverSharedFetchOutlivesCancelledCaller(t *testing.T) {
+	fetcher := newGatedIntentFetcher()
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, resolveErr := resolver.ResolveAccount(firstCtx, resolverTestAccountIDCancelledCaller)
+		firstErr <- resolveErr
+	}()
+	<-fetcher.started
+
+	secondCtx := newWaitingContext(context.Background())
+	type outcome struct {
+		record handles.AccountRecord
+		err    error
+	}
+	secondOutcome := make(chan outcome, 1)
+	go func() {
+		record, resolveErr := resolver.ResolveAccount(secondCtx, resolverTestAccountIDCancelledCaller)
+		secondOutcome <- outcome{record: record, err: resolveErr}
+	}()
+	<-secondCtx.waiting
+
+	cancelFirst()
+	if resolveErr := <-firstErr; !errors.Is(resolveErr, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to see context.Canceled, got %v", resolveErr)
+	}
+	if fetchErr := fetcher.fetchContext().Err(); fetchErr != nil {
+		t.Fatalf("expected the shared fetch to outlive the caller that started it, got %v", fetchErr)
+	}
+
+	close(fetcher.release)
+	second := <-secondOutcome
+	if second.err != nil || second.record.UserName != "example" {
+		t.Fatalf("expected the waiting caller to receive the shared fetch, got %+v, %v", second.record, second.err)
+	}
+	if fetcher.calls != 1 {
+		t.Fatalf("expected one shared fetch, got %d", fetcher.calls)
+	}
+}
+
+func TestResolverFetchGroupsArePerResolver(t *testing.T) {
+	blockedFetcher := newGatedIntentFetcher()
+	defer close(blockedFetcher.release)
+	blockedResolver, err := handles.NewResolver(handles.Config{IntentFetcher: blockedFetcher, Cache: handles.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("create blocked resolver: %v", err)
+	}
+	go func() {
+		_, _ = blockedResolver.ResolveAccount(context.Background(), resolverTestAccountIDSeparateGroups)
+	}()
+	<-blockedFetcher.started
+
+	otherFetcher := newRecordingIntentFetcher(map[string]handles.IntentPage{
+		resolverTestAccountIDSeparateGroups: {
+			HTML:      resolverTestIntentHTMLSuccess,
+			SourceURL: resolverTestIntentURLPrefix + resolverTestAccountIDSeparateGroups,
+		},
+	}, nil)
+	otherResolver, err := handles.NewResolver(handles.Config{IntentFetcher: otherFetcher, Cache: handles.NewMemoryCache()})
+	if err != nil {
+		t.Fatalf("create other resolver: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	record, resolveErr := otherResolver.ResolveAccount(ctx, resolverTestAccountIDSeparateGroups)
+	if resolveErr != nil || record.UserName != "example" {
+		t.Fatalf("expected the other resolver to fetch on its own, got %+v, %v", record, resolveErr)
+	}
+	if otherFetcher.calls[resolverTestAccountIDSeparateGroups] != 1 {
+		t.Fatalf("expected one fetch through the other resolver, got %d", otherFetcher.calls[resolverTestAccountIDSeparateGroups])
+	}
+}
+
 func TestResolverCachesResults(t *testing.T) {
 	fetcher := newRecordingIntentFetcher(map[string]handles.IntentPage{
 		resolverTestAccountIDCacheReuse: {
@@ -229,14 +377,22 @@ func TestResolverCachesResults(t *testing.T) {
 		t.Fatalf("create resolver: %v", err)
 	}
 
-	_, firstErr := resolver.ResolveAccount(context.Background(), resolverTestAccountIDCacheReuse)
+	firstRecord, firstErr := resolver.ResolveAccount(context.Background(), resolverTestAccountIDCacheReuse)
 	if firstErr != nil {
 		t.Fatalf("first resolution failed: %v", firstErr)
 	}
-	_, secondErr := resolver.ResolveAccount(context.Background(), resolverTestAccountIDCacheReuse)
+	secondRecord, secondErr := resolver.ResolveAccount(context.Background(), resolverTestAccountIDCacheReuse)
 	if secondErr != nil {
 		t.Fatalf("second resolution failed: %v", secondErr)
 	}
+	firstProvenance, firstKnown := firstRecord.UserNameProvenance()
+	if !firstKnown || firstProvenance.Source != handles.LabelSourceLiveFetch || firstProvenance.ObservedAt.IsZero() {
+		t.Fatalf("expected live fetch provenance on first resolution, got %+v", firstProvenance)
+	}
+	secondProvenance, secondKnown := secondRecord.UserNameProvenance()
+	if !secondKnown || secondProvenance.Source != handles.LabelSourceResolverCache || !secondProvenance.ObservedAt.Equal(firstProvenance.ObservedAt) {
+		t.Fatalf("expected resolver cache provenance on second resolution, got %+v", secondProvenance)
+	}
 	if fetcher.calls[resolverTestAccountIDCacheReuse] != 1 {
 		t.Fatalf("expected cached response to avoid duplicate fetch, got %d calls", fetcher.calls[resolverTestAccountIDCacheReuse])
 	}
@@ -0,0 +1,122 @@
+package handles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	handleFetchKeyPrefix         = "@"
+	errMessageInvalidHandle      = "not a valid handle"
+	errMessageMissingAccountID   = "profile page did not contain an account id"
+	errMessageHandleMismatchFmt  = "%w: requested @%s, page names @%s"
+	errMessageHandleMismatchBase = "profile page describes a different handle"
+)
+
+var (
+	// ErrInvalidHandle indicates that a handle is empty, too long, contains characters X does not allow, or is a
+	// reserved path such as "home".
+	ErrInvalidHandle = errors.New(errMessageInvalidHandle)
+
+	errMissingAccountID = errors.New(errMessageMissingAccountID)
+	errHandleMismatch   = errors.New(errMessageHandleMismatchBase)
+)
+
+// NormalizeHandle trims whitespace and a leading @ from userName and reports whether the rest is a valid handle.
+func NormalizeHandle(userName string) (string, bool) {
+	handle := validHandle(strings.TrimPrefix(strings.TrimSpace(userName), extractHandlePrefix))
+	return handle, handle != ""
+}
+
+// ResolveHandle resolves a handle, with or without the leading @, into the record of its account, including the
+// numeric identifier. Fresh successes are reused when the cache implements HandleIndex; otherwise the profile page is
+// fetched through the same backends as ResolveAccount and the outcome is cached under the account identifier. Failed
+// handle lookups are not cached because no identifier is known to key them by. An offline resolver answers only from
+// the index and fails with ErrNotCached otherwise.
+func (resolver *Resolver) ResolveHandle(ctx context.Context, userName string) (AccountRecord, error) {
+	handle, valid := NormalizeHandle(userName)
+	if !valid {
+		return AccountRecord{}, fmt.Errorf("%w: %q", ErrInvalidHandle, userName)
+	}
+
+	if index, indexed := resolver.accountCache.(HandleIndex); indexed {
+		if cachedEntry, found := index.LookupHandle(handle); found && resolver.isFresh(cachedEntry) {
+			return resolver.withHistory(cachedEntry.Record.WithSource(LabelSourceResolverCache)), nil
+		}
+	}
+	if resolver.offline {
+		return AccountRecord{}, fmt.Errorf("%w: @%s", ErrNotCached, handle)
+	}
+
+	record, err := resolver.fetches.do(ctx, handleFetchKeyPrefix+strings.ToLower(handle), func(fetchCtx context.Context) (AccountRecord, error) {
+		record, fetchErr := resolver.withRetries(fetchCtx, extractHandlePrefix+handle, func() (AccountRecord, error) {
+			return resolver.fetchHandleOnce(fetchCtx, handle)
+		})
+		if fetchErr != nil {
+			return record, fetchErr
+		}
+		resolver.accountCache.Store(record.AccountID, CacheEntry{Record: record, ResolvedAt: time.Now().UTC()})
+		return record, nil
+	})
+	return resolver.withHistory(record), err
+}
+
+func (resolver *Resolver) fetchHandleOnce(ctx context.Context, handle string) (AccountRecord, error) {
+	request := IntentRequest{UserName: handle, URL: resolver.profileURL(handle)}
+	page, fetchErr := resolver.intentFetcher.FetchIntentPage(ctx, request)
+	return resolver.recordFromProfilePage(request, page, fetchErr)
+}
+
+// recordFromProfilePage finds the account identifier of a fetched profile page and reads the rest of the record the
+// way recordFromPage does for intent pages.
+func (resolver *Resolver) recordFromProfilePage(request IntentRequest, page IntentPage, fetchErr error) (AccountRecord, error) {
+	reference := request.reference()
+	if fetchErr != nil {
+		switch {
+		case errors.Is(fetchErr, context.Canceled), errors.Is(fetchErr, context.DeadlineExceeded), errors.Is(fetchErr, ErrHandleLookupUnsupported):
+			return AccountRecord{}, fetchErr
+		case StatusOf(fetchErr) != "":
+			return AccountRecord{}, fetchErr
+		default:
+			return AccountRecord{}, newResolutionError(reference, AccountStatusTransient, fetchErr)
+		}
+	}
+
+	pageStatus := page.Status
+	if pageStatus == "" {
+		pageStatus = classifyIntentPage(page.HTML, page.AccountID)
+	}
+	if pageStatus != "" && pageStatus != AccountStatusProtected {
+		return AccountRecord{}, newResolutionError(reference, pageStatus, nil)
+	}
+
+	accountID := strings.TrimSpace(page.AccountID)
+	if strings.TrimSpace(page.HTML) != "" {
+		extraction, extractErr := ExtractProfile(page.HTML, accountID)
+		if extractErr != nil && page.UserName == "" {
+			return AccountRecord{}, newResolutionError(reference, AccountStatusTransient, extractErr)
+		}
+		if page.UserName == "" {
+			if !strings.EqualFold(extraction.UserName, request.UserName) {
+				return AccountRecord{}, newResolutionError(reference, AccountStatusTransient, fmt.Errorf(errMessageHandleMismatchFmt, errHandleMismatch, request.UserName, extraction.UserName))
+			}
+			page.UserName = extraction.UserName
+		}
+		if accountID == "" {
+			accountID = extraction.AccountID
+		}
+	}
+	if accountID == "" {
+		return AccountRecord{}, newResolutionError(reference, AccountStatusTransient, errMissingAccountID)
+	}
+	page.Status = pageStatus
+	return resolver.recordFromPage(accountID, page, nil)
+}
+
+func (resolver *Resolver) profileURL(handle string) string {
+	return resolver.baseURL.ResolveReference(&url.URL{Path: "/" + handle}).String()
+}
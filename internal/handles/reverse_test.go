@@ -0,0 +1,169 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	reverseTestUserName        = "reverser"
+	reverseTestAccountID       = "70001"
+	reverseTestProfileHTML     = `<html><head><title>Reverse Name (@reverser) / X</title><script type="application/json">{"users":[{"rest_id":"70002","legacy":{"screen_name":"someoneelse"}},{"rest_id":"70001","legacy":{"screen_name":"Reverser"}}]}</script></head><body><a href="https://x.com/reverser">profile</a></body></html>`
+	reverseTestNoIDProfileHTML = `<html><head><title>Reverse Name (@reverser) / X</title></head><body><a href="https://x.com/reverser">profile</a></body></html>`
+	reverseTestAPIUsernamesURL = "/2/users/by"
+)
+
+type handleIntentFetcher struct {
+	mutex sync.Mutex
+	pages map[string]handles.IntentPage
+	calls map[string]int
+}
+
+func (fetcher *handleIntentFetcher) FetchIntentPage(ctx context.Context, request handles.IntentRequest) (handles.IntentPage, error) {
+	fetcher.mutex.Lock()
+	fetcher.calls[request.UserName]++
+	fetcher.mutex.Unlock()
+	if request.AccountID != "" {
+		return handles.IntentPage{}, errors.New("unexpected identifier lookup")
+	}
+	return fetcher.pages[request.UserName], nil
+}
+
+func TestResolverResolveHandle(t *testing.T) {
+	testCases := []struct {
+		name              string
+		userName          string
+		html              string
+		expectedAccountID string
+		expectedErr       error
+		expectedStatus    handles.AccountStatus
+	}{
+		{
+			name:              "rest id next to the screen name",
+			userName:          "@" + reverseTestUserName,
+			html:              reverseTestProfileHTML,
+			expectedAccountID: reverseTestAccountID,
+		},
+		{
+			name:           "page without an identifier",
+			userName:       reverseTestUserName,
+			html:           reverseTestNoIDProfileHTML,
+			expectedStatus: handles.AccountStatusTransient,
+		},
+		{
+			name:        "reserved path",
+			userName:    "home",
+			expectedErr: handles.ErrInvalidHandle,
+		},
+		{
+			name:        "too long",
+			userName:    "abcdefghijklmnopq",
+			expectedErr: handles.ErrInvalidHandle,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			fetcher := &handleIntentFetcher{
+				pages: map[string]handles.IntentPage{reverseTestUserName: {HTML: testCase.html}},
+				calls: make(map[string]int),
+			}
+			resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+
+			record, resolveErr := resolver.ResolveHandle(context.Background(), testCase.userName)
+			switch {
+			case testCase.expectedErr != nil:
+				if !errors.Is(resolveErr, testCase.expectedErr) {
+					t.Fatalf("expected %v, got %v", testCase.expectedErr, resolveErr)
+				}
+			case testCase.expectedStatus != "":
+				if handles.StatusOf(resolveErr) != testCase.expectedStatus {
+					t.Fatalf("expected status %s, got %v", testCase.expectedStatus, resolveErr)
+				}
+			default:
+				if resolveErr != nil {
+					t.Fatalf("resolve handle: %v", resolveErr)
+				}
+				if record.AccountID != testCase.expectedAccountID || record.UserName != reverseTestUserName {
+					t.Fatalf("unexpected record %+v", record)
+				}
+			}
+		})
+	}
+}
+
+func TestResolverResolveHandleUsesCache(t *testing.T) {
+	fetcher := &handleIntentFetcher{
+		pages: map[string]handles.IntentPage{reverseTestUserName: {HTML: reverseTestProfileHTML}},
+		calls: make(map[string]int),
+	}
+	cache := handles.NewMemoryCache()
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: cache, MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	if _, err := resolver.ResolveHandle(context.Background(), reverseTestUserName); err != nil {
+		t.Fatalf("first resolution: %v", err)
+	}
+	record, err := resolver.ResolveHandle(context.Background(), "@REVERSER")
+	if err != nil {
+		t.Fatalf("second resolution: %v", err)
+	}
+	if fetcher.calls[reverseTestUserName] != 1 {
+		t.Fatalf("expected one fetch, got %d", fetcher.calls[reverseTestUserName])
+	}
+	provenance, known := record.UserNameProvenance()
+	if !known || provenance.Source != handles.LabelSourceResolverCache {
+		t.Fatalf("expected resolver cache provenance, got %+v", provenance)
+	}
+	if entry, found := cache.Lookup(reverseTestAccountID); !found || entry.Record.UserName != reverseTestUserName {
+		t.Fatalf("expected the handle lookup to be cached under its identifier, got %+v", entry)
+	}
+}
+
+func TestAPIBackendResolvesHandles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != reverseTestAPIUsernamesURL || request.URL.Query().Get("usernames") != reverseTestUserName {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"data":[{"id":"70001","username":"reverser","name":"Reverse Name","protected":true}]}`))
+	}))
+	defer server.Close()
+
+	resolver, err := handles.NewResolver(handles.Config{
+		Backends:       []handles.Backend{handles.BackendRedirect, handles.BackendAPI},
+		BaseURL:        server.URL,
+		APIBaseURL:     server.URL,
+		APIBearerToken: apiTestBearerToken,
+		HTTPClient:     server.Client(),
+		Cache:          handles.NewMemoryCache(),
+		MaxAttempts:    1,
+		RateLimit:      ratelimit.Config{RequestsPerSecond: -1},
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+
+	record, err := resolver.ResolveHandle(context.Background(), reverseTestUserName)
+	if err != nil {
+		t.Fatalf("resolve handle: %v", err)
+	}
+	if record.AccountID != reverseTestAccountID || record.DisplayName != "Reverse Name" || record.Status != handles.AccountStatusProtected {
+		t.Fatalf("unexpected record %+v", record)
+	}
+}
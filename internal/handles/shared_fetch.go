@@ -0,0 +1,85 @@
+package handles
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const sharedFetchTimeout = 5 * time.Minute
+
+// sharedFetchCall tracks the callers waiting for one in-flight lookup.
+type sharedFetchCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// sharedFetches shares in-flight lookups between concurrent callers of one resolver, which all use its cache and
+// backends. A lookup runs on a context detached from the caller that started it and bounded by sharedFetchTimeout, so
+// one caller giving up does not fail the others waiting for the same key; the lookup is cancelled only once every
+// waiting caller has given up.
+type sharedFetches struct {
+	group singleflight.Group
+	mutex sync.Mutex
+	calls map[string]*sharedFetchCall
+}
+
+func newSharedFetches() *sharedFetches {
+	return &sharedFetches{calls: map[string]*sharedFetchCall{}}
+}
+
+// do runs fetch for key unless a lookup for key is already in flight, and waits for its outcome or for ctx to end.
+func (fetches *sharedFetches) do(ctx context.Context, key string, fetch func(context.Context) (AccountRecord, error)) (AccountRecord, error) {
+	fetches.mutex.Lock()
+	call, inFlight := fetches.calls[key]
+	if !inFlight {
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedFetchTimeout)
+		call = &sharedFetchCall{ctx: callCtx, cancel: cancel}
+		fetches.calls[key] = call
+	}
+	call.waiters++
+	resultChannel := fetches.group.DoChan(key, func() (interface{}, error) {
+		defer fetches.finish(key, call)
+		record, fetchErr := fetch(call.ctx)
+		return record, fetchErr
+	})
+	fetches.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		fetches.leave(key, call)
+		return AccountRecord{}, ctx.Err()
+	case result := <-resultChannel:
+		fetches.leave(key, call)
+		record, _ := result.Val.(AccountRecord)
+		return record, result.Err
+	}
+}
+
+// finish retires a call whose lookup returned, so that later callers start a fresh lookup.
+func (fetches *sharedFetches) finish(key string, call *sharedFetchCall) {
+	fetches.mutex.Lock()
+	defer fetches.mutex.Unlock()
+	call.cancel()
+	if fetches.calls[key] == call {
+		delete(fetches.calls, key)
+	}
+}
+
+// leave releases one waiter of call and cancels the lookup when it was the last one.
+func (fetches *sharedFetches) leave(key string, call *sharedFetchCall) {
+	fetches.mutex.Lock()
+	defer fetches.mutex.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	if fetches.calls[key] == call {
+		delete(fetches.calls, key)
+		fetches.group.Forget(key)
+	}
+}
@@ -0,0 +1,158 @@
+package handles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AccountStatus classifies what an intent lookup revealed about an account. The zero value means the account is
+// active or its state is unknown.
+type AccountStatus string
+
+const (
+	// AccountStatusSuspended reports an account suspended by the platform.
+	AccountStatusSuspended AccountStatus = "suspended"
+	// AccountStatusDoesNotExist reports an account that was deactivated or deleted.
+	AccountStatusDoesNotExist AccountStatus = "does-not-exist"
+	// AccountStatusProtected reports an account whose posts are protected; its handle still resolves.
+	AccountStatusProtected AccountStatus = "protected"
+	// AccountStatusRateLimited reports a lookup refused because too many requests were made.
+	AccountStatusRateLimited AccountStatus = "rate-limited"
+	// AccountStatusTransient reports a lookup that failed for a reason that may clear up on retry.
+	AccountStatusTransient AccountStatus = "transient"
+
+	errMessageAccountSuspended    = "account suspended"
+	errMessageAccountDoesNotExist = "account does not exist"
+	errMessageRateLimited         = "account lookup rate limited"
+	errMessageTransientFailure    = "account lookup failed"
+	resolutionErrorFormat         = "account %s: %s"
+	resolutionErrorCauseFormat    = "account %s: %s: %v"
+
+	curlyApostrophe    = "’"
+	straightApostrophe = "'"
+)
+
+var (
+	// ErrAccountSuspended matches resolution errors for suspended accounts.
+	ErrAccountSuspended = errors.New(errMessageAccountSuspended)
+	// ErrAccountDoesNotExist matches resolution errors for deactivated or deleted accounts.
+	ErrAccountDoesNotExist = errors.New(errMessageAccountDoesNotExist)
+	// ErrRateLimited matches resolution errors caused by rate limiting.
+	ErrRateLimited = errors.New(errMessageRateLimited)
+	// ErrTransientFailure matches resolution errors that may succeed when retried.
+	ErrTransientFailure = errors.New(errMessageTransientFailure)
+
+	statusErrors = map[AccountStatus]error{
+		AccountStatusSuspended:    ErrAccountSuspended,
+		AccountStatusDoesNotExist: ErrAccountDoesNotExist,
+		AccountStatusRateLimited:  ErrRateLimited,
+		AccountStatusTransient:    ErrTransientFailure,
+	}
+
+	// intentPageMarkers lists lower-cased phrases that identify failure pages, checked in order.
+	intentPageMarkers = []struct {
+		status  AccountStatus
+		phrases []string
+	}{
+		{status: AccountStatusRateLimited, phrases: []string{"rate limit exceeded", "you are being rate limited", "too many requests"}},
+		{status: AccountStatusSuspended, phrases: []string{"account suspended", "has been suspended"}},
+		{status: AccountStatusDoesNotExist, phrases: []string{"this account doesn't exist", "this page doesn't exist", "user not found"}},
+	}
+
+	protectedPagePhrases = []string{"posts are protected", "tweets are protected"}
+)
+
+// ResolutionError describes a failed lookup along with the classified account status.
+type ResolutionError struct {
+	AccountID string
+	Status    AccountStatus
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func newResolutionError(accountID string, status AccountStatus, cause error) *ResolutionError {
+	return &ResolutionError{AccountID: accountID, Status: status, Err: cause}
+}
+
+// Error describes the failure.
+func (resolutionErr *ResolutionError) Error() string {
+	description := errMessageTransientFailure
+	if sentinel, known := statusErrors[resolutionErr.Status]; known {
+		description = sentinel.Error()
+	}
+	if resolutionErr.Err == nil {
+		return fmt.Sprintf(resolutionErrorFormat, resolutionErr.AccountID, description)
+	}
+	return fmt.Sprintf(resolutionErrorCauseFormat, resolutionErr.AccountID, description, resolutionErr.Err)
+}
+
+// Unwrap exposes the underlying cause.
+func (resolutionErr *ResolutionError) Unwrap() error {
+	return resolutionErr.Err
+}
+
+// Is matches the sentinel error for the classified status.
+func (resolutionErr *ResolutionError) Is(target error) bool {
+	sentinel, known := statusErrors[resolutionErr.Status]
+	return known && target == sentinel
+}
+
+// StatusOf returns the account status classified by a resolution error, or the zero status when err is not a
+// ResolutionError.
+func StatusOf(err error) AccountStatus {
+	var resolutionErr *ResolutionError
+	if errors.As(err, &resolutionErr) {
+		return resolutionErr.Status
+	}
+	return ""
+}
+
+// IsRetryable reports whether a resolution error is transient and worth retrying.
+func IsRetryable(err error) bool {
+	return StatusOf(err) == AccountStatusTransient
+}
+
+// IsGhost reports whether the status describes an account that no longer exists on the platform.
+func (status AccountStatus) IsGhost() bool {
+	return status == AccountStatusSuspended || status == AccountStatusDoesNotExist
+}
+
+// withFailureStatus records statuses that describe the account itself; rate limits and transient failures say
+// nothing about the account and leave the record unchanged.
+func (record AccountRecord) withFailureStatus(status AccountStatus) AccountRecord {
+	if status.IsGhost() {
+		record.Status = status
+	}
+	return record
+}
+
+// classifyIntentPage inspects rendered intent page HTML for failure pages. Failure phrases only count on pages that
+// show no profile of accountID, since an active account may quote them in its bio or posts.
+func classifyIntentPage(htmlContent string, accountID string) AccountStatus {
+	normalized := normalizeIntentText(htmlContent)
+	if _, err := ExtractProfile(htmlContent, accountID); err != nil {
+		for _, marker := range intentPageMarkers {
+			if containsAnyPhrase(normalized, marker.phrases) {
+				return marker.status
+			}
+		}
+	}
+	if containsAnyPhrase(normalized, protectedPagePhrases) {
+		return AccountStatusProtected
+	}
+	return ""
+}
+
+func normalizeIntentText(htmlContent string) string {
+	return strings.ToLower(strings.ReplaceAll(htmlContent, curlyApostrophe, straightApostrophe))
+}
+
+func containsAnyPhrase(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package handles_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	statusTestAccountIDSuspended   = "40001"
+	statusTestAccountIDDeleted     = "40002"
+	statusTestAccountIDProtected   = "40003"
+	statusTestAccountIDRateLimited = "40004"
+	statusTestAccountIDMissing     = "40005"
+	statusTestAccountIDFetchError  = "40006"
+	statusTestAccountIDCached      = "40007"
+	statusTestAccountIDQuotingBio  = "40008"
+	statusTestAccountIDQuotingPost = "40009"
+
+	statusTestHTMLSuspended   = "<html><body><h1>Account suspended</h1><a href=\"https://x.com/tos\">Terms</a></body></html>"
+	statusTestHTMLDeleted     = "<html><body><span>This account doesn’t exist</span><a href=\"https://x.com/search\">Search</a></body></html>"
+	statusTestHTMLProtected   = "<html><head><title>Private Person (@private) / X</title></head><body><a href=\"https://x.com/private\">profile</a><span>These posts are protected</span></body></html>"
+	statusTestHTMLRateLimited = "<html><body>Rate limit exceeded</body></html>"
+	statusTestHTMLQuotingBio  = "<html><head><link rel=\"canonical\" href=\"https://x.com/moderator\"><meta property=\"og:description\" content=\"Tracking who has been suspended this week.\"></head><body><a href=\"https://x.com/moderator\">profile</a></body></html>"
+	statusTestHTMLQuotingPost = "<html><head><title>Api Fan (@api_fan) / X</title></head><body><a href=\"https://x.com/api_fan\">profile</a><p>Too many requests again, user not found either.</p></body></html>"
+	statusTestFetchFailure    = "chrome crashed"
+	statusTestMaxAttempts     = 3
+)
+
+func TestResolverClassifiesFailures(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		accountID        string
+		htmlContent      string
+		fetchError       error
+		expectedStatus   handles.AccountStatus
+		expectedSentinel error
+		expectedRecord   handles.AccountStatus
+		expectedUserName string
+		expectedCalls    int
+	}{
+		{
+			name:             "suspended account is not retried",
+			accountID:        statusTestAccountIDSuspended,
+			htmlContent:      statusTestHTMLSuspended,
+			expectedStatus:   handles.AccountStatusSuspended,
+			expectedSentinel: handles.ErrAccountSuspended,
+			expectedRecord:   handles.AccountStatusSuspended,
+			expectedCalls:    1,
+		},
+		{
+			name:             "deleted account is not retried",
+			accountID:        statusTestAccountIDDeleted,
+			htmlContent:      statusTestHTMLDeleted,
+			expectedStatus:   handles.AccountStatusDoesNotExist,
+			expectedSentinel: handles.ErrAccountDoesNotExist,
+			expectedRecord:   handles.AccountStatusDoesNotExist,
+			expectedCalls:    1,
+		},
+		{
+			name:             "protected account resolves with status",
+			accountID:        statusTestAccountIDProtected,
+			htmlContent:      statusTestHTMLProtected,
+			expectedRecord:   handles.AccountStatusProtected,
+			expectedUserName: "private",
+			expectedCalls:    1,
+		},
+		{
+			name:             "rate limited lookup is not retried",
+			accountID:        statusTestAccountIDRateLimited,
+			htmlContent:      statusTestHTMLRateLimited,
+			expectedStatus:   handles.AccountStatusRateLimited,
+			expectedSentinel: handles.ErrRateLimited,
+			expectedCalls:    1,
+		},
+		{
+			name:             "active account quoting a suspension in its bio resolves",
+			accountID:        statusTestAccountIDQuotingBio,
+			htmlContent:      statusTestHTMLQuotingBio,
+			expectedUserName: "moderator",
+			expectedCalls:    1,
+		},
+		{
+			name:             "active account quoting failure phrases in a post resolves",
+			accountID:        statusTestAccountIDQuotingPost,
+			htmlContent:      statusTestHTMLQuotingPost,
+			expectedUserName: "api_fan",
+			expectedCalls:    1,
+		},
+		{
+			name:             "missing handle is retried as transient",
+			accountID:        statusTestAccountIDMissing,
+			htmlContent:      resolverTestIntentHTMLMissingHandle,
+			expectedStatus:   handles.AccountStatusTransient,
+			expectedSentinel: handles.ErrTransientFailure,
+			expectedCalls:    statusTestMaxAttempts,
+		},
+		{
+			name:             "fetch failure is retried as transient",
+			accountID:        statusTestAccountIDFetchError,
+			fetchError:       errors.New(statusTestFetchFailure),
+			expectedStatus:   handles.AccountStatusTransient,
+			expectedSentinel: handles.ErrTransientFailure,
+			expectedCalls:    statusTestMaxAttempts,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			responses := map[string]handles.IntentPage{}
+			fetchErrors := map[string]error{}
+			if testCase.htmlContent != "" {
+				responses[testCase.accountID] = handles.IntentPage{HTML: testCase.htmlContent, SourceURL: resolverTestIntentURLPrefix + testCase.accountID}
+			}
+			if testCase.fetchError != nil {
+				fetchErrors[testCase.accountID] = testCase.fetchError
+			}
+			fetcher := newRecordingIntentFetcher(responses, fetchErrors)
+			resolver, err := handles.NewResolver(handles.Config{
+				IntentFetcher: fetcher,
+				Cache:         handles.NewMemoryCache(),
+				MaxAttempts:   statusTestMaxAttempts,
+				RetryDelay:    -1,
+			})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+
+			record, resolveErr := resolver.ResolveAccount(context.Background(), testCase.accountID)
+			if status := handles.StatusOf(resolveErr); status != testCase.expectedStatus {
+				t.Fatalf("expected error status %q, got %q (%v)", testCase.expectedStatus, status, resolveErr)
+			}
+			if testCase.expectedSentinel != nil && !errors.Is(resolveErr, testCase.expectedSentinel) {
+				t.Fatalf("expected error to match %v, got %v", testCase.expectedSentinel, resolveErr)
+			}
+			if testCase.expectedSentinel == nil && resolveErr != nil {
+				t.Fatalf("unexpected error: %v", resolveErr)
+			}
+			if record.Status != testCase.expectedRecord {
+				t.Fatalf("expected record status %q, got %q", testCase.expectedRecord, record.Status)
+			}
+			if record.UserName != testCase.expectedUserName {
+				t.Fatalf("expected username %q, got %q", testCase.expectedUserName, record.UserName)
+			}
+			if fetcher.calls[testCase.accountID] != testCase.expectedCalls {
+				t.Fatalf("expected %d fetches, got %d", testCase.expectedCalls, fetcher.calls[testCase.accountID])
+			}
+		})
+	}
+}
+
+func TestFileCachePreservesResolutionStatus(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), cacheTestFileName)
+	resolver, cache := newFileCacheResolver(t, cachePath, map[string]handles.IntentPage{
+		statusTestAccountIDCached: {HTML: statusTestHTMLSuspended, SourceURL: resolverTestIntentURLPrefix + statusTestAccountIDCached},
+	})
+	if _, err := resolver.ResolveAccount(context.Background(), statusTestAccountIDCached); !errors.Is(err, handles.ErrAccountSuspended) {
+		t.Fatalf("expected suspended error, got %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("close cache: %v", err)
+	}
+
+	reopenedResolver, reopenedCache := newFileCacheResolver(t, cachePath, nil)
+	defer reopenedCache.Close()
+	record, err := reopenedResolver.ResolveAccount(context.Background(), statusTestAccountIDCached)
+	if !errors.Is(err, handles.ErrAccountSuspended) {
+		t.Fatalf("expected cached suspended error, got %v", err)
+	}
+	if record.Status != handles.AccountStatusSuspended {
+		t.Fatalf("expected cached record status %q, got %q", handles.AccountStatusSuspended, record.Status)
+	}
+}
+
+func newFileCacheResolver(t *testing.T, cachePath string, responses map[string]handles.IntentPage) (*handles.Resolver, *handles.FileCache) {
+	t.Helper()
+	cache, err := handles.OpenFileCache(cachePath)
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	resolver, err := handles.NewResolver(handles.Config{
+		IntentFetcher: newRecordingIntentFetcher(responses, nil),
+		Cache:         cache,
+		RetryDelay:    -1,
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	return resolver, cache
+}
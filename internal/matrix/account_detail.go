@@ -0,0 +1,203 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+// ResolverStatus summarizes how an account's labels were obtained.
+type ResolverStatus string
+
+const (
+	// ResolverStatusArchive reports labels read from one of the loaded archives.
+	ResolverStatusArchive ResolverStatus = "archive"
+	// ResolverStatusResolved reports labels obtained by the handle resolver.
+	ResolverStatusResolved ResolverStatus = "resolved"
+	// ResolverStatusFailed reports that the handle resolver could not resolve the account.
+	ResolverStatusFailed ResolverStatus = "failed"
+	// ResolverStatusUnresolved reports an account without a handle that has not been resolved.
+	ResolverStatusUnresolved ResolverStatus = "unresolved"
+
+	errMessageAccountNotFound = "account not found"
+)
+
+// ErrAccountNotFound indicates that no loaded archive mentions the requested account.
+var ErrAccountNotFound = errors.New(errMessageAccountNotFound)
+
+// bucketOrder lists buckets in the order they are reported for an account.
+var bucketOrder = []BucketName{
+	BucketFriends,
+	BucketLeaders,
+	BucketGroupies,
+	BucketFollowing,
+	BucketFollowers,
+	BucketBlocked,
+	BucketBlockedAndFollowing,
+	BucketBlockedAndFollowers,
+	BucketGhosts,
+}
+
+// OwnerRelationship reports how one archive owner relates to an account.
+type OwnerRelationship struct {
+	Slot  OwnerSlot `json:"slot"`
+	Owner string    `json:"owner"`
+	// Follows reports that the owner follows the account.
+	Follows bool `json:"follows"`
+	// FollowedBy reports that the account follows the owner.
+	FollowedBy bool         `json:"followedBy"`
+	Muted      bool         `json:"muted"`
+	Blocked    bool         `json:"blocked"`
+	Buckets    []BucketName `json:"buckets"`
+	// MemberOfLists lists the URLs of lists created by the account that the owner is a member of. Archives name a
+	// list's creator only in older list URLs, so lists identified by number alone are never matched.
+	MemberOfLists []string `json:"memberOfLists"`
+}
+
+// AccountDetail reports every relationship between an account and the owners of a comparison.
+type AccountDetail struct {
+	Record         AccountRecord       `json:"record"`
+	Relationships  []OwnerRelationship `json:"relationships"`
+	ResolverStatus ResolverStatus      `json:"resolverStatus"`
+	ResolverError  string              `json:"resolverError,omitempty"`
+}
+
+// DescribeAccount looks up an account by numeric identifier or handle, with or without the leading @, and reports its
+// relationship with each owner. Resolution errors keyed by account identifier feed the resolver status.
+func (result ComparisonResult) DescribeAccount(reference string, resolutionErrors map[string]error) (AccountDetail, error) {
+	record, found := result.findAccount(reference)
+	if !found {
+		return AccountDetail{}, fmt.Errorf("%w: %q", ErrAccountNotFound, reference)
+	}
+	detail := AccountDetail{
+		Record: record,
+		Relationships: []OwnerRelationship{
+			result.ownerRelationship(OwnerSlotA, result.OwnerA, result.AccountSetsA, record),
+			result.ownerRelationship(OwnerSlotB, result.OwnerB, result.AccountSetsB, record),
+		},
+	}
+	detail.ResolverStatus, detail.ResolverError = accountResolverStatus(record, resolutionErrors[record.AccountID])
+	return detail, nil
+}
+
+// DescribeResolvedAccount behaves like DescribeAccount, but a handle that no loaded archive carries, such as the new
+// handle of a renamed account, is resolved into its account identifier through resolver and described by identifier.
+func (result ComparisonResult) DescribeResolvedAccount(ctx context.Context, reference string, resolutionErrors map[string]error, resolver AccountIDResolver) (AccountDetail, error) {
+	detail, err := result.DescribeAccount(reference, resolutionErrors)
+	if err == nil || resolver == nil || isNumericAccountID(strings.TrimSpace(reference)) {
+		return detail, err
+	}
+	accountID, resolveErr := resolveAccountReference(ctx, resolver, strings.TrimSpace(reference))
+	if resolveErr != nil {
+		return AccountDetail{}, fmt.Errorf("%w: %q: %v", ErrAccountNotFound, reference, resolveErr)
+	}
+	return result.DescribeAccount(accountID, resolutionErrors)
+}
+
+func (result ComparisonResult) findAccount(reference string) (AccountRecord, bool) {
+	trimmed := strings.TrimSpace(reference)
+	if trimmed == "" {
+		return AccountRecord{}, false
+	}
+	accountSets := []AccountSets{result.AccountSetsA, result.AccountSetsB}
+	if isNumericAccountID(trimmed) {
+		var best AccountRecord
+		found := false
+		for _, accountSet := range accountSets {
+			for _, records := range []map[string]AccountRecord{accountSet.Following, accountSet.Followers} {
+				if record, exists := records[trimmed]; exists && (!found || (best.UserName == "" && record.UserName != "")) {
+					best = record
+					found = true
+				}
+			}
+			if accountSet.Muted[trimmed] || accountSet.Blocked[trimmed] {
+				if !found {
+					best = AccountRecord{AccountID: trimmed}
+					found = true
+				}
+				if record, labeled := accountSet.Labels[trimmed]; labeled && best.UserName == "" && record.UserName != "" {
+					best = record
+				}
+			}
+		}
+		return best, found
+	}
+	handle := strings.TrimPrefix(trimmed, accountHandlePrefix)
+	for _, accountSet := range accountSets {
+		for _, records := range []map[string]AccountRecord{accountSet.Following, accountSet.Followers, accountSet.Labels} {
+			for _, accountID := range sortedRecordIDs(records) {
+				if strings.EqualFold(records[accountID].UserName, handle) {
+					return records[accountID], true
+				}
+			}
+		}
+	}
+	return AccountRecord{}, false
+}
+
+func (result ComparisonResult) ownerRelationship(slot OwnerSlot, owner OwnerIdentity, accountSets AccountSets, record AccountRecord) OwnerRelationship {
+	accountID := record.AccountID
+	relationship := OwnerRelationship{
+		Slot:          slot,
+		Owner:         ownerPretty(owner),
+		Muted:         accountSets.Muted[accountID],
+		Blocked:       accountSets.Blocked[accountID],
+		Buckets:       []BucketName{},
+		MemberOfLists: memberOfLists(accountSets.ListMemberships, record),
+	}
+	_, relationship.Follows = accountSets.Following[accountID]
+	_, relationship.FollowedBy = accountSets.Followers[accountID]
+	for _, bucket := range bucketOrder {
+		records, err := result.Bucket(slot, bucket)
+		if err != nil {
+			continue
+		}
+		for _, record := range records {
+			if record.AccountID == accountID {
+				relationship.Buckets = append(relationship.Buckets, bucket)
+				break
+			}
+		}
+	}
+	return relationship
+}
+
+// memberOfLists returns the URLs of the memberships whose list was created under the record's current or a former
+// handle.
+func memberOfLists(memberships []ListMembership, record AccountRecord) []string {
+	userNames := append([]string{record.UserName}, record.FormerUserNames...)
+	lists := []string{}
+	for _, membership := range memberships {
+		if membership.CreatorUserName == "" {
+			continue
+		}
+		for _, userName := range userNames {
+			if strings.EqualFold(strings.TrimSpace(userName), membership.CreatorUserName) {
+				lists = append(lists, membership.URL)
+				break
+			}
+		}
+	}
+	return lists
+}
+
+func accountResolverStatus(record AccountRecord, resolutionErr error) (ResolverStatus, string) {
+	if (errors.Is(resolutionErr, handles.ErrBudgetExhausted) || errors.Is(resolutionErr, handles.ErrNotCached)) && strings.TrimSpace(record.UserName) == "" {
+		return ResolverStatusUnresolved, resolutionErr.Error()
+	}
+	if resolutionErr != nil {
+		return ResolverStatusFailed, resolutionErr.Error()
+	}
+	provenance, known := record.UserNameProvenance()
+	switch {
+	case strings.TrimSpace(record.UserName) == "":
+		return ResolverStatusUnresolved, ""
+	case known && provenance.Source != handles.LabelSourceArchive:
+		return ResolverStatusResolved, ""
+	default:
+		return ResolverStatusArchive, ""
+	}
+}
@@ -0,0 +1,212 @@
+package matrix_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+func TestDescribeAccount(t *testing.T) {
+	resolvedRecord := matrix.AccountRecord{AccountID: "30"}.WithUserName("resolved", handles.FieldProvenance{Source: handles.LabelSourceLiveFetch})
+	blockedLabel := matrix.AccountRecord{AccountID: "50"}.WithUserName("blocked_label", handles.FieldProvenance{Source: handles.LabelSourceLiveFetch})
+	comparison := matrix.BuildComparison(
+		matrix.AccountSets{
+			Following: map[string]matrix.AccountRecord{"10": {AccountID: "10", UserName: "Friend"}, "30": resolvedRecord},
+			Followers: map[string]matrix.AccountRecord{"10": {AccountID: "10", UserName: "Friend"}},
+			Muted:     map[string]bool{"10": true},
+			Blocked:   map[string]bool{"20": true, "50": true},
+			Labels:    map[string]matrix.AccountRecord{"50": blockedLabel},
+		},
+		matrix.AccountSets{
+			Followers: map[string]matrix.AccountRecord{"10": {AccountID: "10"}, "40": {AccountID: "40"}},
+			ListMemberships: []matrix.ListMembership{
+				{URL: "https://twitter.com/friend/lists/pals", CreatorUserName: "friend"},
+				{URL: "https://x.com/i/lists/1234"},
+			},
+		},
+		matrix.OwnerIdentity{AccountID: "1", UserName: "owner_a"},
+		matrix.OwnerIdentity{AccountID: "2", UserName: "owner_b"},
+	)
+	resolutionErrors := map[string]error{"40": errors.New("suspended")}
+
+	testCases := []struct {
+		name               string
+		reference          string
+		expectedAccountID  string
+		expectedStatus     matrix.ResolverStatus
+		expectedOwnerA     matrix.OwnerRelationship
+		expectedOwnerB     matrix.OwnerRelationship
+		expectedBucketsA   []matrix.BucketName
+		expectedBucketsB   []matrix.BucketName
+		expectedListsB     []string
+		expectedLookupFail bool
+	}{
+		{
+			name:              "handle lookup is case insensitive and reports both owners",
+			reference:         "@friend",
+			expectedAccountID: "10",
+			expectedStatus:    matrix.ResolverStatusArchive,
+			expectedOwnerA:    matrix.OwnerRelationship{Follows: true, FollowedBy: true, Muted: true},
+			expectedOwnerB:    matrix.OwnerRelationship{FollowedBy: true},
+			expectedBucketsA:  []matrix.BucketName{matrix.BucketFriends, matrix.BucketFollowing, matrix.BucketFollowers},
+			expectedBucketsB:  []matrix.BucketName{matrix.BucketGroupies, matrix.BucketFollowers},
+			expectedListsB:    []string{"https://twitter.com/friend/lists/pals"},
+		},
+		{
+			name:              "blocked identifier without records",
+			reference:         "20",
+			expectedAccountID: "20",
+			expectedStatus:    matrix.ResolverStatusUnresolved,
+			expectedOwnerA:    matrix.OwnerRelationship{Blocked: true},
+			expectedBucketsA:  []matrix.BucketName{matrix.BucketBlocked},
+			expectedBucketsB:  []matrix.BucketName{},
+		},
+		{
+			name:              "blocked identifier uses its label",
+			reference:         "50",
+			expectedAccountID: "50",
+			expectedStatus:    matrix.ResolverStatusResolved,
+			expectedOwnerA:    matrix.OwnerRelationship{Blocked: true},
+			expectedBucketsA:  []matrix.BucketName{matrix.BucketBlocked},
+			expectedBucketsB:  []matrix.BucketName{},
+		},
+		{
+			name:              "blocked label handle",
+			reference:         "@Blocked_Label",
+			expectedAccountID: "50",
+			expectedStatus:    matrix.ResolverStatusResolved,
+			expectedOwnerA:    matrix.OwnerRelationship{Blocked: true},
+			expectedBucketsA:  []matrix.BucketName{matrix.BucketBlocked},
+			expectedBucketsB:  []matrix.BucketName{},
+		},
+		{
+			name:              "resolved handle",
+			reference:         "resolved",
+			expectedAccountID: "30",
+			expectedStatus:    matrix.ResolverStatusResolved,
+			expectedOwnerA:    matrix.OwnerRelationship{Follows: true},
+			expectedBucketsA:  []matrix.BucketName{matrix.BucketLeaders, matrix.BucketFollowing},
+			expectedBucketsB:  []matrix.BucketName{},
+		},
+		{
+			name:              "failed resolution",
+			reference:         "40",
+			expectedAccountID: "40",
+			expectedStatus:    matrix.ResolverStatusFailed,
+			expectedOwnerB:    matrix.OwnerRelationship{FollowedBy: true},
+			expectedBucketsA:  []matrix.BucketName{},
+			expectedBucketsB:  []matrix.BucketName{matrix.BucketGroupies, matrix.BucketFollowers},
+		},
+		{
+			name:               "unknown account",
+			reference:          "@nobody",
+			expectedLookupFail: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			detail, err := comparison.DescribeAccount(testCase.reference, resolutionErrors)
+			if testCase.expectedLookupFail {
+				if !errors.Is(err, matrix.ErrAccountNotFound) {
+					t.Fatalf("expected ErrAccountNotFound, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DescribeAccount returned error: %v", err)
+			}
+			if detail.Record.AccountID != testCase.expectedAccountID {
+				t.Fatalf("expected account %s, got %s", testCase.expectedAccountID, detail.Record.AccountID)
+			}
+			if detail.ResolverStatus != testCase.expectedStatus {
+				t.Fatalf("expected resolver status %s, got %s", testCase.expectedStatus, detail.ResolverStatus)
+			}
+			if len(detail.Relationships) != 2 {
+				t.Fatalf("expected two relationships, got %d", len(detail.Relationships))
+			}
+			assertRelationship(t, detail.Relationships[0], testCase.expectedOwnerA, testCase.expectedBucketsA)
+			assertRelationship(t, detail.Relationships[1], testCase.expectedOwnerB, testCase.expectedBucketsB)
+			if len(detail.Relationships[0].MemberOfLists) != 0 {
+				t.Fatalf("expected no lists for owner A, got %v", detail.Relationships[0].MemberOfLists)
+			}
+			if !reflect.DeepEqual(detail.Relationships[1].MemberOfLists, append([]string{}, testCase.expectedListsB...)) {
+				t.Fatalf("expected lists %v for owner B, got %v", testCase.expectedListsB, detail.Relationships[1].MemberOfLists)
+			}
+		})
+	}
+}
+
+func assertRelationship(t *testing.T, actual matrix.OwnerRelationship, expected matrix.OwnerRelationship, expectedBuckets []matrix.BucketName) {
+	t.Helper()
+	if actual.Follows != expected.Follows || actual.FollowedBy != expected.FollowedBy || actual.Muted != expected.Muted || actual.Blocked != expected.Blocked {
+		t.Fatalf("unexpected relationship for %s: %+v", actual.Slot, actual)
+	}
+	if len(actual.Buckets) != len(expectedBuckets) {
+		t.Fatalf("unexpected buckets for %s: %v, want %v", actual.Slot, actual.Buckets, expectedBuckets)
+	}
+	for index, bucket := range expectedBuckets {
+		if actual.Buckets[index] != bucket {
+			t.Fatalf("unexpected buckets for %s: %v, want %v", actual.Slot, actual.Buckets, expectedBuckets)
+		}
+	}
+}
+
+type stubAccountIDResolver struct {
+	accountIDsByHandle map[string]string
+}
+
+func (resolver stubAccountIDResolver) ResolveHandle(_ context.Context, userName string) (handles.AccountRecord, error) {
+	handle, _ := handles.NormalizeHandle(userName)
+	accountID, found := resolver.accountIDsByHandle[handle]
+	if !found {
+		return handles.AccountRecord{}, errors.New("unknown handle")
+	}
+	return handles.AccountRecord{AccountID: accountID, UserName: handle}, nil
+}
+
+func TestDescribeResolvedAccountFindsRenamedAccounts(t *testing.T) {
+	comparison := matrix.BuildComparison(
+		matrix.AccountSets{Following: map[string]matrix.AccountRecord{"10": {AccountID: "10", UserName: "old_name"}}},
+		matrix.AccountSets{},
+		matrix.OwnerIdentity{AccountID: "1"},
+		matrix.OwnerIdentity{AccountID: "2"},
+	)
+	resolver := stubAccountIDResolver{accountIDsByHandle: map[string]string{"new_name": "10", "stranger": "99"}}
+
+	detail, err := comparison.DescribeResolvedAccount(context.Background(), "@new_name", nil, resolver)
+	if err != nil {
+		t.Fatalf("describe renamed account: %v", err)
+	}
+	if detail.Record.AccountID != "10" || !detail.Relationships[0].Follows {
+		t.Fatalf("unexpected detail %+v", detail)
+	}
+	if _, err := comparison.DescribeResolvedAccount(context.Background(), "stranger", nil, resolver); !errors.Is(err, matrix.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound for an account outside the archives, got %v", err)
+	}
+	if _, err := comparison.DescribeResolvedAccount(context.Background(), "@new_name", nil, nil); !errors.Is(err, matrix.ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound without a resolver, got %v", err)
+	}
+}
+
+func TestResolveAccountReferences(t *testing.T) {
+	resolver := stubAccountIDResolver{accountIDsByHandle: map[string]string{"known": "10"}}
+
+	accountIDs, resolveErrs := matrix.ResolveAccountReferences(context.Background(), resolver, []string{" 20 ", "@known", "", "10", "unknown"})
+	if len(accountIDs) != 2 || accountIDs[0] != "20" || accountIDs[1] != "10" {
+		t.Fatalf("unexpected account ids %v", accountIDs)
+	}
+	if len(resolveErrs) != 1 || resolveErrs["unknown"] == nil {
+		t.Fatalf("expected only the unknown handle to fail, got %v", resolveErrs)
+	}
+
+	_, resolveErrs = matrix.ResolveAccountReferences(context.Background(), nil, []string{"@known"})
+	if !errors.Is(resolveErrs["@known"], matrix.ErrNoAccountIDResolver) {
+		t.Fatalf("expected ErrNoAccountIDResolver, got %v", resolveErrs)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io/fs"
 	"sort"
 	"strings"
+
+	"github.com/f-sync/fsync/internal/handles"
 )
 
 //go:embed web/static/* web/templates/*
@@ -16,17 +18,61 @@ const (
 	templateBaseName       = "base"
 	templateIndexFile      = "web/templates/index.tmpl"
 	templateIndexName      = "index.tmpl"
+	templateConsensusFile  = "web/templates/consensus.tmpl"
+	templateConsensusName  = "consensus.tmpl"
+	consensusTitleText     = "Team Consensus"
 	embeddedBaseCSSPath    = "web/static/base.css"
 	embeddedAppJSPath      = "web/static/app.js"
 	twitterUserNameBaseURL = "https://twitter.com/"
 	twitterUserIDBaseURL   = "https://twitter.com/i/user/"
+	followIntentHandleURL  = "https://twitter.com/intent/follow?screen_name="
+	followIntentUserIDURL  = "https://twitter.com/intent/user?user_id="
 	accountHandlePrefix    = "@"
 	displayHandleFormat    = "%s (%s%s)"
 	pageTitleText          = "Twitter Relationship Matrix"
 	unknownLabelText       = "Unknown"
 	embedReadErrorFormat   = "embed read %s: %w"
+	provenanceDateLayout   = "2006-01-02"
+	provenanceResolvedText = "resolved"
+	provenanceBorrowedText = "from other archive"
+	provenanceUnknownDate  = "an unknown date"
+	provenanceNoteFormat   = "%s %s on %s"
+	provenanceNoteJoiner   = "; "
+	formerHandlesPrefix    = "formerly "
+	formerHandlesJoiner    = ", "
 )
 
+// accountStatusBadges labels ghost accounts; protected accounts are marked with a lock instead.
+var accountStatusBadges = map[handles.AccountStatus]string{
+	handles.AccountStatusSuspended:    "Suspended",
+	handles.AccountStatusDoesNotExist: "Deleted",
+}
+
+var labelFieldNames = map[LabelField]string{
+	LabelFieldUserName:    "Handle",
+	LabelFieldDisplayName: "Display name",
+}
+
+var labelSourceDescriptions = map[handles.LabelSource]string{
+	handles.LabelSourceArchive:       "from another archive",
+	handles.LabelSourceResolverCache: "resolved from the resolver cache",
+	handles.LabelSourceLiveFetch:     "resolved by live fetch",
+}
+
+var sortModeLabels = map[SortMode]string{
+	SortModeDisplayName: "Display name",
+	SortModeHandle:      "Handle",
+	SortModeAccountID:   "Account ID",
+	SortModeRecency:     "Export recency",
+}
+
+var accountFilterLabels = map[AccountFilter]string{
+	AccountFilterAll:       "All accounts",
+	AccountFilterProtected: "Protected",
+	AccountFilterPublic:    "Public",
+	AccountFilterVerified:  "Verified",
+}
+
 func embeddedText(path string) (string, error) {
 	content, err := fs.ReadFile(embeddedFS, path)
 	if err != nil {
@@ -43,7 +89,7 @@ func StaticAssets() (fs.FS, error) {
 func parseTemplates(fileSystem fs.FS, files ...string) (*template.Template, error) {
 	templateWithFuncs := template.New(templateBaseName).Funcs(template.FuncMap{
 		"profileURL": func(record AccountRecord) string {
-			return newAccountPresentation(record).ProfileURL()
+			return newAccountPresentation(record, "").ProfileURL()
 		},
 		"label": func(record AccountRecord) string {
 			display := strings.TrimSpace(record.DisplayName)
@@ -79,6 +125,15 @@ func mapKeys(flags map[string]bool) []string {
 	return keys
 }
 
+// labelRecords lists label records ordered by account identifier.
+func labelRecords(labels map[string]AccountRecord) []AccountRecord {
+	records := make([]AccountRecord, 0, len(labels))
+	for _, accountID := range sortedRecordIDs(labels) {
+		records = append(records, labels[accountID])
+	}
+	return records
+}
+
 func ownerPretty(identity OwnerIdentity) string {
 	display := strings.TrimSpace(identity.DisplayName)
 	handle := strings.TrimSpace(identity.UserName)
@@ -0,0 +1,135 @@
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OwnerSlot identifies one of the two archive owners in a comparison.
+type OwnerSlot string
+
+// BucketName identifies a classified list of accounts for an owner.
+type BucketName string
+
+const (
+	// OwnerSlotA selects the first archive owner.
+	OwnerSlotA OwnerSlot = "A"
+	// OwnerSlotB selects the second archive owner.
+	OwnerSlotB OwnerSlot = "B"
+
+	// BucketFriends lists mutual follows.
+	BucketFriends BucketName = "friends"
+	// BucketLeaders lists accounts the owner follows that do not follow back.
+	BucketLeaders BucketName = "leaders"
+	// BucketGroupies lists followers the owner does not follow back.
+	BucketGroupies BucketName = "groupies"
+	// BucketFollowers lists every follower of the owner.
+	BucketFollowers BucketName = "followers"
+	// BucketFollowing lists every account the owner follows.
+	BucketFollowing BucketName = "following"
+	// BucketBlocked lists every account the owner blocked.
+	BucketBlocked BucketName = "blocked"
+	// BucketBlockedAndFollowing lists blocked accounts the owner still follows.
+	BucketBlockedAndFollowing BucketName = "blocked-following"
+	// BucketBlockedAndFollowers lists blocked accounts that still follow the owner.
+	BucketBlockedAndFollowers BucketName = "blocked-followers"
+	// BucketGhosts lists suspended or deleted accounts the owner still follows or is followed by.
+	BucketGhosts BucketName = "ghosts"
+
+	errMessageUnknownOwnerSlot = "unknown owner slot"
+	errMessageUnknownBucket    = "unknown bucket"
+)
+
+var (
+	// ErrUnknownOwnerSlot indicates that an owner slot other than A or B was requested.
+	ErrUnknownOwnerSlot = errors.New(errMessageUnknownOwnerSlot)
+	// ErrUnknownBucket indicates that a bucket name is not recognized.
+	ErrUnknownBucket = errors.New(errMessageUnknownBucket)
+)
+
+// RecordPage is a window into an ordered bucket of account records.
+type RecordPage struct {
+	Records []AccountRecord `json:"records"`
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+	Total   int             `json:"total"`
+}
+
+// HasMore reports whether records remain after the current page.
+func (page RecordPage) HasMore() bool {
+	return page.Offset+len(page.Records) < page.Total
+}
+
+// NextOffset returns the offset of the record following the current page.
+func (page RecordPage) NextOffset() int {
+	return page.Offset + len(page.Records)
+}
+
+// ParseOwnerSlot converts user input into an OwnerSlot.
+func ParseOwnerSlot(value string) (OwnerSlot, error) {
+	switch OwnerSlot(strings.ToUpper(strings.TrimSpace(value))) {
+	case OwnerSlotA:
+		return OwnerSlotA, nil
+	case OwnerSlotB:
+		return OwnerSlotB, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownOwnerSlot, value)
+	}
+}
+
+// Bucket returns the ordered records for the requested owner and bucket.
+func (result ComparisonResult) Bucket(owner OwnerSlot, bucket BucketName) ([]AccountRecord, error) {
+	var buckets map[BucketName][]AccountRecord
+	switch owner {
+	case OwnerSlotA:
+		buckets = map[BucketName][]AccountRecord{
+			BucketFriends:             result.OwnerAFriends,
+			BucketLeaders:             result.OwnerALeaders,
+			BucketGroupies:            result.OwnerAGroupies,
+			BucketFollowers:           result.OwnerAFollowersAll,
+			BucketFollowing:           result.OwnerAFollowingsAll,
+			BucketBlocked:             result.OwnerABlockedAll,
+			BucketBlockedAndFollowing: result.OwnerABlockedAndFollowing,
+			BucketBlockedAndFollowers: result.OwnerABlockedAndFollowers,
+			BucketGhosts:              result.OwnerAGhosts,
+		}
+	case OwnerSlotB:
+		buckets = map[BucketName][]AccountRecord{
+			BucketFriends:             result.OwnerBFriends,
+			BucketLeaders:             result.OwnerBLeaders,
+			BucketGroupies:            result.OwnerBGroupies,
+			BucketFollowers:           result.OwnerBFollowersAll,
+			BucketFollowing:           result.OwnerBFollowingsAll,
+			BucketBlocked:             result.OwnerBBlockedAll,
+			BucketBlockedAndFollowing: result.OwnerBBlockedAndFollowing,
+			BucketBlockedAndFollowers: result.OwnerBBlockedAndFollowers,
+			BucketGhosts:              result.OwnerBGhosts,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownOwnerSlot, owner)
+	}
+	records, found := buckets[BucketName(strings.ToLower(strings.TrimSpace(string(bucket))))]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBucket, bucket)
+	}
+	return records, nil
+}
+
+// PaginateRecords returns the window of records starting at offset; a non-positive limit returns the remainder.
+func PaginateRecords(records []AccountRecord, offset int, limit int) RecordPage {
+	total := len(records)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && limit < total-offset {
+		end = offset + limit
+	}
+	window := make([]AccountRecord, end-offset)
+	copy(window, records[offset:end])
+	return RecordPage{Records: window, Offset: offset, Limit: limit, Total: total}
+}
@@ -1,41 +1,54 @@
 package matrix
 
-import (
-	"sort"
-	"strings"
-)
+import "strings"
 
-// BuildComparison classifies the relationship data for two archive owners.
+// BuildComparison classifies the relationship data for two archive owners using the default ordering.
 func BuildComparison(accountSetsOwnerA AccountSets, accountSetsOwnerB AccountSets, ownerIdentityA OwnerIdentity, ownerIdentityB OwnerIdentity) ComparisonResult {
+	return BuildComparisonWithOptions(accountSetsOwnerA, accountSetsOwnerB, ownerIdentityA, ownerIdentityB, ComparisonOptions{})
+}
+
+// BuildComparisonWithOptions classifies the relationship data for two archive owners and orders and filters every
+// bucket according to the supplied options.
+func BuildComparisonWithOptions(accountSetsOwnerA AccountSets, accountSetsOwnerB AccountSets, ownerIdentityA OwnerIdentity, ownerIdentityB OwnerIdentity, options ComparisonOptions) ComparisonResult {
+	if strings.TrimSpace(string(options.SortMode)) == "" {
+		options.SortMode = DefaultSortMode
+	}
 	comparisonResult := ComparisonResult{
 		AccountSetsA: accountSetsOwnerA,
 		AccountSetsB: accountSetsOwnerB,
 		OwnerA:       ownerIdentityA,
 		OwnerB:       ownerIdentityB,
+		Options:      options,
 	}
+	sorterOwnerA := newRecordSorter(options, accountSetsOwnerA.ExportOrder)
+	sorterOwnerB := newRecordSorter(options, accountSetsOwnerB.ExportOrder)
 
 	friendsForOwnerA, leadersForOwnerA, groupiesForOwnerA := classifyAccountRelationships(accountSetsOwnerA)
-	comparisonResult.OwnerAFriends = toSortedRecords(friendsForOwnerA)
-	comparisonResult.OwnerALeaders = toSortedRecords(leadersForOwnerA)
-	comparisonResult.OwnerAGroupies = toSortedRecords(groupiesForOwnerA)
-	comparisonResult.OwnerAFollowersAll = toSortedRecords(accountSetsOwnerA.Followers)
-	comparisonResult.OwnerAFollowingsAll = toSortedRecords(accountSetsOwnerA.Following)
+	comparisonResult.OwnerAFriends = toSortedRecords(friendsForOwnerA, sorterOwnerA)
+	comparisonResult.OwnerALeaders = toSortedRecords(leadersForOwnerA, sorterOwnerA)
+	comparisonResult.OwnerAGroupies = toSortedRecords(groupiesForOwnerA, sorterOwnerA)
+	comparisonResult.OwnerAFollowersAll = toSortedRecords(accountSetsOwnerA.Followers, sorterOwnerA)
+	comparisonResult.OwnerAFollowingsAll = toSortedRecords(accountSetsOwnerA.Following, sorterOwnerA)
 
 	friendsForOwnerB, leadersForOwnerB, groupiesForOwnerB := classifyAccountRelationships(accountSetsOwnerB)
-	comparisonResult.OwnerBFriends = toSortedRecords(friendsForOwnerB)
-	comparisonResult.OwnerBLeaders = toSortedRecords(leadersForOwnerB)
-	comparisonResult.OwnerBGroupies = toSortedRecords(groupiesForOwnerB)
-	comparisonResult.OwnerBFollowersAll = toSortedRecords(accountSetsOwnerB.Followers)
-	comparisonResult.OwnerBFollowingsAll = toSortedRecords(accountSetsOwnerB.Following)
+	comparisonResult.OwnerBFriends = toSortedRecords(friendsForOwnerB, sorterOwnerB)
+	comparisonResult.OwnerBLeaders = toSortedRecords(leadersForOwnerB, sorterOwnerB)
+	comparisonResult.OwnerBGroupies = toSortedRecords(groupiesForOwnerB, sorterOwnerB)
+	comparisonResult.OwnerBFollowersAll = toSortedRecords(accountSetsOwnerB.Followers, sorterOwnerB)
+	comparisonResult.OwnerBFollowingsAll = toSortedRecords(accountSetsOwnerB.Following, sorterOwnerB)
 
-	comparisonResult.OwnerABlockedAll = resolveBlockedAccounts(accountSetsOwnerA, accountSetsOwnerA, accountSetsOwnerB)
-	comparisonResult.OwnerABlockedAndFollowing = intersectBlockedWithRecords(accountSetsOwnerA, accountSetsOwnerA.Following)
-	comparisonResult.OwnerABlockedAndFollowers = intersectBlockedWithRecords(accountSetsOwnerA, accountSetsOwnerA.Followers)
+	comparisonResult.OwnerABlockedAll = resolveBlockedAccounts(accountSetsOwnerA, accountSetsOwnerA, accountSetsOwnerB, sorterOwnerA)
+	comparisonResult.OwnerABlockedAndFollowing = intersectBlockedWithRecords(accountSetsOwnerA, accountSetsOwnerA.Following, sorterOwnerA)
+	comparisonResult.OwnerABlockedAndFollowers = intersectBlockedWithRecords(accountSetsOwnerA, accountSetsOwnerA.Followers, sorterOwnerA)
 
-	comparisonResult.OwnerBBlockedAll = resolveBlockedAccounts(accountSetsOwnerB, accountSetsOwnerA, accountSetsOwnerB)
-	comparisonResult.OwnerBBlockedAndFollowing = intersectBlockedWithRecords(accountSetsOwnerB, accountSetsOwnerB.Following)
-	comparisonResult.OwnerBBlockedAndFollowers = intersectBlockedWithRecords(accountSetsOwnerB, accountSetsOwnerB.Followers)
+	comparisonResult.OwnerBBlockedAll = resolveBlockedAccounts(accountSetsOwnerB, accountSetsOwnerA, accountSetsOwnerB, sorterOwnerB)
+	comparisonResult.OwnerBBlockedAndFollowing = intersectBlockedWithRecords(accountSetsOwnerB, accountSetsOwnerB.Following, sorterOwnerB)
+	comparisonResult.OwnerBBlockedAndFollowers = intersectBlockedWithRecords(accountSetsOwnerB, accountSetsOwnerB.Followers, sorterOwnerB)
 
+	comparisonResult.OwnerAGhosts = collectGhostAccounts(accountSetsOwnerA, sorterOwnerA)
+	comparisonResult.OwnerBGhosts = collectGhostAccounts(accountSetsOwnerB, sorterOwnerB)
+
+	comparisonResult.applyFilter(options.Filter)
 	return comparisonResult
 }
 
@@ -59,23 +72,15 @@ func classifyAccountRelationships(accountSets AccountSets) (map[string]AccountRe
 	return friends, leaders, groupies
 }
 
-func toSortedRecords(recordsByID map[string]AccountRecord) []AccountRecord {
+func toSortedRecords(recordsByID map[string]AccountRecord, sorter recordSorter) []AccountRecord {
 	sortedRecords := make([]AccountRecord, 0, len(recordsByID))
 	for _, record := range recordsByID {
 		sortedRecords = append(sortedRecords, record)
 	}
-	sortAccountRecords(sortedRecords)
+	sorter.Sort(sortedRecords)
 	return sortedRecords
 }
 
-func sortAccountRecords(records []AccountRecord) {
-	sort.Slice(records, func(firstIndex, secondIndex int) bool {
-		firstKey := recordSortKey(records[firstIndex])
-		secondKey := recordSortKey(records[secondIndex])
-		return strings.ToLower(firstKey) < strings.ToLower(secondKey)
-	})
-}
-
 func recordSortKey(record AccountRecord) string {
 	if record.DisplayName != "" {
 		return record.DisplayName
@@ -86,46 +91,51 @@ func recordSortKey(record AccountRecord) string {
 	return record.AccountID
 }
 
-func resolveBlockedAccounts(ownerAccountSets AccountSets, accountSetsOwnerA AccountSets, accountSetsOwnerB AccountSets) []AccountRecord {
+func resolveBlockedAccounts(ownerAccountSets AccountSets, accountSetsOwnerA AccountSets, accountSetsOwnerB AccountSets, sorter recordSorter) []AccountRecord {
+	lookupOrder := []map[string]AccountRecord{
+		ownerAccountSets.Following, ownerAccountSets.Followers,
+		accountSetsOwnerA.Following, accountSetsOwnerA.Followers,
+		accountSetsOwnerB.Following, accountSetsOwnerB.Followers,
+		ownerAccountSets.Labels, accountSetsOwnerA.Labels, accountSetsOwnerB.Labels,
+	}
 	var blockedRecords []AccountRecord
 	for accountID := range ownerAccountSets.Blocked {
-		if record, found := ownerAccountSets.Following[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		if record, found := ownerAccountSets.Followers[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		if record, found := accountSetsOwnerA.Following[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		if record, found := accountSetsOwnerA.Followers[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		if record, found := accountSetsOwnerB.Following[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		if record, found := accountSetsOwnerB.Followers[accountID]; found {
-			blockedRecords = append(blockedRecords, record)
-			continue
-		}
-		blockedRecords = append(blockedRecords, AccountRecord{AccountID: accountID})
+		blockedRecords = append(blockedRecords, lookupAccountRecord(accountID, lookupOrder))
 	}
-	sortAccountRecords(blockedRecords)
+	sorter.Sort(blockedRecords)
 	return blockedRecords
 }
 
-func intersectBlockedWithRecords(ownerAccountSets AccountSets, recordSet map[string]AccountRecord) []AccountRecord {
+// collectGhostAccounts returns followed accounts and followers that the resolver found suspended or deleted.
+func collectGhostAccounts(ownerAccountSets AccountSets, sorter recordSorter) []AccountRecord {
+	ghosts := map[string]AccountRecord{}
+	for _, records := range []map[string]AccountRecord{ownerAccountSets.Following, ownerAccountSets.Followers} {
+		for accountID, record := range records {
+			if _, seen := ghosts[accountID]; !seen && record.Status.IsGhost() {
+				ghosts[accountID] = record
+			}
+		}
+	}
+	return toSortedRecords(ghosts, sorter)
+}
+
+func intersectBlockedWithRecords(ownerAccountSets AccountSets, recordSet map[string]AccountRecord, sorter recordSorter) []AccountRecord {
 	var blockedIntersection []AccountRecord
 	for accountID := range ownerAccountSets.Blocked {
 		if record, exists := recordSet[accountID]; exists {
 			blockedIntersection = append(blockedIntersection, record)
 		}
 	}
-	sortAccountRecords(blockedIntersection)
+	sorter.Sort(blockedIntersection)
 	return blockedIntersection
 }
+
+// lookupAccountRecord returns the first record for the account in the given maps, falling back to a bare record.
+func lookupAccountRecord(accountID string, recordMaps []map[string]AccountRecord) AccountRecord {
+	for _, records := range recordMaps {
+		if record, found := records[accountID]; found {
+			return record
+		}
+	}
+	return AccountRecord{AccountID: accountID}
+}
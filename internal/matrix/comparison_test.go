@@ -3,6 +3,7 @@ package matrix_test
 import (
 	"testing"
 
+	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
 )
 
@@ -90,6 +91,59 @@ func TestBuildComparison(t *testing.T) {
 	}
 }
 
+func TestBuildComparisonGhostAccounts(t *testing.T) {
+	activeRecord := matrix.AccountRecord{AccountID: "11", DisplayName: "Active"}
+	protectedRecord := matrix.AccountRecord{AccountID: "12", DisplayName: "Protected", Status: handles.AccountStatusProtected}
+	suspendedRecord := matrix.AccountRecord{AccountID: "13", DisplayName: "Suspended", Status: handles.AccountStatusSuspended}
+	deletedRecord := matrix.AccountRecord{AccountID: "14", DisplayName: "Deleted", Status: handles.AccountStatusDoesNotExist}
+
+	testCases := []struct {
+		name            string
+		accountSetsA    matrix.AccountSets
+		accountSetsB    matrix.AccountSets
+		expectedGhostsA []string
+		expectedGhostsB []string
+	}{
+		{
+			name: "lists suspended and deleted accounts from following and followers",
+			accountSetsA: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{
+					activeRecord.AccountID:    activeRecord,
+					suspendedRecord.AccountID: suspendedRecord,
+				},
+				Followers: map[string]matrix.AccountRecord{
+					suspendedRecord.AccountID: suspendedRecord,
+					deletedRecord.AccountID:   deletedRecord,
+					protectedRecord.AccountID: protectedRecord,
+				},
+			},
+			accountSetsB: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{
+					activeRecord.AccountID:    activeRecord,
+					protectedRecord.AccountID: protectedRecord,
+				},
+			},
+			expectedGhostsA: []string{deletedRecord.AccountID, suspendedRecord.AccountID},
+			expectedGhostsB: []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			comparison := matrix.BuildComparison(testCase.accountSetsA, testCase.accountSetsB, matrix.OwnerIdentity{}, matrix.OwnerIdentity{})
+
+			assertIDsEqual(t, "OwnerAGhosts", comparison.OwnerAGhosts, testCase.expectedGhostsA)
+			assertIDsEqual(t, "OwnerBGhosts", comparison.OwnerBGhosts, testCase.expectedGhostsB)
+			bucketRecords, err := comparison.Bucket(matrix.OwnerSlotA, matrix.BucketGhosts)
+			if err != nil {
+				t.Fatalf("ghost bucket: %v", err)
+			}
+			assertIDsEqual(t, "ghosts bucket", bucketRecords, testCase.expectedGhostsA)
+		})
+	}
+}
+
 func assertIDsEqual(t *testing.T, label string, records []matrix.AccountRecord, expectedIDs []string) {
 	t.Helper()
 	if len(records) != len(expectedIDs) {
@@ -0,0 +1,197 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	teamMemberFallbackFormat = "member %d"
+	unknownTeamMemberFormat  = "%w: %q"
+)
+
+// ErrUnknownTeamMember indicates that a filter referenced an owner that is not part of the team.
+var ErrUnknownTeamMember = errors.New("unknown team member")
+
+// TeamArchive pairs an archive owner with the relationships loaded from their export.
+type TeamArchive struct {
+	Owner       OwnerIdentity
+	AccountSets AccountSets
+}
+
+// ConsensusFilter narrows and bounds a consensus ranking.
+type ConsensusFilter struct {
+	// MinFollowedBy keeps accounts followed by at least this many team members.
+	MinFollowedBy int `json:"minFollowedBy"`
+	// MinFollowsBack keeps accounts that follow at least this many team members.
+	MinFollowsBack int `json:"minFollowsBack"`
+	// ExcludeFollowedBy drops accounts already followed by the referenced member, given as an account ID, handle, or
+	// team member label.
+	ExcludeFollowedBy string `json:"excludeFollowedBy,omitempty"`
+	// ExcludeBlockedOrMuted drops accounts blocked or muted by any team member.
+	ExcludeBlockedOrMuted bool `json:"excludeBlockedOrMuted"`
+	// Limit caps the number of ranked entries; zero keeps every match.
+	Limit int `json:"limit"`
+}
+
+// ConsensusEntry reports how the team relates to a single account. Member lists hold team member labels in team
+// order.
+type ConsensusEntry struct {
+	Record      AccountRecord `json:"record"`
+	FollowedBy  []string      `json:"followedBy"`
+	FollowsBack []string      `json:"followsBack"`
+	BlockedBy   []string      `json:"blockedBy"`
+	MutedBy     []string      `json:"mutedBy"`
+}
+
+// ConsensusResult holds a ranked consensus list together with the team it was computed over.
+type ConsensusResult struct {
+	Members []string         `json:"members"`
+	Filter  ConsensusFilter  `json:"filter"`
+	Entries []ConsensusEntry `json:"entries"`
+	// Matches counts the entries that passed the filter before Limit was applied.
+	Matches int `json:"matches"`
+}
+
+// RankConsensus ranks the accounts known to a team by how many members follow them, then by how many members they
+// follow back, then by how few members block or mute them. Team members themselves are never ranked.
+func RankConsensus(archives []TeamArchive, filter ConsensusFilter) (ConsensusResult, error) {
+	result := ConsensusResult{Members: make([]string, 0, len(archives)), Filter: filter}
+	excludedMember := -1
+	memberAccountIDs := map[string]bool{}
+	for index, archive := range archives {
+		result.Members = append(result.Members, TeamMemberLabel(archive.Owner, index))
+		if accountID := strings.TrimSpace(archive.Owner.AccountID); accountID != "" {
+			memberAccountIDs[accountID] = true
+		}
+		if filter.ExcludeFollowedBy != "" && excludedMember < 0 && matchesTeamMember(archive.Owner, result.Members[index], filter.ExcludeFollowedBy) {
+			excludedMember = index
+		}
+	}
+	if filter.ExcludeFollowedBy != "" && excludedMember < 0 {
+		return ConsensusResult{}, fmt.Errorf(unknownTeamMemberFormat, ErrUnknownTeamMember, filter.ExcludeFollowedBy)
+	}
+
+	entries := map[string]*ConsensusEntry{}
+	entryFor := func(record AccountRecord) *ConsensusEntry {
+		entry, found := entries[record.AccountID]
+		if !found {
+			entry = &ConsensusEntry{Record: record}
+			entries[record.AccountID] = entry
+		} else if strings.TrimSpace(entry.Record.UserName) == "" && strings.TrimSpace(record.UserName) != "" {
+			entry.Record = record
+		}
+		return entry
+	}
+	for index, archive := range archives {
+		member := result.Members[index]
+		for _, accountID := range sortedRecordIDs(archive.AccountSets.Following) {
+			entry := entryFor(archive.AccountSets.Following[accountID])
+			entry.FollowedBy = append(entry.FollowedBy, member)
+		}
+		for _, accountID := range sortedRecordIDs(archive.AccountSets.Followers) {
+			entry := entryFor(archive.AccountSets.Followers[accountID])
+			entry.FollowsBack = append(entry.FollowsBack, member)
+		}
+	}
+	for index, archive := range archives {
+		member := result.Members[index]
+		for _, accountID := range mapKeys(archive.AccountSets.Blocked) {
+			if entry, found := entries[accountID]; found {
+				entry.BlockedBy = append(entry.BlockedBy, member)
+			}
+		}
+		for _, accountID := range mapKeys(archive.AccountSets.Muted) {
+			if entry, found := entries[accountID]; found {
+				entry.MutedBy = append(entry.MutedBy, member)
+			}
+		}
+	}
+
+	for accountID, entry := range entries {
+		if memberAccountIDs[accountID] {
+			continue
+		}
+		if len(entry.FollowedBy) < filter.MinFollowedBy || len(entry.FollowsBack) < filter.MinFollowsBack {
+			continue
+		}
+		if filter.ExcludeBlockedOrMuted && (len(entry.BlockedBy) > 0 || len(entry.MutedBy) > 0) {
+			continue
+		}
+		if excludedMember >= 0 {
+			if _, followed := archives[excludedMember].AccountSets.Following[accountID]; followed {
+				continue
+			}
+		}
+		result.Entries = append(result.Entries, *entry)
+	}
+	sort.Slice(result.Entries, func(firstIndex, secondIndex int) bool {
+		return compareConsensusEntries(result.Entries[firstIndex], result.Entries[secondIndex]) < 0
+	})
+	result.Matches = len(result.Entries)
+	if filter.Limit > 0 && len(result.Entries) > filter.Limit {
+		result.Entries = result.Entries[:filter.Limit]
+	}
+	return result, nil
+}
+
+// ResolveExcludedMember rewrites the member referenced by ExcludeFollowedBy into their account ID when the reference
+// is a handle no team member carries, such as a member's new handle after a rename. The handle is looked up through
+// resolver like any other account reference; the filter is returned unchanged when the lookup fails.
+func ResolveExcludedMember(ctx context.Context, resolver AccountIDResolver, archives []TeamArchive, filter ConsensusFilter) ConsensusFilter {
+	reference := strings.TrimSpace(filter.ExcludeFollowedBy)
+	if reference == "" || resolver == nil {
+		return filter
+	}
+	for index, archive := range archives {
+		if matchesTeamMember(archive.Owner, TeamMemberLabel(archive.Owner, index), reference) {
+			return filter
+		}
+	}
+	if accountIDs, _ := ResolveAccountReferences(ctx, resolver, []string{reference}); len(accountIDs) == 1 {
+		filter.ExcludeFollowedBy = accountIDs[0]
+	}
+	return filter
+}
+
+// TeamMemberLabel returns the short label used for a team member in consensus results.
+func TeamMemberLabel(owner OwnerIdentity, index int) string {
+	if handle := strings.TrimSpace(owner.UserName); handle != "" {
+		return accountHandlePrefix + handle
+	}
+	if accountID := strings.TrimSpace(owner.AccountID); accountID != "" {
+		return accountID
+	}
+	return fmt.Sprintf(teamMemberFallbackFormat, index+1)
+}
+
+// matchesTeamMember reports whether the reference names the member by account ID, handle, or team member label.
+func matchesTeamMember(owner OwnerIdentity, label string, reference string) bool {
+	trimmed := strings.TrimSpace(reference)
+	if trimmed == "" {
+		return false
+	}
+	if trimmed == label || trimmed == strings.TrimSpace(owner.AccountID) {
+		return true
+	}
+	handle := strings.TrimPrefix(trimmed, accountHandlePrefix)
+	return handle != "" && strings.EqualFold(handle, strings.TrimSpace(owner.UserName))
+}
+
+func compareConsensusEntries(first ConsensusEntry, second ConsensusEntry) int {
+	if len(first.FollowedBy) != len(second.FollowedBy) {
+		return len(second.FollowedBy) - len(first.FollowedBy)
+	}
+	if len(first.FollowsBack) != len(second.FollowsBack) {
+		return len(second.FollowsBack) - len(first.FollowsBack)
+	}
+	firstRejections := len(first.BlockedBy) + len(first.MutedBy)
+	secondRejections := len(second.BlockedBy) + len(second.MutedBy)
+	if firstRejections != secondRejections {
+		return firstRejections - secondRejections
+	}
+	return compareAccountIDs(first.Record.AccountID, second.Record.AccountID)
+}
@@ -0,0 +1,135 @@
+package matrix_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+func TestRankConsensus(t *testing.T) {
+	popular := matrix.AccountRecord{AccountID: "100", UserName: "popular"}
+	niche := matrix.AccountRecord{AccountID: "200", UserName: "niche"}
+	blocked := matrix.AccountRecord{AccountID: "300", UserName: "blocked"}
+	archives := []matrix.TeamArchive{
+		{
+			Owner: matrix.OwnerIdentity{AccountID: "1", UserName: "me"},
+			AccountSets: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{"100": popular, "2": {AccountID: "2", UserName: "mate_one"}},
+				Followers: map[string]matrix.AccountRecord{"200": niche},
+			},
+		},
+		{
+			Owner: matrix.OwnerIdentity{AccountID: "2", UserName: "mate_one"},
+			AccountSets: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{"100": popular, "200": niche, "300": blocked},
+				Blocked:   map[string]bool{"300": true},
+			},
+		},
+		{
+			Owner: matrix.OwnerIdentity{AccountID: "3", UserName: "mate_two"},
+			AccountSets: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{"100": popular, "200": niche, "300": blocked},
+				Followers: map[string]matrix.AccountRecord{"100": popular},
+				Muted:     map[string]bool{"200": true},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		filter          matrix.ConsensusFilter
+		expectedIDs     []string
+		expectedMatches int
+		expectedError   error
+	}{
+		{
+			name:            "ranks by followers then follow backs then rejections and skips members",
+			filter:          matrix.ConsensusFilter{},
+			expectedIDs:     []string{"100", "200", "300"},
+			expectedMatches: 3,
+		},
+		{
+			name:            "followed by at least two teammates but not by me",
+			filter:          matrix.ConsensusFilter{MinFollowedBy: 2, ExcludeFollowedBy: "@ME"},
+			expectedIDs:     []string{"200", "300"},
+			expectedMatches: 2,
+		},
+		{
+			name:            "excludes blocked or muted accounts",
+			filter:          matrix.ConsensusFilter{ExcludeBlockedOrMuted: true},
+			expectedIDs:     []string{"100"},
+			expectedMatches: 1,
+		},
+		{
+			name:            "limit keeps the match count",
+			filter:          matrix.ConsensusFilter{MinFollowsBack: 1, Limit: 1},
+			expectedIDs:     []string{"100"},
+			expectedMatches: 2,
+		},
+		{
+			name:          "unknown member",
+			filter:        matrix.ConsensusFilter{ExcludeFollowedBy: "stranger"},
+			expectedError: matrix.ErrUnknownTeamMember,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := matrix.RankConsensus(archives, testCase.filter)
+			if testCase.expectedError != nil {
+				if !errors.Is(err, testCase.expectedError) {
+					t.Fatalf("expected error %v, got %v", testCase.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RankConsensus returned error: %v", err)
+			}
+			if result.Matches != testCase.expectedMatches {
+				t.Fatalf("expected %d matches, got %d", testCase.expectedMatches, result.Matches)
+			}
+			records := make([]matrix.AccountRecord, 0, len(result.Entries))
+			for _, entry := range result.Entries {
+				records = append(records, entry.Record)
+			}
+			assertIDsEqual(t, testCase.name, records, testCase.expectedIDs)
+		})
+	}
+}
+
+func TestResolveExcludedMember(t *testing.T) {
+	archives := []matrix.TeamArchive{
+		{Owner: matrix.OwnerIdentity{AccountID: "1", UserName: "old_name"}},
+		{Owner: matrix.OwnerIdentity{AccountID: "2", UserName: "mate"}},
+	}
+	resolver := stubAccountIDResolver{accountIDsByHandle: map[string]string{"new_name": "1", "mate": "20"}}
+
+	testCases := []struct {
+		name              string
+		reference         string
+		resolver          matrix.AccountIDResolver
+		expectedReference string
+		expectUnknown     bool
+	}{
+		{name: "renamed member resolves to their account id", reference: "@new_name", resolver: resolver, expectedReference: "1"},
+		{name: "member handle from the archive is kept", reference: "@mate", resolver: resolver, expectedReference: "@mate"},
+		{name: "unresolvable handle is kept", reference: "stranger", resolver: resolver, expectedReference: "stranger", expectUnknown: true},
+		{name: "no resolver keeps the reference", reference: "@new_name", expectedReference: "@new_name", expectUnknown: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := matrix.ResolveExcludedMember(context.Background(), testCase.resolver, archives, matrix.ConsensusFilter{ExcludeFollowedBy: testCase.reference})
+			if filter.ExcludeFollowedBy != testCase.expectedReference {
+				t.Fatalf("expected %q, got %q", testCase.expectedReference, filter.ExcludeFollowedBy)
+			}
+			if _, err := matrix.RankConsensus(archives, filter); errors.Is(err, matrix.ErrUnknownTeamMember) != testCase.expectUnknown {
+				t.Fatalf("unexpected ranking error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package matrix
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccountFilter restricts comparison buckets to accounts with particular profile flags.
+type AccountFilter string
+
+const (
+	// AccountFilterAll keeps every account.
+	AccountFilterAll AccountFilter = "all"
+	// AccountFilterProtected keeps accounts whose posts are protected.
+	AccountFilterProtected AccountFilter = "protected"
+	// AccountFilterPublic keeps accounts whose posts are not known to be protected.
+	AccountFilterPublic AccountFilter = "public"
+	// AccountFilterVerified keeps accounts whose profile shows a verification badge.
+	AccountFilterVerified AccountFilter = "verified"
+
+	// DefaultAccountFilter is applied when no filter is configured.
+	DefaultAccountFilter = AccountFilterAll
+
+	errMessageUnknownAccountFilter = "unknown account filter"
+)
+
+var supportedAccountFilters = []AccountFilter{AccountFilterAll, AccountFilterProtected, AccountFilterPublic, AccountFilterVerified}
+
+// AccountFilters lists the supported account filters in presentation order.
+func AccountFilters() []AccountFilter {
+	return append([]AccountFilter(nil), supportedAccountFilters...)
+}
+
+// ParseAccountFilter converts user input into an AccountFilter, defaulting to DefaultAccountFilter when empty.
+func ParseAccountFilter(value string) (AccountFilter, error) {
+	normalized := AccountFilter(strings.ToLower(strings.TrimSpace(value)))
+	if normalized == "" {
+		return DefaultAccountFilter, nil
+	}
+	for _, filter := range supportedAccountFilters {
+		if filter == normalized {
+			return filter, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %q", errMessageUnknownAccountFilter, value)
+}
+
+// Matches reports whether the record passes the filter; unknown filters keep every record.
+func (filter AccountFilter) Matches(record AccountRecord) bool {
+	switch filter {
+	case AccountFilterProtected:
+		return record.IsProtected()
+	case AccountFilterPublic:
+		return !record.IsProtected()
+	case AccountFilterVerified:
+		return record.IsVerified()
+	default:
+		return true
+	}
+}
+
+// FilterAccountRecords returns the records that pass the filter, preserving their order.
+func FilterAccountRecords(records []AccountRecord, filter AccountFilter) []AccountRecord {
+	if filter == "" || filter == AccountFilterAll {
+		return records
+	}
+	filtered := make([]AccountRecord, 0, len(records))
+	for _, record := range records {
+		if filter.Matches(record) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// applyFilter narrows every bucket of the comparison to the records that pass the filter.
+func (comparisonResult *ComparisonResult) applyFilter(filter AccountFilter) {
+	if filter == "" || filter == AccountFilterAll {
+		return
+	}
+	buckets := []*[]AccountRecord{
+		&comparisonResult.OwnerAFriends, &comparisonResult.OwnerALeaders, &comparisonResult.OwnerAGroupies,
+		&comparisonResult.OwnerBFriends, &comparisonResult.OwnerBLeaders, &comparisonResult.OwnerBGroupies,
+		&comparisonResult.OwnerAFollowersAll, &comparisonResult.OwnerAFollowingsAll,
+		&comparisonResult.OwnerBFollowersAll, &comparisonResult.OwnerBFollowingsAll,
+		&comparisonResult.OwnerABlockedAll, &comparisonResult.OwnerABlockedAndFollowing, &comparisonResult.OwnerABlockedAndFollowers,
+		&comparisonResult.OwnerBBlockedAll, &comparisonResult.OwnerBBlockedAndFollowing, &comparisonResult.OwnerBBlockedAndFollowers,
+		&comparisonResult.OwnerAGhosts, &comparisonResult.OwnerBGhosts,
+	}
+	for _, bucket := range buckets {
+		*bucket = FilterAccountRecords(*bucket, filter)
+	}
+}
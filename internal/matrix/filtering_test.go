@@ -0,0 +1,46 @@
+package matrix_test
+
+import (
+	"testing"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+func TestBuildComparisonFiltersBuckets(t *testing.T) {
+	publicRecord := matrix.AccountRecord{AccountID: "1", UserName: "public"}
+	lockedRecord := matrix.AccountRecord{AccountID: "2", UserName: "locked"}.WithProfile(handles.Profile{Protected: true})
+	statusLockedRecord := matrix.AccountRecord{AccountID: "3", UserName: "status_locked", Status: handles.AccountStatusProtected}
+	verifiedRecord := matrix.AccountRecord{AccountID: "4", UserName: "verified"}.WithProfile(handles.Profile{Verified: true})
+	records := map[string]matrix.AccountRecord{"1": publicRecord, "2": lockedRecord, "3": statusLockedRecord, "4": verifiedRecord}
+	accountSetsA := matrix.AccountSets{Followers: records, Following: records, Blocked: map[string]bool{"2": true}}
+	accountSetsB := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{}, Following: map[string]matrix.AccountRecord{}}
+
+	testCases := []struct {
+		name               string
+		filterValue        string
+		expectedFriendIDs  []string
+		expectedBlockedIDs []string
+	}{
+		{name: "empty filter keeps every account", filterValue: "", expectedFriendIDs: []string{"1", "2", "3", "4"}, expectedBlockedIDs: []string{"2"}},
+		{name: "protected reads profile and status", filterValue: "protected", expectedFriendIDs: []string{"2", "3"}, expectedBlockedIDs: []string{"2"}},
+		{name: "public excludes protected accounts", filterValue: "Public", expectedFriendIDs: []string{"1", "4"}, expectedBlockedIDs: []string{}},
+		{name: "verified reads the profile badge", filterValue: "verified", expectedFriendIDs: []string{"4"}, expectedBlockedIDs: []string{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter, err := matrix.ParseAccountFilter(testCase.filterValue)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			comparison := matrix.BuildComparisonWithOptions(accountSetsA, accountSetsB, matrix.OwnerIdentity{}, matrix.OwnerIdentity{}, matrix.ComparisonOptions{SortMode: matrix.SortModeAccountID, Filter: filter})
+			assertIDsEqual(t, "friends", comparison.OwnerAFriends, testCase.expectedFriendIDs)
+			assertIDsEqual(t, "blocked", comparison.OwnerABlockedAll, testCase.expectedBlockedIDs)
+		})
+	}
+
+	if _, err := matrix.ParseAccountFilter("famous"); err == nil {
+		t.Fatalf("expected an error for an unknown filter")
+	}
+}
@@ -3,27 +3,180 @@ package matrix
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/f-sync/fsync/internal/handles"
 )
 
-const errMessageMissingResolution = "handle resolution returned no result"
+const (
+	errMessageMissingResolution = "handle resolution returned no result"
+	errMessageNoIDResolver      = "handles cannot be looked up without a resolver"
 
-// ErrMissingHandleResolution indicates that no data was returned for a requested account.
-var ErrMissingHandleResolution = errors.New(errMessageMissingResolution)
+	resolutionPrioritySeparator = ","
+)
+
+var (
+	// ErrMissingHandleResolution indicates that no data was returned for a requested account.
+	ErrMissingHandleResolution = errors.New(errMessageMissingResolution)
+	// ErrNoAccountIDResolver indicates that a handle was given where no resolver can look up its identifier.
+	ErrNoAccountIDResolver = errors.New(errMessageNoIDResolver)
+)
+
+// defaultResolutionPriority resolves mutual follows first, then accounts followed without a follow back, then blocked
+// accounts, then followers that are not followed back.
+var defaultResolutionPriority = []BucketName{BucketFriends, BucketLeaders, BucketBlocked, BucketGroupies}
+
+// ResolutionPolicy orders and bounds handle resolution.
+type ResolutionPolicy struct {
+	// Priority lists the buckets whose accounts are resolved first, in order. Accounts are ranked by the earliest
+	// bucket they belong to for any owner; accounts in none of the listed buckets come last. Ghosts are only known
+	// after resolution and are ignored here.
+	Priority []BucketName
+	// Budget bounds the lookups of a resolution run; accounts it leaves out are reported with
+	// handles.ErrBudgetExhausted.
+	Budget handles.Budget
+	// SkipLabeled leaves accounts whose handle the archives already carry out of the run. By default they are looked
+	// up after every account without a handle, for their profile details and their suspended or deleted status.
+	SkipLabeled bool
+}
+
+// DefaultResolutionPriority returns the bucket order used when no priority is configured.
+func DefaultResolutionPriority() []BucketName {
+	return append([]BucketName(nil), defaultResolutionPriority...)
+}
+
+// DefaultResolutionPolicy returns the default priority without a budget.
+func DefaultResolutionPolicy() ResolutionPolicy {
+	return ResolutionPolicy{Priority: DefaultResolutionPriority()}
+}
+
+// ParseResolutionPriority converts a comma-separated list of bucket names into a resolution priority, defaulting to
+// DefaultResolutionPriority when empty.
+func ParseResolutionPriority(value string) ([]BucketName, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultResolutionPriority(), nil
+	}
+	var priority []BucketName
+	for _, part := range strings.Split(value, resolutionPrioritySeparator) {
+		bucket := BucketName(strings.ToLower(strings.TrimSpace(part)))
+		if !bucket.rankable() {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownBucket, part)
+		}
+		priority = append(priority, bucket)
+	}
+	return priority, nil
+}
+
+// rankable reports whether the bucket can be computed before resolution.
+func (bucket BucketName) rankable() bool {
+	switch bucket {
+	case BucketFriends, BucketLeaders, BucketGroupies, BucketFollowers, BucketFollowing, BucketBlocked,
+		BucketBlockedAndFollowing, BucketBlockedAndFollowers:
+		return true
+	default:
+		return false
+	}
+}
+
+// contains reports whether the account belongs to the bucket of the account set.
+func (bucket BucketName) contains(accountSet *AccountSets, accountID string) bool {
+	_, follows := accountSet.Following[accountID]
+	_, followedBy := accountSet.Followers[accountID]
+	blocked := accountSet.Blocked[accountID]
+	switch bucket {
+	case BucketFriends:
+		return follows && followedBy
+	case BucketLeaders:
+		return follows && !followedBy
+	case BucketGroupies:
+		return followedBy && !follows
+	case BucketFollowing:
+		return follows
+	case BucketFollowers:
+		return followedBy
+	case BucketBlocked:
+		return blocked
+	case BucketBlockedAndFollowing:
+		return blocked && follows
+	case BucketBlockedAndFollowers:
+		return blocked && followedBy
+	default:
+		return false
+	}
+}
 
 // AccountHandleResolver resolves Twitter handles for numeric identifiers.
 type AccountHandleResolver interface {
 	ResolveMany(ctx context.Context, accountIDs []string) map[string]handles.Result
 }
 
+// AccountIDResolver resolves numeric identifiers for Twitter handles.
+type AccountIDResolver interface {
+	ResolveHandle(ctx context.Context, userName string) (handles.AccountRecord, error)
+}
+
+// ResolveAccountReferences maps references holding numeric identifiers or handles, with or without the leading @, onto
+// account identifiers in input order without duplicates. Handles are looked up through resolver; references that
+// cannot be resolved are left out and their errors are returned keyed by reference.
+func ResolveAccountReferences(ctx context.Context, resolver AccountIDResolver, references []string) ([]string, map[string]error) {
+	accountIDs := make([]string, 0, len(references))
+	seen := make(map[string]struct{}, len(references))
+	var errorsByReference map[string]error
+	for _, reference := range references {
+		trimmed := strings.TrimSpace(reference)
+		if trimmed == "" {
+			continue
+		}
+		accountID, resolveErr := resolveAccountReference(ctx, resolver, trimmed)
+		if resolveErr != nil {
+			if errorsByReference == nil {
+				errorsByReference = make(map[string]error)
+			}
+			errorsByReference[trimmed] = resolveErr
+			continue
+		}
+		if _, duplicate := seen[accountID]; duplicate {
+			continue
+		}
+		seen[accountID] = struct{}{}
+		accountIDs = append(accountIDs, accountID)
+	}
+	return accountIDs, errorsByReference
+}
+
+func resolveAccountReference(ctx context.Context, resolver AccountIDResolver, reference string) (string, error) {
+	if isNumericAccountID(reference) {
+		return reference, nil
+	}
+	if resolver == nil {
+		return "", ErrNoAccountIDResolver
+	}
+	record, resolveErr := resolver.ResolveHandle(ctx, reference)
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return record.AccountID, nil
+}
+
 type accountResolutionTarget struct {
 	records map[string]AccountRecord
 }
 
-// MaybeResolveHandles enriches account sets with resolved handles when enabled.
+// MaybeResolveHandles enriches account sets with resolved handles, display names, profile details, and statuses when
+// enabled. Muted and blocked accounts missing from both relationship maps are resolved into the Labels map of their
+// set. Accounts are resolved in the order of DefaultResolutionPolicy.
 func MaybeResolveHandles(ctx context.Context, resolver AccountHandleResolver, shouldResolve bool, accountSets ...*AccountSets) map[string]error {
+	return MaybeResolveHandlesWithPolicy(ctx, resolver, shouldResolve, DefaultResolutionPolicy(), accountSets...)
+}
+
+// MaybeResolveHandlesWithPolicy behaves like MaybeResolveHandles but orders and bounds the lookups with policy. When
+// the budget runs out, the accounts that were resolved are applied and every account left out maps to
+// handles.ErrBudgetExhausted in the returned errors; an offline resolver reports uncached accounts with
+// handles.ErrNotCached. Accounts that already have a handle keep it; their failures are only reported when they show
+// the account suspended or deleted.
+func MaybeResolveHandlesWithPolicy(ctx context.Context, resolver AccountHandleResolver, shouldResolve bool, policy ResolutionPolicy, accountSets ...*AccountSets) map[string]error {
 	if !shouldResolve || resolver == nil {
 		return nil
 	}
@@ -33,39 +186,53 @@ func MaybeResolveHandles(ctx context.Context, resolver AccountHandleResolver, sh
 		if accountSet == nil {
 			continue
 		}
-		collectResolutionTargets(accountSet.Followers, accountIDTargets)
-		collectResolutionTargets(accountSet.Following, accountIDTargets)
+		collectResolutionTargets(accountSet.Followers, accountIDTargets, policy.SkipLabeled)
+		collectResolutionTargets(accountSet.Following, accountIDTargets, policy.SkipLabeled)
+		collectLabelTargets(accountSet, accountIDTargets, policy.SkipLabeled)
 	}
 	if len(accountIDTargets) == 0 {
 		return nil
 	}
 
-	accountIDs := make([]string, 0, len(accountIDTargets))
-	for accountID := range accountIDTargets {
-		accountIDs = append(accountIDs, accountID)
-	}
-
-	resolutionResults := resolver.ResolveMany(ctx, accountIDs)
+	accountIDs := prioritizedAccountIDs(accountIDTargets, policy.Priority, accountSets)
+	resolutionResults := resolver.ResolveMany(handles.WithBudget(ctx, policy.Budget), accountIDs)
 	errorsByAccountID := make(map[string]error)
 	for _, accountID := range accountIDs {
 		result, exists := resolutionResults[accountID]
+		labeled := hasKnownHandle(accountIDTargets[accountID], accountID)
 		if !exists {
-			errorsByAccountID[accountID] = ErrMissingHandleResolution
+			if !labeled {
+				errorsByAccountID[accountID] = ErrMissingHandleResolution
+			}
 			continue
 		}
 		if result.Err != nil {
-			errorsByAccountID[accountID] = result.Err
+			status := handles.StatusOf(result.Err)
+			if !labeled || status.IsGhost() {
+				errorsByAccountID[accountID] = result.Err
+			}
+			if status.IsGhost() {
+				for _, target := range accountIDTargets[accountID] {
+					record := target.records[accountID]
+					record.Status = status
+					target.records[accountID] = record.WithFormerUserNames(result.Record.FormerUserNames...)
+				}
+			}
 			continue
 		}
 		for _, target := range accountIDTargets[accountID] {
 			record := target.records[accountID]
+			record.Status = result.Record.Status
 			if record.UserName == "" {
-				record.UserName = result.Record.UserName
+				record = withResolvedUserName(record, result.Record)
 			}
 			if record.DisplayName == "" {
-				record.DisplayName = result.Record.DisplayName
+				record = withResolvedDisplayName(record, result.Record)
+			}
+			if result.Record.Profile != nil {
+				record = record.WithProfile(*result.Record.Profile)
 			}
-			target.records[accountID] = record
+			target.records[accountID] = record.WithFormerUserNames(result.Record.FormerUserNames...)
 		}
 	}
 	if len(errorsByAccountID) == 0 {
@@ -74,11 +241,120 @@ func MaybeResolveHandles(ctx context.Context, resolver AccountHandleResolver, sh
 	return errorsByAccountID
 }
 
-func collectResolutionTargets(source map[string]AccountRecord, targets map[string][]accountResolutionTarget) {
+// RecordArchiveHistory adds the handle and display name of every archive record to store, dated by the export of the
+// archive that supplied them, so renames between exports appear in the history of the account.
+func RecordArchiveHistory(store handles.HistoryStore, accountSets ...*AccountSets) {
+	if store == nil {
+		return
+	}
+	for _, accountSet := range accountSets {
+		if accountSet == nil {
+			continue
+		}
+		for _, records := range []map[string]AccountRecord{accountSet.Following, accountSet.Followers} {
+			for _, accountID := range sortedRecordIDs(records) {
+				observation, known := handles.ObservationOf(records[accountID])
+				if !known || observation.Source != handles.LabelSourceArchive || observation.ObservedAt.IsZero() {
+					continue
+				}
+				store.Observe(accountID, observation)
+			}
+		}
+	}
+}
+
+func withResolvedUserName(record AccountRecord, resolved AccountRecord) AccountRecord {
+	if provenance, known := resolved.UserNameProvenance(); known {
+		return record.WithUserName(resolved.UserName, provenance)
+	}
+	record.UserName = resolved.UserName
+	return record
+}
+
+func withResolvedDisplayName(record AccountRecord, resolved AccountRecord) AccountRecord {
+	if provenance, known := resolved.DisplayNameProvenance(); known && resolved.DisplayName != "" {
+		return record.WithDisplayName(resolved.DisplayName, provenance)
+	}
+	record.DisplayName = resolved.DisplayName
+	return record
+}
+
+// collectResolutionTargets registers the records of source as resolution targets, leaving out those with a handle
+// when skipLabeled is set.
+func collectResolutionTargets(source map[string]AccountRecord, targets map[string][]accountResolutionTarget, skipLabeled bool) {
 	for accountID, record := range source {
-		if strings.TrimSpace(record.UserName) != "" {
+		if skipLabeled && strings.TrimSpace(record.UserName) != "" {
 			continue
 		}
 		targets[accountID] = append(targets[accountID], accountResolutionTarget{records: source})
 	}
 }
+
+// hasKnownHandle reports whether every record targeted for the account already carries a handle.
+func hasKnownHandle(targets []accountResolutionTarget, accountID string) bool {
+	for _, target := range targets {
+		if strings.TrimSpace(target.records[accountID].UserName) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// collectLabelTargets seeds the Labels map with a bare record for every muted or blocked account outside both
+// relationship maps and registers the records of the map as resolution targets.
+func collectLabelTargets(accountSet *AccountSets, targets map[string][]accountResolutionTarget, skipLabeled bool) {
+	for _, accountIDs := range []map[string]bool{accountSet.Muted, accountSet.Blocked} {
+		for accountID := range accountIDs {
+			if _, following := accountSet.Following[accountID]; following {
+				continue
+			}
+			if _, follower := accountSet.Followers[accountID]; follower {
+				continue
+			}
+			if accountSet.Labels == nil {
+				accountSet.Labels = make(map[string]AccountRecord)
+			}
+			if _, labeled := accountSet.Labels[accountID]; !labeled {
+				accountSet.Labels[accountID] = AccountRecord{AccountID: accountID}
+			}
+		}
+	}
+	collectResolutionTargets(accountSet.Labels, targets, skipLabeled)
+}
+
+// prioritizedAccountIDs orders the targeted accounts without a handle before those with one, then by the earliest
+// priority bucket they belong to, breaking ties by account identifier.
+func prioritizedAccountIDs(targets map[string][]accountResolutionTarget, priority []BucketName, accountSets []*AccountSets) []string {
+	ranks := make(map[string]int, len(targets))
+	accountIDs := make([]string, 0, len(targets))
+	for accountID := range targets {
+		accountIDs = append(accountIDs, accountID)
+		ranks[accountID] = len(priority)
+		for rank, bucket := range priority {
+			if rank >= ranks[accountID] {
+				break
+			}
+			for _, accountSet := range accountSets {
+				if accountSet != nil && bucket.contains(accountSet, accountID) {
+					ranks[accountID] = rank
+					break
+				}
+			}
+		}
+	}
+	labeled := make(map[string]bool, len(targets))
+	for accountID, accountTargets := range targets {
+		labeled[accountID] = hasKnownHandle(accountTargets, accountID)
+	}
+	sort.Slice(accountIDs, func(firstIndex, secondIndex int) bool {
+		first, second := accountIDs[firstIndex], accountIDs[secondIndex]
+		if labeled[first] != labeled[second] {
+			return !labeled[first]
+		}
+		if ranks[first] != ranks[second] {
+			return ranks[first] < ranks[second]
+		}
+		return compareAccountIDs(first, second) < 0
+	})
+	return accountIDs
+}
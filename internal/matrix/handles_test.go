@@ -14,12 +14,23 @@ import (
 const (
 	stubIntentHTMLSuccess             = "<html><head><title>Resolved Name (@resolved) / X</title></head><body><a href=\"https://x.com/resolved\">profile</a></body></html>"
 	stubIntentHTMLMissingHandle       = "<html><head><title>Resolved Name (@resolved) / X</title></head><body>No links</body></html>"
+	stubIntentHTMLSuspended           = "<html><head><title>X</title></head><body><span>Account suspended</span><a href=\"https://x.com/tos\">Terms</a></body></html>"
+	stubIntentHTMLProfile             = "<html><head><title>Profile Name (@profiled) / X</title><meta property=\"og:title\" content=\"Profile Name (@profiled) on X\"><meta property=\"og:image\" content=\"" + stubIntentAvatarURL + "\"></head><body><div data-testid=\"UserName\"><span>Profile Name</span><span>@profiled</span><svg data-testid=\"icon-lock\"></svg></div></body></html>"
+	stubIntentAvatarURL               = "https://pbs.twimg.com/profile_images/31006/profiled_normal.jpg"
 	stubIntentSourceURLPrefix         = "https://x.com/intent/user?user_id="
 	stubIntentErrorMessageMissing     = "no stub intent page for account"
 	matrixTestAccountIDDisabled       = "31001"
 	matrixTestAccountIDSuccess        = "31002"
 	matrixTestAccountIDMissingHandle  = "31003"
 	matrixTestAccountIDFetcherFailure = "31004"
+	matrixTestAccountIDSuspended      = "31005"
+	matrixTestAccountIDProfile        = "31006"
+	matrixTestAccountIDBlockedOnly    = "31007"
+	matrixTestAccountIDMutedOnly      = "31008"
+	matrixTestAccountIDBlockedFriend  = "31009"
+	matrixTestAccountIDLabeled        = "31010"
+	matrixTestAccountIDUnlabeled      = "31011"
+	matrixTestArchiveUserName         = "archive_handle"
 )
 
 type stubIntentFetcher struct {
@@ -49,6 +60,104 @@ func (resolver *stubResolver) ResolveMany(_ context.Context, _ []string) map[str
 	return nil
 }
 
+// orderRecordingResolver records the order of the requested accounts, resolves the first one, and reports the rest
+// as left out by the budget.
+type orderRecordingResolver struct {
+	accountIDs []string
+}
+
+func (resolver *orderRecordingResolver) ResolveMany(_ context.Context, accountIDs []string) map[string]handles.Result {
+	resolver.accountIDs = append([]string(nil), accountIDs...)
+	results := make(map[string]handles.Result, len(accountIDs))
+	for index, accountID := range accountIDs {
+		if index == 0 {
+			results[accountID] = handles.Result{Record: handles.AccountRecord{AccountID: accountID, UserName: "first"}}
+			continue
+		}
+		results[accountID] = handles.Result{Err: handles.ErrBudgetExhausted}
+	}
+	return results
+}
+
+func TestMaybeResolveHandlesWithPolicyOrdersByBucket(t *testing.T) {
+	unnamed := func(accountID string) matrix.AccountRecord { return matrix.AccountRecord{AccountID: accountID} }
+	accountSetsA := matrix.AccountSets{
+		Following: map[string]matrix.AccountRecord{"1": unnamed("1"), "2": unnamed("2"), "30": unnamed("30")},
+		Followers: map[string]matrix.AccountRecord{"2": unnamed("2"), "4": unnamed("4")},
+		Blocked:   map[string]bool{"5": true},
+		Muted:     map[string]bool{"6": true},
+	}
+	accountSetsB := matrix.AccountSets{
+		Following: map[string]matrix.AccountRecord{"4": unnamed("4")},
+		Followers: map[string]matrix.AccountRecord{"4": unnamed("4")},
+	}
+
+	testCases := []struct {
+		name          string
+		priority      []matrix.BucketName
+		expectedOrder []string
+	}{
+		{
+			name:          "default priority",
+			priority:      matrix.DefaultResolutionPriority(),
+			expectedOrder: []string{"2", "4", "1", "30", "5", "6"},
+		},
+		{
+			name:          "blocked first",
+			priority:      []matrix.BucketName{matrix.BucketBlocked, matrix.BucketGroupies},
+			expectedOrder: []string{"5", "4", "1", "2", "6", "30"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			setA, setB := copyAccountSets(accountSetsA), copyAccountSets(accountSetsB)
+			resolver := &orderRecordingResolver{}
+			policy := matrix.ResolutionPolicy{Priority: testCase.priority, Budget: handles.Budget{MaxLookups: 1}}
+
+			resolutionErrors := matrix.MaybeResolveHandlesWithPolicy(context.Background(), resolver, true, policy, &setA, &setB)
+
+			if fmt.Sprint(resolver.accountIDs) != fmt.Sprint(testCase.expectedOrder) {
+				t.Fatalf("expected order %v, got %v", testCase.expectedOrder, resolver.accountIDs)
+			}
+			if len(resolutionErrors) != len(testCase.expectedOrder)-1 {
+				t.Fatalf("expected every account but the first to be reported unresolved, got %v", resolutionErrors)
+			}
+			for _, accountID := range testCase.expectedOrder[1:] {
+				if !errors.Is(resolutionErrors[accountID], handles.ErrBudgetExhausted) {
+					t.Fatalf("expected %s to be left out by the budget, got %v", accountID, resolutionErrors[accountID])
+				}
+			}
+		})
+	}
+}
+
+func TestParseResolutionPriority(t *testing.T) {
+	testCases := []struct {
+		name             string
+		value            string
+		expectedPriority []matrix.BucketName
+		expectedErr      error
+	}{
+		{name: "empty uses the default", value: "", expectedPriority: matrix.DefaultResolutionPriority()},
+		{name: "trims and lowercases", value: " Blocked , friends", expectedPriority: []matrix.BucketName{matrix.BucketBlocked, matrix.BucketFriends}},
+		{name: "ghosts are only known after resolution", value: "friends,ghosts", expectedErr: matrix.ErrUnknownBucket},
+		{name: "unknown bucket", value: "strangers", expectedErr: matrix.ErrUnknownBucket},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			priority, err := matrix.ParseResolutionPriority(testCase.value)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("expected error %v, got %v", testCase.expectedErr, err)
+			}
+			if fmt.Sprint(priority) != fmt.Sprint(testCase.expectedPriority) {
+				t.Fatalf("expected priority %v, got %v", testCase.expectedPriority, priority)
+			}
+		})
+	}
+}
+
 func TestMaybeResolveHandlesDisabled(t *testing.T) {
 	decoratedRecord := matrix.AccountRecord{AccountID: matrixTestAccountIDDisabled}
 	baseAccountSets := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{matrixTestAccountIDDisabled: decoratedRecord}, Following: map[string]matrix.AccountRecord{matrixTestAccountIDDisabled: decoratedRecord}}
@@ -106,6 +215,9 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 		expectedUserName    string
 		expectedDisplayName string
 		expectedCalls       int32
+		expectedStatus      handles.AccountStatus
+		expectedAvatarURL   string
+		expectedProtected   bool
 	}{
 		{
 			name:                "successful resolution",
@@ -115,6 +227,16 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 			expectedDisplayName: "Resolved Name",
 			expectedCalls:       1,
 		},
+		{
+			name:                "profile details reach the account sets",
+			accountID:           matrixTestAccountIDProfile,
+			htmlContent:         stubIntentHTMLProfile,
+			expectedUserName:    "profiled",
+			expectedDisplayName: "Profile Name",
+			expectedCalls:       1,
+			expectedAvatarURL:   stubIntentAvatarURL,
+			expectedProtected:   true,
+		},
 		{
 			name:          "missing handle in html",
 			accountID:     matrixTestAccountIDMissingHandle,
@@ -129,6 +251,14 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 			expectError:   true,
 			expectedCalls: 1,
 		},
+		{
+			name:           "suspended account is marked",
+			accountID:      matrixTestAccountIDSuspended,
+			htmlContent:    stubIntentHTMLSuspended,
+			expectError:    true,
+			expectedCalls:  1,
+			expectedStatus: handles.AccountStatusSuspended,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -147,6 +277,7 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 			resolver, err := handles.NewResolver(handles.Config{
 				IntentFetcher: fetcher,
 				MaxConcurrent: 2,
+				MaxAttempts:   1,
 			})
 			if err != nil {
 				t.Fatalf("create resolver: %v", err)
@@ -181,8 +312,18 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 				if followerSet.Following[testCase.accountID].UserName != testCase.expectedUserName {
 					t.Fatalf("expected following record to be enriched")
 				}
+				profile := followerSet.Followers[testCase.accountID].Profile
+				if profile == nil || profile.FetchedAt.IsZero() {
+					t.Fatalf("expected a fetched profile, got %+v", profile)
+				}
+				if profile.AvatarURL != testCase.expectedAvatarURL || profile.Protected != testCase.expectedProtected {
+					t.Fatalf("unexpected profile details: %+v", profile)
+				}
 			}
 
+			if followerSet.Followers[testCase.accountID].Status != testCase.expectedStatus {
+				t.Fatalf("unexpected account status: %q", followerSet.Followers[testCase.accountID].Status)
+			}
 			if fetcher.callCount.Load() != testCase.expectedCalls {
 				t.Fatalf("unexpected fetcher call count: %d", fetcher.callCount.Load())
 			}
@@ -190,6 +331,72 @@ func TestMaybeResolveHandlesResolution(t *testing.T) {
 	}
 }
 
+func TestMaybeResolveHandlesLabelsMutedAndBlockedAccounts(t *testing.T) {
+	blockedFriend := matrix.AccountRecord{AccountID: matrixTestAccountIDBlockedFriend, UserName: "friend"}
+
+	testCases := []struct {
+		name             string
+		accountID        string
+		expectedLabeled  bool
+		expectedUserName string
+	}{
+		{name: "blocked account outside the relationships", accountID: matrixTestAccountIDBlockedOnly, expectedLabeled: true, expectedUserName: "resolved"},
+		{name: "muted account outside the relationships", accountID: matrixTestAccountIDMutedOnly, expectedLabeled: true, expectedUserName: "resolved"},
+		{name: "blocked follower keeps its relationship record", accountID: matrixTestAccountIDBlockedFriend, expectedLabeled: false, expectedUserName: "friend"},
+	}
+
+	fetcher := &stubIntentFetcher{htmlByAccountID: map[string]string{
+		matrixTestAccountIDBlockedOnly: stubIntentHTMLSuccess,
+		matrixTestAccountIDMutedOnly:   stubIntentHTMLSuccess,
+	}}
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, MaxConcurrent: 2, MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	accountSets := matrix.AccountSets{
+		Followers: map[string]matrix.AccountRecord{blockedFriend.AccountID: blockedFriend},
+		Following: map[string]matrix.AccountRecord{},
+		Muted:     map[string]bool{matrixTestAccountIDMutedOnly: true},
+		Blocked:   map[string]bool{matrixTestAccountIDBlockedOnly: true, blockedFriend.AccountID: true},
+	}
+
+	if resolutionErrors := matrix.MaybeResolveHandles(context.Background(), resolver, true, &accountSets); len(resolutionErrors) != 0 {
+		t.Fatalf("expected no errors, received %v", resolutionErrors)
+	}
+	// The blocked follower already has a handle; it is looked up for its status, and its failure is not reported.
+	if fetcher.callCount.Load() != 3 {
+		t.Fatalf("unexpected fetcher call count: %d", fetcher.callCount.Load())
+	}
+	if len(accountSets.Followers) != 1 || len(accountSets.Following) != 0 {
+		t.Fatalf("expected relationship maps to stay untouched, got followers %v and following %v", accountSets.Followers, accountSets.Following)
+	}
+	comparison := matrix.BuildComparison(accountSets, matrix.AccountSets{}, matrix.OwnerIdentity{}, matrix.OwnerIdentity{})
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			label, labeled := accountSets.Labels[testCase.accountID]
+			if labeled != testCase.expectedLabeled {
+				t.Fatalf("expected labeled %t, got %t", testCase.expectedLabeled, labeled)
+			}
+			if labeled && (label.AccountID != testCase.accountID || label.UserName != testCase.expectedUserName) {
+				t.Fatalf("unexpected label: %+v", label)
+			}
+			if !accountSets.Blocked[testCase.accountID] {
+				return
+			}
+			for _, record := range comparison.OwnerABlockedAll {
+				if record.AccountID == testCase.accountID {
+					if record.UserName != testCase.expectedUserName {
+						t.Fatalf("expected blocked bucket to show @%s, got %+v", testCase.expectedUserName, record)
+					}
+					return
+				}
+			}
+			t.Fatalf("account %s missing from the blocked bucket", testCase.accountID)
+		})
+	}
+}
+
 func copyAccountSets(original matrix.AccountSets) matrix.AccountSets {
 	copyFollowers := make(map[string]matrix.AccountRecord, len(original.Followers))
 	for accountID, record := range original.Followers {
@@ -207,5 +414,110 @@ func copyAccountSets(original matrix.AccountSets) matrix.AccountSets {
 	for accountID, blocked := range original.Blocked {
 		copyBlocked[accountID] = blocked
 	}
-	return matrix.AccountSets{Followers: copyFollowers, Following: copyFollowing, Muted: copyMuted, Blocked: copyBlocked}
+	copyLabels := make(map[string]matrix.AccountRecord, len(original.Labels))
+	for accountID, record := range original.Labels {
+		copyLabels[accountID] = record
+	}
+	return matrix.AccountSets{Followers: copyFollowers, Following: copyFollowing, Muted: copyMuted, Blocked: copyBlocked, Labels: copyLabels}
+}
+
+func TestMaybeResolveHandlesChecksLabeledAccounts(t *testing.T) {
+	testCases := []struct {
+		name              string
+		htmlContent       string
+		fetchError        error
+		skipLabeled       bool
+		expectedCalls     int32
+		expectError       bool
+		expectedStatus    handles.AccountStatus
+		expectedAvatarURL string
+		expectGhost       bool
+	}{
+		{
+			name:           "suspended account with an archive handle becomes a ghost",
+			htmlContent:    stubIntentHTMLSuspended,
+			expectedCalls:  1,
+			expectError:    true,
+			expectedStatus: handles.AccountStatusSuspended,
+			expectGhost:    true,
+		},
+		{
+			name:              "profile details reach accounts with an archive handle",
+			htmlContent:       stubIntentHTMLProfile,
+			expectedCalls:     1,
+			expectedAvatarURL: stubIntentAvatarURL,
+		},
+		{
+			name:          "failed lookups of accounts with a handle are not reported",
+			fetchError:    errors.New("fetch failed"),
+			expectedCalls: 1,
+		},
+		{
+			name:        "skipped when the policy asks",
+			htmlContent: stubIntentHTMLSuspended,
+			skipLabeled: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fetcher := &stubIntentFetcher{htmlByAccountID: map[string]string{}, errorByAccountID: map[string]error{}}
+			if testCase.htmlContent != "" {
+				fetcher.htmlByAccountID[matrixTestAccountIDLabeled] = testCase.htmlContent
+			}
+			if testCase.fetchError != nil {
+				fetcher.errorByAccountID[matrixTestAccountIDLabeled] = testCase.fetchError
+			}
+			resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+			labeled := matrix.AccountRecord{AccountID: matrixTestAccountIDLabeled, UserName: matrixTestArchiveUserName}
+			accountSets := matrix.AccountSets{Following: map[string]matrix.AccountRecord{labeled.AccountID: labeled}}
+			policy := matrix.ResolutionPolicy{Priority: matrix.DefaultResolutionPriority(), SkipLabeled: testCase.skipLabeled}
+
+			resolutionErrors := matrix.MaybeResolveHandlesWithPolicy(context.Background(), resolver, true, policy, &accountSets)
+
+			if fetcher.callCount.Load() != testCase.expectedCalls {
+				t.Fatalf("unexpected fetcher call count: %d", fetcher.callCount.Load())
+			}
+			if (resolutionErrors[labeled.AccountID] != nil) != testCase.expectError {
+				t.Fatalf("unexpected resolution errors: %v", resolutionErrors)
+			}
+			record := accountSets.Following[labeled.AccountID]
+			if record.UserName != matrixTestArchiveUserName || record.Status != testCase.expectedStatus {
+				t.Fatalf("unexpected record: %+v", record)
+			}
+			if testCase.expectedAvatarURL != "" && (record.Profile == nil || record.Profile.AvatarURL != testCase.expectedAvatarURL) {
+				t.Fatalf("expected the resolved profile, got %+v", record.Profile)
+			}
+			comparison := matrix.BuildComparison(accountSets, matrix.AccountSets{}, matrix.OwnerIdentity{}, matrix.OwnerIdentity{})
+			if isGhost := len(comparison.OwnerAGhosts) == 1 && comparison.OwnerAGhosts[0].AccountID == labeled.AccountID; isGhost != testCase.expectGhost {
+				t.Fatalf("expected ghost %t, got ghosts %+v", testCase.expectGhost, comparison.OwnerAGhosts)
+			}
+		})
+	}
+}
+
+func TestMaybeResolveHandlesLooksUpAccountsWithoutHandlesFirst(t *testing.T) {
+	accountSets := matrix.AccountSets{
+		Following: map[string]matrix.AccountRecord{
+			matrixTestAccountIDLabeled: {AccountID: matrixTestAccountIDLabeled, UserName: matrixTestArchiveUserName},
+		},
+		Followers: map[string]matrix.AccountRecord{
+			matrixTestAccountIDLabeled:   {AccountID: matrixTestAccountIDLabeled, UserName: matrixTestArchiveUserName},
+			matrixTestAccountIDUnlabeled: {AccountID: matrixTestAccountIDUnlabeled},
+		},
+	}
+	resolver := &orderRecordingResolver{}
+	policy := matrix.ResolutionPolicy{Priority: matrix.DefaultResolutionPriority(), Budget: handles.Budget{MaxLookups: 1}}
+
+	resolutionErrors := matrix.MaybeResolveHandlesWithPolicy(context.Background(), resolver, true, policy, &accountSets)
+
+	if expected := []string{matrixTestAccountIDUnlabeled, matrixTestAccountIDLabeled}; fmt.Sprint(resolver.accountIDs) != fmt.Sprint(expected) {
+		t.Fatalf("expected order %v, got %v", expected, resolver.accountIDs)
+	}
+	if len(resolutionErrors) != 0 {
+		t.Fatalf("expected the budget to leave out only the account with a handle, unreported, got %v", resolutionErrors)
+	}
 }
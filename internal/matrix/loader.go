@@ -8,6 +8,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
 )
 
 const (
@@ -18,13 +21,17 @@ const (
 	followerFileName      = "follower.js"
 	muteFileName          = "mute.js"
 	blockFileName         = "block.js"
+	listsMemberFileName   = "lists-member.js"
 	dataTypeFollowing     = "following"
 	dataTypeFollower      = "follower"
 	dataTypeMute          = "mute"
 	dataTypeBlock         = "block"
+	dataTypeListsMember   = "listsMember"
 	jsonArrayPattern      = `(?s)\[.*\]`
 	jsonObjectPattern     = `(?s)\{.*\}`
 	userIDPattern         = `(?:user_id=|/i/user/)(\d+)`
+	listCreatorPattern    = `^https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/([A-Za-z0-9_]+)/lists/`
+	listIDPathSegment     = "i"
 	ownerMissingDataError = "no follower.js or following.js found in zip"
 	jsonArrayMissingError = "no JSON array found"
 )
@@ -33,6 +40,7 @@ var (
 	reFirstArray  = regexp.MustCompile(jsonArrayPattern)
 	reFirstObject = regexp.MustCompile(jsonObjectPattern)
 	reUserID      = regexp.MustCompile(userIDPattern)
+	reListCreator = regexp.MustCompile(listCreatorPattern)
 )
 
 type manifest struct {
@@ -41,6 +49,9 @@ type manifest struct {
 		UserName    string `json:"userName"`
 		DisplayName string `json:"displayName"`
 	} `json:"userInfo"`
+	ArchiveInfo struct {
+		GenerationDate string `json:"generationDate"`
+	} `json:"archiveInfo"`
 	DataTypes map[string]struct {
 		Files []struct {
 			FileName string `json:"fileName"`
@@ -57,12 +68,13 @@ func ReadTwitterZip(zipPath string) (AccountSets, OwnerIdentity, error) {
 	defer zipReader.Close()
 
 	var archiveManifest manifest
+	var manifestModified time.Time
 	blobs := map[string][]byte{}
 
 	for _, file := range zipReader.File {
 		lowerBase := strings.ToLower(filepath.Base(file.Name))
 		switch lowerBase {
-		case manifestFileName, accountFileName, profileFileName, followingFileName, followerFileName, muteFileName, blockFileName:
+		case manifestFileName, accountFileName, profileFileName, followingFileName, followerFileName, muteFileName, blockFileName, listsMemberFileName:
 			reader, openErr := file.Open()
 			if openErr != nil {
 				return AccountSets{}, OwnerIdentity{}, openErr
@@ -74,6 +86,7 @@ func ReadTwitterZip(zipPath string) (AccountSets, OwnerIdentity, error) {
 			}
 			blobs[lowerBase] = data
 			if lowerBase == manifestFileName {
+				manifestModified = file.Modified
 				if object := reFirstObject.Find(data); len(object) > 0 {
 					_ = json.Unmarshal(object, &archiveManifest)
 				}
@@ -87,6 +100,8 @@ func ReadTwitterZip(zipPath string) (AccountSets, OwnerIdentity, error) {
 		owner.UserName = archiveManifest.UserInfo.UserName
 		owner.DisplayName = archiveManifest.UserInfo.DisplayName
 	}
+	owner.ExportedAt = archiveExportTime(archiveManifest.ArchiveInfo.GenerationDate, manifestModified)
+	archiveProvenance := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: owner.ProvenanceOrigin(), ObservedAt: owner.ExportedAt}
 
 	loadIfNeeded := func(kind string) {
 		dataType, exists := archiveManifest.DataTypes[kind]
@@ -119,27 +134,32 @@ func ReadTwitterZip(zipPath string) (AccountSets, OwnerIdentity, error) {
 	loadIfNeeded(dataTypeFollower)
 	loadIfNeeded(dataTypeMute)
 	loadIfNeeded(dataTypeBlock)
+	loadIfNeeded(dataTypeListsMember)
 
 	accountSets := AccountSets{
-		Followers: map[string]AccountRecord{},
-		Following: map[string]AccountRecord{},
-		Muted:     map[string]bool{},
-		Blocked:   map[string]bool{},
+		Followers:   map[string]AccountRecord{},
+		Following:   map[string]AccountRecord{},
+		Muted:       map[string]bool{},
+		Blocked:     map[string]bool{},
+		Labels:      map[string]AccountRecord{},
+		ExportOrder: map[string]int{},
 	}
 
 	if data := blobs[followingFileName]; len(data) > 0 {
 		records, _ := parseArrayOfUsers(data, "following")
-		for _, record := range records {
+		for position, record := range records {
 			if record.AccountID != "" {
-				accountSets.Following[record.AccountID] = record
+				accountSets.Following[record.AccountID] = withArchiveProvenance(record, archiveProvenance)
+				recordExportOrder(accountSets.ExportOrder, record.AccountID, position)
 			}
 		}
 	}
 	if data := blobs[followerFileName]; len(data) > 0 {
 		records, _ := parseArrayOfUsers(data, "follower")
-		for _, record := range records {
+		for position, record := range records {
 			if record.AccountID != "" {
-				accountSets.Followers[record.AccountID] = record
+				accountSets.Followers[record.AccountID] = withArchiveProvenance(record, archiveProvenance)
+				recordExportOrder(accountSets.ExportOrder, record.AccountID, position)
 			}
 		}
 	}
@@ -154,12 +174,46 @@ func ReadTwitterZip(zipPath string) (AccountSets, OwnerIdentity, error) {
 		}
 	}
 
+	if data := blobs[listsMemberFileName]; len(data) > 0 {
+		accountSets.ListMemberships = parseListMemberships(data)
+	}
+
 	if len(accountSets.Followers) == 0 && len(accountSets.Following) == 0 {
 		return AccountSets{}, OwnerIdentity{}, errors.New(ownerMissingDataError)
 	}
 	return accountSets, owner, nil
 }
 
+// archiveExportTime reports when an archive was generated, preferring the manifest generation date and falling
+// back to the manifest entry modification time.
+func archiveExportTime(generationDate string, manifestModified time.Time) time.Time {
+	if parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(generationDate)); err == nil {
+		return parsed.UTC()
+	}
+	if manifestModified.IsZero() {
+		return time.Time{}
+	}
+	return manifestModified.UTC()
+}
+
+func withArchiveProvenance(record AccountRecord, provenance handles.FieldProvenance) AccountRecord {
+	if strings.TrimSpace(record.UserName) != "" {
+		record = record.WithUserName(record.UserName, provenance)
+	}
+	if strings.TrimSpace(record.DisplayName) != "" {
+		record = record.WithDisplayName(record.DisplayName, provenance)
+	}
+	return record
+}
+
+// recordExportOrder keeps the most recent position observed for an account across export files.
+func recordExportOrder(exportOrder map[string]int, accountID string, position int) {
+	if existing, found := exportOrder[accountID]; found && existing <= position {
+		return
+	}
+	exportOrder[accountID] = position
+}
+
 func parseArrayOfUsers(js []byte, innerKey string) ([]AccountRecord, error) {
 	arrayContent := reFirstArray.Find(js)
 	if len(arrayContent) == 0 {
@@ -245,6 +299,36 @@ func parseArrayOfIDs(js []byte, innerKeys ...string) []string {
 	return ids
 }
 
+// parseListMemberships reads the list URLs of lists-member.js and the creator handle each URL names, if any.
+func parseListMemberships(js []byte) []ListMembership {
+	arrayContent := reFirstArray.Find(js)
+	if len(arrayContent) == 0 {
+		return nil
+	}
+	var raw []map[string]any
+	if err := json.Unmarshal(arrayContent, &raw); err != nil {
+		trimmed := strings.TrimSuffix(strings.TrimSpace(string(arrayContent)), ";")
+		_ = json.Unmarshal([]byte(trimmed), &raw)
+	}
+	var memberships []ListMembership
+	for _, record := range raw {
+		obj, _ := record["userListInfo"].(map[string]any)
+		if obj == nil {
+			continue
+		}
+		listURL := strings.TrimSpace(stringValueForKey(obj, "url"))
+		if listURL == "" {
+			continue
+		}
+		membership := ListMembership{URL: listURL}
+		if match := reListCreator.FindStringSubmatch(listURL); len(match) == 2 && match[1] != listIDPathSegment {
+			membership.CreatorUserName = match[1]
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships
+}
+
 func firstAvailableValue(data map[string]any, keys ...string) any {
 	for _, key := range keys {
 		if value, ok := data[key]; ok {
@@ -4,8 +4,11 @@ import (
 	"archive/zip"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
 )
 
@@ -103,6 +106,59 @@ func TestReadTwitterZip(t *testing.T) {
 	}
 }
 
+func TestReadTwitterZipRecordsArchiveProvenance(t *testing.T) {
+	archivePath := createArchive(t, map[string]string{
+		"manifest.js":  `{"userInfo":{"accountId":"owner","userName":"owner_name"},"archiveInfo":{"generationDate":"2023-05-01T10:00:00.000Z"}}`,
+		"following.js": `[{"following":{"accountId":"1","userName":"followed"}}]`,
+		"follower.js":  `[{"follower":{"accountId":"2"}}]`,
+	})
+
+	accountSets, owner, err := matrix.ReadTwitterZip(archivePath)
+	if err != nil {
+		t.Fatalf("ReadTwitterZip returned error: %v", err)
+	}
+	expectedExportTime := time.Date(2023, time.May, 1, 10, 0, 0, 0, time.UTC)
+	if !owner.ExportedAt.Equal(expectedExportTime) {
+		t.Fatalf("expected export time %v, got %v", expectedExportTime, owner.ExportedAt)
+	}
+
+	provenance, known := accountSets.Following["1"].UserNameProvenance()
+	if !known {
+		t.Fatalf("expected handle provenance for following record")
+	}
+	if provenance.Source != handles.LabelSourceArchive || provenance.Origin != "owner" || !provenance.ObservedAt.Equal(expectedExportTime) {
+		t.Fatalf("unexpected handle provenance: %+v", provenance)
+	}
+	if _, known := accountSets.Following["1"].DisplayNameProvenance(); known {
+		t.Fatalf("expected no provenance for missing display name")
+	}
+	if _, known := accountSets.Followers["2"].UserNameProvenance(); known {
+		t.Fatalf("expected no provenance for missing handle")
+	}
+}
+
+func TestReadTwitterZipReadsListMemberships(t *testing.T) {
+	archivePath := createArchive(t, map[string]string{
+		"following.js": `[{"following":{"accountId":"1"}}]`,
+		"lists-member.js": `window.YTD.lists_member.part0 = [
+			{"userListInfo":{"url":"https://twitter.com/List_Maker/lists/go-people"}},
+			{"userListInfo":{"url":"https://x.com/i/lists/1234"}}
+		]`,
+	})
+
+	accountSets, _, err := matrix.ReadTwitterZip(archivePath)
+	if err != nil {
+		t.Fatalf("ReadTwitterZip returned error: %v", err)
+	}
+	expected := []matrix.ListMembership{
+		{URL: "https://twitter.com/List_Maker/lists/go-people", CreatorUserName: "List_Maker"},
+		{URL: "https://x.com/i/lists/1234"},
+	}
+	if !reflect.DeepEqual(accountSets.ListMemberships, expected) {
+		t.Fatalf("unexpected list memberships %+v", accountSets.ListMemberships)
+	}
+}
+
 func createArchive(t *testing.T, files map[string]string) string {
 	t.Helper()
 	tempDir := t.TempDir()
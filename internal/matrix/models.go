@@ -1,6 +1,11 @@
 package matrix
 
-import "github.com/f-sync/fsync/internal/handles"
+import (
+	"strings"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
 
 // AccountRecord represents a single Twitter account relationship.
 type AccountRecord = handles.AccountRecord
@@ -11,6 +16,21 @@ type AccountSets struct {
 	Following map[string]AccountRecord
 	Muted     map[string]bool
 	Blocked   map[string]bool
+	// Labels holds resolved records for muted and blocked accounts that appear in neither relationship map. It labels
+	// those accounts without ever feeding the follower or following buckets.
+	Labels map[string]AccountRecord
+	// ExportOrder records the position of each account in the export files; lower values are more recent.
+	ExportOrder map[string]int
+	// ListMemberships lists the X lists the owner is a member of, as recorded in the archive.
+	ListMemberships []ListMembership
+}
+
+// ListMembership describes an X list that an archive owner is a member of. Archives record only the list URL, so the
+// list's creator is known only when the URL names it.
+type ListMembership struct {
+	URL string `json:"url"`
+	// CreatorUserName is the handle in the list URL; it is empty for URLs that identify the list by number only.
+	CreatorUserName string `json:"creatorUserName,omitempty"`
 }
 
 // OwnerIdentity describes the owner of a Twitter export archive.
@@ -18,6 +38,16 @@ type OwnerIdentity struct {
 	AccountID   string
 	UserName    string
 	DisplayName string
+	// ExportedAt records when the owner's archive was generated; it is zero when unknown.
+	ExportedAt time.Time
+}
+
+// ProvenanceOrigin returns the identifier recorded as the origin of labels read from the owner's archive.
+func (owner OwnerIdentity) ProvenanceOrigin() string {
+	if accountID := strings.TrimSpace(owner.AccountID); accountID != "" {
+		return accountID
+	}
+	return strings.TrimSpace(owner.UserName)
 }
 
 // ComparisonResult holds all derived data required to render a comparison view.
@@ -26,6 +56,7 @@ type ComparisonResult struct {
 	AccountSetsB AccountSets
 	OwnerA       OwnerIdentity
 	OwnerB       OwnerIdentity
+	Options      ComparisonOptions
 
 	OwnerAFriends  []AccountRecord
 	OwnerALeaders  []AccountRecord
@@ -45,6 +76,17 @@ type ComparisonResult struct {
 	OwnerBBlockedAll          []AccountRecord
 	OwnerBBlockedAndFollowing []AccountRecord
 	OwnerBBlockedAndFollowers []AccountRecord
+
+	OwnerAGhosts []AccountRecord
+	OwnerBGhosts []AccountRecord
+}
+
+// ComparisonOptions customizes how comparison buckets are ordered and filtered.
+type ComparisonOptions struct {
+	SortMode SortMode
+	Locale   string
+	// Filter keeps only accounts with the selected profile flags; every account is kept when empty.
+	Filter AccountFilter
 }
 
 // UploadSummary describes an archive that has been uploaded for comparison.
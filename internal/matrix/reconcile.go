@@ -0,0 +1,269 @@
+package matrix
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+// LabelField names an account label that is reconciled across archives.
+type LabelField string
+
+const (
+	// LabelFieldUserName identifies the handle label.
+	LabelFieldUserName LabelField = "userName"
+	// LabelFieldDisplayName identifies the display name label.
+	LabelFieldDisplayName LabelField = "displayName"
+)
+
+// LabelConflict reports an account whose sources disagree on a label; differing handles usually indicate a rename.
+type LabelConflict struct {
+	AccountID string     `json:"accountId"`
+	Field     LabelField `json:"field"`
+	Values    []string   `json:"values"`
+	Chosen    string     `json:"chosen"`
+}
+
+// LabelReconciliation summarizes a reconciliation pass.
+type LabelReconciliation struct {
+	// Labels holds the best-known record for every account identifier observed.
+	Labels map[string]AccountRecord
+	// Conflicts lists accounts whose sources disagree, ordered by account identifier and field.
+	Conflicts []LabelConflict
+	// FilledRecords counts relationship records that gained a label they were missing.
+	FilledRecords int
+}
+
+// labelCandidates tallies the observed values for one label of one account in observation order.
+type labelCandidates struct {
+	values []string
+	counts map[string]int
+	// spelling keeps the first spelling seen for values compared case-insensitively.
+	spelling map[string]string
+	// provenance keeps the provenance of the newest observation of each value, or of its first observation when none
+	// carries a time.
+	provenance map[string]handles.FieldProvenance
+	// latest keeps the newest observation time of each value.
+	latest map[string]time.Time
+}
+
+// handleSightings keeps, per lowercased handle of one account, the spelling and the latest time an archive showed it.
+type handleSightings map[string]handles.HandleObservation
+
+func (sightings handleSightings) observe(record AccountRecord) {
+	observation, known := handles.ObservationOf(record)
+	if !known || observation.ObservedAt.IsZero() {
+		return
+	}
+	key := strings.ToLower(observation.UserName)
+	if previous, seen := sightings[key]; !seen || observation.ObservedAt.After(previous.ObservedAt) {
+		sightings[key] = observation
+	}
+}
+
+// formerUserNames lists the handles last seen before the record's own handle was observed, most recent first.
+func (sightings handleSightings) formerUserNames(record AccountRecord) []string {
+	current, known := sightings[strings.ToLower(strings.TrimSpace(record.UserName))]
+	if !known {
+		return nil
+	}
+	var earlier []handles.HandleObservation
+	for _, sighting := range sightings {
+		if sighting.ObservedAt.Before(current.ObservedAt) {
+			earlier = append(earlier, sighting)
+		}
+	}
+	sort.Slice(earlier, func(firstIndex, secondIndex int) bool {
+		return earlier[firstIndex].ObservedAt.After(earlier[secondIndex].ObservedAt)
+	})
+	former := make([]string, 0, len(earlier))
+	for _, sighting := range earlier {
+		former = append(former, sighting.UserName)
+	}
+	return former
+}
+
+func newLabelCandidates() *labelCandidates {
+	return &labelCandidates{
+		counts:     map[string]int{},
+		spelling:   map[string]string{},
+		provenance: map[string]handles.FieldProvenance{},
+		latest:     map[string]time.Time{},
+	}
+}
+
+func (candidates *labelCandidates) observe(value string, provenance handles.FieldProvenance, known bool, caseInsensitive bool) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return
+	}
+	key := trimmed
+	if caseInsensitive {
+		key = strings.ToLower(trimmed)
+	}
+	if _, seen := candidates.counts[key]; !seen {
+		candidates.values = append(candidates.values, key)
+		candidates.spelling[key] = trimmed
+	}
+	candidates.counts[key]++
+	if !known {
+		return
+	}
+	if _, recorded := candidates.provenance[key]; !recorded || provenance.ObservedAt.After(candidates.latest[key]) {
+		candidates.provenance[key] = provenance
+		candidates.latest[key] = provenance.ObservedAt
+	}
+}
+
+// best returns the most recently observed value, so that a renamed account takes its newest handle even when more
+// archives carry an older one. Values observed at the same time, or without a time, fall back to the most frequent
+// value and then to the earliest observation. The provenance of the chosen value is returned when one was recorded.
+func (candidates *labelCandidates) best() (string, handles.FieldProvenance, bool) {
+	bestKey := ""
+	bestCount := 0
+	var bestObservedAt time.Time
+	for _, key := range candidates.values {
+		observedAt := candidates.latest[key]
+		newer := observedAt.After(bestObservedAt)
+		if newer || (observedAt.Equal(bestObservedAt) && candidates.counts[key] > bestCount) {
+			bestKey = key
+			bestCount = candidates.counts[key]
+			bestObservedAt = observedAt
+		}
+	}
+	provenance, known := candidates.provenance[bestKey]
+	return candidates.spelling[bestKey], provenance, known
+}
+
+func (candidates *labelCandidates) distinct() []string {
+	distinct := make([]string, 0, len(candidates.values))
+	for _, key := range candidates.values {
+		distinct = append(distinct, candidates.spelling[key])
+	}
+	return distinct
+}
+
+// ReconcileLabels merges the best-known handle and display name for every account identifier across the supplied
+// account sets and writes them back into records that lack them. Sets are consulted in order, following before
+// followers before the muted and blocked labels, so callers control precedence by argument order. Existing non-empty labels are never overwritten. When
+// archives exported at different times show an account under different handles, records carrying the newer handle
+// list the older ones in FormerUserNames.
+func ReconcileLabels(accountSets ...*AccountSets) LabelReconciliation {
+	userNames := map[string]*labelCandidates{}
+	displayNames := map[string]*labelCandidates{}
+	sightings := map[string]handleSightings{}
+	var accountIDs []string
+
+	observe := func(records map[string]AccountRecord) {
+		for _, accountID := range sortedRecordIDs(records) {
+			record := records[accountID]
+			if _, known := userNames[accountID]; !known {
+				userNames[accountID] = newLabelCandidates()
+				displayNames[accountID] = newLabelCandidates()
+				sightings[accountID] = handleSightings{}
+				accountIDs = append(accountIDs, accountID)
+			}
+			sightings[accountID].observe(record)
+			userNameProvenance, userNameKnown := record.UserNameProvenance()
+			userNames[accountID].observe(record.UserName, userNameProvenance, userNameKnown, true)
+			displayNameProvenance, displayNameKnown := record.DisplayNameProvenance()
+			displayNames[accountID].observe(record.DisplayName, displayNameProvenance, displayNameKnown, false)
+		}
+	}
+	for _, accountSet := range accountSets {
+		if accountSet == nil {
+			continue
+		}
+		observe(accountSet.Following)
+		observe(accountSet.Followers)
+		observe(accountSet.Labels)
+	}
+
+	reconciliation := LabelReconciliation{Labels: make(map[string]AccountRecord, len(accountIDs))}
+	sort.Slice(accountIDs, func(firstIndex, secondIndex int) bool {
+		return compareAccountIDs(accountIDs[firstIndex], accountIDs[secondIndex]) < 0
+	})
+	for _, accountID := range accountIDs {
+		label := AccountRecord{AccountID: accountID}
+		userName, userNameProvenance, userNameKnown := userNames[accountID].best()
+		if userNameKnown {
+			label = label.WithUserName(userName, userNameProvenance)
+		} else {
+			label.UserName = userName
+		}
+		displayName, displayNameProvenance, displayNameKnown := displayNames[accountID].best()
+		if displayNameKnown {
+			label = label.WithDisplayName(displayName, displayNameProvenance)
+		} else {
+			label.DisplayName = displayName
+		}
+		reconciliation.Labels[accountID] = label
+		if values := userNames[accountID].distinct(); len(values) > 1 {
+			reconciliation.Conflicts = append(reconciliation.Conflicts, LabelConflict{AccountID: accountID, Field: LabelFieldUserName, Values: values, Chosen: label.UserName})
+		}
+		if values := displayNames[accountID].distinct(); len(values) > 1 {
+			reconciliation.Conflicts = append(reconciliation.Conflicts, LabelConflict{AccountID: accountID, Field: LabelFieldDisplayName, Values: values, Chosen: label.DisplayName})
+		}
+	}
+
+	for _, accountSet := range accountSets {
+		if accountSet == nil {
+			continue
+		}
+		reconciliation.FilledRecords += applyLabels(accountSet.Following, reconciliation.Labels)
+		reconciliation.FilledRecords += applyLabels(accountSet.Followers, reconciliation.Labels)
+		reconciliation.FilledRecords += applyLabels(accountSet.Labels, reconciliation.Labels)
+		applyFormerUserNames(accountSet.Following, sightings)
+		applyFormerUserNames(accountSet.Followers, sightings)
+		applyFormerUserNames(accountSet.Labels, sightings)
+	}
+	return reconciliation
+}
+
+func applyFormerUserNames(records map[string]AccountRecord, sightings map[string]handleSightings) {
+	for accountID, record := range records {
+		if len(sightings[accountID]) < 2 {
+			continue
+		}
+		if former := sightings[accountID].formerUserNames(record); len(former) > 0 {
+			records[accountID] = record.WithFormerUserNames(former...)
+		}
+	}
+}
+
+func applyLabels(records map[string]AccountRecord, labels map[string]AccountRecord) int {
+	filled := 0
+	for accountID, record := range records {
+		label, found := labels[accountID]
+		if !found {
+			continue
+		}
+		changed := false
+		if strings.TrimSpace(record.UserName) == "" && label.UserName != "" {
+			record = withResolvedUserName(record, label)
+			changed = true
+		}
+		if strings.TrimSpace(record.DisplayName) == "" && label.DisplayName != "" {
+			record = withResolvedDisplayName(record, label)
+			changed = true
+		}
+		if changed {
+			records[accountID] = record
+			filled++
+		}
+	}
+	return filled
+}
+
+func sortedRecordIDs(records map[string]AccountRecord) []string {
+	accountIDs := make([]string, 0, len(records))
+	for accountID := range records {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Slice(accountIDs, func(firstIndex, secondIndex int) bool {
+		return compareAccountIDs(accountIDs[firstIndex], accountIDs[secondIndex]) < 0
+	})
+	return accountIDs
+}
@@ -0,0 +1,187 @@
+package matrix_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/handles"
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+func TestReconcileLabels(t *testing.T) {
+	testCases := []struct {
+		name                string
+		accountSetsA        matrix.AccountSets
+		accountSetsB        matrix.AccountSets
+		expectedFollowerA   matrix.AccountRecord
+		expectedFilled      int
+		expectedConflicts   []matrix.LabelConflict
+		expectedLabelForID  string
+		expectedLabelHandle string
+		expectedLabelsB     map[string]matrix.AccountRecord
+	}{
+		{
+			name: "borrows handle and display name from other archive",
+			accountSetsA: matrix.AccountSets{
+				Followers: map[string]matrix.AccountRecord{"100": {AccountID: "100"}},
+			},
+			accountSetsB: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{"100": {AccountID: "100", UserName: "known", DisplayName: "Known Name"}},
+			},
+			expectedFollowerA:   matrix.AccountRecord{AccountID: "100", UserName: "known", DisplayName: "Known Name"},
+			expectedFilled:      1,
+			expectedLabelForID:  "100",
+			expectedLabelHandle: "known",
+		},
+		{
+			name: "reports renamed handles and keeps existing labels",
+			accountSetsA: matrix.AccountSets{
+				Followers: map[string]matrix.AccountRecord{"200": {AccountID: "200", UserName: "old_name", DisplayName: "Same"}},
+			},
+			accountSetsB: matrix.AccountSets{
+				Following: map[string]matrix.AccountRecord{"200": {AccountID: "200", UserName: "new_name", DisplayName: "Same"}},
+				Followers: map[string]matrix.AccountRecord{"200": {AccountID: "200", UserName: "NEW_NAME"}},
+			},
+			expectedFollowerA: matrix.AccountRecord{AccountID: "200", UserName: "old_name", DisplayName: "Same"},
+			expectedFilled:    1,
+			expectedConflicts: []matrix.LabelConflict{
+				{AccountID: "200", Field: matrix.LabelFieldUserName, Values: []string{"old_name", "new_name"}, Chosen: "new_name"},
+			},
+			expectedLabelForID:  "200",
+			expectedLabelHandle: "new_name",
+		},
+		{
+			name: "borrows resolved handle of muted account",
+			accountSetsA: matrix.AccountSets{
+				Followers: map[string]matrix.AccountRecord{"300": {AccountID: "300"}},
+			},
+			accountSetsB: matrix.AccountSets{
+				Muted:  map[string]bool{"300": true},
+				Labels: map[string]matrix.AccountRecord{"300": {AccountID: "300", UserName: "resolved"}},
+			},
+			expectedFollowerA:   matrix.AccountRecord{AccountID: "300", UserName: "resolved"},
+			expectedFilled:      1,
+			expectedLabelForID:  "300",
+			expectedLabelHandle: "resolved",
+		},
+		{
+			name: "reports resolved handle of blocked account that differs from archive",
+			accountSetsA: matrix.AccountSets{
+				Followers: map[string]matrix.AccountRecord{"400": {AccountID: "400", UserName: "archived", DisplayName: "Blocked"}},
+			},
+			accountSetsB: matrix.AccountSets{
+				Blocked: map[string]bool{"400": true, "401": true},
+				Labels: map[string]matrix.AccountRecord{
+					"400": {AccountID: "400", UserName: "renamed"},
+					"401": {AccountID: "401"},
+				},
+			},
+			expectedFollowerA: matrix.AccountRecord{AccountID: "400", UserName: "archived", DisplayName: "Blocked"},
+			expectedFilled:    1,
+			expectedConflicts: []matrix.LabelConflict{
+				{AccountID: "400", Field: matrix.LabelFieldUserName, Values: []string{"archived", "renamed"}, Chosen: "archived"},
+			},
+			expectedLabelForID:  "400",
+			expectedLabelHandle: "archived",
+			expectedLabelsB: map[string]matrix.AccountRecord{
+				"400": {AccountID: "400", UserName: "renamed", DisplayName: "Blocked"},
+				"401": {AccountID: "401"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			accountSetsA := copyAccountSets(testCase.accountSetsA)
+			accountSetsB := copyAccountSets(testCase.accountSetsB)
+
+			reconciliation := matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+
+			follower := accountSetsA.Followers[testCase.expectedFollowerA.AccountID]
+			if !reflect.DeepEqual(follower, testCase.expectedFollowerA) {
+				t.Fatalf("unexpected follower record: %+v", follower)
+			}
+			if testCase.expectedLabelsB != nil && !reflect.DeepEqual(accountSetsB.Labels, testCase.expectedLabelsB) {
+				t.Fatalf("unexpected labels: %+v", accountSetsB.Labels)
+			}
+			if reconciliation.FilledRecords != testCase.expectedFilled {
+				t.Fatalf("expected %d filled records, got %d", testCase.expectedFilled, reconciliation.FilledRecords)
+			}
+			if label := reconciliation.Labels[testCase.expectedLabelForID]; label.UserName != testCase.expectedLabelHandle {
+				t.Fatalf("expected best handle %q, got %q", testCase.expectedLabelHandle, label.UserName)
+			}
+			if len(reconciliation.Conflicts) != len(testCase.expectedConflicts) {
+				t.Fatalf("expected %d conflicts, got %+v", len(testCase.expectedConflicts), reconciliation.Conflicts)
+			}
+			for index, expected := range testCase.expectedConflicts {
+				actual := reconciliation.Conflicts[index]
+				if actual.AccountID != expected.AccountID || actual.Field != expected.Field || actual.Chosen != expected.Chosen {
+					t.Fatalf("unexpected conflict %+v, want %+v", actual, expected)
+				}
+				if len(actual.Values) != len(expected.Values) {
+					t.Fatalf("unexpected conflict values %v, want %v", actual.Values, expected.Values)
+				}
+				for valueIndex, value := range expected.Values {
+					if actual.Values[valueIndex] != value {
+						t.Fatalf("unexpected conflict values %v, want %v", actual.Values, expected.Values)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileLabelsKeepsProvenance(t *testing.T) {
+	provenance := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "owner_b", ObservedAt: time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)}
+	accountSetsA := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{"100": {AccountID: "100"}}}
+	accountSetsB := matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("known", provenance)}}
+
+	matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+
+	borrowed, known := accountSetsA.Followers["100"].UserNameProvenance()
+	if !known || borrowed != provenance {
+		t.Fatalf("expected borrowed handle to keep provenance %+v, got %+v", provenance, borrowed)
+	}
+	if _, known := accountSetsA.Followers["100"].DisplayNameProvenance(); known {
+		t.Fatalf("expected no display name provenance")
+	}
+}
+
+func TestReconcileLabelsPrefersNewestHandle(t *testing.T) {
+	olderExport := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "owner_a", ObservedAt: time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)}
+	newerExport := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "owner_c", ObservedAt: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)}
+	olderSetsA := matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("old_name", olderExport)}}
+	olderSetsB := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("old_name", olderExport)}}
+	newerSets := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("new_name", newerExport)}}
+
+	reconciliation := matrix.ReconcileLabels(&olderSetsA, &olderSetsB, &newerSets)
+
+	label := reconciliation.Labels["100"]
+	if label.UserName != "new_name" {
+		t.Fatalf("expected the newest handle to win over the more frequent one, got %q", label.UserName)
+	}
+	if provenance, known := label.UserNameProvenance(); !known || provenance != newerExport {
+		t.Fatalf("expected provenance %+v, got %+v", newerExport, provenance)
+	}
+	if len(reconciliation.Conflicts) != 1 || reconciliation.Conflicts[0].Chosen != "new_name" {
+		t.Fatalf("expected one conflict choosing the newest handle, got %+v", reconciliation.Conflicts)
+	}
+}
+
+func TestReconcileLabelsRecordsFormerHandles(t *testing.T) {
+	olderExport := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "owner_a", ObservedAt: time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)}
+	newerExport := handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "owner_b", ObservedAt: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)}
+	accountSetsA := matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("old_name", olderExport)}}
+	accountSetsB := matrix.AccountSets{Followers: map[string]matrix.AccountRecord{"100": matrix.AccountRecord{AccountID: "100"}.WithUserName("new_name", newerExport)}}
+
+	matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+
+	if former := accountSetsB.Followers["100"].FormerUserNames; !reflect.DeepEqual(former, []string{"old_name"}) {
+		t.Fatalf("expected the newer record to list the older handle, got %v", former)
+	}
+	if former := accountSetsA.Following["100"].FormerUserNames; len(former) != 0 {
+		t.Fatalf("expected the older record to list no former handles, got %v", former)
+	}
+}
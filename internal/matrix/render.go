@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"net/url"
 	"strings"
+
+	"github.com/f-sync/fsync/internal/handles"
 )
 
 // ComparisonPageData captures the state needed to render the interactive comparison page.
@@ -13,6 +16,23 @@ type ComparisonPageData struct {
 	Comparison *ComparisonResult
 	Uploads    []UploadSummary
 	Errors     []string
+	// PageLimit caps the number of accounts rendered per bucket; zero renders every account.
+	PageLimit int
+	// BucketsEndpoint enables sort controls and incremental loading against the bucket API when set.
+	BucketsEndpoint string
+	// LabelConflicts lists accounts whose archives disagree on labels.
+	LabelConflicts []LabelConflict
+	// ConsensusPath links the navigation bar to the team consensus view when set.
+	ConsensusPath string
+	// AccountsEndpoint lets the account detail panel query the account API; without it details are computed in the page.
+	AccountsEndpoint string
+	// ResolutionEndpoint is polled for handle resolution progress when set.
+	ResolutionEndpoint string
+	// JobEventsEndpoint streams background job changes as Server-Sent Events; when set, the page follows uploads,
+	// resolution and exports through it instead of polling ResolutionEndpoint.
+	JobEventsEndpoint string
+	// ExportsEndpoint starts bucket export jobs when set, adding an export button to every bucket.
+	ExportsEndpoint string
 }
 
 // RenderComparisonPage assembles the HTML output using the embedded assets and templates.
@@ -59,6 +79,22 @@ type comparisonPageViewModel struct {
 	OwnerALists ownerListViewModel
 	OwnerBLists ownerListViewModel
 
+	SortMode        SortMode
+	SortOptions     []sortOptionViewModel
+	Filter          AccountFilter
+	FilterOptions   []filterOptionViewModel
+	BucketsEndpoint string
+	LabelConflicts  []labelConflictViewModel
+	ConsensusPath   string
+	// AccountsEndpoint is the account detail API used by the detail panel, if any.
+	AccountsEndpoint string
+	// ResolutionEndpoint reports handle resolution progress, if any.
+	ResolutionEndpoint string
+	// JobEventsEndpoint streams background job changes, if any.
+	JobEventsEndpoint string
+	// ExportsEndpoint starts bucket exports, if any.
+	ExportsEndpoint string
+
 	Uploads []uploadSummaryViewModel
 	Errors  []string
 
@@ -73,13 +109,44 @@ type uploadSummaryViewModel struct {
 	FileName   string
 }
 
+type labelConflictViewModel struct {
+	AccountID  string
+	ProfileURL string
+	FieldLabel string
+	Values     []string
+	Chosen     string
+}
+
+type sortOptionViewModel struct {
+	Value    SortMode
+	Label    string
+	Selected bool
+}
+
+type filterOptionViewModel struct {
+	Value    AccountFilter
+	Label    string
+	Selected bool
+}
+
 type ownerListViewModel struct {
-	Friends             []accountCardTemplateData
-	Leaders             []accountCardTemplateData
-	Groupies            []accountCardTemplateData
-	BlockedAll          []accountCardTemplateData
-	BlockedAndFollowing []accountCardTemplateData
-	BlockedAndFollowers []accountCardTemplateData
+	Friends             bucketViewModel
+	Leaders             bucketViewModel
+	Groupies            bucketViewModel
+	BlockedAll          bucketViewModel
+	BlockedAndFollowing bucketViewModel
+	BlockedAndFollowers bucketViewModel
+	Ghosts              bucketViewModel
+}
+
+// bucketViewModel holds the rendered page of a bucket plus the metadata needed to fetch the remainder.
+type bucketViewModel struct {
+	Owner      OwnerSlot
+	Bucket     BucketName
+	Entries    []accountCardTemplateData
+	Total      int
+	NextOffset int
+	HasMore    bool
 }
 
 type accountCardTemplateData struct {
@@ -90,10 +157,12 @@ type accountCardTemplateData struct {
 
 type accountPresentation struct {
 	record AccountRecord
+	// ownerOrigin is the provenance origin of the owner whose list shows the record.
+	ownerOrigin string
 }
 
-func newAccountPresentation(record AccountRecord) accountPresentation {
-	return accountPresentation{record: record}
+func newAccountPresentation(record AccountRecord, ownerOrigin string) accountPresentation {
+	return accountPresentation{record: record, ownerOrigin: ownerOrigin}
 }
 
 func (presentation accountPresentation) Display() string {
@@ -111,6 +180,10 @@ func (presentation accountPresentation) Display() string {
 	return unknownLabelText
 }
 
+func (presentation accountPresentation) AccountID() string {
+	return presentation.record.AccountID
+}
+
 func (presentation accountPresentation) Handle() string {
 	handle := strings.TrimSpace(presentation.record.UserName)
 	if handle == "" {
@@ -119,6 +192,18 @@ func (presentation accountPresentation) Handle() string {
 	return accountHandlePrefix + handle
 }
 
+// FormerHandles returns a "formerly @old" hint when the account was seen under other handles, most recent first.
+func (presentation accountPresentation) FormerHandles() string {
+	if len(presentation.record.FormerUserNames) == 0 {
+		return ""
+	}
+	formerHandles := make([]string, 0, len(presentation.record.FormerUserNames))
+	for _, userName := range presentation.record.FormerUserNames {
+		formerHandles = append(formerHandles, accountHandlePrefix+userName)
+	}
+	return formerHandlesPrefix + strings.Join(formerHandles, formerHandlesJoiner)
+}
+
 func (presentation accountPresentation) ProfileURL() string {
 	if strings.TrimSpace(presentation.record.UserName) != "" {
 		return twitterUserNameBaseURL + presentation.record.UserName
@@ -126,13 +211,94 @@ func (presentation accountPresentation) ProfileURL() string {
 	return twitterUserIDBaseURL + presentation.record.AccountID
 }
 
+// StatusBadge labels accounts the resolver found suspended or deleted.
+func (presentation accountPresentation) StatusBadge() string {
+	return accountStatusBadges[presentation.record.Status]
+}
+
+// Protected reports whether the card shows the protected lock.
+func (presentation accountPresentation) Protected() bool {
+	return presentation.record.IsProtected()
+}
+
+// AvatarURL returns the profile picture observed by the resolver, if any.
+func (presentation accountPresentation) AvatarURL() string {
+	if presentation.record.Profile == nil {
+		return ""
+	}
+	return strings.TrimSpace(presentation.record.Profile.AvatarURL)
+}
+
+// ProvenanceMarker returns a short marker for labels that did not come from the owner's own archive.
+func (presentation accountPresentation) ProvenanceMarker() string {
+	marker := ""
+	for _, provenance := range presentation.foreignProvenance() {
+		if provenance.Source != handles.LabelSourceArchive {
+			return provenanceResolvedText
+		}
+		marker = provenanceBorrowedText
+	}
+	return marker
+}
+
+// ProvenanceNote describes where each foreign label came from and when it was observed.
+func (presentation accountPresentation) ProvenanceNote() string {
+	fields := presentation.foreignProvenance()
+	notes := make([]string, 0, len(fields))
+	for _, field := range []LabelField{LabelFieldUserName, LabelFieldDisplayName} {
+		provenance, found := fields[field]
+		if !found {
+			continue
+		}
+		observedAt := provenanceUnknownDate
+		if !provenance.ObservedAt.IsZero() {
+			observedAt = provenance.ObservedAt.UTC().Format(provenanceDateLayout)
+		}
+		notes = append(notes, fmt.Sprintf(provenanceNoteFormat, labelFieldNames[field], labelSourceDescriptions[provenance.Source], observedAt))
+	}
+	return strings.Join(notes, provenanceNoteJoiner)
+}
+
+// foreignProvenance returns the provenance of displayed labels obtained outside the owner's archive.
+func (presentation accountPresentation) foreignProvenance() map[LabelField]handles.FieldProvenance {
+	fields := map[LabelField]handles.FieldProvenance{}
+	if provenance, known := presentation.record.UserNameProvenance(); known && presentation.isForeign(provenance) {
+		fields[LabelFieldUserName] = provenance
+	}
+	if provenance, known := presentation.record.DisplayNameProvenance(); known && presentation.isForeign(provenance) {
+		fields[LabelFieldDisplayName] = provenance
+	}
+	return fields
+}
+
+func (presentation accountPresentation) isForeign(provenance handles.FieldProvenance) bool {
+	if provenance.Source != handles.LabelSourceArchive {
+		return true
+	}
+	return provenance.Origin != "" && provenance.Origin != presentation.ownerOrigin
+}
+
+// FollowIntentURL returns the one-click follow intent for the account.
+func (presentation accountPresentation) FollowIntentURL() string {
+	return FollowIntentURL(presentation.record)
+}
+
+// FollowIntentURL returns the one-click follow intent for a record, preferring the handle over the account ID.
+func FollowIntentURL(record AccountRecord) string {
+	if handle := strings.TrimSpace(record.UserName); handle != "" {
+		return followIntentHandleURL + url.QueryEscape(handle)
+	}
+	return followIntentUserIDURL + url.QueryEscape(record.AccountID)
+}
+
 type accountBadgeDecorator struct {
-	mutedIDs   map[string]bool
-	blockedIDs map[string]bool
+	mutedIDs    map[string]bool
+	blockedIDs  map[string]bool
+	ownerOrigin string
 }
 
-func newAccountBadgeDecorator(mutedIDs map[string]bool, blockedIDs map[string]bool) accountBadgeDecorator {
-	return accountBadgeDecorator{mutedIDs: mutedIDs, blockedIDs: blockedIDs}
+func newAccountBadgeDecorator(mutedIDs map[string]bool, blockedIDs map[string]bool, ownerOrigin string) accountBadgeDecorator {
+	return accountBadgeDecorator{mutedIDs: mutedIDs, blockedIDs: blockedIDs, ownerOrigin: ownerOrigin}
 }
 
 func (decorator accountBadgeDecorator) Decorate(records []AccountRecord) []accountCardTemplateData {
@@ -142,7 +308,7 @@ func (decorator accountBadgeDecorator) Decorate(records []AccountRecord) []accou
 	decorated := make([]accountCardTemplateData, 0, len(records))
 	for _, record := range records {
 		decorated = append(decorated, accountCardTemplateData{
-			Presentation: newAccountPresentation(record),
+			Presentation: newAccountPresentation(record, decorator.ownerOrigin),
 			Muted:        decorator.isMuted(record.AccountID),
 			Blocked:      decorator.isBlocked(record.AccountID),
 		})
@@ -164,11 +330,70 @@ func (decorator accountBadgeDecorator) isBlocked(accountID string) bool {
 	return decorator.blockedIDs[accountID]
 }
 
+func (decorator accountBadgeDecorator) DecorateBucket(owner OwnerSlot, bucket BucketName, records []AccountRecord, pageLimit int) bucketViewModel {
+	page := PaginateRecords(records, 0, pageLimit)
+	return bucketViewModel{
+		Owner:      owner,
+		Bucket:     bucket,
+		Entries:    decorator.Decorate(page.Records),
+		Total:      page.Total,
+		NextOffset: page.NextOffset(),
+		HasMore:    page.HasMore(),
+	}
+}
+
+func newSortOptions(selected SortMode) []sortOptionViewModel {
+	options := make([]sortOptionViewModel, 0, len(supportedSortModes))
+	for _, mode := range supportedSortModes {
+		options = append(options, sortOptionViewModel{Value: mode, Label: sortModeLabels[mode], Selected: mode == selected})
+	}
+	return options
+}
+
+func newFilterOptions(selected AccountFilter) []filterOptionViewModel {
+	options := make([]filterOptionViewModel, 0, len(supportedAccountFilters))
+	for _, filter := range supportedAccountFilters {
+		options = append(options, filterOptionViewModel{Value: filter, Label: accountFilterLabels[filter], Selected: filter == selected})
+	}
+	return options
+}
+
+func newLabelConflictViewModels(conflicts []LabelConflict) []labelConflictViewModel {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	viewModels := make([]labelConflictViewModel, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		values := conflict.Values
+		chosen := conflict.Chosen
+		if conflict.Field == LabelFieldUserName {
+			values = make([]string, 0, len(conflict.Values))
+			for _, value := range conflict.Values {
+				values = append(values, accountHandlePrefix+value)
+			}
+			chosen = accountHandlePrefix + chosen
+		}
+		viewModels = append(viewModels, labelConflictViewModel{
+			AccountID:  conflict.AccountID,
+			ProfileURL: twitterUserIDBaseURL + conflict.AccountID,
+			FieldLabel: labelFieldNames[conflict.Field],
+			Values:     values,
+			Chosen:     chosen,
+		})
+	}
+	return viewModels
+}
+
 func newComparisonPageViewModel(pageData ComparisonPageData, cssText string, jsText string, matrixJSON string) comparisonPageViewModel {
 	viewModel := comparisonPageViewModel{
-		Title: pageTitleText,
-		CSS:   template.CSS(cssText),
-		JS:    template.JS(jsText),
+		Title:              pageTitleText,
+		CSS:                template.CSS(cssText),
+		JS:                 template.JS(jsText),
+		ConsensusPath:      pageData.ConsensusPath,
+		AccountsEndpoint:   pageData.AccountsEndpoint,
+		ResolutionEndpoint: pageData.ResolutionEndpoint,
+		JobEventsEndpoint:  pageData.JobEventsEndpoint,
+		ExportsEndpoint:    pageData.ExportsEndpoint,
 	}
 
 	if len(pageData.Errors) > 0 {
@@ -191,27 +416,43 @@ func newComparisonPageViewModel(pageData ComparisonPageData, cssText string, jsT
 	}
 
 	comparison := *pageData.Comparison
-	ownerADecorator := newAccountBadgeDecorator(comparison.AccountSetsA.Muted, comparison.AccountSetsA.Blocked)
-	ownerBDecorator := newAccountBadgeDecorator(comparison.AccountSetsB.Muted, comparison.AccountSetsB.Blocked)
+	ownerADecorator := newAccountBadgeDecorator(comparison.AccountSetsA.Muted, comparison.AccountSetsA.Blocked, comparison.OwnerA.ProvenanceOrigin())
+	ownerBDecorator := newAccountBadgeDecorator(comparison.AccountSetsB.Muted, comparison.AccountSetsB.Blocked, comparison.OwnerB.ProvenanceOrigin())
+
+	pageLimit := pageData.PageLimit
 
 	viewModel.HasComparison = true
 	viewModel.OwnerA = ownerPretty(comparison.OwnerA)
 	viewModel.OwnerB = ownerPretty(comparison.OwnerB)
+	viewModel.SortMode = comparison.Options.SortMode
+	if viewModel.SortMode == "" {
+		viewModel.SortMode = DefaultSortMode
+	}
+	viewModel.SortOptions = newSortOptions(viewModel.SortMode)
+	viewModel.Filter = comparison.Options.Filter
+	if viewModel.Filter == "" {
+		viewModel.Filter = DefaultAccountFilter
+	}
+	viewModel.FilterOptions = newFilterOptions(viewModel.Filter)
+	viewModel.BucketsEndpoint = pageData.BucketsEndpoint
+	viewModel.LabelConflicts = newLabelConflictViewModels(pageData.LabelConflicts)
 	viewModel.OwnerALists = ownerListViewModel{
-		Friends:             ownerADecorator.Decorate(comparison.OwnerAFriends),
-		Leaders:             ownerADecorator.Decorate(comparison.OwnerALeaders),
-		Groupies:            ownerADecorator.Decorate(comparison.OwnerAGroupies),
-		BlockedAll:          ownerADecorator.Decorate(comparison.OwnerABlockedAll),
-		BlockedAndFollowing: ownerADecorator.Decorate(comparison.OwnerABlockedAndFollowing),
-		BlockedAndFollowers: ownerADecorator.Decorate(comparison.OwnerABlockedAndFollowers),
+		Friends:             ownerADecorator.DecorateBucket(OwnerSlotA, BucketFriends, comparison.OwnerAFriends, pageLimit),
+		Leaders:             ownerADecorator.DecorateBucket(OwnerSlotA, BucketLeaders, comparison.OwnerALeaders, pageLimit),
+		Groupies:            ownerADecorator.DecorateBucket(OwnerSlotA, BucketGroupies, comparison.OwnerAGroupies, pageLimit),
+		BlockedAll:          ownerADecorator.DecorateBucket(OwnerSlotA, BucketBlocked, comparison.OwnerABlockedAll, pageLimit),
+		BlockedAndFollowing: ownerADecorator.DecorateBucket(OwnerSlotA, BucketBlockedAndFollowing, comparison.OwnerABlockedAndFollowing, pageLimit),
+		BlockedAndFollowers: ownerADecorator.DecorateBucket(OwnerSlotA, BucketBlockedAndFollowers, comparison.OwnerABlockedAndFollowers, pageLimit),
+		Ghosts:              ownerADecorator.DecorateBucket(OwnerSlotA, BucketGhosts, comparison.OwnerAGhosts, pageLimit),
 	}
 	viewModel.OwnerBLists = ownerListViewModel{
-		Friends:             ownerBDecorator.Decorate(comparison.OwnerBFriends),
-		Leaders:             ownerBDecorator.Decorate(comparison.OwnerBLeaders),
-		Groupies:            ownerBDecorator.Decorate(comparison.OwnerBGroupies),
-		BlockedAll:          ownerBDecorator.Decorate(comparison.OwnerBBlockedAll),
-		BlockedAndFollowing: ownerBDecorator.Decorate(comparison.OwnerBBlockedAndFollowing),
-		BlockedAndFollowers: ownerBDecorator.Decorate(comparison.OwnerBBlockedAndFollowers),
+		Friends:             ownerBDecorator.DecorateBucket(OwnerSlotB, BucketFriends, comparison.OwnerBFriends, pageLimit),
+		Leaders:             ownerBDecorator.DecorateBucket(OwnerSlotB, BucketLeaders, comparison.OwnerBLeaders, pageLimit),
+		Groupies:            ownerBDecorator.DecorateBucket(OwnerSlotB, BucketGroupies, comparison.OwnerBGroupies, pageLimit),
+		BlockedAll:          ownerBDecorator.DecorateBucket(OwnerSlotB, BucketBlocked, comparison.OwnerBBlockedAll, pageLimit),
+		BlockedAndFollowing: ownerBDecorator.DecorateBucket(OwnerSlotB, BucketBlockedAndFollowing, comparison.OwnerBBlockedAndFollowing, pageLimit),
+		BlockedAndFollowers: ownerBDecorator.DecorateBucket(OwnerSlotB, BucketBlockedAndFollowers, comparison.OwnerBBlockedAndFollowers, pageLimit),
+		Ghosts:              ownerBDecorator.DecorateBucket(OwnerSlotB, BucketGhosts, comparison.OwnerBGhosts, pageLimit),
 	}
 	viewModel.MatrixJSON = template.JS(matrixJSON)
 	viewModel.Counts.A.Followers = len(comparison.OwnerAFollowersAll)
@@ -233,32 +474,46 @@ func newComparisonPageViewModel(pageData ComparisonPageData, cssText string, jsT
 
 func buildMatrixJSON(comparison ComparisonResult) (string, error) {
 	matrix := struct {
-		OwnerA     string `json:"ownerA"`
-		OwnerB     string `json:"ownerB"`
+		OwnerA     string        `json:"ownerA"`
+		OwnerB     string        `json:"ownerB"`
+		SortMode   SortMode      `json:"sortMode"`
+		Filter     AccountFilter `json:"filter"`
+		Locale     string        `json:"locale"`
 		OwnerAData struct {
+			Origin    string          `json:"origin"`
 			Followers []AccountRecord `json:"followers"`
 			Following []AccountRecord `json:"following"`
 			Muted     []string        `json:"muted"`
 			Blocked   []string        `json:"blocked"`
+			Labels    []AccountRecord `json:"labels"`
 		} `json:"A"`
 		OwnerBData struct {
+			Origin    string          `json:"origin"`
 			Followers []AccountRecord `json:"followers"`
 			Following []AccountRecord `json:"following"`
 			Muted     []string        `json:"muted"`
 			Blocked   []string        `json:"blocked"`
+			Labels    []AccountRecord `json:"labels"`
 		} `json:"B"`
 	}{
-		OwnerA: ownerPretty(comparison.OwnerA),
-		OwnerB: ownerPretty(comparison.OwnerB),
+		OwnerA:   ownerPretty(comparison.OwnerA),
+		OwnerB:   ownerPretty(comparison.OwnerB),
+		SortMode: comparison.Options.SortMode,
+		Filter:   comparison.Options.Filter,
+		Locale:   comparison.Options.Locale,
 	}
+	matrix.OwnerAData.Origin = comparison.OwnerA.ProvenanceOrigin()
 	matrix.OwnerAData.Followers = comparison.OwnerAFollowersAll
 	matrix.OwnerAData.Following = comparison.OwnerAFollowingsAll
 	matrix.OwnerAData.Muted = mapKeys(comparison.AccountSetsA.Muted)
 	matrix.OwnerAData.Blocked = mapKeys(comparison.AccountSetsA.Blocked)
+	matrix.OwnerAData.Labels = labelRecords(comparison.AccountSetsA.Labels)
+	matrix.OwnerBData.Origin = comparison.OwnerB.ProvenanceOrigin()
 	matrix.OwnerBData.Followers = comparison.OwnerBFollowersAll
 	matrix.OwnerBData.Following = comparison.OwnerBFollowingsAll
 	matrix.OwnerBData.Muted = mapKeys(comparison.AccountSetsB.Muted)
 	matrix.OwnerBData.Blocked = mapKeys(comparison.AccountSetsB.Blocked)
+	matrix.OwnerBData.Labels = labelRecords(comparison.AccountSetsB.Labels)
 
 	encoded, err := json.Marshal(matrix)
 	if err != nil {
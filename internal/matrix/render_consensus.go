@@ -0,0 +1,76 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+// ConsensusPageData captures the state needed to render a team consensus page.
+type ConsensusPageData struct {
+	Result *ConsensusResult
+	Errors []string
+	// FilterAction enables the filter form, submitting to this path, when set.
+	FilterAction string
+}
+
+type consensusPageViewModel struct {
+	Title        string
+	CSS          template.CSS
+	Errors       []string
+	HasResult    bool
+	Members      []string
+	Filter       ConsensusFilter
+	Matches      int
+	Entries      []consensusEntryViewModel
+	FilterAction string
+}
+
+type consensusEntryViewModel struct {
+	Rank         int
+	Presentation accountPresentation
+	FollowedBy   []string
+	FollowsBack  []string
+	BlockedBy    []string
+	MutedBy      []string
+}
+
+// RenderConsensusPage assembles the team consensus HTML output using the embedded assets and templates.
+func RenderConsensusPage(pageData ConsensusPageData) (string, error) {
+	cssText, err := embeddedText(embeddedBaseCSSPath)
+	if err != nil {
+		return "", err
+	}
+	viewModel := consensusPageViewModel{
+		Title:        consensusTitleText,
+		CSS:          template.CSS(cssText),
+		Errors:       pageData.Errors,
+		FilterAction: pageData.FilterAction,
+	}
+	if pageData.Result != nil {
+		viewModel.HasResult = true
+		viewModel.Members = pageData.Result.Members
+		viewModel.Filter = pageData.Result.Filter
+		viewModel.Matches = pageData.Result.Matches
+		viewModel.Entries = make([]consensusEntryViewModel, 0, len(pageData.Result.Entries))
+		for index, entry := range pageData.Result.Entries {
+			viewModel.Entries = append(viewModel.Entries, consensusEntryViewModel{
+				Rank:         index + 1,
+				Presentation: newAccountPresentation(entry.Record, ""),
+				FollowedBy:   entry.FollowedBy,
+				FollowsBack:  entry.FollowsBack,
+				BlockedBy:    entry.BlockedBy,
+				MutedBy:      entry.MutedBy,
+			})
+		}
+	}
+	tmpl, err := parseTemplates(embeddedFS, templateConsensusFile)
+	if err != nil {
+		return "", fmt.Errorf("template parse: %w", err)
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buffer, templateConsensusName, viewModel); err != nil {
+		return "", fmt.Errorf("template execute: %w", err)
+	}
+	return buffer.String(), nil
+}
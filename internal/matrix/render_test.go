@@ -3,7 +3,9 @@ package matrix_test
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
 )
 
@@ -73,3 +75,95 @@ func TestRenderComparisonPageWithComparisonData(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderComparisonPageMarksForeignLabels(t *testing.T) {
+	observedAt := time.Date(2024, time.March, 2, 12, 0, 0, 0, time.UTC)
+	resolvedRecord := matrix.AccountRecord{AccountID: "10"}.WithUserName("resolved_handle", handles.FieldProvenance{Source: handles.LabelSourceLiveFetch, ObservedAt: observedAt})
+	borrowedRecord := matrix.AccountRecord{AccountID: "11"}.WithUserName("borrowed_handle", handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "2", ObservedAt: observedAt})
+	ownRecord := matrix.AccountRecord{AccountID: "12"}.WithUserName("own_handle", handles.FieldProvenance{Source: handles.LabelSourceArchive, Origin: "1", ObservedAt: observedAt})
+	comparison := matrix.ComparisonResult{
+		AccountSetsA:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		AccountSetsB:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		OwnerA:        matrix.OwnerIdentity{AccountID: "1", UserName: "owner_a"},
+		OwnerB:        matrix.OwnerIdentity{AccountID: "2", UserName: "owner_b"},
+		OwnerAFriends: []matrix.AccountRecord{resolvedRecord, borrowedRecord, ownRecord},
+	}
+
+	html, err := matrix.RenderComparisonPage(matrix.ComparisonPageData{Comparison: &comparison})
+	if err != nil {
+		t.Fatalf("RenderComparisonPage returned error: %v", err)
+	}
+
+	expectedSnippets := []string{
+		`title="Handle resolved by live fetch on 2024-03-02">resolved</span>`,
+		`title="Handle from another archive on 2024-03-02">from other archive</span>`,
+	}
+	for _, snippet := range expectedSnippets {
+		if !strings.Contains(html, snippet) {
+			t.Fatalf("expected HTML to contain %q", snippet)
+		}
+	}
+	if count := strings.Count(html, `<span class="provenance-marker small"`); count != 2 {
+		t.Fatalf("expected 2 provenance markers, got %d", count)
+	}
+}
+
+func TestRenderComparisonPageShowsFormerHandles(t *testing.T) {
+	renamedRecord := matrix.AccountRecord{AccountID: "10", UserName: "new_name"}.WithFormerUserNames("old_name", "older_name")
+	comparison := matrix.ComparisonResult{
+		AccountSetsA:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		AccountSetsB:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		OwnerAFriends: []matrix.AccountRecord{renamedRecord},
+	}
+
+	html, err := matrix.RenderComparisonPage(matrix.ComparisonPageData{Comparison: &comparison})
+	if err != nil {
+		t.Fatalf("RenderComparisonPage returned error: %v", err)
+	}
+	if snippet := `<span class="former-handles small">formerly @old_name, @older_name</span>`; !strings.Contains(html, snippet) {
+		t.Fatalf("expected HTML to contain %q", snippet)
+	}
+}
+
+func TestRenderComparisonPageShowsAvatarAndLock(t *testing.T) {
+	lockedRecord := matrix.AccountRecord{AccountID: "20", UserName: "locked", DisplayName: "Locked"}.WithProfile(handles.Profile{
+		AvatarURL: "https://pbs.twimg.com/profile_images/20/locked_normal.jpg",
+		Protected: true,
+	})
+	plainRecord := matrix.AccountRecord{AccountID: "21", UserName: "plain", DisplayName: "Plain"}
+	comparison := matrix.ComparisonResult{
+		AccountSetsA:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		AccountSetsB:  matrix.AccountSets{Muted: map[string]bool{}, Blocked: map[string]bool{}},
+		OwnerA:        matrix.OwnerIdentity{AccountID: "1", UserName: "owner_a"},
+		OwnerB:        matrix.OwnerIdentity{AccountID: "2", UserName: "owner_b"},
+		Options:       matrix.ComparisonOptions{Filter: matrix.AccountFilterProtected},
+		OwnerAFriends: []matrix.AccountRecord{lockedRecord, plainRecord},
+	}
+
+	html, err := matrix.RenderComparisonPage(matrix.ComparisonPageData{
+		Comparison:        &comparison,
+		BucketsEndpoint:   "/api/buckets",
+		JobEventsEndpoint: "/api/jobs/events",
+		ExportsEndpoint:   "/api/exports",
+	})
+	if err != nil {
+		t.Fatalf("RenderComparisonPage returned error: %v", err)
+	}
+
+	expectedSnippets := []string{
+		`data-job-events-endpoint="/api/jobs/events"`,
+		`data-exports-endpoint="/api/exports"`,
+		`<img class="account-avatar" src="https://pbs.twimg.com/profile_images/20/locked_normal.jpg"`,
+		`Locked <span class="protected-lock"`,
+		`<option value="protected" selected>Protected</option>`,
+		`data-filter="protected"`,
+	}
+	for _, snippet := range expectedSnippets {
+		if !strings.Contains(html, snippet) {
+			t.Fatalf("expected HTML to contain %q", snippet)
+		}
+	}
+	if count := strings.Count(html, `<span class="protected-lock"`); count != 1 {
+		t.Fatalf("expected 1 protected lock, got %d", count)
+	}
+}
@@ -0,0 +1,176 @@
+package matrix
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// SortMode selects the ordering applied to account buckets.
+type SortMode string
+
+const (
+	// SortModeDisplayName orders accounts by display name, falling back to handle and identifier.
+	SortModeDisplayName SortMode = "name"
+	// SortModeHandle orders accounts by handle; accounts without a handle are listed last.
+	SortModeHandle SortMode = "handle"
+	// SortModeAccountID orders accounts by their numeric identifier.
+	SortModeAccountID SortMode = "id"
+	// SortModeRecency orders accounts by their position in the export, most recent first.
+	SortModeRecency SortMode = "recency"
+
+	// DefaultSortMode is applied when no sort mode is configured.
+	DefaultSortMode = SortModeDisplayName
+
+	errMessageUnknownSortMode = "unknown sort mode"
+	errMessageInvalidLocale   = "invalid sort locale"
+)
+
+var supportedSortModes = []SortMode{SortModeDisplayName, SortModeHandle, SortModeAccountID, SortModeRecency}
+
+// SortModes lists the supported sort modes in presentation order.
+func SortModes() []SortMode {
+	return append([]SortMode(nil), supportedSortModes...)
+}
+
+// ParseSortMode converts user input into a SortMode, defaulting to DefaultSortMode when empty.
+func ParseSortMode(value string) (SortMode, error) {
+	normalized := SortMode(strings.ToLower(strings.TrimSpace(value)))
+	if normalized == "" {
+		return DefaultSortMode, nil
+	}
+	for _, mode := range supportedSortModes {
+		if mode == normalized {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %q", errMessageUnknownSortMode, value)
+}
+
+// ParseSortLocale validates a BCP 47 locale used for collation; empty input selects the root collation.
+func ParseSortLocale(value string) (language.Tag, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return language.Und, nil
+	}
+	tag, err := language.Parse(trimmed)
+	if err != nil {
+		return language.Und, fmt.Errorf("%s %q: %w", errMessageInvalidLocale, value, err)
+	}
+	return tag, nil
+}
+
+// SortAccountRecords orders records in place using the supplied options and export positions.
+func SortAccountRecords(records []AccountRecord, options ComparisonOptions, exportOrder map[string]int) {
+	newRecordSorter(options, exportOrder).Sort(records)
+}
+
+type recordSorter struct {
+	mode        SortMode
+	collator    *collate.Collator
+	exportOrder map[string]int
+}
+
+func newRecordSorter(options ComparisonOptions, exportOrder map[string]int) recordSorter {
+	mode, err := ParseSortMode(string(options.SortMode))
+	if err != nil {
+		mode = DefaultSortMode
+	}
+	tag, err := ParseSortLocale(options.Locale)
+	if err != nil {
+		tag = language.Und
+	}
+	return recordSorter{
+		mode:        mode,
+		collator:    collate.New(tag, collate.IgnoreCase),
+		exportOrder: exportOrder,
+	}
+}
+
+// Sort orders records deterministically, breaking ties by numeric account identifier.
+func (sorter recordSorter) Sort(records []AccountRecord) {
+	sort.SliceStable(records, func(firstIndex, secondIndex int) bool {
+		comparison := sorter.compare(records[firstIndex], records[secondIndex])
+		if comparison == 0 {
+			comparison = compareAccountIDs(records[firstIndex].AccountID, records[secondIndex].AccountID)
+		}
+		return comparison < 0
+	})
+}
+
+func (sorter recordSorter) compare(first AccountRecord, second AccountRecord) int {
+	switch sorter.mode {
+	case SortModeHandle:
+		return sorter.compareText(strings.TrimSpace(first.UserName), strings.TrimSpace(second.UserName))
+	case SortModeAccountID:
+		return 0
+	case SortModeRecency:
+		return compareExportPositions(sorter.exportOrder, first.AccountID, second.AccountID)
+	default:
+		return sorter.compareText(recordSortKey(first), recordSortKey(second))
+	}
+}
+
+// compareText collates two labels, placing empty labels after populated ones.
+func (sorter recordSorter) compareText(first string, second string) int {
+	switch {
+	case first == "" && second == "":
+		return 0
+	case first == "":
+		return 1
+	case second == "":
+		return -1
+	}
+	return sorter.collator.CompareString(first, second)
+}
+
+func compareExportPositions(exportOrder map[string]int, firstAccountID string, secondAccountID string) int {
+	firstPosition, firstFound := exportOrder[firstAccountID]
+	secondPosition, secondFound := exportOrder[secondAccountID]
+	switch {
+	case firstFound && secondFound:
+		return firstPosition - secondPosition
+	case firstFound:
+		return -1
+	case secondFound:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareAccountIDs orders numeric identifiers by value without parsing them into fixed-width integers.
+func compareAccountIDs(first string, second string) int {
+	firstTrimmed := strings.TrimLeft(first, "0")
+	secondTrimmed := strings.TrimLeft(second, "0")
+	firstNumeric := isNumericAccountID(first)
+	secondNumeric := isNumericAccountID(second)
+	switch {
+	case firstNumeric && secondNumeric:
+		if len(firstTrimmed) != len(secondTrimmed) {
+			return len(firstTrimmed) - len(secondTrimmed)
+		}
+		return strings.Compare(firstTrimmed, secondTrimmed)
+	case firstNumeric:
+		return -1
+	case secondNumeric:
+		return 1
+	default:
+		return strings.Compare(first, second)
+	}
+}
+
+func isNumericAccountID(accountID string) bool {
+	if accountID == "" {
+		return false
+	}
+	for _, character := range accountID {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package matrix_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+func TestSortAccountRecords(t *testing.T) {
+	accentedRecord := matrix.AccountRecord{AccountID: "30", UserName: "emile", DisplayName: "Émile"}
+	lowercaseRecord := matrix.AccountRecord{AccountID: "20", UserName: "zed", DisplayName: "alice"}
+	uppercaseRecord := matrix.AccountRecord{AccountID: "100", UserName: "bob", DisplayName: "Zoë"}
+	duplicateNameHigh := matrix.AccountRecord{AccountID: "10", DisplayName: "Same"}
+	duplicateNameLow := matrix.AccountRecord{AccountID: "9", DisplayName: "Same"}
+	exportOrder := map[string]int{"100": 0, "30": 1, "9": 2}
+
+	testCases := []struct {
+		name        string
+		options     matrix.ComparisonOptions
+		records     []matrix.AccountRecord
+		expectedIDs []string
+	}{
+		{
+			name:        "display name uses collation instead of byte order",
+			options:     matrix.ComparisonOptions{SortMode: matrix.SortModeDisplayName},
+			records:     []matrix.AccountRecord{uppercaseRecord, accentedRecord, lowercaseRecord},
+			expectedIDs: []string{"20", "30", "100"},
+		},
+		{
+			name:        "equal keys break ties by numeric identifier",
+			options:     matrix.ComparisonOptions{},
+			records:     []matrix.AccountRecord{duplicateNameHigh, duplicateNameLow},
+			expectedIDs: []string{"9", "10"},
+		},
+		{
+			name:        "handle ordering lists missing handles last",
+			options:     matrix.ComparisonOptions{SortMode: matrix.SortModeHandle},
+			records:     []matrix.AccountRecord{duplicateNameLow, lowercaseRecord, uppercaseRecord, accentedRecord},
+			expectedIDs: []string{"100", "30", "20", "9"},
+		},
+		{
+			name:        "account identifier ordering is numeric",
+			options:     matrix.ComparisonOptions{SortMode: matrix.SortModeAccountID},
+			records:     []matrix.AccountRecord{uppercaseRecord, duplicateNameLow, lowercaseRecord},
+			expectedIDs: []string{"9", "20", "100"},
+		},
+		{
+			name:        "recency follows export positions and lists unknown positions last",
+			options:     matrix.ComparisonOptions{SortMode: matrix.SortModeRecency},
+			records:     []matrix.AccountRecord{lowercaseRecord, duplicateNameLow, accentedRecord, uppercaseRecord},
+			expectedIDs: []string{"100", "30", "9", "20"},
+		},
+		{
+			name:        "swedish locale collates accented letters after z",
+			options:     matrix.ComparisonOptions{SortMode: matrix.SortModeDisplayName, Locale: "sv"},
+			records:     []matrix.AccountRecord{{AccountID: "1", DisplayName: "Öberg"}, {AccountID: "2", DisplayName: "Zander"}},
+			expectedIDs: []string{"2", "1"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			records := append([]matrix.AccountRecord(nil), testCase.records...)
+			matrix.SortAccountRecords(records, testCase.options, exportOrder)
+			assertIDsEqual(t, testCase.name, records, testCase.expectedIDs)
+		})
+	}
+}
+
+func TestParseSortMode(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expectedMode matrix.SortMode
+		expectError  bool
+	}{
+		{name: "empty selects default", input: "", expectedMode: matrix.DefaultSortMode},
+		{name: "case insensitive", input: " Recency ", expectedMode: matrix.SortModeRecency},
+		{name: "unknown mode", input: "followers", expectError: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			mode, err := matrix.ParseSortMode(testCase.input)
+			if testCase.expectError {
+				if err == nil {
+					t.Fatalf("expected error for %q", testCase.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if mode != testCase.expectedMode {
+				t.Fatalf("expected mode %q, got %q", testCase.expectedMode, mode)
+			}
+		})
+	}
+}
+
+func TestPaginateRecords(t *testing.T) {
+	records := []matrix.AccountRecord{{AccountID: "1"}, {AccountID: "2"}, {AccountID: "3"}}
+
+	testCases := []struct {
+		name            string
+		offset          int
+		limit           int
+		expectedIDs     []string
+		expectedHasMore bool
+	}{
+		{name: "first page", offset: 0, limit: 2, expectedIDs: []string{"1", "2"}, expectedHasMore: true},
+		{name: "last page", offset: 2, limit: 2, expectedIDs: []string{"3"}, expectedHasMore: false},
+		{name: "unlimited", offset: 1, limit: 0, expectedIDs: []string{"2", "3"}, expectedHasMore: false},
+		{name: "offset past end", offset: 10, limit: 2, expectedIDs: []string{}, expectedHasMore: false},
+		{name: "maximum limit", offset: 1, limit: math.MaxInt, expectedIDs: []string{"2", "3"}, expectedHasMore: false},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			page := matrix.PaginateRecords(records, testCase.offset, testCase.limit)
+			assertIDsEqual(t, testCase.name, page.Records, testCase.expectedIDs)
+			if page.Total != len(records) {
+				t.Fatalf("expected total %d, got %d", len(records), page.Total)
+			}
+			if page.HasMore() != testCase.expectedHasMore {
+				t.Fatalf("expected HasMore %t, got %t", testCase.expectedHasMore, page.HasMore())
+			}
+		})
+	}
+}
@@ -14,45 +14,136 @@
     const ID_COMPARISON_OPERATION = "cmpOp";
     const ID_COMPARISON_OUTPUT = "cmpOut";
     const ID_COMPARISON_BUTTON = "runCmp";
+    const ID_SORT_FORM = "sortForm";
+    const ID_SORT_SELECT = "sortMode";
+    const ID_FILTER_SELECT = "accountFilter";
+    const ID_ACCOUNT_DETAIL_PANEL = "accountDetailPanel";
+    const ID_ACCOUNT_DETAIL_BODY = "accountDetailBody";
+    const ID_ACCOUNT_DETAIL_CLOSE = "accountDetailClose";
+    const ID_RESOLUTION_PROGRESS = "resolutionProgress";
+    const ID_RESOLUTION_PROGRESS_BAR = "resolutionProgressBar";
+    const ID_JOB_PROGRESS = "jobProgress";
+    const ID_COMPARISON_COLUMN = "comparisonColumn";
+    const ID_RESOLUTION_PROGRESS_TEXT = "resolutionProgressText";
 
     const ROUTE_UPLOADS = "/api/uploads";
+    const ROUTE_JOBS = "/api/jobs";
     const HTTP_METHOD_POST = "POST";
     const HTTP_METHOD_DELETE = "DELETE";
     const JSON_KEY_UPLOADS = "uploads";
     const JSON_KEY_ERROR = "error";
     const JSON_KEY_COMPARISON_READY = "comparisonReady";
+    const JSON_KEY_JOB = "job";
+    const RESOLUTION_STATE_RUNNING = "running";
+    const RESOLUTION_STATE_DONE = "done";
+    const RESOLUTION_POLL_INTERVAL_MS = 2000;
+    const JOB_POLL_INTERVAL_MS = 1000;
+    const JOB_EVENT_NAME = "job";
+    const JOB_STATE_RUNNING = "running";
+    const JOB_STATE_DONE = "done";
+    const JOB_STATE_FAILED = "failed";
+    const JOB_KIND_RESOLUTION = "resolution";
+    const JOB_KIND_LABELS = { "upload": "Reading archives", "resolution": "Resolving handles", "export": "Exporting accounts" };
+    const EXPORT_FORMAT_CSV = "csv";
+    const QUERY_FORMAT = "format";
 
     const CLASS_DROPZONE_ACTIVE = "is-dragover";
     const CLASS_SECTION_TOGGLE = "section-toggle";
     const CLASS_SECTION_CONTENT = "section-content";
     const CLASS_HIDDEN = "is-hidden";
+    const CLASS_LOAD_MORE = "load-more";
+    const CLASS_ACCOUNT_LIST = "account-list";
+    const CLASS_EXPORT_BUCKET = "export-bucket";
+    const CLASS_JOB_CANCEL = "job-cancel";
 
     const ATTRIBUTE_SECTION_TARGET = "data-section-id";
     const ATTRIBUTE_ARIA_CONTROLS = "aria-controls";
     const ATTRIBUTE_ARIA_EXPANDED = "aria-expanded";
+    const ATTRIBUTE_OWNER = "data-owner";
+    const ATTRIBUTE_BUCKET = "data-bucket";
+    const ATTRIBUTE_NEXT_OFFSET = "data-next-offset";
+    const ATTRIBUTE_TOTAL = "data-total";
+    const ATTRIBUTE_ACCOUNT_ID = "data-account-id";
+    const ATTRIBUTE_JOB_ID = "data-job-id";
 
     const VALUE_TRUE = "true";
     const VALUE_FALSE = "false";
 
     const TEXT_UPLOAD_GENERIC_ERROR = "Upload failed. Please verify the file format.";
     const TEXT_RESET_GENERIC_ERROR = "Reset failed. Please try again.";
+    const TEXT_EXPORT_GENERIC_ERROR = "Export failed. Please try again.";
+    const TEXT_EXPORT_BUTTON = "Export CSV";
+    const TEXT_CANCEL = "Cancel";
+    const TEXT_JOB_FALLBACK_LABEL = "Working";
     const TEXT_UPLOAD_PLACEHOLDER = "No archives uploaded yet.";
     const TEXT_UNKNOWN = "Unknown";
     const TEXT_HANDLE_PREFIX = "@";
     const TEXT_FOLLOW_BUTTON = "Follow";
     const TEXT_MUTED = "Muted";
     const TEXT_BLOCKED = "Blocked";
+    const ACCOUNT_STATUS_BADGES = { "suspended": "Suspended", "does-not-exist": "Deleted" };
+    const ACCOUNT_STATUS_PROTECTED = "protected";
+    const TEXT_PROTECTED_ACCOUNT = "Protected account";
+    const CLASS_ACCOUNT_AVATAR = "account-avatar";
+    const CLASS_PROTECTED_LOCK = "protected-lock";
+    const AVATAR_SIZE_PX = 32;
+    const GHOST_STATUSES = ["suspended", "does-not-exist"];
     const TEXT_NONE = "None";
     const TEXT_HIDE = "Hide";
     const TEXT_SHOW = "Show";
+    const TEXT_SHOW_MORE = "Show more";
+    const TEXT_LOAD_MORE_ERROR = "Unable to load more accounts.";
+    const TEXT_PROVENANCE_RESOLVED = "resolved";
+    const TEXT_PROVENANCE_BORROWED = "from other archive";
+    const TEXT_PROVENANCE_UNKNOWN_DATE = "an unknown date";
+    const TEXT_FORMER_HANDLES_PREFIX = "formerly ";
+    const TEXT_FORMER_HANDLES_JOINER = ", ";
+    const PROVENANCE_SOURCE_ARCHIVE = "archive";
+    const PROVENANCE_FIELD_LABELS = { userName: "Handle", displayName: "Display name" };
+    const PROVENANCE_SOURCE_DESCRIPTIONS = {
+        "archive": "from another archive",
+        "resolver-cache": "resolved from the resolver cache",
+        "live-fetch": "resolved by live fetch",
+    };
+    const CLASS_PROVENANCE_MARKER = "provenance-marker";
+    const CLASS_FORMER_HANDLES = "former-handles";
+    const TEXT_ACCOUNT_DETAIL_ERROR = "Unable to load account details.";
+    const TEXT_YES = "Yes";
+    const TEXT_NO = "No";
+    const RESOLVER_STATUS_ARCHIVE = "archive";
+    const RESOLVER_STATUS_RESOLVED = "resolved";
+    const RESOLVER_STATUS_UNRESOLVED = "unresolved";
+    const BUCKET_ORDER = ["friends", "leaders", "groupies", "following", "followers", "blocked", "blocked-following", "blocked-followers", "ghosts"];
+
+    const SORT_MODE_NAME = "name";
+    const SORT_MODE_HANDLE = "handle";
+    const SORT_MODE_ID = "id";
+    const SORT_MODE_RECENCY = "recency";
+    const QUERY_SORT = "sort";
+    const QUERY_FILTER = "filter";
+    const QUERY_OFFSET = "offset";
+    const QUERY_LIMIT = "limit";
+    const LOAD_MORE_PAGE_SIZE = 200;
+    const SECONDS_PER_MINUTE = 60;
 
     const PROFILE_BASE_URL = "https://twitter.com/";
     const PROFILE_ID_BASE_URL = "https://twitter.com/i/user/";
     const FOLLOW_SCREEN_NAME_URL = "https://twitter.com/intent/follow?screen_name=";
     const FOLLOW_ACCOUNT_ID_URL = "https://twitter.com/intent/user?user_id=";
 
+    // jobStates keeps the latest status of every job seen on this page, jobCallbacks the handlers waiting for jobs
+    // started here to finish, and activeJobIds the jobs seen running, whose completion the page reacts to.
+    const jobStates = new Map();
+    const jobCallbacks = new Map();
+    const activeJobIds = new Set();
+    let jobEventSource = null;
+    let accountDetailData = null;
+
     initializeUploadUI();
     initializeMatrixFeatures();
+    if (!followJobEvents()) {
+        pollResolutionProgress(false);
+    }
 
     function initializeUploadUI() {
         const fileInputElement = document.getElementById(ID_ARCHIVE_INPUT);
@@ -150,10 +241,22 @@
             }
             return response.json();
         }).then(body => {
-            const uploads = Array.isArray(body[JSON_KEY_UPLOADS]) ? body[JSON_KEY_UPLOADS] : [];
-            renderUploadsList(uploads, options.uploadsListElement, options.placeholderElement);
-            const comparisonReady = Boolean(body[JSON_KEY_COMPARISON_READY]);
-            updateCompareButton(options.compareButtonElement, comparisonReady);
+            followJob(body[JSON_KEY_JOB], job => {
+                if (job.state !== JOB_STATE_DONE) {
+                    if (job.state === JOB_STATE_FAILED) {
+                        setAlertMessage(options.alertContainerElement, job.error || TEXT_UPLOAD_GENERIC_ERROR, true);
+                    }
+                    return;
+                }
+                const result = job.result || {};
+                const uploads = Array.isArray(result[JSON_KEY_UPLOADS]) ? result[JSON_KEY_UPLOADS] : [];
+                renderUploadsList(uploads, options.uploadsListElement, options.placeholderElement);
+                const comparisonReady = Boolean(result[JSON_KEY_COMPARISON_READY]);
+                updateCompareButton(options.compareButtonElement, comparisonReady);
+                if (comparisonReady && !jobEventSource) {
+                    pollResolutionProgress(false);
+                }
+            });
         }).catch(error => {
             setAlertMessage(options.alertContainerElement, error.message || TEXT_UPLOAD_GENERIC_ERROR, true);
         });
@@ -244,8 +347,200 @@
         containerElement.appendChild(alert);
     }
 
+    // followJobEvents subscribes to the job event stream; it returns false when the page has no stream or the browser
+    // lacks Server-Sent Events, leaving the page to poll instead.
+    function followJobEvents() {
+        const endpoint = document.getElementById(ID_JOB_PROGRESS)?.dataset.jobEventsEndpoint || "";
+        if (!endpoint || typeof window.EventSource !== "function") {
+            return false;
+        }
+        jobEventSource = new EventSource(endpoint);
+        jobEventSource.addEventListener(JOB_EVENT_NAME, event => {
+            let job;
+            try {
+                job = JSON.parse(event.data);
+            } catch (error) {
+                return;
+            }
+            applyJobUpdate(job);
+        });
+        return true;
+    }
+
+    // followJob calls onFinish once the job leaves the running state, polling the job when no event stream is open.
+    function followJob(job, onFinish) {
+        if (!job || !job.id) {
+            onFinish({ state: JOB_STATE_FAILED });
+            return;
+        }
+        const known = jobStates.get(job.id) || job;
+        if (known.state !== JOB_STATE_RUNNING) {
+            onFinish(known);
+            return;
+        }
+        jobCallbacks.set(job.id, onFinish);
+        if (!jobEventSource) {
+            applyJobUpdate(known);
+            pollJob(job.id);
+        }
+    }
+
+    function pollJob(jobId) {
+        fetch(`${ROUTE_JOBS}/${encodeURIComponent(jobId)}`).then(response => {
+            if (!response.ok) {
+                throw new Error(response.statusText);
+            }
+            return response.json();
+        }).then(job => {
+            applyJobUpdate(job);
+            if (job.state === JOB_STATE_RUNNING) {
+                window.setTimeout(() => pollJob(jobId), JOB_POLL_INTERVAL_MS);
+            }
+        }).catch(() => applyJobUpdate({ id: jobId, state: JOB_STATE_FAILED }));
+    }
+
+    function applyJobUpdate(job) {
+        if (!job || !job.id) {
+            return;
+        }
+        jobStates.set(job.id, job);
+        if (job.state === JOB_STATE_RUNNING) {
+            activeJobIds.add(job.id);
+            renderJobProgress(job);
+            return;
+        }
+        const wasActive = activeJobIds.delete(job.id);
+        document.querySelector(`#${ID_JOB_PROGRESS} [${ATTRIBUTE_JOB_ID}="${CSS.escape(job.id)}"]`)?.remove();
+        const onFinish = jobCallbacks.get(job.id);
+        if (onFinish) {
+            jobCallbacks.delete(job.id);
+            onFinish(job);
+        }
+        if (wasActive && job.kind === JOB_KIND_RESOLUTION && job.state === JOB_STATE_DONE) {
+            rerenderComparison();
+        }
+    }
+
+    function renderJobProgress(job) {
+        const containerElement = document.getElementById(ID_JOB_PROGRESS);
+        if (!containerElement) {
+            return;
+        }
+        let jobElement = containerElement.querySelector(`[${ATTRIBUTE_JOB_ID}="${CSS.escape(job.id)}"]`);
+        if (!jobElement) {
+            jobElement = document.createElement("div");
+            jobElement.className = "mb-3";
+            jobElement.setAttribute(ATTRIBUTE_JOB_ID, job.id);
+            jobElement.innerHTML = `
+                <div class="d-flex justify-content-between align-items-center small text-muted mb-1 gap-2">
+                    <span>${escapeHTML(JOB_KIND_LABELS[job.kind] || TEXT_JOB_FALLBACK_LABEL)}</span>
+                    <span class="ms-auto" data-job-text></span>
+                    <button type="button" class="btn btn-link btn-sm p-0 ${CLASS_JOB_CANCEL}">${TEXT_CANCEL}</button>
+                </div>
+                <div class="progress" role="progressbar" aria-valuemin="0" aria-valuemax="100">
+                    <div class="progress-bar progress-bar-striped progress-bar-animated" style="width: 0%"></div>
+                </div>`;
+            jobElement.querySelector(`.${CLASS_JOB_CANCEL}`)?.addEventListener("click", () => {
+                fetch(`${ROUTE_JOBS}/${encodeURIComponent(job.id)}`, { method: HTTP_METHOD_DELETE }).catch(() => {});
+            });
+            containerElement.appendChild(jobElement);
+        }
+        const progress = job.progress || {};
+        const total = progress.total || 0;
+        const completed = progress.completed || 0;
+        const percent = total > 0 ? Math.round((completed / total) * 100) : 0;
+        const barElement = jobElement.querySelector(".progress-bar");
+        if (barElement) {
+            barElement.style.width = `${percent}%`;
+        }
+        jobElement.querySelector("[role=progressbar]")?.setAttribute("aria-valuenow", String(percent));
+        const textElement = jobElement.querySelector("[data-job-text]");
+        if (textElement) {
+            const failed = progress.failed ? `, ${progress.failed} failed` : "";
+            const skipped = progress.skipped ? `, ${progress.skipped} skipped` : "";
+            const eta = progress.etaSeconds > 0 ? ` · ETA ${formatDuration(progress.etaSeconds)}` : "";
+            textElement.textContent = total > 0 ? `${completed}/${total}${failed}${skipped}${eta}` : "";
+        }
+    }
+
+    // rerenderComparison swaps in the comparison of a freshly rendered page, falling back to a reload.
+    function rerenderComparison() {
+        fetch(window.location.href).then(response => {
+            if (!response.ok) {
+                throw new Error(response.statusText);
+            }
+            return response.text();
+        }).then(pageHTML => {
+            const nextDocument = new DOMParser().parseFromString(pageHTML, "text/html");
+            const nextColumn = nextDocument.getElementById(ID_COMPARISON_COLUMN);
+            const currentColumn = document.getElementById(ID_COMPARISON_COLUMN);
+            const nextMatrix = nextDocument.getElementById(ID_MATRIX_DATA);
+            const currentMatrix = document.getElementById(ID_MATRIX_DATA);
+            if (!nextColumn || !currentColumn || !nextMatrix || !currentMatrix) {
+                throw new Error(TEXT_UNKNOWN);
+            }
+            currentColumn.replaceWith(document.adoptNode(nextColumn));
+            currentMatrix.textContent = nextMatrix.textContent;
+            const nextPanel = nextDocument.getElementById(ID_ACCOUNT_DETAIL_PANEL);
+            const currentPanel = document.getElementById(ID_ACCOUNT_DETAIL_PANEL);
+            if (nextPanel && currentPanel) {
+                currentPanel.replaceWith(document.adoptNode(nextPanel));
+            } else if (nextPanel) {
+                document.querySelector("main")?.after(document.adoptNode(nextPanel));
+            }
+            initializeMatrixFeatures();
+        }).catch(() => window.location.reload());
+    }
+
+    function pollResolutionProgress(wasRunning) {
+        const endpoint = document.getElementById(ID_COMPARISON_PANEL)?.dataset.resolutionEndpoint || "";
+        const containerElement = document.getElementById(ID_RESOLUTION_PROGRESS);
+        if (!endpoint || !containerElement) {
+            return;
+        }
+        fetch(endpoint).then(response => response.ok ? response.json() : null).then(progress => {
+            if (!progress) {
+                return;
+            }
+            if (progress.state === RESOLUTION_STATE_RUNNING) {
+                renderResolutionProgress(containerElement, progress);
+                window.setTimeout(() => pollResolutionProgress(true), RESOLUTION_POLL_INTERVAL_MS);
+                return;
+            }
+            containerElement.classList.add(CLASS_HIDDEN);
+            if (wasRunning && progress.state === RESOLUTION_STATE_DONE) {
+                window.location.reload();
+            }
+        }).catch(() => containerElement.classList.add(CLASS_HIDDEN));
+    }
+
+    function renderResolutionProgress(containerElement, progress) {
+        const total = progress.total || 0;
+        const completed = progress.completed || 0;
+        const percent = total > 0 ? Math.round((completed / total) * 100) : 0;
+        const barElement = document.getElementById(ID_RESOLUTION_PROGRESS_BAR);
+        if (barElement) {
+            barElement.style.width = `${percent}%`;
+        }
+        containerElement.querySelector("[role=progressbar]")?.setAttribute("aria-valuenow", String(percent));
+        const textElement = document.getElementById(ID_RESOLUTION_PROGRESS_TEXT);
+        if (textElement) {
+            const eta = progress.etaSeconds > 0 ? ` · ETA ${formatDuration(progress.etaSeconds)}` : "";
+            const skipped = progress.skipped ? `, ${progress.skipped} skipped` : "";
+            textElement.textContent = `${completed}/${total} (${progress.failed || 0} failed${skipped})${eta}`;
+        }
+        containerElement.classList.remove(CLASS_HIDDEN);
+    }
+
+    function formatDuration(totalSeconds) {
+        const seconds = Math.round(totalSeconds);
+        const minutes = Math.floor(seconds / SECONDS_PER_MINUTE);
+        return minutes > 0 ? `${minutes}m ${seconds % SECONDS_PER_MINUTE}s` : `${seconds}s`;
+    }
+
     function initializeMatrixFeatures() {
         setupSectionToggles();
+        setupSortControls();
 
         const matrixElement = document.getElementById(ID_MATRIX_DATA);
         if (!matrixElement) {
@@ -265,6 +560,269 @@
             return;
         }
         initializeComparisonCalculator(matrixData);
+        setupLoadMoreButtons(matrixData);
+        setupExportButtons(matrixData);
+        setupAccountDetailPanel(matrixData);
+    }
+
+    // setupAccountDetailPanel may run again after the comparison is re-rendered; the document listener is installed
+    // once and always uses the latest matrix data and panel.
+    function setupAccountDetailPanel(data) {
+        const panelElement = document.getElementById(ID_ACCOUNT_DETAIL_PANEL);
+        const bodyElement = document.getElementById(ID_ACCOUNT_DETAIL_BODY);
+        if (!panelElement || !bodyElement) {
+            return;
+        }
+        document.getElementById(ID_ACCOUNT_DETAIL_CLOSE)?.addEventListener("click", () => panelElement.classList.add(CLASS_HIDDEN));
+        const listening = accountDetailData !== null;
+        accountDetailData = data;
+        if (listening) {
+            return;
+        }
+        document.addEventListener("click", event => {
+            const panelElement = document.getElementById(ID_ACCOUNT_DETAIL_PANEL);
+            const bodyElement = document.getElementById(ID_ACCOUNT_DETAIL_BODY);
+            const endpoint = document.getElementById(ID_COMPARISON_PANEL)?.dataset.accountsEndpoint || "";
+            if (!panelElement || !bodyElement) {
+                return;
+            }
+            const target = event.target instanceof Element ? event.target : null;
+            const accountElement = target?.closest(`[${ATTRIBUTE_ACCOUNT_ID}]`);
+            if (!accountElement || target.closest("a, button")) {
+                return;
+            }
+            const accountId = accountElement.getAttribute(ATTRIBUTE_ACCOUNT_ID);
+            if (!accountId) {
+                return;
+            }
+            panelElement.classList.remove(CLASS_HIDDEN);
+            if (!endpoint) {
+                bodyElement.innerHTML = renderAccountDetail(describeAccountLocally(accountId, accountDetailData));
+                return;
+            }
+            fetch(`${endpoint}/${encodeURIComponent(accountId)}`)
+                .then(response => response.ok ? response.json() : Promise.reject(new Error(response.statusText)))
+                .then(detail => {
+                    bodyElement.innerHTML = renderAccountDetail(detail);
+                })
+                .catch(() => {
+                    bodyElement.innerHTML = `<div class="alert alert-danger mb-0" role="alert">${TEXT_ACCOUNT_DETAIL_ERROR}</div>`;
+                });
+        });
+    }
+
+    // describeAccountLocally mirrors the account detail API from the embedded matrix data for static pages.
+    function describeAccountLocally(accountId, data) {
+        const owners = [["A", data.A, data.ownerA], ["B", data.B, data.ownerB]];
+        let record = null;
+        const relationships = owners.map(([slot, ownerData, ownerLabel]) => {
+            const following = (ownerData?.following || []).find(candidate => candidate.AccountID === accountId);
+            const follower = (ownerData?.followers || []).find(candidate => candidate.AccountID === accountId);
+            [following, follower].forEach(candidate => {
+                if (candidate && (!record || (!record.UserName && candidate.UserName))) {
+                    record = candidate;
+                }
+            });
+            const label = (ownerData?.labels || []).find(candidate => candidate.AccountID === accountId);
+            if (label && (!record || (!record.UserName && label.UserName))) {
+                record = label;
+            }
+            const follows = Boolean(following);
+            const followedBy = Boolean(follower);
+            const blocked = (ownerData?.blocked || []).includes(accountId);
+            const memberships = {
+                "friends": follows && followedBy,
+                "leaders": follows && !followedBy,
+                "groupies": followedBy && !follows,
+                "following": follows,
+                "followers": followedBy,
+                "blocked": blocked,
+                "blocked-following": blocked && follows,
+                "blocked-followers": blocked && followedBy,
+                "ghosts": (follows || followedBy) && GHOST_STATUSES.includes((following || follower).Status),
+            };
+            return {
+                slot,
+                owner: ownerLabel,
+                follows,
+                followedBy,
+                muted: (ownerData?.muted || []).includes(accountId),
+                blocked,
+                buckets: BUCKET_ORDER.filter(bucket => memberships[bucket]),
+            };
+        });
+        const resolvedRecord = record || { AccountID: accountId };
+        return { record: resolvedRecord, relationships, resolverStatus: localResolverStatus(resolvedRecord) };
+    }
+
+    function localResolverStatus(record) {
+        if (!record.UserName) {
+            return RESOLVER_STATUS_UNRESOLVED;
+        }
+        const source = record.Provenance?.userName?.source;
+        return source && source !== PROVENANCE_SOURCE_ARCHIVE ? RESOLVER_STATUS_RESOLVED : RESOLVER_STATUS_ARCHIVE;
+    }
+
+    function renderAccountDetail(detail) {
+        const record = detail.record || {};
+        const displayText = record.DisplayName?.trim() || record.UserName?.trim() || record.AccountID || TEXT_UNKNOWN;
+        const handleText = record.UserName ? `${TEXT_HANDLE_PREFIX}${record.UserName}` : "";
+        const formerText = formerHandlesText(record);
+        const formerHTML = formerText ? ` <span class="${CLASS_FORMER_HANDLES}">${escapeHTML(formerText)}</span>` : "";
+        const yesNo = value => value ? TEXT_YES : TEXT_NO;
+        const rows = (detail.relationships || []).map(relationship => `
+            <tr>
+                <th scope="row">${escapeHTML(relationship.owner || relationship.slot)}</th>
+                <td>${yesNo(relationship.follows)}</td>
+                <td>${yesNo(relationship.followedBy)}</td>
+                <td>${yesNo(relationship.muted)}</td>
+                <td>${yesNo(relationship.blocked)}</td>
+                <td>${escapeHTML((relationship.buckets || []).join(", ") || TEXT_NONE)}</td>
+                <td>${renderListLinks(relationship.memberOfLists)}</td>
+            </tr>`).join("");
+        const resolverError = detail.resolverError ? ` (${escapeHTML(detail.resolverError)})` : "";
+        return `
+            <p class="mb-1"><strong>${escapeHTML(displayText)}</strong> <span class="text-muted">${escapeHTML(handleText)}</span>${formerHTML}</p>
+            <p class="text-muted mb-2">ID ${escapeHTML(record.AccountID || "")} · labels: ${escapeHTML(detail.resolverStatus || "")}${resolverError}</p>
+            <table class="table table-sm mb-0">
+                <thead><tr><th scope="col">Owner</th><th scope="col">Follows</th><th scope="col">Followed by</th><th scope="col">Muted</th><th scope="col">Blocked</th><th scope="col">Buckets</th><th scope="col">On their lists</th></tr></thead>
+                <tbody>${rows}</tbody>
+            </table>`;
+    }
+
+    // renderListLinks links the lists an owner is on; details built from embedded page data carry no lists.
+    function renderListLinks(listURLs) {
+        if (!Array.isArray(listURLs)) {
+            return TEXT_UNKNOWN;
+        }
+        if (listURLs.length === 0) {
+            return TEXT_NONE;
+        }
+        return listURLs.map(listURL => {
+            const name = listURL.split("/").filter(Boolean).pop() || listURL;
+            return `<a href="${escapeHTML(listURL)}" target="_blank" rel="noopener">${escapeHTML(name)}</a>`;
+        }).join(", ");
+    }
+
+    function setupSortControls() {
+        const formElement = document.getElementById(ID_SORT_FORM);
+        if (!formElement) {
+            return;
+        }
+        [ID_SORT_SELECT, ID_FILTER_SELECT].forEach(selectId => {
+            document.getElementById(selectId)?.addEventListener("change", () => formElement.submit());
+        });
+    }
+
+    function setupLoadMoreButtons(data) {
+        const panelElement = document.getElementById(ID_COMPARISON_PANEL);
+        const endpoint = panelElement?.dataset.bucketsEndpoint || "";
+        if (!endpoint) {
+            return;
+        }
+        const sortMode = panelElement.dataset.sortMode || data.sortMode || SORT_MODE_NAME;
+        const filter = panelElement.dataset.filter || data.filter || "";
+        const ownerMeta = {
+            A: metaLookupForOwner(buildOwnerData(data.A)),
+            B: metaLookupForOwner(buildOwnerData(data.B)),
+        };
+        document.querySelectorAll(`.${CLASS_LOAD_MORE}`).forEach(button => {
+            button.addEventListener("click", () => {
+                const owner = button.getAttribute(ATTRIBUTE_OWNER);
+                const bucket = button.getAttribute(ATTRIBUTE_BUCKET);
+                const listElement = button.parentElement?.querySelector(`.${CLASS_ACCOUNT_LIST}[${ATTRIBUTE_OWNER}="${owner}"][${ATTRIBUTE_BUCKET}="${bucket}"]`);
+                if (!owner || !bucket || !listElement) {
+                    return;
+                }
+                loadMoreRecords(endpoint, sortMode, filter, owner, bucket, listElement, button, ownerMeta[owner]);
+            });
+        });
+    }
+
+    // setupExportButtons adds a button after every bucket that exports the whole bucket in an export job and downloads
+    // the file once the job finishes.
+    function setupExportButtons(data) {
+        const panelElement = document.getElementById(ID_COMPARISON_PANEL);
+        const endpoint = panelElement?.dataset.exportsEndpoint || "";
+        if (!endpoint) {
+            return;
+        }
+        const query = new URLSearchParams();
+        query.set(QUERY_SORT, panelElement.dataset.sortMode || data.sortMode || SORT_MODE_NAME);
+        const filter = panelElement.dataset.filter || data.filter || "";
+        if (filter) {
+            query.set(QUERY_FILTER, filter);
+        }
+        query.set(QUERY_FORMAT, EXPORT_FORMAT_CSV);
+        const alertContainerElement = document.getElementById(ID_UPLOAD_ALERTS);
+        document.querySelectorAll(`.${CLASS_ACCOUNT_LIST}`).forEach(listElement => {
+            const owner = listElement.getAttribute(ATTRIBUTE_OWNER);
+            const bucket = listElement.getAttribute(ATTRIBUTE_BUCKET);
+            if (!owner || !bucket || !listElement.parentElement) {
+                return;
+            }
+            const button = document.createElement("button");
+            button.type = "button";
+            button.className = `btn btn-sm btn-outline-secondary ms-2 ${CLASS_EXPORT_BUCKET}`;
+            button.textContent = TEXT_EXPORT_BUTTON;
+            button.addEventListener("click", () => {
+                button.setAttribute("disabled", VALUE_TRUE);
+                fetch(`${endpoint}/${encodeURIComponent(owner)}/${encodeURIComponent(bucket)}?${query.toString()}`, {
+                    method: HTTP_METHOD_POST,
+                }).then(response => response.json().catch(() => ({})).then(body => {
+                    if (!response.ok) {
+                        throw new Error(body[JSON_KEY_ERROR] || TEXT_EXPORT_GENERIC_ERROR);
+                    }
+                    followJob(body[JSON_KEY_JOB], job => {
+                        button.removeAttribute("disabled");
+                        if (job.state === JOB_STATE_DONE && job.result?.download) {
+                            window.location.assign(job.result.download);
+                        } else if (job.state === JOB_STATE_FAILED) {
+                            setAlertMessage(alertContainerElement, job.error || TEXT_EXPORT_GENERIC_ERROR, true);
+                        }
+                    });
+                })).catch(error => {
+                    button.removeAttribute("disabled");
+                    setAlertMessage(alertContainerElement, error.message || TEXT_EXPORT_GENERIC_ERROR, true);
+                });
+            });
+            listElement.parentElement.appendChild(button);
+        });
+    }
+
+    function loadMoreRecords(endpoint, sortMode, filter, owner, bucket, listElement, button, metaSource) {
+        const offset = Number(listElement.getAttribute(ATTRIBUTE_NEXT_OFFSET)) || 0;
+        const query = new URLSearchParams();
+        query.set(QUERY_SORT, sortMode);
+        if (filter) {
+            query.set(QUERY_FILTER, filter);
+        }
+        query.set(QUERY_OFFSET, String(offset));
+        query.set(QUERY_LIMIT, String(LOAD_MORE_PAGE_SIZE));
+        button.setAttribute("disabled", VALUE_TRUE);
+        fetch(`${endpoint}/${encodeURIComponent(owner)}/${encodeURIComponent(bucket)}?${query.toString()}`).then(response => {
+            if (!response.ok) {
+                throw new Error(TEXT_LOAD_MORE_ERROR);
+            }
+            return response.json();
+        }).then(page => {
+            const records = Array.isArray(page.records) ? page.records : [];
+            const sources = metaSource ? [metaSource] : [];
+            listElement.insertAdjacentHTML("beforeend", records.map(record => renderAccountRecord(record, sources, false)).join(""));
+            const nextOffset = (Number(page.offset) || 0) + records.length;
+            const total = Number(page.total) || nextOffset;
+            listElement.setAttribute(ATTRIBUTE_NEXT_OFFSET, String(nextOffset));
+            listElement.setAttribute(ATTRIBUTE_TOTAL, String(total));
+            if (nextOffset >= total || records.length === 0) {
+                button.remove();
+                return;
+            }
+            button.textContent = `${TEXT_SHOW_MORE} (${nextOffset} of ${total})`;
+            button.removeAttribute("disabled");
+        }).catch(error => {
+            button.textContent = error.message || TEXT_LOAD_MORE_ERROR;
+            button.removeAttribute("disabled");
+        });
     }
 
     function setupSectionToggles() {
@@ -288,7 +846,7 @@
     function initializeComparisonCalculator(data) {
         const ownerAData = buildOwnerData(data.A);
         const ownerBData = buildOwnerData(data.B);
-        const metaContext = { A: ownerAData, B: ownerBData };
+        const metaContext = { A: ownerAData, B: ownerBData, compare: recordComparator(data.sortMode, data.locale, data) };
         const operationSelect = document.getElementById(ID_COMPARISON_OPERATION);
         const runButton = document.getElementById(ID_COMPARISON_BUTTON);
         const outputContainer = document.getElementById(ID_COMPARISON_OUTPUT);
@@ -310,6 +868,57 @@
             following: indexById(owner?.following || []),
             muted: new Set(owner?.muted || []),
             blocked: new Set(owner?.blocked || []),
+            origin: owner?.origin || "",
+        };
+    }
+
+    function recordComparator(sortMode, locale, data) {
+        const collator = new Intl.Collator(locale || undefined, { sensitivity: "accent" });
+        const positions = new Map();
+        [data.A?.following, data.A?.followers, data.B?.following, data.B?.followers].forEach(records => {
+            (records || []).forEach((record, index) => {
+                if (record && record.AccountID && !positions.has(record.AccountID)) {
+                    positions.set(record.AccountID, index);
+                }
+            });
+        });
+        const compareText = (first, second) => {
+            if (!first && !second) {
+                return 0;
+            }
+            if (!first) {
+                return 1;
+            }
+            if (!second) {
+                return -1;
+            }
+            return collator.compare(first, second);
+        };
+        const compareIDs = (first, second) => {
+            const firstID = first.AccountID || "";
+            const secondID = second.AccountID || "";
+            if (firstID.length !== secondID.length) {
+                return firstID.length - secondID.length;
+            }
+            return firstID < secondID ? -1 : firstID > secondID ? 1 : 0;
+        };
+        return (first, second) => {
+            let result = 0;
+            switch (sortMode) {
+                case SORT_MODE_HANDLE:
+                    result = compareText(first.UserName?.trim(), second.UserName?.trim());
+                    break;
+                case SORT_MODE_ID:
+                    result = 0;
+                    break;
+                case SORT_MODE_RECENCY:
+                    result = (positions.has(first.AccountID) ? positions.get(first.AccountID) : Number.MAX_SAFE_INTEGER)
+                        - (positions.has(second.AccountID) ? positions.get(second.AccountID) : Number.MAX_SAFE_INTEGER);
+                    break;
+                default:
+                    result = compareText(first.DisplayName || first.UserName || first.AccountID, second.DisplayName || second.UserName || second.AccountID);
+            }
+            return result !== 0 ? result : compareIDs(first, second);
         };
     }
 
@@ -396,11 +1005,7 @@
             return;
         }
         const records = Array.from(resultsMap.values());
-        records.sort((first, second) => {
-            const firstKey = (first.DisplayName || first.UserName || first.AccountID || "").toLowerCase();
-            const secondKey = (second.DisplayName || second.UserName || second.AccountID || "").toLowerCase();
-            return firstKey.localeCompare(secondKey);
-        });
+        records.sort(metaContext.compare);
         if (records.length === 0) {
             container.innerHTML = `<p class="text-muted fst-italic">${TEXT_NONE}</p>`;
             return;
@@ -432,6 +1037,7 @@
         const mutedSet = ownerData?.muted instanceof Set ? ownerData.muted : new Set();
         const blockedSet = ownerData?.blocked instanceof Set ? ownerData.blocked : new Set();
         return {
+            origin: ownerData?.origin || "",
             isMuted(accountId) {
                 return mutedSet.has(accountId);
             },
@@ -446,6 +1052,10 @@
         const displayText = record.DisplayName?.trim() || record.UserName?.trim() || record.AccountID || TEXT_UNKNOWN;
         const handleText = record.UserName ? `${TEXT_HANDLE_PREFIX}${record.UserName}` : "";
         const badges = [];
+        const statusBadge = ACCOUNT_STATUS_BADGES[record.Status];
+        if (statusBadge) {
+            badges.push(`<span class="badge text-bg-secondary me-2">${statusBadge}</span>`);
+        }
         if (metaSources.some(source => source.isMuted(record.AccountID))) {
             badges.push(`<span class="badge text-bg-warning me-2">${TEXT_MUTED}</span>`);
         }
@@ -460,7 +1070,49 @@
         }
         const badgeHTML = badges.length ? `<div class="mt-2">${badges.join(" ")}</div>` : "";
         const handleHTML = handleText ? `<span class="text-muted small">${escapeHTML(handleText)}</span>` : "";
-        return `<li class="mb-3 pb-3 border-bottom"><a class="text-decoration-none" target="_blank" rel="noopener" href="${profileURL}"><strong class="d-block">${escapeHTML(displayText)}</strong></a>${handleHTML}${badgeHTML}</li>`;
+        const formerText = formerHandlesText(record);
+        const formerHTML = formerText ? `<span class="${CLASS_FORMER_HANDLES} small">${escapeHTML(formerText)}</span>` : "";
+        const provenanceHTML = renderProvenanceMarker(record, metaSources.map(source => source.origin));
+        const avatarURL = record.Profile?.avatarURL?.trim() || "";
+        const avatarHTML = avatarURL
+            ? `<img class="${CLASS_ACCOUNT_AVATAR}" src="${escapeHTML(avatarURL)}" alt="" width="${AVATAR_SIZE_PX}" height="${AVATAR_SIZE_PX}" loading="lazy" referrerpolicy="no-referrer">`
+            : "";
+        const isProtected = record.Status === ACCOUNT_STATUS_PROTECTED || Boolean(record.Profile?.protected);
+        const lockHTML = isProtected
+            ? ` <span class="${CLASS_PROTECTED_LOCK}" title="${TEXT_PROTECTED_ACCOUNT}" aria-label="${TEXT_PROTECTED_ACCOUNT}">&#128274;</span>`
+            : "";
+        return `<li class="mb-3 pb-3 border-bottom d-flex gap-2" ${ATTRIBUTE_ACCOUNT_ID}="${escapeHTML(record.AccountID)}">${avatarHTML}<div class="d-flex flex-column"><a class="text-decoration-none" target="_blank" rel="noopener" href="${profileURL}"><strong class="d-block">${escapeHTML(displayText)}${lockHTML}</strong></a>${handleHTML}${formerHTML}${provenanceHTML}${badgeHTML}</div></li>`;
+    }
+
+    function formerHandlesText(record) {
+        const formerHandles = (record.FormerUserNames || []).map(userName => `${TEXT_HANDLE_PREFIX}${userName}`);
+        return formerHandles.length ? TEXT_FORMER_HANDLES_PREFIX + formerHandles.join(TEXT_FORMER_HANDLES_JOINER) : "";
+    }
+
+    function renderProvenanceMarker(record, ownerOrigins) {
+        const foreignFields = Object.keys(PROVENANCE_FIELD_LABELS).filter(field => {
+            const provenance = record.Provenance?.[field];
+            if (!provenance) {
+                return false;
+            }
+            if (provenance.source !== PROVENANCE_SOURCE_ARCHIVE) {
+                return true;
+            }
+            return Boolean(provenance.origin) && !ownerOrigins.includes(provenance.origin);
+        });
+        if (!foreignFields.length) {
+            return "";
+        }
+        const resolved = foreignFields.some(field => record.Provenance[field].source !== PROVENANCE_SOURCE_ARCHIVE);
+        const notes = foreignFields.map(field => {
+            const provenance = record.Provenance[field];
+            const observedAt = provenance.observedAt && !provenance.observedAt.startsWith("0001-")
+                ? provenance.observedAt.slice(0, 10)
+                : TEXT_PROVENANCE_UNKNOWN_DATE;
+            return `${PROVENANCE_FIELD_LABELS[field]} ${PROVENANCE_SOURCE_DESCRIPTIONS[provenance.source] || provenance.source} on ${observedAt}`;
+        });
+        const markerText = resolved ? TEXT_PROVENANCE_RESOLVED : TEXT_PROVENANCE_BORROWED;
+        return `<span class="${CLASS_PROVENANCE_MARKER} small" title="${escapeHTML(notes.join("; "))}">${markerText}</span>`;
     }
 
     function escapeHTML(input) {
@@ -0,0 +1,2 @@
+// Package ratelimit paces outbound requests and retries them when the remote side pushes back.
+package ratelimit
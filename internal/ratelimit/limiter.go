@@ -0,0 +1,360 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestsPerSecond is the steady pace used when Config.RequestsPerSecond is zero.
+	DefaultRequestsPerSecond = 2.0
+	// DefaultBurst is how many requests may be sent back to back when Config.Burst is zero.
+	DefaultBurst = 1
+	// DefaultMaxRetries is the retry budget of a single request when Config.MaxRetries is zero.
+	DefaultMaxRetries = 3
+	// DefaultMaxWait caps server-advised waits when Config.MaxWait is zero.
+	DefaultMaxWait = time.Minute
+	// DefaultRateLimitWait is how long a 429 response without Retry-After or x-rate-limit-reset is waited out.
+	DefaultRateLimitWait = 30 * time.Second
+	// DefaultBaseBackoff is the first backoff after a network error or 5xx response when Config.BaseBackoff is zero.
+	DefaultBaseBackoff = 500 * time.Millisecond
+	// DefaultMaxBackoff caps exponential backoff when Config.MaxBackoff is zero.
+	DefaultMaxBackoff = 8 * time.Second
+	// DefaultSlowdownFactor multiplies the pace after each 429 when Config.SlowdownFactor is zero.
+	DefaultSlowdownFactor = 0.5
+
+	retryAfterHeader      = "Retry-After"
+	rateLimitResetHeader  = "x-rate-limit-reset"
+	recoveryStepFraction  = 0.1
+	minimumPaceDivisor    = 16
+	bodyDrainLimit        = 4096
+	errMessageRateLimited = "rate limited"
+	errMessageNoReplay    = "request body cannot be replayed"
+	errWaitExceedsFormat  = "%w for %s, longer than the %s limit"
+	errRetriesSpentFormat = "%w after %d retries"
+)
+
+var (
+	// ErrRateLimited reports a request refused because the remote side asked for a longer pause than allowed or kept
+	// refusing past the retry budget.
+	ErrRateLimited = errors.New(errMessageRateLimited)
+
+	errNoReplay = errors.New(errMessageNoReplay)
+)
+
+// Config configures a Limiter.
+type Config struct {
+	// RequestsPerSecond is the steady pace; DefaultRequestsPerSecond applies when zero and negative disables pacing.
+	RequestsPerSecond float64
+	// Burst is how many requests may be sent back to back; DefaultBurst applies when zero.
+	Burst int
+	// MaxRetries bounds how often one request is retried after a 429, a 5xx response, or a network error;
+	// DefaultMaxRetries applies when zero and negative disables retries.
+	MaxRetries int
+	// MaxWait caps how long a server-advised pause is waited out before failing with ErrRateLimited; DefaultMaxWait
+	// applies when zero.
+	MaxWait time.Duration
+	// BaseBackoff and MaxBackoff bound the exponential backoff between retries of network errors and 5xx responses.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// SlowdownFactor multiplies the pace after each 429 and must lie between zero and one; DefaultSlowdownFactor
+	// applies otherwise. The pace recovers gradually with each successful response.
+	SlowdownFactor float64
+	// OnRetry, when set, is called before each retry.
+	OnRetry func(Retry)
+}
+
+// Retry describes a retry about to happen.
+type Retry struct {
+	Attempt    int
+	Wait       time.Duration
+	StatusCode int
+	Err        error
+}
+
+// Limiter paces requests with a token bucket, pauses every caller when the remote side returns 429, and slows its
+// pace until requests succeed again. A Limiter is safe for concurrent use and is meant to be shared by every request
+// sent to the same service.
+type Limiter struct {
+	targetRate     float64
+	burst          float64
+	maxRetries     int
+	maxWait        time.Duration
+	baseBackoff    time.Duration
+	maxBackoff     time.Duration
+	slowdownFactor float64
+	onRetry        func(Retry)
+
+	mutex       sync.Mutex
+	rate        float64
+	tokens      float64
+	refilledAt  time.Time
+	pausedUntil time.Time
+}
+
+// New constructs a Limiter from configuration values.
+func New(configuration Config) *Limiter {
+	targetRate := configuration.RequestsPerSecond
+	if targetRate == 0 {
+		targetRate = DefaultRequestsPerSecond
+	}
+	burst := configuration.Burst
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	maxRetries := configuration.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	} else if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	maxWait := configuration.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	baseBackoff := configuration.BaseBackoff
+	if baseBackoff <= 0 {
+		baseBackoff = DefaultBaseBackoff
+	}
+	maxBackoff := configuration.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	slowdownFactor := configuration.SlowdownFactor
+	if slowdownFactor <= 0 || slowdownFactor >= 1 {
+		slowdownFactor = DefaultSlowdownFactor
+	}
+	return &Limiter{
+		targetRate:     targetRate,
+		burst:          float64(burst),
+		maxRetries:     maxRetries,
+		maxWait:        maxWait,
+		baseBackoff:    baseBackoff,
+		maxBackoff:     maxBackoff,
+		slowdownFactor: slowdownFactor,
+		onRetry:        configuration.OnRetry,
+		rate:           targetRate,
+		tokens:         float64(burst),
+		refilledAt:     time.Now(),
+	}
+}
+
+// Wait blocks until the next request may be sent. It fails with ErrRateLimited without waiting when the limiter is
+// paused for longer than MaxWait.
+func (limiter *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay, reserveErr := limiter.reserve(time.Now())
+		if reserveErr != nil {
+			return reserveErr
+		}
+		if delay <= 0 {
+			return ctx.Err()
+		}
+		if sleepErr := Sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
+
+// Pause stops every caller for wait and slows the pace, as a 429 response demands.
+func (limiter *Limiter) Pause(wait time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if resumeAt := time.Now().Add(wait); resumeAt.After(limiter.pausedUntil) {
+		limiter.pausedUntil = resumeAt
+	}
+	if limiter.targetRate > 0 {
+		limiter.rate = max(limiter.targetRate/minimumPaceDivisor, limiter.rate*limiter.slowdownFactor)
+		limiter.tokens = 0
+	}
+}
+
+// RecordSuccess lets a slowed pace recover a step toward the configured rate.
+func (limiter *Limiter) RecordSuccess() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if limiter.targetRate > 0 && limiter.rate < limiter.targetRate {
+		limiter.rate = min(limiter.targetRate, limiter.rate+limiter.targetRate*recoveryStepFraction)
+	}
+}
+
+// Rate reports the current pace in requests per second; it is negative when pacing is disabled.
+func (limiter *Limiter) Rate() float64 {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return limiter.rate
+}
+
+// MaxWait reports the longest server-advised pause the limiter waits out.
+func (limiter *Limiter) MaxWait() time.Duration {
+	return limiter.maxWait
+}
+
+// Backoff returns the pause before retry number attempt: exponential from BaseBackoff, capped at MaxBackoff, with
+// the upper half randomized so concurrent callers spread out.
+func (limiter *Limiter) Backoff(attempt int) time.Duration {
+	backoff := limiter.maxBackoff
+	if attempt >= 1 && attempt < 32 {
+		backoff = min(limiter.maxBackoff, limiter.baseBackoff<<(attempt-1))
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// Do sends request, pacing every attempt and retrying 429 responses, 5xx responses, and network errors within the
+// retry budget. Other responses are returned unread. A 429 whose advised pause exceeds MaxWait, or that persists past
+// the budget, fails with ErrRateLimited; once the budget is spent a 5xx response is returned as is.
+func (limiter *Limiter) Do(client *http.Client, request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	for attempt := 1; ; attempt++ {
+		if waitErr := limiter.Wait(ctx); waitErr != nil {
+			return nil, waitErr
+		}
+		attemptRequest, replayErr := replayableRequest(request, attempt)
+		if replayErr != nil {
+			return nil, replayErr
+		}
+		response, doErr := client.Do(attemptRequest)
+		retriesLeft := attempt <= limiter.maxRetries
+
+		switch {
+		case doErr != nil:
+			if ctx.Err() != nil || !retriesLeft {
+				return nil, doErr
+			}
+			if sleepErr := limiter.backoffRetry(ctx, Retry{Attempt: attempt, Err: doErr}); sleepErr != nil {
+				return nil, sleepErr
+			}
+		case response.StatusCode == http.StatusTooManyRequests:
+			drainAndClose(response.Body)
+			wait, advised := AdvisedWait(response.Header, time.Now())
+			if !advised {
+				wait = DefaultRateLimitWait
+			}
+			limiter.Pause(wait)
+			if wait > limiter.maxWait {
+				return nil, fmt.Errorf(errWaitExceedsFormat, ErrRateLimited, wait, limiter.maxWait)
+			}
+			if !retriesLeft {
+				return nil, fmt.Errorf(errRetriesSpentFormat, ErrRateLimited, limiter.maxRetries)
+			}
+			limiter.notify(Retry{Attempt: attempt, Wait: wait, StatusCode: response.StatusCode})
+		case response.StatusCode >= http.StatusInternalServerError && retriesLeft:
+			drainAndClose(response.Body)
+			if sleepErr := limiter.backoffRetry(ctx, Retry{Attempt: attempt, StatusCode: response.StatusCode}); sleepErr != nil {
+				return nil, sleepErr
+			}
+		default:
+			if response.StatusCode < http.StatusInternalServerError {
+				limiter.RecordSuccess()
+			}
+			return response, nil
+		}
+	}
+}
+
+// AdvisedWait reads Retry-After (seconds or HTTP date) or x-rate-limit-reset (Unix seconds) and reports how long
+// the server asked callers to back off. A reset time in the past yields zero.
+func AdvisedWait(header http.Header, now time.Time) (time.Duration, bool) {
+	if retryAfter := strings.TrimSpace(header.Get(retryAfterHeader)); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if retryAt, err := http.ParseTime(retryAfter); err == nil {
+			return nonNegative(retryAt.Sub(now)), true
+		}
+	}
+	if reset := strings.TrimSpace(header.Get(rateLimitResetHeader)); reset != "" {
+		if epochSeconds, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			return nonNegative(time.Unix(epochSeconds, 0).Sub(now)), true
+		}
+	}
+	return 0, false
+}
+
+// Sleep pauses for duration or until ctx is done.
+func Sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token when one is available and otherwise reports how long to wait for the next one.
+func (limiter *Limiter) reserve(now time.Time) (time.Duration, error) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if paused := limiter.pausedUntil.Sub(now); paused > 0 {
+		if paused > limiter.maxWait {
+			return 0, fmt.Errorf(errWaitExceedsFormat, ErrRateLimited, paused.Round(time.Second), limiter.maxWait)
+		}
+		return paused, nil
+	}
+	if limiter.rate <= 0 {
+		return 0, nil
+	}
+	elapsed := now.Sub(limiter.refilledAt).Seconds()
+	limiter.tokens = min(limiter.burst, limiter.tokens+elapsed*limiter.rate)
+	limiter.refilledAt = now
+	if limiter.tokens >= 1 {
+		limiter.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - limiter.tokens) / limiter.rate * float64(time.Second)), nil
+}
+
+func (limiter *Limiter) backoffRetry(ctx context.Context, retry Retry) error {
+	retry.Wait = limiter.Backoff(retry.Attempt)
+	limiter.notify(retry)
+	return Sleep(ctx, retry.Wait)
+}
+
+func (limiter *Limiter) notify(retry Retry) {
+	if limiter.onRetry != nil {
+		limiter.onRetry(retry)
+	}
+}
+
+// replayableRequest returns the request for an attempt, rewinding its body for retries.
+func replayableRequest(request *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || request.Body == nil || request.Body == http.NoBody {
+		return request, nil
+	}
+	if request.GetBody == nil {
+		return nil, errNoReplay
+	}
+	body, bodyErr := request.GetBody()
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+	replay := request.Clone(request.Context())
+	replay.Body = body
+	return replay, nil
+}
+
+func nonNegative(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, bodyDrainLimit)
+	_ = body.Close()
+}
@@ -0,0 +1,246 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/f-sync/fsync/internal/ratelimit"
+)
+
+const (
+	limiterTestRequestsPerSecond = 4.0
+	limiterTestPostBody          = "grant_type=refresh_token"
+	limiterTestLongRetryAfter    = "120"
+	limiterTestShortBackoff      = time.Millisecond
+)
+
+func TestAdvisedWait(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name           string
+		headers        map[string]string
+		expectedWait   time.Duration
+		expectedAdvice bool
+	}{
+		{name: "retry after seconds", headers: map[string]string{"Retry-After": "7"}, expectedWait: 7 * time.Second, expectedAdvice: true},
+		{name: "retry after http date", headers: map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, expectedWait: 90 * time.Second, expectedAdvice: true},
+		{name: "rate limit reset epoch", headers: map[string]string{"x-rate-limit-reset": strconv.FormatInt(now.Add(5*time.Minute).Unix(), 10)}, expectedWait: 5 * time.Minute, expectedAdvice: true},
+		{name: "reset in the past", headers: map[string]string{"x-rate-limit-reset": strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)}, expectedWait: 0, expectedAdvice: true},
+		{name: "retry after wins over reset", headers: map[string]string{"Retry-After": "3", "x-rate-limit-reset": strconv.FormatInt(now.Add(time.Hour).Unix(), 10)}, expectedWait: 3 * time.Second, expectedAdvice: true},
+		{name: "malformed headers give no advice", headers: map[string]string{"Retry-After": "soon", "x-rate-limit-reset": "later"}},
+		{name: "no headers give no advice", headers: map[string]string{}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range testCase.headers {
+				header.Set(key, value)
+			}
+			wait, advised := ratelimit.AdvisedWait(header, now)
+			if wait != testCase.expectedWait || advised != testCase.expectedAdvice {
+				t.Fatalf("expected %s (%t), got %s (%t)", testCase.expectedWait, testCase.expectedAdvice, wait, advised)
+			}
+		})
+	}
+}
+
+func TestBackoffGrowsWithJitterWithinBounds(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	testCases := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 1, ceiling: 100 * time.Millisecond},
+		{attempt: 2, ceiling: 200 * time.Millisecond},
+		{attempt: 4, ceiling: 800 * time.Millisecond},
+		{attempt: 8, ceiling: time.Second},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(strconv.Itoa(testCase.attempt), func(t *testing.T) {
+			for sample := 0; sample < 50; sample++ {
+				backoff := limiter.Backoff(testCase.attempt)
+				if backoff < testCase.ceiling/2 || backoff > testCase.ceiling {
+					t.Fatalf("attempt %d backoff %s outside [%s, %s]", testCase.attempt, backoff, testCase.ceiling/2, testCase.ceiling)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterPacesRequests(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerSecond: limiterTestRequestsPerSecond, Burst: 2})
+	start := time.Now()
+	for request := 0; request < 4; request++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// Two requests ride the burst and the next two wait a quarter second each.
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Fatalf("expected pacing to take about 500ms, took %s", elapsed)
+	}
+}
+
+func TestLimiterAdaptsToRateLimits(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerSecond: limiterTestRequestsPerSecond, MaxWait: time.Second})
+	limiter.Pause(0)
+	if rate := limiter.Rate(); rate != limiterTestRequestsPerSecond/2 {
+		t.Fatalf("expected pace to halve after a 429, got %v", rate)
+	}
+	for success := 0; success < 10; success++ {
+		limiter.RecordSuccess()
+	}
+	if rate := limiter.Rate(); rate != limiterTestRequestsPerSecond {
+		t.Fatalf("expected pace to recover to %v, got %v", limiterTestRequestsPerSecond, rate)
+	}
+
+	limiter.Pause(time.Hour)
+	if err := limiter.Wait(context.Background()); !errors.Is(err, ratelimit.ErrRateLimited) {
+		t.Fatalf("expected a pause beyond MaxWait to fail fast, got %v", err)
+	}
+}
+
+func TestLimiterWaitHonorsCancellation(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{MaxWait: time.Hour})
+	limiter.Pause(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+type limiterTestServer struct {
+	mutex     sync.Mutex
+	calls     int
+	bodies    []string
+	responses []func(http.ResponseWriter)
+}
+
+func (server *limiterTestServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	body, _ := io.ReadAll(request.Body)
+	server.bodies = append(server.bodies, string(body))
+	respond := server.responses[min(server.calls, len(server.responses)-1)]
+	server.calls++
+	respond(writer)
+}
+
+func respondStatus(status int, headers map[string]string) func(http.ResponseWriter) {
+	return func(writer http.ResponseWriter) {
+		for key, value := range headers {
+			writer.Header().Set(key, value)
+		}
+		writer.WriteHeader(status)
+	}
+}
+
+func TestLimiterDoRetries(t *testing.T) {
+	testCases := []struct {
+		name             string
+		responses        []func(http.ResponseWriter)
+		maxRetries       int
+		expectedStatus   int
+		expectedSentinel error
+		expectedCalls    int
+	}{
+		{
+			name:           "retry after zero is retried",
+			responses:      []func(http.ResponseWriter){respondStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "0"}), respondStatus(http.StatusOK, nil)},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+		},
+		{
+			name:           "server errors back off and retry",
+			responses:      []func(http.ResponseWriter){respondStatus(http.StatusBadGateway, nil), respondStatus(http.StatusServiceUnavailable, nil), respondStatus(http.StatusOK, nil)},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  3,
+		},
+		{
+			name:             "long advised wait fails fast",
+			responses:        []func(http.ResponseWriter){respondStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": limiterTestLongRetryAfter})},
+			expectedSentinel: ratelimit.ErrRateLimited,
+			expectedCalls:    1,
+		},
+		{
+			name:             "retry budget is bounded",
+			responses:        []func(http.ResponseWriter){respondStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "0"})},
+			maxRetries:       2,
+			expectedSentinel: ratelimit.ErrRateLimited,
+			expectedCalls:    3,
+		},
+		{
+			name:           "exhausted server errors return the last response",
+			responses:      []func(http.ResponseWriter){respondStatus(http.StatusInternalServerError, nil)},
+			maxRetries:     1,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  2,
+		},
+		{
+			name:           "client errors are not retried",
+			responses:      []func(http.ResponseWriter){respondStatus(http.StatusNotFound, nil)},
+			expectedStatus: http.StatusNotFound,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			handler := &limiterTestServer{responses: testCase.responses}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			var retries []ratelimit.Retry
+			limiter := ratelimit.New(ratelimit.Config{
+				RequestsPerSecond: -1,
+				MaxRetries:        testCase.maxRetries,
+				BaseBackoff:       limiterTestShortBackoff,
+				MaxBackoff:        limiterTestShortBackoff,
+				OnRetry:           func(retry ratelimit.Retry) { retries = append(retries, retry) },
+			})
+			request, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(limiterTestPostBody))
+			if err != nil {
+				t.Fatalf("create request: %v", err)
+			}
+			response, doErr := limiter.Do(server.Client(), request)
+			if testCase.expectedSentinel != nil {
+				if !errors.Is(doErr, testCase.expectedSentinel) {
+					t.Fatalf("expected %v, got %v", testCase.expectedSentinel, doErr)
+				}
+			} else {
+				if doErr != nil {
+					t.Fatalf("unexpected error: %v", doErr)
+				}
+				_ = response.Body.Close()
+				if response.StatusCode != testCase.expectedStatus {
+					t.Fatalf("expected status %d, got %d", testCase.expectedStatus, response.StatusCode)
+				}
+			}
+			if handler.calls != testCase.expectedCalls {
+				t.Fatalf("expected %d requests, got %d", testCase.expectedCalls, handler.calls)
+			}
+			if len(retries) != testCase.expectedCalls-1 && testCase.expectedSentinel == nil {
+				t.Fatalf("expected %d retry notifications, got %d", testCase.expectedCalls-1, len(retries))
+			}
+			for _, body := range handler.bodies {
+				if body != limiterTestPostBody {
+					t.Fatalf("expected every attempt to replay the body, got %q", body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+const (
+	consensusRoutePath                  = "/consensus"
+	consensusAPIRoutePath               = "/api/consensus"
+	minFollowedByQueryParameter         = "min-followed-by"
+	minFollowsBackQueryParameter        = "min-follows-back"
+	notFollowedByQueryParameter         = "not-followed-by"
+	excludeBlockedQueryParameter        = "exclude-blocked"
+	defaultConsensusMinFollowedBy       = 1
+	errMessageConsensusUnavailable      = "load or upload at least one archive before requesting consensus"
+	errMessageInvalidConsensusThreshold = "min-followed-by and min-follows-back must be non-negative integers"
+	errMessageInvalidExcludeBlocked     = "exclude-blocked must be a boolean"
+	logMessageConsensusRenderFailure    = "consensus render failure"
+)
+
+var errConsensusUnavailable = errors.New(errMessageConsensusUnavailable)
+
+func (handler applicationHandler) serveConsensusPage(ginContext *gin.Context) {
+	var pageErrors []string
+	var result *matrix.ConsensusResult
+	filter, err := handler.consensusFilter(ginContext)
+	if err != nil {
+		pageErrors = append(pageErrors, err.Error())
+	} else if ranked, rankErr := handler.rankConsensus(ginContext.Request.Context(), filter); rankErr != nil {
+		pageErrors = append(pageErrors, rankErr.Error())
+	} else {
+		result = &ranked
+	}
+
+	pageHTML, err := matrix.RenderConsensusPage(matrix.ConsensusPageData{Result: result, Errors: pageErrors, FilterAction: consensusRoutePath})
+	if err != nil {
+		handler.logger.Error(logMessageConsensusRenderFailure, zap.Error(err))
+		ginContext.Data(http.StatusInternalServerError, htmlContentType, []byte(errMessageRenderFailure))
+		return
+	}
+	ginContext.Data(http.StatusOK, htmlContentType, []byte(pageHTML))
+}
+
+func (handler applicationHandler) serveConsensus(ginContext *gin.Context) {
+	filter, err := handler.consensusFilter(ginContext)
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := handler.rankConsensus(ginContext.Request.Context(), filter)
+	switch {
+	case errors.Is(err, errConsensusUnavailable):
+		handler.writeJSONError(ginContext, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
+		return
+	}
+	ginContext.Header("Content-Type", jsonContentType)
+	ginContext.JSON(http.StatusOK, result)
+}
+
+// rankConsensus ranks accounts across the configured team archives and the uploaded archives. Labels are reconciled
+// on copies so stored archives are never modified, and a member referenced by a handle the archives do not carry is
+// resolved when handle resolution is enabled.
+func (handler applicationHandler) rankConsensus(ctx context.Context, filter matrix.ConsensusFilter) (matrix.ConsensusResult, error) {
+	archives := handler.consensusArchives()
+	if len(archives) == 0 {
+		return matrix.ConsensusResult{}, errConsensusUnavailable
+	}
+	accountSets := make([]*matrix.AccountSets, 0, len(archives))
+	for index := range archives {
+		accountSets = append(accountSets, &archives[index].AccountSets)
+	}
+	matrix.ReconcileLabels(accountSets...)
+	filter = matrix.ResolveExcludedMember(ctx, handler.accountIDResolver(), archives, filter)
+	return matrix.RankConsensus(archives, filter)
+}
+
+// consensusArchives returns copies of the team archives followed by any uploaded archive whose owner is not already
+// part of the team.
+func (handler applicationHandler) consensusArchives() []matrix.TeamArchive {
+	archives := make([]matrix.TeamArchive, 0, len(handler.teamArchives)+2)
+	for _, archive := range handler.teamArchives {
+		archives = append(archives, matrix.TeamArchive{Owner: archive.Owner, AccountSets: copyAccountSets(archive.AccountSets)})
+	}
+	snapshot := handler.store.Snapshot()
+	if snapshot.ComparisonData == nil {
+		return archives
+	}
+	uploaded := []matrix.TeamArchive{
+		{Owner: snapshot.ComparisonData.OwnerA, AccountSets: copyAccountSets(snapshot.ComparisonData.AccountSetsA)},
+		{Owner: snapshot.ComparisonData.OwnerB, AccountSets: copyAccountSets(snapshot.ComparisonData.AccountSetsB)},
+	}
+	for _, candidate := range uploaded {
+		duplicate := false
+		for _, archive := range archives {
+			if sameOwner(archive.Owner, candidate.Owner) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			archives = append(archives, candidate)
+		}
+	}
+	return archives
+}
+
+func (handler applicationHandler) consensusFilter(ginContext *gin.Context) (matrix.ConsensusFilter, error) {
+	filter := matrix.ConsensusFilter{
+		MinFollowedBy:     defaultConsensusMinFollowedBy,
+		ExcludeFollowedBy: strings.TrimSpace(ginContext.Query(notFollowedByQueryParameter)),
+		Limit:             handler.pageLimit,
+	}
+	if rawValue := strings.TrimSpace(ginContext.Query(minFollowedByQueryParameter)); rawValue != "" {
+		value, err := strconv.Atoi(rawValue)
+		if err != nil || value < 0 {
+			return filter, errors.New(errMessageInvalidConsensusThreshold)
+		}
+		filter.MinFollowedBy = value
+	}
+	minFollowsBack, err := nonNegativeQueryInteger(ginContext, minFollowsBackQueryParameter)
+	if err != nil {
+		return filter, errors.New(errMessageInvalidConsensusThreshold)
+	}
+	filter.MinFollowsBack = minFollowsBack
+	if rawValue := strings.TrimSpace(ginContext.Query(excludeBlockedQueryParameter)); rawValue != "" {
+		excludeBlocked, parseErr := strconv.ParseBool(rawValue)
+		if parseErr != nil {
+			return filter, errors.New(errMessageInvalidExcludeBlocked)
+		}
+		filter.ExcludeBlockedOrMuted = excludeBlocked
+	}
+	if rawLimit := strings.TrimSpace(ginContext.Query(limitQueryParameter)); rawLimit != "" {
+		limit, limitErr := nonNegativeQueryInteger(ginContext, limitQueryParameter)
+		if limitErr != nil {
+			return filter, limitErr
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
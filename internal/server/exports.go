@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/f-sync/fsync/internal/matrix"
+)
+
+const (
+	exportsRoutePath              = "/api/exports"
+	exportRoutePattern            = exportsRoutePath + "/:owner/:bucket"
+	formatQueryParameter          = "format"
+	exportFormatCSV               = "csv"
+	exportFormatJSONL             = "jsonl"
+	exportTempFilePattern         = "fsync-export-*"
+	exportFileNameFormat          = "%s-%s.%s"
+	exportCSVContentType          = "text/csv; charset=utf-8"
+	exportJSONLContentType        = "application/x-ndjson; charset=utf-8"
+	exportColumnID                = "id"
+	exportColumnHandle            = "handle"
+	exportColumnDisplayName       = "display_name"
+	exportColumnStatus            = "status"
+	errMessageUnknownExportFormat = "format must be csv or jsonl"
+	errMessageExportUnavailable   = "upload two archives before requesting exports"
+)
+
+var errUnknownExportFormat = errors.New(errMessageUnknownExportFormat)
+
+// exportResult is the result of a finished export job in the job routes.
+type exportResult struct {
+	Records  int    `json:"records"`
+	FileName string `json:"fileName"`
+	Download string `json:"download"`
+}
+
+// exportRecordWriter writes bucket records in one export format.
+type exportRecordWriter interface {
+	Write(record matrix.AccountRecord) error
+	Flush() error
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (recordWriter csvRecordWriter) Write(record matrix.AccountRecord) error {
+	return recordWriter.writer.Write([]string{record.AccountID, record.UserName, record.DisplayName, string(record.Status)})
+}
+
+func (recordWriter csvRecordWriter) Flush() error {
+	recordWriter.writer.Flush()
+	return recordWriter.writer.Error()
+}
+
+type jsonlRecordWriter struct {
+	encoder *json.Encoder
+}
+
+func (recordWriter jsonlRecordWriter) Write(record matrix.AccountRecord) error {
+	return recordWriter.encoder.Encode(record)
+}
+
+func (jsonlRecordWriter) Flush() error {
+	return nil
+}
+
+// newExportRecordWriter returns a writer for format and the content type of its output. CSV output starts with a
+// header row; JSON Lines output holds one account record per line, as served by the bucket API.
+func newExportRecordWriter(writer io.Writer, format string) (exportRecordWriter, string, error) {
+	switch format {
+	case exportFormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write([]string{exportColumnID, exportColumnHandle, exportColumnDisplayName, exportColumnStatus}); err != nil {
+			return nil, "", err
+		}
+		return csvRecordWriter{writer: csvWriter}, exportCSVContentType, nil
+	case exportFormatJSONL:
+		return jsonlRecordWriter{encoder: json.NewEncoder(writer)}, exportJSONLContentType, nil
+	default:
+		return nil, "", fmt.Errorf("%w: %q", errUnknownExportFormat, format)
+	}
+}
+
+// startExport validates the request and exports a bucket of the current comparison in an export job, answering with
+// the job. The finished job serves the file from its download route.
+func (handler applicationHandler) startExport(ginContext *gin.Context) {
+	options, err := handler.comparisonOptions(ginContext)
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
+		return
+	}
+	owner, err := matrix.ParseOwnerSlot(ginContext.Param(bucketOwnerParameter))
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	}
+	bucket := matrix.BucketName(strings.ToLower(strings.TrimSpace(ginContext.Param(bucketNameParameter))))
+	format := strings.ToLower(strings.TrimSpace(ginContext.DefaultQuery(formatQueryParameter, exportFormatCSV)))
+	if format != exportFormatCSV && format != exportFormatJSONL {
+		handler.writeJSONError(ginContext, http.StatusBadRequest, errMessageUnknownExportFormat)
+		return
+	}
+	snapshot := handler.store.Snapshot()
+	if snapshot.ComparisonData == nil {
+		handler.writeJSONError(ginContext, http.StatusConflict, errMessageExportUnavailable)
+		return
+	}
+	// An empty comparison rejects unknown bucket names before any job starts.
+	if _, err := (matrix.ComparisonResult{}).Bucket(owner, bucket); err != nil {
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	}
+
+	comparisonData := snapshot.ComparisonData
+	job := handler.jobs.start(jobKindExport, func(ctx context.Context, tracker *jobTracker) (any, error) {
+		comparison := handler.buildComparison(comparisonData, options)
+		records, err := comparison.Bucket(owner, bucket)
+		if err != nil {
+			return nil, err
+		}
+		fileName := fmt.Sprintf(exportFileNameFormat, strings.ToLower(string(owner)), bucket, format)
+		return writeExport(ctx, tracker, records, format, fileName)
+	})
+	ginContext.Header("Content-Type", jsonContentType)
+	ginContext.JSON(http.StatusAccepted, jobResponse{Job: job})
+}
+
+// writeExport writes records to a temporary file attached to the job as fileName.
+func writeExport(ctx context.Context, tracker *jobTracker, records []matrix.AccountRecord, format string, fileName string) (exportResult, error) {
+	file, err := os.CreateTemp("", exportTempFilePattern)
+	if err != nil {
+		return exportResult{}, fmt.Errorf("create export file: %w", err)
+	}
+	defer file.Close()
+	recordWriter, contentType, err := newExportRecordWriter(file, format)
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return exportResult{}, err
+	}
+	tracker.attach(jobDownload{path: file.Name(), fileName: fileName, contentType: contentType}, func() { _ = os.Remove(file.Name()) })
+
+	for index, record := range records {
+		if err := ctx.Err(); err != nil {
+			return exportResult{}, err
+		}
+		if err := recordWriter.Write(record); err != nil {
+			return exportResult{}, err
+		}
+		tracker.setProgress(jobProgress{Total: len(records), Completed: index + 1})
+	}
+	if err := recordWriter.Flush(); err != nil {
+		return exportResult{}, err
+	}
+	if err := file.Close(); err != nil {
+		return exportResult{}, err
+	}
+	return exportResult{Records: len(records), FileName: fileName, Download: fmt.Sprintf(jobDownloadPathFormat, tracker.id)}, nil
+}
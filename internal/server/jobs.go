@@ -0,0 +1,407 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jobsRoutePath           = "/api/jobs"
+	jobEventsRoutePath      = jobsRoutePath + "/events"
+	jobRoutePattern         = jobsRoutePath + "/:" + jobIDParameter
+	jobDownloadRoutePattern = jobRoutePattern + "/download"
+	jobDownloadPathFormat   = jobsRoutePath + "/%s/download"
+	jobIDParameter          = "id"
+	jobEventName            = "job"
+	eventStreamContentType  = "text/event-stream"
+	cacheControlHeader      = "Cache-Control"
+	cacheControlNoCache     = "no-cache"
+	// maxFinishedJobs bounds how many finished jobs stay listed; older ones are forgotten and their files removed.
+	maxFinishedJobs = 50
+	// jobProgressInterval throttles progress events so that fast jobs do not flood the event stream; state changes
+	// are always published.
+	jobProgressInterval = 250 * time.Millisecond
+	// jobSubscriberBuffer is the number of events a stream may fall behind before it is closed; browsers reconnect
+	// and receive the current jobs again.
+	jobSubscriberBuffer = 64
+
+	jobKindUpload     jobKind = "upload"
+	jobKindResolution jobKind = "resolution"
+	jobKindExport     jobKind = "export"
+
+	jobStateRunning   jobState = "running"
+	jobStateDone      jobState = "done"
+	jobStateFailed    jobState = "failed"
+	jobStateCancelled jobState = "cancelled"
+
+	errMessageJobNotFound    = "job not found"
+	errMessageJobNoDownload  = "job has no download"
+	errMessageJobNotFinished = "job has not finished"
+)
+
+var (
+	errJobNotFound    = errors.New(errMessageJobNotFound)
+	errJobNoDownload  = errors.New(errMessageJobNoDownload)
+	errJobNotFinished = errors.New(errMessageJobNotFinished)
+)
+
+// jobKind names the work a background job does.
+type jobKind string
+
+// jobState is the lifecycle state of a background job.
+type jobState string
+
+// finished reports whether the state is final.
+func (state jobState) finished() bool {
+	return state != jobStateRunning
+}
+
+// jobProgress counts the work items of a job. Failed, Skipped and ETASeconds are reported by resolution jobs only.
+type jobProgress struct {
+	Total      int     `json:"total"`
+	Completed  int     `json:"completed"`
+	Failed     int     `json:"failed,omitempty"`
+	Skipped    int     `json:"skipped,omitempty"`
+	ETASeconds float64 `json:"etaSeconds,omitempty"`
+}
+
+// jobStatus is the JSON view of a job served by the job routes and the event stream.
+type jobStatus struct {
+	ID         string      `json:"id"`
+	Kind       jobKind     `json:"kind"`
+	State      jobState    `json:"state"`
+	Progress   jobProgress `json:"progress"`
+	Error      string      `json:"error,omitempty"`
+	Result     any         `json:"result,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+}
+
+type jobResponse struct {
+	Job jobStatus `json:"job"`
+}
+
+// jobDownload is a file produced by a job and served from its download route until the job is forgotten.
+type jobDownload struct {
+	path        string
+	fileName    string
+	contentType string
+}
+
+// jobFunc does the work of a job. It should return promptly once ctx is cancelled; its result is published when it
+// returns without an error.
+type jobFunc func(ctx context.Context, tracker *jobTracker) (any, error)
+
+type backgroundJob struct {
+	status          jobStatus
+	cancel          context.CancelFunc
+	download        *jobDownload
+	cleanups        []func()
+	lastPublishedAt time.Time
+}
+
+// jobManager runs background jobs, keeps their state for the job routes, and publishes every change to the
+// subscribers of the event stream.
+type jobManager struct {
+	ctx         context.Context
+	mutex       sync.Mutex
+	sequence    uint64
+	jobs        map[string]*backgroundJob
+	order       []string
+	subscribers map[chan jobStatus]struct{}
+}
+
+// newJobManager returns a job manager whose jobs are cancelled when ctx ends; a nil ctx never ends.
+func newJobManager(ctx context.Context) *jobManager {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &jobManager{ctx: ctx, jobs: map[string]*backgroundJob{}, subscribers: map[chan jobStatus]struct{}{}}
+}
+
+// jobTracker lets a job report progress and attach files while it runs.
+type jobTracker struct {
+	manager *jobManager
+	id      string
+	ctx     context.Context
+}
+
+// start registers a job of kind and runs it in the background.
+func (manager *jobManager) start(kind jobKind, run jobFunc) jobStatus {
+	tracker := manager.begin(kind)
+	go tracker.run(run)
+	return tracker.status()
+}
+
+// begin registers a running job of kind without starting any work, so that the caller can bind the job context
+// before handing the work to run.
+func (manager *jobManager) begin(kind jobKind) *jobTracker {
+	ctx, cancel := context.WithCancel(manager.ctx)
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.sequence++
+	id := strconv.FormatUint(manager.sequence, 10)
+	manager.jobs[id] = &backgroundJob{
+		status: jobStatus{ID: id, Kind: kind, State: jobStateRunning, StartedAt: time.Now().UTC()},
+		cancel: cancel,
+	}
+	manager.order = append(manager.order, id)
+	manager.publishLocked(manager.jobs[id])
+	return &jobTracker{manager: manager, id: id, ctx: ctx}
+}
+
+// list returns every known job, oldest first.
+func (manager *jobManager) list() []jobStatus {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	return manager.listLocked()
+}
+
+func (manager *jobManager) listLocked() []jobStatus {
+	statuses := make([]jobStatus, 0, len(manager.order))
+	for _, id := range manager.order {
+		statuses = append(statuses, manager.jobs[id].status)
+	}
+	return statuses
+}
+
+func (manager *jobManager) get(id string) (jobStatus, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job, found := manager.jobs[id]
+	if !found {
+		return jobStatus{}, false
+	}
+	return job.status, true
+}
+
+// cancel asks a running job to stop; the job reports the cancelled state once its work returns.
+func (manager *jobManager) cancel(id string) (jobStatus, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job, found := manager.jobs[id]
+	if !found {
+		return jobStatus{}, false
+	}
+	job.cancel()
+	return job.status, true
+}
+
+// cancelKind asks every running job of kind to stop.
+func (manager *jobManager) cancelKind(kind jobKind) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	for _, job := range manager.jobs {
+		if job.status.Kind == kind && !job.status.State.finished() {
+			job.cancel()
+		}
+	}
+}
+
+// download returns the file attached to a finished job.
+func (manager *jobManager) download(id string) (jobDownload, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job, found := manager.jobs[id]
+	switch {
+	case !found:
+		return jobDownload{}, errJobNotFound
+	case !job.status.State.finished():
+		return jobDownload{}, errJobNotFinished
+	case job.download == nil || job.status.State != jobStateDone:
+		return jobDownload{}, errJobNoDownload
+	}
+	return *job.download, nil
+}
+
+// subscribe returns a channel receiving every later job change, the jobs known at subscription time, and a function
+// that ends the subscription. The channel is closed when the subscriber falls too far behind.
+func (manager *jobManager) subscribe() (<-chan jobStatus, []jobStatus, func()) {
+	updates := make(chan jobStatus, jobSubscriberBuffer)
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.subscribers[updates] = struct{}{}
+	unsubscribe := func() {
+		manager.mutex.Lock()
+		defer manager.mutex.Unlock()
+		if _, subscribed := manager.subscribers[updates]; subscribed {
+			delete(manager.subscribers, updates)
+			close(updates)
+		}
+	}
+	return updates, manager.listLocked(), unsubscribe
+}
+
+func (manager *jobManager) publishLocked(job *backgroundJob) {
+	job.lastPublishedAt = time.Now()
+	for updates := range manager.subscribers {
+		select {
+		case updates <- job.status:
+		default:
+			delete(manager.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+// forgetLocked drops the oldest finished jobs beyond maxFinishedJobs and releases their files.
+func (manager *jobManager) forgetLocked() {
+	finished := 0
+	for _, id := range manager.order {
+		if manager.jobs[id].status.State.finished() {
+			finished++
+		}
+	}
+	kept := manager.order[:0]
+	for _, id := range manager.order {
+		job := manager.jobs[id]
+		if finished > maxFinishedJobs && job.status.State.finished() {
+			finished--
+			for _, cleanup := range job.cleanups {
+				cleanup()
+			}
+			delete(manager.jobs, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	manager.order = kept
+}
+
+// run does the work of the job and records its outcome.
+func (tracker *jobTracker) run(work jobFunc) {
+	result, err := work(tracker.ctx, tracker)
+	tracker.finish(result, err)
+}
+
+// jobContext returns the context of the job, cancelled through the job routes.
+func (tracker *jobTracker) jobContext() context.Context {
+	return tracker.ctx
+}
+
+func (tracker *jobTracker) status() jobStatus {
+	status, _ := tracker.manager.get(tracker.id)
+	return status
+}
+
+// setProgress records progress, publishing it unless an event for the job went out within jobProgressInterval.
+func (tracker *jobTracker) setProgress(progress jobProgress) {
+	manager := tracker.manager
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job := manager.jobs[tracker.id]
+	job.status.Progress = progress
+	if time.Since(job.lastPublishedAt) >= jobProgressInterval || progress.Completed == progress.Total {
+		manager.publishLocked(job)
+	}
+}
+
+// attach makes path downloadable once the job is done; the file is removed when the job is forgotten or does not
+// finish successfully.
+func (tracker *jobTracker) attach(download jobDownload, cleanup func()) {
+	manager := tracker.manager
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job := manager.jobs[tracker.id]
+	job.download = &download
+	job.cleanups = append(job.cleanups, cleanup)
+}
+
+// finish records the outcome of the job: cancellations, including resolutions made stale by new uploads, end in
+// the cancelled state and other errors in the failed state.
+func (tracker *jobTracker) finish(result any, err error) {
+	manager := tracker.manager
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job := manager.jobs[tracker.id]
+	finishedAt := time.Now().UTC()
+	job.status.FinishedAt = &finishedAt
+	switch {
+	case err == nil:
+		job.status.State = jobStateDone
+		job.status.Result = result
+	case errors.Is(err, context.Canceled) || errors.Is(err, ErrStaleResolution):
+		job.status.State = jobStateCancelled
+	default:
+		job.status.State = jobStateFailed
+		job.status.Error = err.Error()
+	}
+	job.cancel()
+	if job.status.State != jobStateDone {
+		for _, cleanup := range job.cleanups {
+			cleanup()
+		}
+		job.cleanups = nil
+		job.download = nil
+	}
+	manager.publishLocked(job)
+	manager.forgetLocked()
+}
+
+func (handler applicationHandler) serveJobs(ginContext *gin.Context) {
+	ginContext.JSON(http.StatusOK, handler.jobs.list())
+}
+
+func (handler applicationHandler) serveJob(ginContext *gin.Context) {
+	status, found := handler.jobs.get(ginContext.Param(jobIDParameter))
+	if !found {
+		handler.writeJSONError(ginContext, http.StatusNotFound, errMessageJobNotFound)
+		return
+	}
+	ginContext.JSON(http.StatusOK, status)
+}
+
+func (handler applicationHandler) cancelJob(ginContext *gin.Context) {
+	status, found := handler.jobs.cancel(ginContext.Param(jobIDParameter))
+	if !found {
+		handler.writeJSONError(ginContext, http.StatusNotFound, errMessageJobNotFound)
+		return
+	}
+	ginContext.JSON(http.StatusAccepted, status)
+}
+
+func (handler applicationHandler) downloadJob(ginContext *gin.Context) {
+	download, err := handler.jobs.download(ginContext.Param(jobIDParameter))
+	switch {
+	case errors.Is(err, errJobNotFound):
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		handler.writeJSONError(ginContext, http.StatusConflict, err.Error())
+		return
+	}
+	ginContext.Header("Content-Type", download.contentType)
+	ginContext.FileAttachment(download.path, download.fileName)
+}
+
+// streamJobEvents serves job changes as Server-Sent Events named jobEventName, starting with every known job.
+func (handler applicationHandler) streamJobEvents(ginContext *gin.Context) {
+	updates, current, unsubscribe := handler.jobs.subscribe()
+	defer unsubscribe()
+	ginContext.Header("Content-Type", eventStreamContentType)
+	ginContext.Header(cacheControlHeader, cacheControlNoCache)
+	ginContext.Status(http.StatusOK)
+	for _, status := range current {
+		ginContext.SSEvent(jobEventName, status)
+	}
+	ginContext.Writer.Flush()
+	done := ginContext.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case status, open := <-updates:
+			if !open {
+				return
+			}
+			ginContext.SSEvent(jobEventName, status)
+			ginContext.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/f-sync/fsync/internal/handles"
+)
+
+const (
+	resolutionRoutePath            = "/api/resolution"
+	logMessageResolutionRun        = "handle resolution progress"
+	logMessageResolutionDiscarded  = "handle resolution discarded"
+	logFieldResolved               = "resolved"
+	logFieldFailed                 = "failed"
+	logFieldSkipped                = "skipped"
+	logFieldTotal                  = "total"
+	logFieldVersion                = "version"
+	errMessageStaleResolution      = "handle resolution results belong to archives that were replaced or cleared"
+	errMessageResolutionNotStarted = "handle resolution was not started"
+
+	// ResolutionStateIdle reports that no resolution was started for the current archives.
+	ResolutionStateIdle ResolutionState = "idle"
+	// ResolutionStateRunning reports a resolution in progress.
+	ResolutionStateRunning ResolutionState = "running"
+	// ResolutionStateDone reports a resolution whose results were stored.
+	ResolutionStateDone ResolutionState = "done"
+	// ResolutionStateCancelled reports a resolution stopped before it finished, because the archives were cleared or
+	// replaced or the server is shutting down; its results were discarded.
+	ResolutionStateCancelled ResolutionState = "cancelled"
+)
+
+var (
+	// ErrStaleResolution is returned by ComparisonStore.ResolveHandles when the store version changed while the job
+	// ran; the results are discarded.
+	ErrStaleResolution = errors.New(errMessageStaleResolution)
+
+	errResolutionNotStarted = errors.New(errMessageResolutionNotStarted)
+)
+
+// ResolutionState is the lifecycle state of a ResolutionJob.
+type ResolutionState string
+
+// ResolutionJob describes a background handle resolution bound to one version of a ComparisonStore. The zero value
+// is an idle job.
+type ResolutionJob struct {
+	// ID distinguishes jobs started for the same version.
+	ID      uint64
+	Version uint64
+	State   ResolutionState
+	// StartedAt and FinishedAt are zero until the job starts and finishes.
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// resolutionStatus is the JSON body served at resolutionRoutePath.
+type resolutionStatus struct {
+	State          ResolutionState `json:"state"`
+	Version        uint64          `json:"version"`
+	Total          int             `json:"total"`
+	Completed      int             `json:"completed"`
+	Resolved       int             `json:"resolved"`
+	Failed         int             `json:"failed"`
+	Skipped        int             `json:"skipped"`
+	ElapsedSeconds float64         `json:"elapsedSeconds"`
+	ETASeconds     float64         `json:"etaSeconds"`
+}
+
+// resolutionResult is the result of a finished resolution job in the job routes.
+type resolutionResult struct {
+	Version  uint64 `json:"version"`
+	Total    int    `json:"total"`
+	Resolved int    `json:"resolved"`
+	Failed   int    `json:"failed"`
+	Skipped  int    `json:"skipped"`
+}
+
+// resolutionProgress keeps the progress of the most recently started resolution job so the page can poll it; events
+// of older jobs are ignored.
+type resolutionProgress struct {
+	mutex    sync.Mutex
+	jobID    uint64
+	progress handles.Progress
+}
+
+func newResolutionProgress() *resolutionProgress {
+	return &resolutionProgress{}
+}
+
+// start tracks job and returns a context that records its progress events and passes them to observe.
+func (tracker *resolutionProgress) start(ctx context.Context, job ResolutionJob, observe func(handles.Progress)) context.Context {
+	tracker.mutex.Lock()
+	tracker.jobID = job.ID
+	tracker.progress = handles.Progress{}
+	tracker.mutex.Unlock()
+	return handles.WithProgress(ctx, func(event handles.ProgressEvent) {
+		tracker.record(job.ID, event)
+		observe(event.Progress)
+	})
+}
+
+func (tracker *resolutionProgress) record(jobID uint64, event handles.ProgressEvent) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if tracker.jobID == jobID {
+		tracker.progress = event.Progress
+	}
+}
+
+// finish clears the ETA of a finished job and returns its final progress.
+func (tracker *resolutionProgress) finish(job ResolutionJob) handles.Progress {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if tracker.jobID != job.ID {
+		return handles.Progress{}
+	}
+	tracker.progress.ETA = 0
+	return tracker.progress
+}
+
+// status combines the job state kept by the store with the tracked progress of the same job.
+func (tracker *resolutionProgress) status(job ResolutionJob) resolutionStatus {
+	status := resolutionStatus{State: job.State, Version: job.Version}
+	if status.State == "" {
+		status.State = ResolutionStateIdle
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if job.ID == 0 || tracker.jobID != job.ID {
+		return status
+	}
+	status.Total = tracker.progress.Total
+	status.Completed = tracker.progress.Completed
+	status.Resolved = tracker.progress.Resolved
+	status.Failed = tracker.progress.Failed
+	status.Skipped = tracker.progress.Skipped
+	status.ElapsedSeconds = tracker.progress.Elapsed.Seconds()
+	if job.State == ResolutionStateRunning {
+		status.ETASeconds = tracker.progress.ETA.Seconds()
+	}
+	return status
+}
+
+func (handler applicationHandler) serveResolution(ginContext *gin.Context) {
+	ginContext.JSON(http.StatusOK, handler.resolution.status(handler.store.ResolutionJob()))
+}
+
+// startResolution starts a resolution job for the current archives, replacing any job still running, and lists it
+// with the background jobs. Cancelling the background job cancels the resolution.
+func (handler applicationHandler) startResolution() {
+	tracker := handler.jobs.begin(jobKindResolution)
+	ctx, job, started := handler.store.StartResolution(tracker.jobContext())
+	if !started {
+		tracker.finish(nil, errResolutionNotStarted)
+		return
+	}
+	handler.logger.Info(logMessageHandleResolution, zap.Uint64(logFieldVersion, job.Version))
+	ctx = handler.resolution.start(ctx, job, func(progress handles.Progress) {
+		tracker.setProgress(jobProgress{
+			Total:      progress.Total,
+			Completed:  progress.Completed,
+			Failed:     progress.Failed,
+			Skipped:    progress.Skipped,
+			ETASeconds: progress.ETA.Seconds(),
+		})
+	})
+	go tracker.run(func(context.Context, *jobTracker) (any, error) {
+		return handler.runResolution(ctx, job)
+	})
+}
+
+// runResolution runs job with a context prepared by startResolution.
+func (handler applicationHandler) runResolution(ctx context.Context, job ResolutionJob) (resolutionResult, error) {
+	errorsByAccountID, err := handler.store.ResolveHandles(ctx, job, handler.handleResolver, handler.resolutionPolicy)
+	progress := handler.resolution.finish(job)
+	if err != nil {
+		handler.logger.Info(logMessageResolutionDiscarded, zap.Uint64(logFieldVersion, job.Version), zap.Error(err))
+		return resolutionResult{}, err
+	}
+	for accountID, resolutionErr := range errorsByAccountID {
+		if errors.Is(resolutionErr, handles.ErrBudgetExhausted) || errors.Is(resolutionErr, handles.ErrNotCached) {
+			continue
+		}
+		handler.logger.Warn(logMessageHandleResolutionError, zap.String(logFieldAccountID, accountID), zap.Error(resolutionErr))
+	}
+	handler.logger.Info(logMessageResolutionRun, zap.Uint64(logFieldVersion, job.Version), zap.Int(logFieldTotal, progress.Total), zap.Int(logFieldResolved, progress.Resolved), zap.Int(logFieldFailed, progress.Failed), zap.Int(logFieldSkipped, progress.Skipped))
+	return resolutionResult{Version: job.Version, Total: progress.Total, Resolved: progress.Resolved, Failed: progress.Failed, Skipped: progress.Skipped}, nil
+}
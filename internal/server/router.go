@@ -7,12 +7,15 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
 )
 
@@ -20,6 +23,17 @@ const (
 	comparisonRoutePath             = "/"
 	healthRoutePath                 = "/healthz"
 	uploadsRoutePath                = "/api/uploads"
+	bucketsRoutePath                = "/api/buckets"
+	bucketRoutePattern              = bucketsRoutePath + "/:owner/:bucket"
+	bucketOwnerParameter            = "owner"
+	bucketNameParameter             = "bucket"
+	accountsRoutePath               = "/api/accounts"
+	accountRoutePattern             = accountsRoutePath + "/:reference"
+	accountReferenceParameter       = "reference"
+	sortQueryParameter              = "sort"
+	filterQueryParameter            = "filter"
+	offsetQueryParameter            = "offset"
+	limitQueryParameter             = "limit"
 	staticRoutePath                 = "/static"
 	htmlContentType                 = "text/html; charset=utf-8"
 	jsonContentType                 = "application/json; charset=utf-8"
@@ -37,6 +51,9 @@ const (
 	errMessageTooManyArchives       = "two archives already uploaded; reset before adding more"
 	errMessageRenderFailure         = "comparison page rendering failed"
 	errMessageUploadPersistFailure  = "unable to persist uploaded file"
+	errMessageComparisonUnavailable = "upload two archives before requesting buckets"
+	errMessageInvalidPagination     = "offset and limit must be non-negative integers"
+	errMessageAccountsUnavailable   = "upload two archives before requesting account details"
 	logMessageRenderFailure         = "comparison render failure"
 	logMessageStoreFailure          = "upload store failure"
 	logMessageArchiveParseFailure   = "archive parse failure"
@@ -58,20 +75,24 @@ type ComparisonData struct {
 	AccountSetsB matrix.AccountSets
 	OwnerA       matrix.OwnerIdentity
 	OwnerB       matrix.OwnerIdentity
+	// LabelConflicts lists accounts whose archives disagree on handles or display names.
+	LabelConflicts []matrix.LabelConflict
+	// ResolutionErrors holds the most recent handle resolution failure for each account identifier.
+	ResolutionErrors map[string]error
 }
 
 // ComparisonService encapsulates the logic required to build and render comparison pages.
 type ComparisonService interface {
-	BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity) matrix.ComparisonResult
+	BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity, options matrix.ComparisonOptions) matrix.ComparisonResult
 	RenderComparisonPage(pageData matrix.ComparisonPageData) (string, error)
 }
 
 // MatrixComparisonService implements ComparisonService by delegating to the matrix package.
 type MatrixComparisonService struct{}
 
-// BuildComparison uses matrix.BuildComparison to construct the result.
-func (MatrixComparisonService) BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity) matrix.ComparisonResult {
-	return matrix.BuildComparison(accountSetsA, accountSetsB, ownerA, ownerB)
+// BuildComparison uses matrix.BuildComparisonWithOptions to construct the result.
+func (MatrixComparisonService) BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity, options matrix.ComparisonOptions) matrix.ComparisonResult {
+	return matrix.BuildComparisonWithOptions(accountSetsA, accountSetsB, ownerA, ownerB, options)
 }
 
 // RenderComparisonPage uses matrix.RenderComparisonPage to produce the HTML output.
@@ -86,18 +107,47 @@ type RouterConfig struct {
 	Logger         *zap.Logger
 	ResolveHandles bool
 	HandleResolver matrix.AccountHandleResolver
-}
-
-// ComparisonStore persists uploaded archives and exposes comparison snapshots.
+	// HandleHistory records the handles and display names of uploaded archives, dated by their export; nothing is
+	// recorded when nil.
+	HandleHistory handles.HistoryStore
+	// ResolutionPolicy orders and bounds background handle resolution; a zero policy uses the default priority
+	// without a budget.
+	ResolutionPolicy matrix.ResolutionPolicy
+	// SortMode is the default bucket ordering; the sort query parameter overrides it per request.
+	SortMode matrix.SortMode
+	// SortLocale selects the collation used for name and handle ordering.
+	SortLocale string
+	// PageLimit caps the number of accounts rendered per bucket on the comparison page.
+	PageLimit int
+	// TeamArchives lists preloaded archives ranked alongside the uploaded archives in the consensus view.
+	TeamArchives []matrix.TeamArchive
+	// Context bounds the background jobs, which are cancelled when it ends, typically as the server shuts down. Jobs
+	// only end through the job routes when nil.
+	Context context.Context
+}
+
+// ComparisonStore persists uploaded archives and exposes comparison snapshots. Every successful Upsert and every
+// Clear advances the store version and cancels the running resolution job. Snapshots share their ComparisonData until
+// the archives or their resolution results change, so callers must not modify it.
 type ComparisonStore interface {
 	Snapshot() ComparisonSnapshot
 	Upsert(upload ArchiveUpload) (ComparisonSnapshot, error)
 	Clear() ComparisonSnapshot
-	ResolveHandles(ctx context.Context, resolver matrix.AccountHandleResolver) map[string]error
+	// StartResolution starts a resolution job for the current version, cancelling the previous job, and returns a
+	// context derived from parent that is cancelled when the version changes. It reports false when there is no
+	// comparison to resolve.
+	StartResolution(parent context.Context) (context.Context, ResolutionJob, bool)
+	// ResolveHandles runs job and stores its results unless the version changed meanwhile, in which case the results
+	// are discarded and ErrStaleResolution is returned.
+	ResolveHandles(ctx context.Context, job ResolutionJob, resolver matrix.AccountHandleResolver, policy matrix.ResolutionPolicy) (map[string]error, error)
+	// ResolutionJob returns the most recent resolution job.
+	ResolutionJob() ResolutionJob
 }
 
 // ComparisonSnapshot represents the current upload state and optional comparison data.
 type ComparisonSnapshot struct {
+	// Version identifies the stored archives; it changes whenever they are replaced or cleared.
+	Version        uint64
 	Uploads        []matrix.UploadSummary
 	ComparisonData *ComparisonData
 }
@@ -134,46 +184,114 @@ func NewRouter(configuration RouterConfig) (*gin.Engine, error) {
 	}
 	engine.StaticFS(staticRoutePath, http.FS(staticFiles))
 
+	sortMode, err := matrix.ParseSortMode(string(configuration.SortMode))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := matrix.ParseSortLocale(configuration.SortLocale); err != nil {
+		return nil, err
+	}
+	pageLimit := configuration.PageLimit
+	if pageLimit < 0 {
+		pageLimit = 0
+	}
+	resolutionPolicy := configuration.ResolutionPolicy
+	if len(resolutionPolicy.Priority) == 0 {
+		resolutionPolicy.Priority = matrix.DefaultResolutionPriority()
+	}
+
 	handler := applicationHandler{
-		store:          store,
-		service:        service,
-		logger:         logger,
-		resolveHandles: configuration.ResolveHandles,
-		handleResolver: configuration.HandleResolver,
+		store:            store,
+		service:          service,
+		logger:           logger,
+		resolveHandles:   configuration.ResolveHandles,
+		handleResolver:   configuration.HandleResolver,
+		handleHistory:    configuration.HandleHistory,
+		resolutionPolicy: resolutionPolicy,
+		sortMode:         sortMode,
+		sortLocale:       configuration.SortLocale,
+		pageLimit:        pageLimit,
+		teamArchives:     configuration.TeamArchives,
+		resolution:       newResolutionProgress(),
+		jobs:             newJobManager(configuration.Context),
+		archiveMutex:     &sync.Mutex{},
+		comparisons:      &comparisonCache{},
 	}
 
 	engine.GET(comparisonRoutePath, handler.serveComparison)
 	engine.GET(healthRoutePath, handler.healthStatus)
 	engine.POST(uploadsRoutePath, handler.uploadArchives)
 	engine.DELETE(uploadsRoutePath, handler.resetArchives)
+	engine.GET(bucketRoutePattern, handler.serveBucket)
+	engine.GET(accountRoutePattern, handler.serveAccount)
+	engine.GET(resolutionRoutePath, handler.serveResolution)
+	engine.GET(consensusRoutePath, handler.serveConsensusPage)
+	engine.GET(consensusAPIRoutePath, handler.serveConsensus)
+	engine.POST(exportRoutePattern, handler.startExport)
+	engine.GET(jobsRoutePath, handler.serveJobs)
+	engine.GET(jobEventsRoutePath, handler.streamJobEvents)
+	engine.GET(jobRoutePattern, handler.serveJob)
+	engine.DELETE(jobRoutePattern, handler.cancelJob)
+	engine.GET(jobDownloadRoutePattern, handler.downloadJob)
 
 	return engine, nil
 }
 
 type applicationHandler struct {
-	store          ComparisonStore
-	service        ComparisonService
-	logger         *zap.Logger
-	resolveHandles bool
-	handleResolver matrix.AccountHandleResolver
+	store            ComparisonStore
+	service          ComparisonService
+	logger           *zap.Logger
+	resolveHandles   bool
+	handleResolver   matrix.AccountHandleResolver
+	handleHistory    handles.HistoryStore
+	resolutionPolicy matrix.ResolutionPolicy
+	sortMode         matrix.SortMode
+	sortLocale       string
+	pageLimit        int
+	teamArchives     []matrix.TeamArchive
+	resolution       *resolutionProgress
+	jobs             *jobManager
+	// archiveMutex orders upload jobs storing archives against clears, so that a cleared upload stores nothing.
+	archiveMutex *sync.Mutex
+	comparisons  *comparisonCache
+}
+
+// comparisonCache keeps the comparisons built from the current comparison data, one per set of options, so that
+// paginated bucket requests do not rebuild and sort the whole comparison.
+type comparisonCache struct {
+	mutex   sync.Mutex
+	data    *ComparisonData
+	results map[matrix.ComparisonOptions]matrix.ComparisonResult
 }
 
 func (handler applicationHandler) serveComparison(ginContext *gin.Context) {
+	var pageErrors []string
+	options, optionsErr := handler.comparisonOptions(ginContext)
+	if optionsErr != nil {
+		pageErrors = append(pageErrors, optionsErr.Error())
+	}
+
 	snapshot := handler.store.Snapshot()
 	var comparisonResult *matrix.ComparisonResult
+	var labelConflicts []matrix.LabelConflict
 	if snapshot.ComparisonData != nil {
-		result := handler.service.BuildComparison(
-			snapshot.ComparisonData.AccountSetsA,
-			snapshot.ComparisonData.AccountSetsB,
-			snapshot.ComparisonData.OwnerA,
-			snapshot.ComparisonData.OwnerB,
-		)
+		result := handler.buildComparison(snapshot.ComparisonData, options)
 		comparisonResult = &result
+		labelConflicts = snapshot.ComparisonData.LabelConflicts
 	}
 
 	pageHTML, err := handler.service.RenderComparisonPage(matrix.ComparisonPageData{
-		Comparison: comparisonResult,
-		Uploads:    snapshot.Uploads,
+		Comparison:         comparisonResult,
+		Uploads:            snapshot.Uploads,
+		Errors:             pageErrors,
+		LabelConflicts:     labelConflicts,
+		PageLimit:          handler.pageLimit,
+		BucketsEndpoint:    bucketsRoutePath,
+		AccountsEndpoint:   accountsRoutePath,
+		ConsensusPath:      consensusRoutePath,
+		ResolutionEndpoint: resolutionRoutePath,
+		JobEventsEndpoint:  jobEventsRoutePath,
+		ExportsEndpoint:    exportsRoutePath,
 	})
 	if err != nil {
 		handler.logger.Error(logMessageRenderFailure, zap.Error(err))
@@ -183,10 +301,137 @@ func (handler applicationHandler) serveComparison(ginContext *gin.Context) {
 	ginContext.Data(http.StatusOK, htmlContentType, []byte(pageHTML))
 }
 
+func (handler applicationHandler) serveBucket(ginContext *gin.Context) {
+	options, err := handler.comparisonOptions(ginContext)
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
+		return
+	}
+	owner, err := matrix.ParseOwnerSlot(ginContext.Param(bucketOwnerParameter))
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	}
+	offset, limit, err := paginationParameters(ginContext)
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	snapshot := handler.store.Snapshot()
+	if snapshot.ComparisonData == nil {
+		handler.writeJSONError(ginContext, http.StatusConflict, errMessageComparisonUnavailable)
+		return
+	}
+	comparison := handler.buildComparison(snapshot.ComparisonData, options)
+	records, err := comparison.Bucket(owner, matrix.BucketName(ginContext.Param(bucketNameParameter)))
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	}
+	ginContext.Header("Content-Type", jsonContentType)
+	ginContext.JSON(http.StatusOK, matrix.PaginateRecords(records, offset, limit))
+}
+
+func (handler applicationHandler) serveAccount(ginContext *gin.Context) {
+	snapshot := handler.store.Snapshot()
+	if snapshot.ComparisonData == nil {
+		handler.writeJSONError(ginContext, http.StatusConflict, errMessageAccountsUnavailable)
+		return
+	}
+	comparison := handler.buildComparison(snapshot.ComparisonData, matrix.ComparisonOptions{SortMode: handler.sortMode, Locale: handler.sortLocale})
+	detail, err := comparison.DescribeResolvedAccount(ginContext.Request.Context(), ginContext.Param(accountReferenceParameter), snapshot.ComparisonData.ResolutionErrors, handler.accountIDResolver())
+	if err != nil {
+		handler.writeJSONError(ginContext, http.StatusNotFound, err.Error())
+		return
+	}
+	ginContext.Header("Content-Type", jsonContentType)
+	ginContext.JSON(http.StatusOK, detail)
+}
+
+// accountIDResolver returns the handle resolver when network resolution is enabled and it can look handles up.
+func (handler applicationHandler) accountIDResolver() matrix.AccountIDResolver {
+	if !handler.resolveHandles {
+		return nil
+	}
+	idResolver, _ := handler.handleResolver.(matrix.AccountIDResolver)
+	return idResolver
+}
+
+// comparisonOptions combines the configured ordering with the sort and filter query parameters; invalid input falls
+// back to the configured ordering and every account, and is reported to the caller.
+func (handler applicationHandler) comparisonOptions(ginContext *gin.Context) (matrix.ComparisonOptions, error) {
+	options := matrix.ComparisonOptions{SortMode: handler.sortMode, Locale: handler.sortLocale}
+	filter, err := matrix.ParseAccountFilter(ginContext.Query(filterQueryParameter))
+	if err != nil {
+		return options, err
+	}
+	options.Filter = filter
+	requestedSort := strings.TrimSpace(ginContext.Query(sortQueryParameter))
+	if requestedSort == "" {
+		return options, nil
+	}
+	sortMode, err := matrix.ParseSortMode(requestedSort)
+	if err != nil {
+		return options, err
+	}
+	options.SortMode = sortMode
+	return options, nil
+}
+
+// buildComparison returns the comparison of comparisonData for options, built once per snapshot of the store.
+func (handler applicationHandler) buildComparison(comparisonData *ComparisonData, options matrix.ComparisonOptions) matrix.ComparisonResult {
+	cache := handler.comparisons
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.data != comparisonData {
+		cache.data = comparisonData
+		cache.results = make(map[matrix.ComparisonOptions]matrix.ComparisonResult)
+	}
+	if result, cached := cache.results[options]; cached {
+		return result
+	}
+	result := handler.service.BuildComparison(
+		comparisonData.AccountSetsA,
+		comparisonData.AccountSetsB,
+		comparisonData.OwnerA,
+		comparisonData.OwnerB,
+		options,
+	)
+	cache.results[options] = result
+	return result
+}
+
+func paginationParameters(ginContext *gin.Context) (int, int, error) {
+	offset, err := nonNegativeQueryInteger(ginContext, offsetQueryParameter)
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, err := nonNegativeQueryInteger(ginContext, limitQueryParameter)
+	if err != nil {
+		return 0, 0, err
+	}
+	return offset, limit, nil
+}
+
+func nonNegativeQueryInteger(ginContext *gin.Context, name string) (int, error) {
+	rawValue := strings.TrimSpace(ginContext.Query(name))
+	if rawValue == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(rawValue)
+	if err != nil || value < 0 {
+		return 0, errors.New(errMessageInvalidPagination)
+	}
+	return value, nil
+}
+
 func (handler applicationHandler) healthStatus(ginContext *gin.Context) {
 	ginContext.JSON(http.StatusOK, map[string]string{healthStatusKey: healthStatusOK})
 }
 
+// uploadArchives saves the uploaded archives and reads them in an upload job, answering with the job so that large
+// archives do not hold the request open.
 func (handler applicationHandler) uploadArchives(ginContext *gin.Context) {
 	multipartForm, err := ginContext.MultipartForm()
 	if err != nil {
@@ -199,7 +444,7 @@ func (handler applicationHandler) uploadArchives(ginContext *gin.Context) {
 		return
 	}
 
-	var snapshot ComparisonSnapshot
+	savedArchives := make([]savedArchive, 0, len(files))
 	for _, fileHeader := range files {
 		tempPath, cleanup, saveErr := handler.saveUploadedFile(ginContext, fileHeader)
 		if saveErr != nil {
@@ -208,54 +453,107 @@ func (handler applicationHandler) uploadArchives(ginContext *gin.Context) {
 			if cleanup != nil {
 				cleanup()
 			}
+			removeSavedArchives(savedArchives)
 			return
 		}
+		savedArchives = append(savedArchives, savedArchive{fileName: fileHeader.Filename, path: tempPath, cleanup: cleanup})
+	}
 
-		accountSets, owner, parseErr := matrix.ReadTwitterZip(tempPath)
-		if cleanup != nil {
-			cleanup()
+	job := handler.jobs.start(jobKindUpload, func(ctx context.Context, tracker *jobTracker) (any, error) {
+		defer removeSavedArchives(savedArchives)
+		return handler.readArchives(ctx, tracker, savedArchives)
+	})
+	ginContext.Header("Content-Type", jsonContentType)
+	ginContext.JSON(http.StatusAccepted, jobResponse{Job: job})
+}
+
+// savedArchive is an uploaded archive copied to a temporary file for its upload job.
+type savedArchive struct {
+	fileName string
+	path     string
+	cleanup  func()
+}
+
+func removeSavedArchives(savedArchives []savedArchive) {
+	for _, archive := range savedArchives {
+		archive.cleanup()
+	}
+}
+
+// readArchives parses and stores each archive in turn, then starts handle resolution once both archives are present.
+// Archives stored before a failure or a cancellation stay stored; once the job is cancelled, including by a clear,
+// nothing more is stored and no resolution starts.
+func (handler applicationHandler) readArchives(ctx context.Context, tracker *jobTracker, savedArchives []savedArchive) (uploadResponse, error) {
+	var snapshot ComparisonSnapshot
+	for index, archive := range savedArchives {
+		if err := ctx.Err(); err != nil {
+			return uploadResponse{}, err
 		}
+		tracker.setProgress(jobProgress{Total: len(savedArchives), Completed: index})
+		accountSets, owner, parseErr := matrix.ReadTwitterZip(archive.path)
 		if parseErr != nil {
-			handler.logger.Error(logMessageArchiveParseFailure, zap.Error(parseErr), zap.String(logFieldArchiveName, fileHeader.Filename))
-			handler.writeJSONError(ginContext, http.StatusBadRequest, fmt.Sprintf("%s: %v", errMessageInvalidArchive, parseErr))
-			return
+			handler.logger.Error(logMessageArchiveParseFailure, zap.Error(parseErr), zap.String(logFieldArchiveName, archive.fileName))
+			return uploadResponse{}, fmt.Errorf("%s: %v", errMessageInvalidArchive, parseErr)
 		}
 
-		upload := ArchiveUpload{FileName: fileHeader.Filename, AccountSets: accountSets, Owner: owner}
-		snapshot, err = handler.store.Upsert(upload)
+		matrix.RecordArchiveHistory(handler.handleHistory, &accountSets)
+		var err error
+		snapshot, err = handler.storeArchive(ctx, ArchiveUpload{FileName: archive.fileName, AccountSets: accountSets, Owner: owner})
+		if errors.Is(err, context.Canceled) {
+			return uploadResponse{}, err
+		}
 		if err != nil {
-			handler.logger.Error(logMessageStoreFailure, zap.Error(err), zap.String(logFieldArchiveName, fileHeader.Filename))
+			handler.logger.Error(logMessageStoreFailure, zap.Error(err), zap.String(logFieldArchiveName, archive.fileName))
 			if errors.Is(err, errTooManyArchives) {
-				handler.writeJSONError(ginContext, http.StatusBadRequest, err.Error())
-			} else {
-				handler.writeJSONError(ginContext, http.StatusInternalServerError, errMessageStoreUpdate)
+				return uploadResponse{}, err
 			}
-			return
+			return uploadResponse{}, errors.New(errMessageStoreUpdate)
 		}
 	}
+	tracker.setProgress(jobProgress{Total: len(savedArchives), Completed: len(savedArchives)})
 
 	if handler.resolveHandles && handler.handleResolver != nil && snapshot.ComparisonData != nil {
-		handler.logger.Info(logMessageHandleResolution)
-		go handler.resolveHandlesAsync()
+		if err := handler.startUploadResolution(ctx); err != nil {
+			return uploadResponse{}, err
+		}
 	}
-
-	ginContext.Header("Content-Type", jsonContentType)
-	ginContext.JSON(http.StatusOK, uploadResponse{
+	return uploadResponse{
 		Uploads:         snapshot.Uploads,
 		ComparisonReady: snapshot.ComparisonData != nil,
-	})
+	}, nil
 }
 
-func (handler applicationHandler) resetArchives(ginContext *gin.Context) {
-	handler.store.Clear()
-	ginContext.Status(http.StatusNoContent)
+// storeArchive stores upload unless the upload job owning ctx was cancelled, which resetArchives does under the same
+// lock.
+func (handler applicationHandler) storeArchive(ctx context.Context, upload ArchiveUpload) (ComparisonSnapshot, error) {
+	handler.archiveMutex.Lock()
+	defer handler.archiveMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return ComparisonSnapshot{}, err
+	}
+	return handler.store.Upsert(upload)
 }
 
-func (handler applicationHandler) resolveHandlesAsync() {
-	errorsByAccountID := handler.store.ResolveHandles(context.Background(), handler.handleResolver)
-	for accountID, resolutionErr := range errorsByAccountID {
-		handler.logger.Warn(logMessageHandleResolutionError, zap.String(logFieldAccountID, accountID), zap.Error(resolutionErr))
+// startUploadResolution starts handle resolution for the archives stored by the upload job owning ctx unless the job
+// was cancelled.
+func (handler applicationHandler) startUploadResolution(ctx context.Context) error {
+	handler.archiveMutex.Lock()
+	defer handler.archiveMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	handler.startResolution()
+	return nil
+}
+
+// resetArchives clears the stored archives and cancels running upload jobs, which would otherwise store their
+// archives again once they finish reading them.
+func (handler applicationHandler) resetArchives(ginContext *gin.Context) {
+	handler.archiveMutex.Lock()
+	defer handler.archiveMutex.Unlock()
+	handler.jobs.cancelKind(jobKindUpload)
+	handler.store.Clear()
+	ginContext.Status(http.StatusNoContent)
 }
 
 func (handler applicationHandler) saveUploadedFile(ginContext *gin.Context, fileHeader *multipart.FileHeader) (string, func(), error) {
@@ -291,9 +589,16 @@ type errorResponse struct {
 }
 
 type memoryComparisonStore struct {
-	mutex     sync.RWMutex
-	primary   *archiveRecord
-	secondary *archiveRecord
+	mutex            sync.RWMutex
+	version          uint64
+	primary          *archiveRecord
+	secondary        *archiveRecord
+	resolutionErrors map[string]error
+	// comparison is the reconciled comparison data of the stored archives, rebuilt whenever they or their resolution
+	// results change and shared by every snapshot in between.
+	comparison *ComparisonData
+	job        ResolutionJob
+	cancelJob  context.CancelFunc
 }
 
 type archiveRecord struct {
@@ -326,21 +631,25 @@ func (store *memoryComparisonStore) Upsert(upload ArchiveUpload) (ComparisonSnap
 	if store.primary == nil {
 		record.slotLabel = slotLabelPrimary
 		store.primary = &record
+		store.advanceLocked()
 		return store.snapshotLocked(), nil
 	}
 	if sameOwner(store.primary.owner, record.owner) || sameFileForUnknownOwner(*store.primary, record) {
 		record.slotLabel = store.primary.slotLabel
 		store.primary = &record
+		store.advanceLocked()
 		return store.snapshotLocked(), nil
 	}
 	if store.secondary == nil {
 		record.slotLabel = slotLabelSecondary
 		store.secondary = &record
+		store.advanceLocked()
 		return store.snapshotLocked(), nil
 	}
 	if sameOwner(store.secondary.owner, record.owner) || sameFileForUnknownOwner(*store.secondary, record) {
 		record.slotLabel = store.secondary.slotLabel
 		store.secondary = &record
+		store.advanceLocked()
 		return store.snapshotLocked(), nil
 	}
 	return ComparisonSnapshot{}, errTooManyArchives
@@ -351,32 +660,87 @@ func (store *memoryComparisonStore) Clear() ComparisonSnapshot {
 	defer store.mutex.Unlock()
 	store.primary = nil
 	store.secondary = nil
+	store.resolutionErrors = nil
+	store.advanceLocked()
 	return store.snapshotLocked()
 }
 
-func (store *memoryComparisonStore) ResolveHandles(ctx context.Context, resolver matrix.AccountHandleResolver) map[string]error {
-	store.mutex.RLock()
+// advanceLocked starts a new version: resolution errors no longer apply and the running job is cancelled.
+func (store *memoryComparisonStore) advanceLocked() {
+	store.version++
+	store.resolutionErrors = nil
+	store.stopJobLocked(ResolutionStateCancelled)
+	store.refreshComparisonLocked()
+}
+
+// stopJobLocked releases the context of the running job and records its final state.
+func (store *memoryComparisonStore) stopJobLocked(state ResolutionState) {
+	if store.cancelJob != nil {
+		store.cancelJob()
+		store.cancelJob = nil
+	}
+	if store.job.State == ResolutionStateRunning {
+		store.job.State = state
+		store.job.FinishedAt = time.Now().UTC()
+	}
+}
+
+func (store *memoryComparisonStore) StartResolution(parent context.Context) (context.Context, ResolutionJob, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 	if store.primary == nil || store.secondary == nil {
+		return nil, ResolutionJob{}, false
+	}
+	store.stopJobLocked(ResolutionStateCancelled)
+	ctx, cancel := context.WithCancel(parent)
+	store.job = ResolutionJob{ID: store.job.ID + 1, Version: store.version, State: ResolutionStateRunning, StartedAt: time.Now().UTC()}
+	store.cancelJob = cancel
+	return ctx, store.job, true
+}
+
+func (store *memoryComparisonStore) ResolutionJob() ResolutionJob {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.job
+}
+
+func (store *memoryComparisonStore) ResolveHandles(ctx context.Context, job ResolutionJob, resolver matrix.AccountHandleResolver, policy matrix.ResolutionPolicy) (map[string]error, error) {
+	if job.ID == 0 {
+		return nil, errResolutionNotStarted
+	}
+	store.mutex.RLock()
+	if !store.currentJobLocked(job) || store.primary == nil || store.secondary == nil {
 		store.mutex.RUnlock()
-		return nil
+		return nil, ErrStaleResolution
 	}
-	primary := *store.primary
-	secondary := *store.secondary
+	accountSetsPrimary := copyAccountSets(store.primary.accountSets)
+	accountSetsSecondary := copyAccountSets(store.secondary.accountSets)
 	store.mutex.RUnlock()
 
-	accountSetsPrimary := copyAccountSets(primary.accountSets)
-	accountSetsSecondary := copyAccountSets(secondary.accountSets)
-	errorsByAccountID := matrix.MaybeResolveHandles(ctx, resolver, true, &accountSetsPrimary, &accountSetsSecondary)
+	matrix.ReconcileLabels(&accountSetsPrimary, &accountSetsSecondary)
+	errorsByAccountID := matrix.MaybeResolveHandlesWithPolicy(ctx, resolver, true, policy, &accountSetsPrimary, &accountSetsSecondary)
+	matrix.ReconcileLabels(&accountSetsPrimary, &accountSetsSecondary)
 
 	store.mutex.Lock()
-	if store.primary != nil && (sameOwner(store.primary.owner, primary.owner) || sameFileForUnknownOwner(*store.primary, primary)) {
-		store.primary.accountSets = accountSetsPrimary
+	defer store.mutex.Unlock()
+	if !store.currentJobLocked(job) {
+		return nil, ErrStaleResolution
 	}
-	if store.secondary != nil && (sameOwner(store.secondary.owner, secondary.owner) || sameFileForUnknownOwner(*store.secondary, secondary)) {
-		store.secondary.accountSets = accountSetsSecondary
+	if err := ctx.Err(); err != nil {
+		store.stopJobLocked(ResolutionStateCancelled)
+		return nil, err
 	}
-	store.mutex.Unlock()
-	return errorsByAccountID
+	store.primary.accountSets = accountSetsPrimary
+	store.secondary.accountSets = accountSetsSecondary
+	store.resolutionErrors = copyErrorMap(errorsByAccountID)
+	store.refreshComparisonLocked()
+	store.stopJobLocked(ResolutionStateDone)
+	return errorsByAccountID, nil
+}
+
+// currentJobLocked reports whether job is the running job of the current version.
+func (store *memoryComparisonStore) currentJobLocked(job ResolutionJob) bool {
+	return store.job.ID == job.ID && store.job.State == ResolutionStateRunning && store.version == job.Version
 }
 
 func (store *memoryComparisonStore) snapshotLocked() ComparisonSnapshot {
@@ -387,16 +751,26 @@ func (store *memoryComparisonStore) snapshotLocked() ComparisonSnapshot {
 	if store.secondary != nil {
 		uploads = append(uploads, matrix.UploadSummary{SlotLabel: store.secondary.slotLabel, OwnerLabel: ownerSummary(store.secondary.owner), FileName: store.secondary.fileName})
 	}
-	var comparison *ComparisonData
-	if store.primary != nil && store.secondary != nil {
-		comparison = &ComparisonData{
-			AccountSetsA: copyAccountSets(store.primary.accountSets),
-			AccountSetsB: copyAccountSets(store.secondary.accountSets),
-			OwnerA:       store.primary.owner,
-			OwnerB:       store.secondary.owner,
-		}
+	return ComparisonSnapshot{Version: store.version, Uploads: uploads, ComparisonData: store.comparison}
+}
+
+// refreshComparisonLocked rebuilds the comparison data from the stored archives and resolution results.
+func (store *memoryComparisonStore) refreshComparisonLocked() {
+	store.comparison = nil
+	if store.primary == nil || store.secondary == nil {
+		return
+	}
+	accountSetsA := copyAccountSets(store.primary.accountSets)
+	accountSetsB := copyAccountSets(store.secondary.accountSets)
+	reconciliation := matrix.ReconcileLabels(&accountSetsA, &accountSetsB)
+	store.comparison = &ComparisonData{
+		AccountSetsA:     accountSetsA,
+		AccountSetsB:     accountSetsB,
+		OwnerA:           store.primary.owner,
+		OwnerB:           store.secondary.owner,
+		LabelConflicts:   reconciliation.Conflicts,
+		ResolutionErrors: copyErrorMap(store.resolutionErrors),
 	}
-	return ComparisonSnapshot{Uploads: uploads, ComparisonData: comparison}
 }
 
 func sameOwner(first matrix.OwnerIdentity, second matrix.OwnerIdentity) bool {
@@ -441,10 +815,14 @@ func ownerSummary(owner matrix.OwnerIdentity) string {
 
 func copyAccountSets(source matrix.AccountSets) matrix.AccountSets {
 	return matrix.AccountSets{
-		Followers: copyAccountRecordMap(source.Followers),
-		Following: copyAccountRecordMap(source.Following),
-		Muted:     copyBoolMap(source.Muted),
-		Blocked:   copyBoolMap(source.Blocked),
+		Followers:   copyAccountRecordMap(source.Followers),
+		Following:   copyAccountRecordMap(source.Following),
+		Muted:       copyBoolMap(source.Muted),
+		Blocked:     copyBoolMap(source.Blocked),
+		Labels:      copyAccountRecordMap(source.Labels),
+		ExportOrder: copyIntMap(source.ExportOrder),
+		// List memberships are never modified after loading, so the copy shares them.
+		ListMemberships: source.ListMemberships,
 	}
 }
 
@@ -469,3 +847,25 @@ func copyBoolMap(source map[string]bool) map[string]bool {
 	}
 	return cloned
 }
+
+func copyIntMap(source map[string]int) map[string]int {
+	if len(source) == 0 {
+		return map[string]int{}
+	}
+	cloned := make(map[string]int, len(source))
+	for key, value := range source {
+		cloned[key] = value
+	}
+	return cloned
+}
+
+func copyErrorMap(source map[string]error) map[string]error {
+	if len(source) == 0 {
+		return map[string]error{}
+	}
+	cloned := make(map[string]error, len(source))
+	for key, value := range source {
+		cloned[key] = value
+	}
+	return cloned
+}
@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -13,10 +14,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/f-sync/fsync/internal/handles"
 	"github.com/f-sync/fsync/internal/matrix"
+	"github.com/f-sync/fsync/internal/ratelimit"
 	"github.com/f-sync/fsync/internal/server"
+	"github.com/f-sync/fsync/internal/testing/fakex"
 )
 
 type comparisonServiceStub struct {
@@ -25,8 +31,8 @@ type comparisonServiceStub struct {
 	lastPageData matrix.ComparisonPageData
 }
 
-func (stub *comparisonServiceStub) BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity) matrix.ComparisonResult {
-	return matrix.ComparisonResult{AccountSetsA: accountSetsA, AccountSetsB: accountSetsB, OwnerA: ownerA, OwnerB: ownerB}
+func (stub *comparisonServiceStub) BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity, options matrix.ComparisonOptions) matrix.ComparisonResult {
+	return matrix.ComparisonResult{AccountSetsA: accountSetsA, AccountSetsB: accountSetsB, OwnerA: ownerA, OwnerB: ownerB, Options: options}
 }
 
 func (stub *comparisonServiceStub) RenderComparisonPage(pageData matrix.ComparisonPageData) (string, error) {
@@ -34,6 +40,17 @@ func (stub *comparisonServiceStub) RenderComparisonPage(pageData matrix.Comparis
 	return stub.renderedHTML, stub.renderError
 }
 
+// countingComparisonService builds comparisons through the matrix package and counts the builds.
+type countingComparisonService struct {
+	server.MatrixComparisonService
+	builds atomic.Int32
+}
+
+func (service *countingComparisonService) BuildComparison(accountSetsA matrix.AccountSets, accountSetsB matrix.AccountSets, ownerA matrix.OwnerIdentity, ownerB matrix.OwnerIdentity, options matrix.ComparisonOptions) matrix.ComparisonResult {
+	service.builds.Add(1)
+	return service.MatrixComparisonService.BuildComparison(accountSetsA, accountSetsB, ownerA, ownerB, options)
+}
+
 type comparisonStoreStub struct {
 	snapshot server.ComparisonSnapshot
 }
@@ -50,8 +67,16 @@ func (comparisonStoreStub) Clear() server.ComparisonSnapshot {
 	return server.ComparisonSnapshot{}
 }
 
-func (comparisonStoreStub) ResolveHandles(context.Context, matrix.AccountHandleResolver) map[string]error {
-	return nil
+func (comparisonStoreStub) StartResolution(context.Context) (context.Context, server.ResolutionJob, bool) {
+	return nil, server.ResolutionJob{}, false
+}
+
+func (comparisonStoreStub) ResolveHandles(context.Context, server.ResolutionJob, matrix.AccountHandleResolver, matrix.ResolutionPolicy) (map[string]error, error) {
+	return nil, nil
+}
+
+func (comparisonStoreStub) ResolutionJob() server.ResolutionJob {
+	return server.ResolutionJob{}
 }
 
 func TestServeComparisonResponses(t *testing.T) {
@@ -164,14 +189,10 @@ func TestUploadArchivesFlow(t *testing.T) {
 	})
 
 	// First upload
-	recorder := httptest.NewRecorder()
-	request := newUploadRequest(t, archiveA)
-	router.ServeHTTP(recorder, request)
-	if recorder.Code != http.StatusOK {
-		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
-	}
 	var firstResponse uploadResponse
-	if err := json.Unmarshal(recorder.Body.Bytes(), &firstResponse); err != nil {
+	if job := uploadArchive(t, router, archiveA); job.State != "done" {
+		t.Fatalf("expected the upload job to finish, got %+v", job)
+	} else if err := json.Unmarshal(job.Result, &firstResponse); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 	if firstResponse.ComparisonReady {
@@ -185,14 +206,10 @@ func TestUploadArchivesFlow(t *testing.T) {
 	}
 
 	// Second upload
-	recorder = httptest.NewRecorder()
-	request = newUploadRequest(t, archiveB)
-	router.ServeHTTP(recorder, request)
-	if recorder.Code != http.StatusOK {
-		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
-	}
 	var secondResponse uploadResponse
-	if err := json.Unmarshal(recorder.Body.Bytes(), &secondResponse); err != nil {
+	if job := uploadArchive(t, router, archiveB); job.State != "done" {
+		t.Fatalf("expected the upload job to finish, got %+v", job)
+	} else if err := json.Unmarshal(job.Result, &secondResponse); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 	if !secondResponse.ComparisonReady {
@@ -209,8 +226,8 @@ func TestUploadArchivesFlow(t *testing.T) {
 	}
 
 	// Ensure GET renders HTML containing the owner name
-	recorder = httptest.NewRecorder()
-	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
 	router.ServeHTTP(recorder, request)
 	if recorder.Code != http.StatusOK {
 		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
@@ -220,6 +237,596 @@ func TestUploadArchivesFlow(t *testing.T) {
 	}
 }
 
+type handlePageFetcherStub struct{}
+
+func (handlePageFetcherStub) FetchIntentPage(_ context.Context, request handles.IntentRequest) (handles.IntentPage, error) {
+	return handles.IntentPage{UserName: "user" + request.AccountID, SourceURL: request.URL}, nil
+}
+
+type resolutionStatusResponse struct {
+	State     string `json:"state"`
+	Version   uint64 `json:"version"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Resolved  int    `json:"resolved"`
+	Failed    int    `json:"failed"`
+}
+
+func TestResolutionProgressEndpoint(t *testing.T) {
+	const (
+		resolutionRoute      = "/api/resolution"
+		expectedTotal        = 3
+		resolutionPollPeriod = 10 * time.Millisecond
+		resolutionDeadline   = 5 * time.Second
+	)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: handlePageFetcherStub{}, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	fetchStatus := func() resolutionStatusResponse {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, resolutionRoute, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+		var status resolutionStatusResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode resolution status: %v", err)
+		}
+		return status
+	}
+	if status := fetchStatus(); status.State != "idle" {
+		t.Fatalf("expected idle resolution before uploads, got %q", status.State)
+	}
+
+	archives := []map[string]string{
+		{
+			"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a","displayName":"Owner A"}}`,
+			"following.js": `[{"following":{"accountId":"810"}},{"following":{"accountId":"811"}}]`,
+		},
+		{
+			"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b","displayName":"Owner B"}}`,
+			"follower.js": `[{"follower":{"accountId":"810"}},{"follower":{"accountId":"812"}}]`,
+		},
+	}
+	for _, archive := range archives {
+		if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+			t.Fatalf("expected the upload job to finish, got %+v", job)
+		}
+	}
+
+	deadline := time.Now().Add(resolutionDeadline)
+	status := fetchStatus()
+	for status.State != "done" && time.Now().Before(deadline) {
+		if status.State != "running" {
+			t.Fatalf("expected running resolution after uploads, got %q", status.State)
+		}
+		time.Sleep(resolutionPollPeriod)
+		status = fetchStatus()
+	}
+	if status.State != "done" {
+		t.Fatalf("resolution did not finish, last status %+v", status)
+	}
+	if status.Total != expectedTotal || status.Completed != expectedTotal || status.Resolved != expectedTotal || status.Failed != 0 {
+		t.Fatalf("unexpected final progress %+v", status)
+	}
+}
+
+// blockingPageFetcherStub holds every fetch until release is closed or the fetch context is cancelled.
+type blockingPageFetcherStub struct {
+	started   chan struct{}
+	cancelled chan struct{}
+	release   chan struct{}
+}
+
+func newBlockingPageFetcherStub() blockingPageFetcherStub {
+	return blockingPageFetcherStub{started: make(chan struct{}, 16), cancelled: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (stub blockingPageFetcherStub) FetchIntentPage(ctx context.Context, request handles.IntentRequest) (handles.IntentPage, error) {
+	stub.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		stub.cancelled <- struct{}{}
+		return handles.IntentPage{}, ctx.Err()
+	case <-stub.release:
+		return handles.IntentPage{UserName: "user" + request.AccountID, SourceURL: request.URL}, nil
+	}
+}
+
+func TestResolutionCancelledWhenArchivesChange(t *testing.T) {
+	const (
+		resolutionRoute      = "/api/resolution"
+		uploadsRoute         = "/api/uploads"
+		resolutionPollPeriod = 10 * time.Millisecond
+		resolutionDeadline   = 5 * time.Second
+	)
+	archiveA := map[string]string{
+		"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a","displayName":"Owner A"}}`,
+		"following.js": `[{"following":{"accountId":"910"}}]`,
+	}
+	archiveB := map[string]string{
+		"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b","displayName":"Owner B"}}`,
+		"follower.js": `[{"follower":{"accountId":"910"}}]`,
+	}
+	testCases := []struct {
+		name string
+		// change replaces or clears the archives while the first resolution runs.
+		change func(t *testing.T, router http.Handler)
+		// fetchCancelled reports that the change leaves no job waiting for the first fetch, which is then cancelled.
+		fetchCancelled bool
+		// settled reports whether status, polled after the change, shows the expected job.
+		settled func(first resolutionStatusResponse, status resolutionStatusResponse) bool
+	}{
+		{
+			name: "clear",
+			change: func(t *testing.T, router http.Handler) {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, uploadsRoute, nil))
+				if recorder.Code != http.StatusNoContent {
+					t.Fatalf("expected status %d, got %d", http.StatusNoContent, recorder.Code)
+				}
+			},
+			fetchCancelled: true,
+			settled: func(first resolutionStatusResponse, status resolutionStatusResponse) bool {
+				return status.State == "cancelled" && status.Version == first.Version
+			},
+		},
+		{
+			name: "re-upload",
+			change: func(t *testing.T, router http.Handler) {
+				if job := uploadArchive(t, router, createArchive(t, archiveA)); job.State != "done" {
+					t.Fatalf("expected the upload job to finish, got %+v", job)
+				}
+			},
+			// The job for the new version may take over the first job's lookup of the same account, but it is never
+			// cancelled.
+			settled: func(first resolutionStatusResponse, status resolutionStatusResponse) bool {
+				return status.State != "cancelled" && status.Version > first.Version
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			fetcher := newBlockingPageFetcherStub()
+			resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+			if err != nil {
+				t.Fatalf("create resolver: %v", err)
+			}
+			router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver})
+			if err != nil {
+				t.Fatalf("NewRouter returned error: %v", err)
+			}
+			defer close(fetcher.release)
+			fetchStatus := func() resolutionStatusResponse {
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, resolutionRoute, nil))
+				var status resolutionStatusResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+					t.Fatalf("decode resolution status: %v", err)
+				}
+				return status
+			}
+			waitFor := func(signal <-chan struct{}, description string) {
+				select {
+				case <-signal:
+				case <-time.After(resolutionDeadline):
+					t.Fatalf("timed out waiting for %s", description)
+				}
+			}
+
+			for _, archive := range []map[string]string{archiveA, archiveB} {
+				if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+					t.Fatalf("expected the upload job to finish, got %+v", job)
+				}
+			}
+			waitFor(fetcher.started, "the first fetch")
+			first := fetchStatus()
+			if first.State != "running" {
+				t.Fatalf("expected a running resolution, got %+v", first)
+			}
+
+			testCase.change(t, router)
+			if testCase.fetchCancelled {
+				waitFor(fetcher.cancelled, "the first fetch to be cancelled")
+			}
+			deadline := time.Now().Add(resolutionDeadline)
+			status := fetchStatus()
+			for !testCase.settled(first, status) && time.Now().Before(deadline) {
+				time.Sleep(resolutionPollPeriod)
+				status = fetchStatus()
+			}
+			if !testCase.settled(first, status) {
+				t.Fatalf("unexpected resolution status %+v after %+v", status, first)
+			}
+		})
+	}
+}
+
+func TestCancelResolutionJob(t *testing.T) {
+	fetcher := newBlockingPageFetcherStub()
+	defer close(fetcher.release)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	for _, archive := range []map[string]string{
+		{"manifest.js": `{"userInfo":{"accountId":"1","userName":"owner_a"}}`, "following.js": `[{"following":{"accountId":"930"}}]`},
+		{"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b"}}`, "follower.js": `[{"follower":{"accountId":"930"}}]`},
+	} {
+		if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+			t.Fatalf("expected the upload job to finish, got %+v", job)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	var jobs []jobStatusResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jobs); err != nil {
+		t.Fatalf("decode jobs: %v", err)
+	}
+	if len(jobs) != 3 || jobs[2].Kind != "resolution" || jobs[2].State != "running" {
+		t.Fatalf("expected two uploads and a running resolution, got %+v", jobs)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+jobs[2].ID, nil))
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, recorder.Code)
+	}
+	if job := waitForJob(t, router, jobs[2].ID); job.State != "cancelled" {
+		t.Fatalf("expected the resolution job to be cancelled, got %+v", job)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/resolution", nil))
+	if !strings.Contains(recorder.Body.String(), `"state":"cancelled"`) {
+		t.Fatalf("expected the store to report a cancelled resolution, got %s", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/jobs/999", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for an unknown job, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+// gatedHistoryStore holds the archive observations of one account until release is closed, keeping its upload job
+// between reading and storing the archive.
+type gatedHistoryStore struct {
+	accountID string
+	entered   chan struct{}
+	release   chan struct{}
+}
+
+func (gatedHistoryStore) History(string) []handles.HandleObservation {
+	return nil
+}
+
+func (store gatedHistoryStore) Observe(accountID string, _ handles.HandleObservation) {
+	if accountID != store.accountID {
+		return
+	}
+	store.entered <- struct{}{}
+	<-store.release
+}
+
+func TestClearCancelsRunningUploads(t *testing.T) {
+	const gatedAccountID = "950"
+	history := gatedHistoryStore{accountID: gatedAccountID, entered: make(chan struct{}, 1), release: make(chan struct{})}
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: handlePageFetcherStub{}, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver, HandleHistory: history})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	archiveA := createArchive(t, map[string]string{
+		"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a"},"archiveInfo":{"generationDate":"2024-01-01T00:00:00Z"}}`,
+		"following.js": `[{"following":{"accountId":"940","userName":"friend_a"}}]`,
+	})
+	archiveB := createArchive(t, map[string]string{
+		"manifest.js":  `{"userInfo":{"accountId":"2","userName":"owner_b"},"archiveInfo":{"generationDate":"2024-01-01T00:00:00Z"}}`,
+		"following.js": `[{"following":{"accountId":"` + gatedAccountID + `","userName":"friend_b"}}]`,
+	})
+	if job := uploadArchive(t, router, archiveA); job.State != "done" {
+		t.Fatalf("expected the upload job to finish, got %+v", job)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newUploadRequest(t, archiveB))
+	var envelope jobEnvelope
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode upload response: %v", err)
+	}
+	select {
+	case <-history.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the second upload to read its archive")
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/uploads", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	close(history.release)
+	if job := waitForJob(t, router, envelope.Job.ID); job.State != "cancelled" {
+		t.Fatalf("expected the cleared upload job to be cancelled, got %+v", job)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	var jobs []jobStatusResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jobs); err != nil {
+		t.Fatalf("decode jobs: %v", err)
+	}
+	for _, job := range jobs {
+		if job.Kind == "resolution" {
+			t.Fatalf("expected no resolution for cleared archives, got %+v", jobs)
+		}
+	}
+	var response uploadResponse
+	if job := uploadArchive(t, router, archiveA); job.State != "done" {
+		t.Fatalf("expected the upload job to finish, got %+v", job)
+	} else if err := json.Unmarshal(job.Result, &response); err != nil {
+		t.Fatalf("decode upload result: %v", err)
+	}
+	if len(response.Uploads) != 1 || response.ComparisonReady {
+		t.Fatalf("expected only the new upload after the clear, got %+v", response)
+	}
+}
+
+func TestRouterContextCancelsJobs(t *testing.T) {
+	fetcher := newBlockingPageFetcherStub()
+	defer close(fetcher.release)
+	resolver, err := handles.NewResolver(handles.Config{IntentFetcher: fetcher, Cache: handles.NewMemoryCache(), MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver, Context: ctx})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	for _, archive := range []map[string]string{
+		{"manifest.js": `{"userInfo":{"accountId":"1","userName":"owner_a"}}`, "following.js": `[{"following":{"accountId":"960"}}]`},
+		{"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b"}}`, "follower.js": `[{"follower":{"accountId":"960"}}]`},
+	} {
+		if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+			t.Fatalf("expected the upload job to finish, got %+v", job)
+		}
+	}
+	select {
+	case <-fetcher.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the resolution to fetch")
+	}
+
+	cancel()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	var jobs []jobStatusResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jobs); err != nil {
+		t.Fatalf("decode jobs: %v", err)
+	}
+	if len(jobs) != 3 || jobs[2].Kind != "resolution" {
+		t.Fatalf("expected two uploads and a resolution, got %+v", jobs)
+	}
+	if job := waitForJob(t, router, jobs[2].ID); job.State != "cancelled" {
+		t.Fatalf("expected the resolution job to be cancelled, got %+v", job)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/resolution", nil))
+	if !strings.Contains(recorder.Body.String(), `"state":"cancelled"`) {
+		t.Fatalf("expected the store to report a cancelled resolution, got %s", recorder.Body.String())
+	}
+}
+
+func TestJobEventsStream(t *testing.T) {
+	router, err := server.NewRouter(server.RouterConfig{})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	httpServer := httptest.NewServer(router)
+	defer httpServer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/api/jobs/events", nil)
+	if err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("open event stream: %v", err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Fatalf("expected an event stream, got %q", contentType)
+	}
+
+	archive := createArchive(t, map[string]string{
+		"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a"}}`,
+		"following.js": `[{"following":{"accountId":"10"}}]`,
+	})
+	uploadRequest := newUploadRequest(t, archive)
+	uploadRequest.RequestURI = ""
+	uploadRequest.URL, _ = uploadRequest.URL.Parse(httpServer.URL + "/api/uploads")
+	uploadResponse, err := http.DefaultClient.Do(uploadRequest)
+	if err != nil {
+		t.Fatalf("upload archive: %v", err)
+	}
+	uploadResponse.Body.Close()
+
+	var states []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var job jobStatusResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &job); err != nil {
+			t.Fatalf("decode job event %q: %v", line, err)
+		}
+		if job.Kind != "upload" {
+			t.Fatalf("unexpected job event %+v", job)
+		}
+		states = append(states, job.State)
+		if job.State != "running" {
+			break
+		}
+	}
+	if len(states) == 0 || states[0] != "running" || states[len(states)-1] != "done" {
+		t.Fatalf("expected the upload to run and finish, got states %v (%v)", states, scanner.Err())
+	}
+}
+
+func TestExportJob(t *testing.T) {
+	router, err := server.NewRouter(server.RouterConfig{})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/exports/A/friends", nil))
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected status %d before uploads, got %d", http.StatusConflict, recorder.Code)
+	}
+	for _, archive := range []map[string]string{
+		{
+			"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a"}}`,
+			"following.js": `[{"following":{"accountId":"40","userName":"bravo","displayName":"Bravo"}},{"following":{"accountId":"41","userName":"alpha","displayName":"Alpha"}}]`,
+			"follower.js":  `[{"follower":{"accountId":"40"}},{"follower":{"accountId":"41"}}]`,
+		},
+		{"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b"}}`, "follower.js": `[{"follower":{"accountId":"40"}}]`},
+	} {
+		if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+			t.Fatalf("expected the upload job to finish, got %+v", job)
+		}
+	}
+
+	testCases := []struct {
+		name               string
+		route              string
+		expectedStatusCode int
+		expectedContent    string
+	}{
+		{name: "csv", route: "/api/exports/A/friends", expectedStatusCode: http.StatusAccepted, expectedContent: "id,handle,display_name,status\n41,alpha,Alpha,\n40,bravo,Bravo,\n"},
+		{name: "jsonl", route: "/api/exports/a/friends?format=jsonl", expectedStatusCode: http.StatusAccepted, expectedContent: `{"AccountID":"41","UserName":"alpha","DisplayName":"Alpha",`},
+		{name: "unknown format", route: "/api/exports/A/friends?format=xml", expectedStatusCode: http.StatusBadRequest},
+		{name: "unknown bucket", route: "/api/exports/A/nobody", expectedStatusCode: http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, testCase.route, nil))
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedStatusCode != http.StatusAccepted {
+				return
+			}
+			var envelope jobEnvelope
+			if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("decode export response: %v", err)
+			}
+			job := waitForJob(t, router, envelope.Job.ID)
+			var result struct {
+				Records  int    `json:"records"`
+				Download string `json:"download"`
+			}
+			if err := json.Unmarshal(job.Result, &result); err != nil || job.State != "done" || result.Records != 2 {
+				t.Fatalf("unexpected export job %+v (%v)", job, err)
+			}
+			recorder = httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, result.Download, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status %d for the download, got %d", http.StatusOK, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), testCase.expectedContent) {
+				t.Fatalf("expected the export to contain %q, got %q", testCase.expectedContent, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestResolutionAgainstFakeX(t *testing.T) {
+	const (
+		resolutionRoute      = "/api/resolution"
+		resolutionPollPeriod = 10 * time.Millisecond
+		resolutionDeadline   = 5 * time.Second
+	)
+	fakeServer := fakex.NewServer([]fakex.Account{
+		{ID: "820", UserName: "fake_friend"},
+		{ID: "821", UserName: "fake_fan"},
+		{ID: "822", UserName: "fake_banned", Status: fakex.StatusSuspended},
+	})
+	defer fakeServer.Close()
+	cache := handles.NewMemoryCache()
+	resolver, err := handles.NewResolver(handles.Config{
+		Backends:    []handles.Backend{handles.BackendRedirect},
+		BaseURL:     fakeServer.URL,
+		Cache:       cache,
+		MaxAttempts: 1,
+		RateLimit:   ratelimit.Config{RequestsPerSecond: -1},
+	})
+	if err != nil {
+		t.Fatalf("create resolver: %v", err)
+	}
+	router, err := server.NewRouter(server.RouterConfig{ResolveHandles: true, HandleResolver: resolver})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	archives := []map[string]string{
+		{
+			"manifest.js":  `{"userInfo":{"accountId":"1","userName":"owner_a","displayName":"Owner A"}}`,
+			"following.js": `[{"following":{"accountId":"820"}},{"following":{"accountId":"822"}}]`,
+		},
+		{
+			"manifest.js": `{"userInfo":{"accountId":"2","userName":"owner_b","displayName":"Owner B"}}`,
+			"follower.js": `[{"follower":{"accountId":"821"}}]`,
+		},
+	}
+	for _, archive := range archives {
+		if job := uploadArchive(t, router, createArchive(t, archive)); job.State != "done" {
+			t.Fatalf("expected the upload job to finish, got %+v", job)
+		}
+	}
+
+	var status resolutionStatusResponse
+	for deadline := time.Now().Add(resolutionDeadline); status.State != "done" && time.Now().Before(deadline); time.Sleep(resolutionPollPeriod) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, resolutionRoute, nil))
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode resolution status: %v", err)
+		}
+	}
+	if status.State != "done" || status.Resolved != 2 || status.Failed != 1 {
+		t.Fatalf("unexpected final progress %+v", status)
+	}
+	for accountID, expectedUserName := range map[string]string{"820": "fake_friend", "821": "fake_fan"} {
+		if entry, found := cache.Lookup(accountID); !found || entry.Record.UserName != expectedUserName {
+			t.Fatalf("expected @%s cached for %s, got %+v", expectedUserName, accountID, entry)
+		}
+	}
+	if entry, _ := cache.Lookup("822"); !errors.Is(entry.Err, handles.ErrAccountSuspended) {
+		t.Fatalf("expected the suspended account to be cached as suspended, got %+v", entry)
+	}
+}
+
 func TestUploadArchivesRejectsInvalidZip(t *testing.T) {
 	router, err := server.NewRouter(server.RouterConfig{})
 	if err != nil {
@@ -230,18 +837,317 @@ func TestUploadArchivesRejectsInvalidZip(t *testing.T) {
 		"manifest.js": `{"userInfo":{"accountId":"3"}}`,
 	})
 
-	recorder := httptest.NewRecorder()
-	request := newUploadRequest(t, invalidArchive)
-	router.ServeHTTP(recorder, request)
-	if recorder.Code != http.StatusBadRequest {
-		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	job := uploadArchive(t, router, invalidArchive)
+	if job.State != "failed" {
+		t.Fatalf("expected the upload job to fail, got %+v", job)
 	}
-	var response uploadErrorResponse
-	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	if !strings.Contains(job.Error, "uploaded file must be a Twitter archive zip") {
+		t.Fatalf("expected error message to mention invalid archive, got %q", job.Error)
 	}
-	if !strings.Contains(response.Error, "uploaded file must be a Twitter archive zip") {
-		t.Fatalf("expected error message to mention invalid archive, got %q", response.Error)
+}
+
+func TestServeBucketPaginates(t *testing.T) {
+	comparisonData := &server.ComparisonData{
+		AccountSetsA: matrix.AccountSets{
+			Following: map[string]matrix.AccountRecord{
+				"10": {AccountID: "10", UserName: "zulu"},
+				"11": {AccountID: "11", UserName: "alpha"},
+				"12": {AccountID: "12", UserName: "mike", Status: handles.AccountStatusProtected},
+			},
+		},
+		AccountSetsB: matrix.AccountSets{},
+	}
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedIDs        []string
+		expectedTotal      int
+	}{
+		{
+			name:               "first page by handle",
+			path:               "/api/buckets/A/following?sort=handle&limit=2",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"11", "12"},
+			expectedTotal:      3,
+		},
+		{
+			name:               "second page by account id",
+			path:               "/api/buckets/a/following?sort=id&offset=2&limit=2",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"12"},
+			expectedTotal:      3,
+		},
+		{
+			name:               "protected accounts only",
+			path:               "/api/buckets/A/following?filter=protected",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"12"},
+			expectedTotal:      1,
+		},
+		{
+			name:               "invalid filter",
+			path:               "/api/buckets/A/following?filter=famous",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "unknown bucket",
+			path:               "/api/buckets/A/strangers",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "invalid sort mode",
+			path:               "/api/buckets/A/following?sort=random",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "negative offset",
+			path:               "/api/buckets/A/following?offset=-1",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			router, err := server.NewRouter(server.RouterConfig{
+				Store: comparisonStoreStub{snapshot: server.ComparisonSnapshot{ComparisonData: comparisonData}},
+			})
+			if err != nil {
+				t.Fatalf("NewRouter returned error: %v", err)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d (%s)", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var page matrix.RecordPage
+			if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if page.Total != testCase.expectedTotal {
+				t.Fatalf("expected total %d, got %d", testCase.expectedTotal, page.Total)
+			}
+			if len(page.Records) != len(testCase.expectedIDs) {
+				t.Fatalf("expected %d records, got %d", len(testCase.expectedIDs), len(page.Records))
+			}
+			for index, record := range page.Records {
+				if record.AccountID != testCase.expectedIDs[index] {
+					t.Fatalf("record %d = %s, want %s", index, record.AccountID, testCase.expectedIDs[index])
+				}
+			}
+		})
+	}
+}
+
+func TestServeBucketReusesComparison(t *testing.T) {
+	comparisonData := &server.ComparisonData{
+		AccountSetsA: matrix.AccountSets{
+			Following: map[string]matrix.AccountRecord{
+				"10": {AccountID: "10", UserName: "zulu"},
+				"11": {AccountID: "11", UserName: "alpha"},
+			},
+		},
+	}
+	service := &countingComparisonService{}
+	router, err := server.NewRouter(server.RouterConfig{
+		Service: service,
+		Store:   comparisonStoreStub{snapshot: server.ComparisonSnapshot{ComparisonData: comparisonData}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	requests := []struct {
+		path           string
+		expectedBuilds int32
+	}{
+		{path: "/api/buckets/A/following?sort=handle&limit=1", expectedBuilds: 1},
+		{path: "/api/buckets/A/following?sort=handle&offset=1&limit=1", expectedBuilds: 1},
+		{path: "/api/buckets/B/followers?sort=handle", expectedBuilds: 1},
+		{path: "/api/buckets/A/following?sort=id&limit=1", expectedBuilds: 2},
+		{path: "/api/buckets/A/following?sort=handle&limit=1", expectedBuilds: 2},
+	}
+	for _, request := range requests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, request.path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d (%s)", request.path, recorder.Code, recorder.Body.String())
+		}
+		if builds := service.builds.Load(); builds != request.expectedBuilds {
+			t.Fatalf("%s: expected %d comparison builds, got %d", request.path, request.expectedBuilds, builds)
+		}
+	}
+}
+
+func TestServeAccountDetail(t *testing.T) {
+	comparisonData := &server.ComparisonData{
+		AccountSetsA:     matrix.AccountSets{Following: map[string]matrix.AccountRecord{"10": {AccountID: "10", UserName: "target"}}},
+		AccountSetsB:     matrix.AccountSets{Blocked: map[string]bool{"10": true}},
+		OwnerA:           matrix.OwnerIdentity{AccountID: "1", UserName: "owner_a"},
+		OwnerB:           matrix.OwnerIdentity{AccountID: "2", UserName: "owner_b"},
+		ResolutionErrors: map[string]error{"10": errors.New("lookup failed")},
+	}
+
+	testCases := []struct {
+		name               string
+		path               string
+		snapshot           server.ComparisonSnapshot
+		expectedStatusCode int
+	}{
+		{name: "by handle", path: "/api/accounts/@target", snapshot: server.ComparisonSnapshot{ComparisonData: comparisonData}, expectedStatusCode: http.StatusOK},
+		{name: "by identifier", path: "/api/accounts/10", snapshot: server.ComparisonSnapshot{ComparisonData: comparisonData}, expectedStatusCode: http.StatusOK},
+		{name: "unknown account", path: "/api/accounts/@missing", snapshot: server.ComparisonSnapshot{ComparisonData: comparisonData}, expectedStatusCode: http.StatusNotFound},
+		{name: "comparison unavailable", path: "/api/accounts/10", expectedStatusCode: http.StatusConflict},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			router, err := server.NewRouter(server.RouterConfig{Store: comparisonStoreStub{snapshot: testCase.snapshot}})
+			if err != nil {
+				t.Fatalf("NewRouter returned error: %v", err)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d (%s)", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var detail matrix.AccountDetail
+			if err := json.Unmarshal(recorder.Body.Bytes(), &detail); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if detail.Record.AccountID != "10" || detail.ResolverStatus != matrix.ResolverStatusFailed || detail.ResolverError != "lookup failed" {
+				t.Fatalf("unexpected detail: %+v", detail)
+			}
+			if len(detail.Relationships) != 2 || !detail.Relationships[0].Follows || !detail.Relationships[1].Blocked {
+				t.Fatalf("unexpected relationships: %+v", detail.Relationships)
+			}
+		})
+	}
+}
+
+func TestServeConsensusRanksTeamAndUploads(t *testing.T) {
+	comparisonData := &server.ComparisonData{
+		AccountSetsA: matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": {AccountID: "100", UserName: "shared"}}},
+		AccountSetsB: matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": {AccountID: "100"}, "200": {AccountID: "200", UserName: "single"}}},
+		OwnerA:       matrix.OwnerIdentity{AccountID: "1", UserName: "owner_a"},
+		OwnerB:       matrix.OwnerIdentity{AccountID: "2", UserName: "owner_b"},
+	}
+	teamArchives := []matrix.TeamArchive{
+		{
+			Owner:       matrix.OwnerIdentity{AccountID: "3", UserName: "teammate"},
+			AccountSets: matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": {AccountID: "100"}, "200": {AccountID: "200"}}},
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		path               string
+		snapshot           server.ComparisonSnapshot
+		expectedStatusCode int
+		expectedIDs        []string
+		expectedMembers    int
+	}{
+		{
+			name:               "ranks across team and uploaded archives",
+			path:               "/api/consensus?min-followed-by=2",
+			snapshot:           server.ComparisonSnapshot{ComparisonData: comparisonData},
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"100", "200"},
+			expectedMembers:    3,
+		},
+		{
+			name:               "not followed by an uploaded owner",
+			path:               "/api/consensus?min-followed-by=2&not-followed-by=@owner_a",
+			snapshot:           server.ComparisonSnapshot{ComparisonData: comparisonData},
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"200"},
+			expectedMembers:    3,
+		},
+		{
+			name:               "team archives alone",
+			path:               "/api/consensus",
+			expectedStatusCode: http.StatusOK,
+			expectedIDs:        []string{"100", "200"},
+			expectedMembers:    1,
+		},
+		{
+			name:               "unknown member",
+			path:               "/api/consensus?not-followed-by=stranger",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "invalid threshold",
+			path:               "/api/consensus?min-followed-by=many",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			router, err := server.NewRouter(server.RouterConfig{
+				Store:        comparisonStoreStub{snapshot: testCase.snapshot},
+				TeamArchives: teamArchives,
+			})
+			if err != nil {
+				t.Fatalf("NewRouter returned error: %v", err)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.path, nil))
+			if recorder.Code != testCase.expectedStatusCode {
+				t.Fatalf("expected status %d, got %d (%s)", testCase.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var result matrix.ConsensusResult
+			if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(result.Members) != testCase.expectedMembers {
+				t.Fatalf("expected %d members, got %v", testCase.expectedMembers, result.Members)
+			}
+			if len(result.Entries) != len(testCase.expectedIDs) {
+				t.Fatalf("expected %d entries, got %+v", len(testCase.expectedIDs), result.Entries)
+			}
+			for index, entry := range result.Entries {
+				if entry.Record.AccountID != testCase.expectedIDs[index] {
+					t.Fatalf("entry %d = %s, want %s", index, entry.Record.AccountID, testCase.expectedIDs[index])
+				}
+			}
+		})
+	}
+}
+
+func TestServeConsensusPageRendersFollowIntents(t *testing.T) {
+	router, err := server.NewRouter(server.RouterConfig{
+		TeamArchives: []matrix.TeamArchive{{
+			Owner:       matrix.OwnerIdentity{AccountID: "3", UserName: "teammate"},
+			AccountSets: matrix.AccountSets{Following: map[string]matrix.AccountRecord{"100": {AccountID: "100", UserName: "shared"}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/consensus", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, snippet := range []string{"https://twitter.com/intent/follow?screen_name=shared", `action="/consensus"`, "Followed by 1"} {
+		if !strings.Contains(body, snippet) {
+			t.Fatalf("expected consensus page to contain %q", snippet)
+		}
 	}
 }
 
@@ -266,8 +1172,63 @@ type uploadResponse struct {
 	ComparisonReady bool                   `json:"comparisonReady"`
 }
 
-type uploadErrorResponse struct {
-	Error string `json:"error"`
+type jobStatusResponse struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	State    string `json:"state"`
+	Error    string `json:"error"`
+	Progress struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+	} `json:"progress"`
+	Result json.RawMessage `json:"result"`
+}
+
+type jobEnvelope struct {
+	Job jobStatusResponse `json:"job"`
+}
+
+// uploadArchive posts archivePath and returns its upload job once it finished.
+func uploadArchive(t *testing.T, router http.Handler, archivePath string) jobStatusResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newUploadRequest(t, archivePath))
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+	}
+	var envelope jobEnvelope
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode upload response: %v", err)
+	}
+	if envelope.Job.Kind != "upload" {
+		t.Fatalf("expected an upload job, got %+v", envelope.Job)
+	}
+	return waitForJob(t, router, envelope.Job.ID)
+}
+
+// waitForJob polls the job route until the job leaves the running state.
+func waitForJob(t *testing.T, router http.Handler, jobID string) jobStatusResponse {
+	t.Helper()
+	const (
+		jobPollPeriod = 5 * time.Millisecond
+		jobDeadline   = 5 * time.Second
+	)
+	var job jobStatusResponse
+	for deadline := time.Now().Add(jobDeadline); time.Now().Before(deadline); time.Sleep(jobPollPeriod) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/jobs/"+jobID, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d for job %s, got %d", http.StatusOK, jobID, recorder.Code)
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode job: %v", err)
+		}
+		if job.State != "running" {
+			return job
+		}
+	}
+	t.Fatalf("job %s did not finish, last status %+v", jobID, job)
+	return job
 }
 
 func newUploadRequest(t *testing.T, archivePath string) *http.Request {
@@ -0,0 +1,71 @@
+package fakex
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// ChromeEnvironmentVariable makes a test binary that calls RunChromeIfRequested act as headless Chrome.
+	ChromeEnvironmentVariable = "FAKEX_CHROME"
+
+	chromeFlagPrefix      = "--"
+	chromeRequestTimeout  = 30 * time.Second
+	chromeExitFailure     = 1
+	chromeErrMissingURL   = "fake chrome: no URL argument"
+	chromeErrFetchFormat  = "fake chrome: %v\n"
+	chromeErrExecutable   = "locate test binary: %v"
+	chromeEnvironmentFlag = "1"
+)
+
+// RunChromeIfRequested turns the process into a stand-in for `chrome --headless --dump-dom <url>` when
+// ChromeEnvironmentVariable is set: it fetches the last non-flag argument, prints the body whatever the status, and
+// exits. Call it first in TestMain so that ChromeBinary can hand the test binary to Chrome-based fetchers. The page
+// is not rendered, so scripts do not run.
+func RunChromeIfRequested() {
+	if os.Getenv(ChromeEnvironmentVariable) == "" {
+		return
+	}
+	if err := dumpDOM(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, chromeErrFetchFormat, err)
+		os.Exit(chromeExitFailure)
+	}
+	os.Exit(0)
+}
+
+// ChromeBinary returns the running test binary for use as a Chrome binary path and sets ChromeEnvironmentVariable
+// for the rest of the test, so the test must not run in parallel. The package's TestMain must call
+// RunChromeIfRequested.
+func ChromeBinary(tb testing.TB) string {
+	tb.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		tb.Fatalf(chromeErrExecutable, err)
+	}
+	tb.Setenv(ChromeEnvironmentVariable, chromeEnvironmentFlag)
+	return executable
+}
+
+func dumpDOM(arguments []string, output io.Writer) error {
+	pageURL := ""
+	for _, argument := range arguments {
+		if !strings.HasPrefix(argument, chromeFlagPrefix) {
+			pageURL = argument
+		}
+	}
+	if pageURL == "" {
+		return fmt.Errorf(chromeErrMissingURL)
+	}
+	response, err := (&http.Client{Timeout: chromeRequestTimeout}).Get(pageURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(output, response.Body)
+	return err
+}
@@ -0,0 +1,341 @@
+// Package fakex serves a fixture table of X accounts from an httptest server so that resolver backends and the
+// commands built on them can be tested end to end without network access. One server answers intent pages, profile
+// pages, /i/user/<id> redirects and the X API v2 users lookups; fixtures can be suspended, missing, protected,
+// rate limited or slow.
+package fakex
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a fixture account.
+type Status string
+
+const (
+	// StatusActive serves the account normally.
+	StatusActive Status = ""
+	// StatusProtected serves the account with protected posts; its handle still resolves.
+	StatusProtected Status = "protected"
+	// StatusSuspended serves the suspension page and redirect.
+	StatusSuspended Status = "suspended"
+	// StatusNotFound serves the page X shows for deactivated or deleted accounts.
+	StatusNotFound Status = "not-found"
+	// StatusLoginWall serves a login prompt instead of the intent and profile pages, as X does for some protected or
+	// age-restricted accounts when logged out; redirects and the API still answer.
+	StatusLoginWall Status = "login-wall"
+)
+
+const (
+	// IntentPath serves intent pages for ?user_id=<id>.
+	IntentPath = "/intent/user"
+	// RedirectPathPrefix precedes the account identifier of profile redirects.
+	RedirectPathPrefix = "/i/user/"
+	// SuspendedPath is where redirects of suspended accounts lead.
+	SuspendedPath = "/account/suspended"
+	// APIUsersPath serves the users lookup by identifier.
+	APIUsersPath = "/2/users"
+	// APIUsernamesPath serves the users lookup by handle.
+	APIUsernamesPath = "/2/users/by"
+	// BearerToken is the only token the API paths accept.
+	BearerToken = "fakex-bearer-token"
+
+	intentQueryUserID     = "user_id"
+	apiQueryIDs           = "ids"
+	apiQueryUsernames     = "usernames"
+	apiListSeparator      = ","
+	authorizationHeader   = "Authorization"
+	bearerPrefix          = "Bearer "
+	retryAfterHeader      = "Retry-After"
+	contentTypeHeader     = "Content-Type"
+	contentTypeHTML       = "text/html; charset=utf-8"
+	contentTypeJSON       = "application/json"
+	profileURLFormat      = "https://x.com/%s"
+	apiProblemNotFound    = "https://api.twitter.com/2/problems/resource-not-found"
+	apiSuspendedDetailFmt = "User has been suspended: [%s]."
+	apiNotFoundDetailFmt  = "Could not find user with %s: [%s]."
+
+	profilePageFormat = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>%[1]s (@%[2]s) / X</title>
+<script type="application/ld+json">{"@context":"http://schema.org","@type":"ProfilePage","mainEntity":{"@type":"Person","additionalName":%[4]s,"givenName":%[5]s,"identifier":%[6]s,"url":%[7]s}}</script>
+</head><body><a href="%[3]s">@%[2]s</a>%[8]s<a href="/i/flow/login">Log in</a></body></html>`
+	protectedNotice = `<p>These posts are protected</p>`
+	suspendedPage   = `<!DOCTYPE html><html><head><title>X</title></head><body><a href="https://x.com/tos">Terms</a><p>Account suspended</p></body></html>`
+	notFoundPage    = `<!DOCTYPE html><html><head><title>X</title></head><body><a href="https://x.com/home">Home</a><p>This account doesn’t exist</p></body></html>`
+	loginWallPage   = `<!DOCTYPE html><html><head><title>Log in to X / X</title></head><body><a href="/i/flow/login">Log in</a><a href="/i/flow/signup">Sign up</a></body></html>`
+	rateLimitedPage = `<!DOCTYPE html><html><head><title>X</title></head><body><p>Rate limit exceeded</p></body></html>`
+)
+
+// Account is one row of the fixture table.
+type Account struct {
+	ID          string
+	UserName    string
+	DisplayName string
+	Status      Status
+	// RateLimited is how many requests naming the account are answered with 429 before it is served.
+	RateLimited int
+	// RetryAfter is sent with those 429 responses in whole seconds; zero asks for an immediate retry.
+	RetryAfter time.Duration
+	// Delay holds every response naming the account back by this long, or until the request is cancelled.
+	Delay time.Duration
+}
+
+// Server is a fake X web and API host.
+type Server struct {
+	*httptest.Server
+
+	accountsByID       map[string]Account
+	accountIDsByHandle map[string]string
+
+	mutex       sync.Mutex
+	rateLimited map[string]int
+	requests    []string
+}
+
+// NewServer starts a server answering for accounts. Close it when done.
+func NewServer(accounts []Account) *Server {
+	server := &Server{
+		accountsByID:       make(map[string]Account, len(accounts)),
+		accountIDsByHandle: make(map[string]string, len(accounts)),
+		rateLimited:        make(map[string]int),
+	}
+	for _, account := range accounts {
+		server.accountsByID[account.ID] = account
+		if account.UserName != "" {
+			server.accountIDsByHandle[strings.ToLower(account.UserName)] = account.ID
+		}
+		if account.RateLimited > 0 {
+			server.rateLimited[account.ID] = account.RateLimited
+		}
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// IntentURL returns the intent page URL of accountID on this server.
+func (server *Server) IntentURL(accountID string) string {
+	return server.URL + IntentPath + "?" + intentQueryUserID + "=" + accountID
+}
+
+// Requests lists the request URIs received so far, in arrival order.
+func (server *Server) Requests() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.requests...)
+}
+
+// RequestCount reports how many requests had a path starting with pathPrefix.
+func (server *Server) RequestCount(pathPrefix string) int {
+	count := 0
+	for _, requestURI := range server.Requests() {
+		if strings.HasPrefix(requestURI, pathPrefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (server *Server) serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	server.requests = append(server.requests, request.URL.RequestURI())
+	server.mutex.Unlock()
+
+	switch path := request.URL.Path; {
+	case path == IntentPath:
+		server.serveIntentPage(writer, request)
+	case strings.HasPrefix(path, RedirectPathPrefix):
+		server.serveRedirect(writer, request, strings.TrimPrefix(path, RedirectPathPrefix))
+	case path == SuspendedPath:
+		writeHTML(writer, http.StatusOK, suspendedPage)
+	case path == APIUsersPath:
+		server.serveAPIUsers(writer, request, apiQueryIDs, server.accountsFromIDs)
+	case path == APIUsernamesPath:
+		server.serveAPIUsers(writer, request, apiQueryUsernames, server.accountsFromHandles)
+	default:
+		server.serveProfilePage(writer, request, strings.Trim(path, "/"))
+	}
+}
+
+func (server *Server) serveIntentPage(writer http.ResponseWriter, request *http.Request) {
+	account, found := server.accountsByID[request.URL.Query().Get(intentQueryUserID)]
+	if !found {
+		writeHTML(writer, http.StatusOK, notFoundPage)
+		return
+	}
+	if !server.hold(writer, request, account) {
+		return
+	}
+	writeHTML(writer, http.StatusOK, account.page())
+}
+
+func (server *Server) serveProfilePage(writer http.ResponseWriter, request *http.Request, handle string) {
+	account, found := server.accountsByID[server.accountIDsByHandle[strings.ToLower(handle)]]
+	if !found {
+		writeHTML(writer, http.StatusNotFound, notFoundPage)
+		return
+	}
+	if !server.hold(writer, request, account) {
+		return
+	}
+	writeHTML(writer, http.StatusOK, account.page())
+}
+
+func (server *Server) serveRedirect(writer http.ResponseWriter, request *http.Request, accountID string) {
+	account, found := server.accountsByID[accountID]
+	if !found {
+		http.NotFound(writer, request)
+		return
+	}
+	if !server.hold(writer, request, account) {
+		return
+	}
+	switch account.Status {
+	case StatusSuspended:
+		http.Redirect(writer, request, SuspendedPath, http.StatusFound)
+	case StatusNotFound:
+		http.NotFound(writer, request)
+	default:
+		http.Redirect(writer, request, "/"+account.UserName, http.StatusFound)
+	}
+}
+
+func (server *Server) serveAPIUsers(writer http.ResponseWriter, request *http.Request, queryKey string, lookup func([]string) ([]Account, []apiProblem)) {
+	if request.Header.Get(authorizationHeader) != bearerPrefix+BearerToken {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	accounts, problems := lookup(strings.Split(request.URL.Query().Get(queryKey), apiListSeparator))
+	for _, account := range accounts {
+		if !server.hold(writer, request, account) {
+			return
+		}
+	}
+
+	response := apiUsersResponse{Data: []apiUser{}, Errors: problems}
+	for _, account := range accounts {
+		switch account.Status {
+		case StatusSuspended:
+			response.Errors = append(response.Errors, apiProblem{Value: account.ID, ResourceID: account.ID, Title: "Forbidden", Detail: fmt.Sprintf(apiSuspendedDetailFmt, account.ID), Type: apiProblemNotFound})
+		case StatusNotFound:
+			response.Errors = append(response.Errors, account.notFoundProblem(apiQueryIDs, account.ID))
+		default:
+			response.Data = append(response.Data, apiUser{ID: account.ID, Name: account.DisplayName, Username: account.UserName, Protected: account.Status == StatusProtected})
+		}
+	}
+	writer.Header().Set(contentTypeHeader, contentTypeJSON)
+	_ = json.NewEncoder(writer).Encode(response)
+}
+
+func (server *Server) accountsFromIDs(accountIDs []string) ([]Account, []apiProblem) {
+	var accounts []Account
+	var problems []apiProblem
+	for _, accountID := range accountIDs {
+		account, found := server.accountsByID[accountID]
+		if !found {
+			problems = append(problems, Account{}.notFoundProblem(apiQueryIDs, accountID))
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, problems
+}
+
+func (server *Server) accountsFromHandles(handles []string) ([]Account, []apiProblem) {
+	var accounts []Account
+	var problems []apiProblem
+	for _, handle := range handles {
+		account, found := server.accountsByID[server.accountIDsByHandle[strings.ToLower(handle)]]
+		if !found {
+			problems = append(problems, Account{}.notFoundProblem(apiQueryUsernames, handle))
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, problems
+}
+
+// hold applies the rate limit and delay of account and reports whether the request should still be answered.
+func (server *Server) hold(writer http.ResponseWriter, request *http.Request, account Account) bool {
+	server.mutex.Lock()
+	limited := server.rateLimited[account.ID] > 0
+	if limited {
+		server.rateLimited[account.ID]--
+	}
+	server.mutex.Unlock()
+	if limited {
+		writer.Header().Set(retryAfterHeader, strconv.Itoa(int(account.RetryAfter/time.Second)))
+		writeHTML(writer, http.StatusTooManyRequests, rateLimitedPage)
+		return false
+	}
+	if account.Delay > 0 {
+		timer := time.NewTimer(account.Delay)
+		defer timer.Stop()
+		select {
+		case <-request.Context().Done():
+			return false
+		case <-timer.C:
+		}
+	}
+	return true
+}
+
+// page renders what X shows for the account on its intent and profile pages.
+func (account Account) page() string {
+	switch account.Status {
+	case StatusSuspended:
+		return suspendedPage
+	case StatusNotFound:
+		return notFoundPage
+	case StatusLoginWall:
+		return loginWallPage
+	}
+	notice := ""
+	if account.Status == StatusProtected {
+		notice = protectedNotice
+	}
+	profileURL := fmt.Sprintf(profileURLFormat, account.UserName)
+	return fmt.Sprintf(profilePageFormat, html.EscapeString(account.DisplayName), account.UserName, profileURL,
+		jsonString(account.UserName), jsonString(account.DisplayName), jsonString(account.ID), jsonString(profileURL), notice)
+}
+
+func (account Account) notFoundProblem(queryKey string, value string) apiProblem {
+	return apiProblem{Value: value, ResourceID: account.ID, Title: "Not Found Error", Detail: fmt.Sprintf(apiNotFoundDetailFmt, queryKey, value), Type: apiProblemNotFound}
+}
+
+type apiUsersResponse struct {
+	Data   []apiUser    `json:"data"`
+	Errors []apiProblem `json:"errors,omitempty"`
+}
+
+type apiUser struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Protected bool   `json:"protected"`
+}
+
+type apiProblem struct {
+	Value      string `json:"value"`
+	ResourceID string `json:"resource_id,omitempty"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Type       string `json:"type"`
+}
+
+func writeHTML(writer http.ResponseWriter, status int, body string) {
+	writer.Header().Set(contentTypeHeader, contentTypeHTML)
+	writer.WriteHeader(status)
+	_, _ = writer.Write([]byte(body))
+}
+
+func jsonString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -1,15 +1,21 @@
-ID=156576788
-CHROME_BIN="/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"
-for url in "https://x.com/intent/user?user_id=$ID" "https://x.com/i/user/$ID"; do
-  handle=$(
-    "$CHROME_BIN" --headless=new --disable-gpu --use-gl=swiftshader --enable-unsafe-swiftshader \
-      --hide-scrollbars --no-first-run --no-default-browser-check --log-level=3 --silent --disable-logging \
-      --virtual-time-budget=15000 --dump-dom "$url" 2>/dev/null \
-    | tr "'" '"' \
-    | grep -Eo 'https://(x|twitter)\.com/[A-Za-z0-9_]{1,15}' \
-    | sed -E 's#https?://(x|twitter)\.com/##' \
-    | grep -Ev '^(home|tos|privacy|explore|notifications|settings|i|intent|login|signup|share|account|compose|messages|search)$' \
-    | head -n1
-  )
-  [ -n "$handle" ] && echo "$handle (retrieved from ${url})" && break
-done
+#!/usr/bin/env bash
+# Resolves account IDs through cmd/resolve with the chrome backend, as a quick check that headless Chrome still reads
+# handles from X. CHROME_BIN selects the browser (the usual install locations are searched when it is unset), and the
+# IDs default to a single known account. Extra cmd/resolve flags go in RESOLVE_FLAGS, e.g. RESOLVE_FLAGS="--backends
+# redirect,chrome".
+set -euo pipefail
+
+cd "$(dirname "$0")/.."
+
+if [ "$#" -eq 0 ]; then
+  set -- 156576788
+fi
+
+flags=(--backends chrome)
+if [ -n "${CHROME_BIN:-}" ]; then
+  flags+=(--chrome-binary "$CHROME_BIN")
+fi
+# shellcheck disable=SC2206
+flags+=(${RESOLVE_FLAGS:-})
+
+go run ./cmd/resolve "${flags[@]}" "$@"